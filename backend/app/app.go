@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	gosync "sync"
 
 	"fuknotion/backend/internal/auth"
 	"fuknotion/backend/internal/config"
@@ -12,7 +13,10 @@ import (
 	"fuknotion/backend/internal/filesystem"
 	"fuknotion/backend/internal/models"
 	"fuknotion/backend/internal/note"
+	"fuknotion/backend/internal/peer"
+	"fuknotion/backend/internal/workspace"
 
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 	"golang.org/x/oauth2"
 )
 
@@ -21,12 +25,15 @@ type App struct {
 	ctx            context.Context
 	fs             *filesystem.FileSystem
 	config         *config.Config
-	db             *database.Database
 	userDB         *database.Database
-	noteService    *note.Service
+	workspaces     *workspace.Manager
 	oauthService   *auth.OAuthService
 	storage        *auth.SecureStorage
 	sessionManager *auth.SessionManager
+
+	peerMu         gosync.Mutex // guards the LAN sync server
+	peerServer     *peer.Server
+	peerAdvertiser *peer.Advertiser
 }
 
 // NewApp creates a new App application struct
@@ -62,19 +69,6 @@ func (a *App) Startup(ctx context.Context) {
 	}
 	a.userDB = userDB
 
-	// Initialize workspace database
-	// For now, use a default workspace path. In Phase 07, we'll add workspace management
-	workspacePath := filepath.Join(appDataPath, "workspaces", "default")
-	db, err := database.InitWorkspaceDB(workspacePath)
-	if err != nil {
-		fmt.Printf("Failed to initialize database: %v\n", err)
-		return
-	}
-	a.db = db
-
-	// Initialize note service
-	a.noteService = note.NewService(db, fs)
-
 	// Load configuration
 	configPath := filepath.Join(appDataPath, "config.json")
 	cfg, err := config.LoadConfig(configPath)
@@ -84,6 +78,25 @@ func (a *App) Startup(ctx context.Context) {
 	}
 	a.config = cfg
 
+	// Open the last used workspace (or the default one on first launch)
+	a.workspaces = workspace.NewManager(appDataPath, userDB)
+	a.workspaces.OnExternalChange(
+		func(change *note.FileChange) {
+			runtime.EventsEmit(ctx, "note:external-change", change)
+		},
+		func(path string, err error) {
+			fmt.Printf("Failed to sync external change to %s: %v\n", path, err)
+			runtime.EventsEmit(ctx, "note:external-error", map[string]string{"path": path, "error": err.Error()})
+		},
+	)
+	ws, err := a.workspaces.Open(cfg.ActiveWorkspace)
+	if err != nil {
+		fmt.Printf("Failed to open workspace: %v\n", err)
+		return
+	}
+	cfg.ActiveWorkspace = ws.ID
+	a.purgeExpiredTrash()
+
 	// Initialize auth services
 	clientID := os.Getenv("GOOGLE_CLIENT_ID")
 	clientSecret := os.Getenv("GOOGLE_CLIENT_SECRET")
@@ -123,9 +136,16 @@ func (a *App) Shutdown(ctx context.Context) {
 		a.sessionManager.Stop()
 	}
 
+	// Stop LAN sync before the workspace it serves is closed
+	a.peerMu.Lock()
+	if err := a.stopPeerSync(); err != nil {
+		fmt.Printf("Failed to stop LAN sync: %v\n", err)
+	}
+	a.peerMu.Unlock()
+
 	// Close databases
-	if a.db != nil {
-		if err := a.db.Close(); err != nil {
+	if a.workspaces != nil {
+		if err := a.workspaces.Close(); err != nil {
 			fmt.Printf("Failed to close workspace database: %v\n", err)
 		}
 	}
@@ -219,9 +239,10 @@ func (a *App) GetConfig() map[string]interface{} {
 	}
 
 	return map[string]interface{}{
-		"theme":            a.config.Theme,
-		"autoSave":         a.config.AutoSave,
-		"autoSaveInterval": a.config.AutoSaveInterval,
+		"theme":              a.config.Theme,
+		"autoSave":           a.config.AutoSave,
+		"autoSaveInterval":   a.config.AutoSaveInterval,
+		"trashRetentionDays": a.config.TrashRetention,
 	}
 }
 
@@ -244,6 +265,10 @@ func (a *App) UpdateConfig(key string, value interface{}) error {
 		if interval, ok := value.(float64); ok {
 			a.config.AutoSaveInterval = int(interval)
 		}
+	case "trashRetentionDays":
+		if days, ok := value.(float64); ok && days >= 0 {
+			a.config.TrashRetention = int(days)
+		}
 	default:
 		return fmt.Errorf("unknown config key: %s", key)
 	}
@@ -253,44 +278,84 @@ func (a *App) UpdateConfig(key string, value interface{}) error {
 	return config.SaveConfig(configPath, a.config)
 }
 
+// session returns the active workspace session. The returned release
+// function must be called when the caller is done with it.
+func (a *App) session() (*workspace.Session, func(), error) {
+	if a.workspaces == nil {
+		return nil, nil, fmt.Errorf("workspace not initialized")
+	}
+	return a.workspaces.Acquire()
+}
+
 // CreateNote creates a new note
 func (a *App) CreateNote(title, content, folderID string) (*models.Note, error) {
-	if a.noteService == nil {
-		return nil, fmt.Errorf("note service not initialized")
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
 	}
-	return a.noteService.CreateNote(title, content, folderID)
+	defer release()
+	return ws.Notes.CreateNote(title, content, folderID)
 }
 
 // GetNote retrieves a note by ID
 func (a *App) GetNote(id string) (*models.Note, error) {
-	if a.noteService == nil {
-		return nil, fmt.Errorf("note service not initialized")
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
 	}
-	return a.noteService.GetNote(id)
+	defer release()
+	return ws.Notes.GetNote(id)
 }
 
-// UpdateNote updates an existing note
-func (a *App) UpdateNote(id, title, content string) error {
-	if a.noteService == nil {
-		return fmt.Errorf("note service not initialized")
+// UpdateNote updates an existing note, optionally rewriting links to its old title
+func (a *App) UpdateNote(id, title, content string, rewriteLinks bool) error {
+	ws, release, err := a.session()
+	if err != nil {
+		return err
 	}
-	return a.noteService.UpdateNote(id, title, content)
+	defer release()
+	return ws.Notes.UpdateNote(id, title, content, rewriteLinks)
 }
 
 // DeleteNote deletes a note
 func (a *App) DeleteNote(id string) error {
-	if a.noteService == nil {
-		return fmt.Errorf("note service not initialized")
+	ws, release, err := a.session()
+	if err != nil {
+		return err
 	}
-	return a.noteService.DeleteNote(id)
+	defer release()
+	return ws.Notes.DeleteNote(id)
 }
 
-// ListNotes lists all notes
-func (a *App) ListNotes() ([]*models.Note, error) {
-	if a.noteService == nil {
-		return nil, fmt.Errorf("note service not initialized")
+// ListNotes lists all notes, or only those carrying tag when it is not empty
+func (a *App) ListNotes(tag string) ([]*models.Note, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
 	}
-	return a.noteService.ListNotes()
+	defer release()
+	return ws.Notes.ListNotes(tag)
+}
+
+// ListNotePage lists one page of notes without reading their files; pass the
+// returned NextCursor back in opts.Cursor for the next page
+func (a *App) ListNotePage(opts note.ListOptions) (*note.NotePage, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.ListNotePage(opts)
+}
+
+// ReorderNote moves a note to position within its folder's manual order
+func (a *App) ReorderNote(id string, position int) error {
+	ws, release, err := a.session()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return ws.Notes.ReorderNote(id, position)
 }
 
 // SearchResult represents a search result for frontend
@@ -300,18 +365,48 @@ type SearchResult struct {
 	Rank    float64      `json:"rank"`
 }
 
-// SearchNotes searches for notes using full-text search
+// SearchNotes searches notes; the query supports phrases, prefixes, -exclusions
+// and qualifiers such as tag:, folder:, is:favorite, created: and title:
 func (a *App) SearchNotes(query string) ([]*SearchResult, error) {
-	if a.noteService == nil {
-		return nil, fmt.Errorf("note service not initialized")
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	results, err := ws.Notes.SearchNotes(query)
+	if err != nil {
+		return nil, err
 	}
 
-	results, err := a.noteService.SearchNotes(query)
+	return toSearchResults(results), nil
+}
+
+// SearchPage is one page of search results for frontend
+type SearchPage struct {
+	Results    []*SearchResult `json:"results"`
+	NextCursor string          `json:"nextCursor,omitempty"`
+}
+
+// SearchNotesPage returns one page of search results; pass the returned
+// NextCursor back as cursor for the next page
+func (a *App) SearchNotesPage(query string, limit int, cursor string) (*SearchPage, error) {
+	ws, release, err := a.session()
 	if err != nil {
 		return nil, err
 	}
+	defer release()
 
-	// Convert to app-level SearchResult
+	page, err := ws.Notes.SearchNotesPage(query, limit, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SearchPage{Results: toSearchResults(page.Results), NextCursor: page.NextCursor}, nil
+}
+
+// toSearchResults converts note search results to app-level SearchResults
+func toSearchResults(results []*note.SearchResult) []*SearchResult {
 	appResults := make([]*SearchResult, len(results))
 	for i, r := range results {
 		appResults[i] = &SearchResult{
@@ -320,6 +415,48 @@ func (a *App) SearchNotes(query string) ([]*SearchResult, error) {
 			Rank:    r.Rank,
 		}
 	}
+	return appResults
+}
 
-	return appResults, nil
+// ReindexWorkspace rebuilds the active workspace's note index from the
+// markdown files on disk and reports files that could not be parsed
+func (a *App) ReindexWorkspace() (*note.ReindexReport, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.Reindex()
+}
+
+// RebuildSearchIndex rebuilds the active workspace's full-text search index
+// from the note files
+func (a *App) RebuildSearchIndex() (*note.SearchIndexReport, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.RebuildSearchIndex()
+}
+
+// OptimizeSearchIndex compacts the active workspace's full-text search index
+func (a *App) OptimizeSearchIndex() error {
+	ws, release, err := a.session()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return ws.Notes.OptimizeSearchIndex()
+}
+
+// CheckSearchIndex checks the active workspace's full-text search index for
+// corruption and rows out of step with the notes
+func (a *App) CheckSearchIndex() (*note.SearchIndexCheck, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.CheckSearchIndex()
 }
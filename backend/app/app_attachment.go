@@ -0,0 +1,58 @@
+package app
+
+import (
+	"net/http"
+	"strings"
+
+	"fuknotion/backend/internal/attachment"
+)
+
+// SaveAttachment stores a file, such as an image pasted into the editor, and
+// returns it with the URL to embed in the note
+func (a *App) SaveAttachment(name string, data []byte) (*attachment.Attachment, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Attachments.Save(name, data)
+}
+
+// ListNoteAttachments lists the attachments a note embeds
+func (a *App) ListNoteAttachments(noteID string) ([]*attachment.Attachment, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Attachments.ListForNote(noteID)
+}
+
+// CollectAttachments deletes attachments that no note refers to any more
+func (a *App) CollectAttachments() (*attachment.GCReport, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Attachments.CollectGarbage()
+}
+
+// AttachmentHandler serves the active workspace's attachments to the webview.
+// It is a function rather than a method so Wails does not bind it.
+func AttachmentHandler(a *App) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, attachment.URLPrefix) {
+			http.NotFound(w, r)
+			return
+		}
+
+		ws, release, err := a.session()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		defer release()
+		ws.Attachments.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"fuknotion/backend/internal/note"
+)
+
+// ListNoteConflicts lists the notes whose versions a sync could not fully
+// merge, for the user to resolve
+func (a *App) ListNoteConflicts() ([]*note.NoteConflict, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.ListConflicts()
+}
+
+// ResolveNoteConflict marks a note's conflicts as dealt with
+func (a *App) ResolveNoteConflict(noteID string) error {
+	ws, release, err := a.session()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return ws.Notes.ResolveConflict(noteID)
+}
+
+// MergeNoteEdit merges the editor's content, edited from baseContent, with
+// the note as saved now, for when it changed while being edited. Lines both
+// changed are left between conflict markers.
+func (a *App) MergeNoteEdit(id, baseContent, content string) (*note.EditMerge, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.MergeEdit(id, baseContent, content)
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"fmt"
+	"os"
+
+	"fuknotion/backend/internal/export"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// exportFilters are the save dialog's file types for each export format
+var exportFilters = map[export.Format]runtime.FileFilter{
+	export.FormatHTML:        {DisplayName: "HTML (*.html)", Pattern: "*.html"},
+	export.FormatPrintHTML:   {DisplayName: "Printable HTML (*.html)", Pattern: "*.html"},
+	export.FormatMarkdownZip: {DisplayName: "Markdown archive (*.zip)", Pattern: "*.zip"},
+	export.FormatMarkdown:    {DisplayName: "Markdown (*.md)", Pattern: "*.md"},
+}
+
+// Export renders a note, folder or the whole workspace and asks where to save
+// it. It returns the saved path, or "" if the user cancelled the dialog.
+func (a *App) Export(req export.Request) (string, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return "", err
+	}
+	if req.Scope == export.ScopeWorkspace && req.Title == "" {
+		req.Title = ws.Workspace.Name
+	}
+	out, err := ws.Export.Export(req)
+	// Don't hold the workspace open while the dialog waits on the user
+	release()
+	if err != nil {
+		return "", err
+	}
+
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export",
+		DefaultFilename: out.Filename,
+		Filters:         []runtime.FileFilter{exportFilters[req.Format]},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to choose export location: %w", err)
+	}
+	if path == "" {
+		return "", nil
+	}
+
+	if err := os.WriteFile(path, out.Data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write export: %w", err)
+	}
+	return path, nil
+}
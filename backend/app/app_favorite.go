@@ -0,0 +1,44 @@
+package app
+
+import "fuknotion/backend/internal/models"
+
+// SetFavorite marks or unmarks a note as a favorite
+func (a *App) SetFavorite(id string, favorite bool) error {
+	ws, release, err := a.session()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return ws.Notes.SetFavorite(id, favorite)
+}
+
+// ListFavorites lists favorite notes, pinned ones first
+func (a *App) ListFavorites() ([]*models.Note, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.ListFavorites()
+}
+
+// PinNote pins a note to the top of the note list at position among the
+// pinned notes; a negative position adds it last
+func (a *App) PinNote(id string, position int) error {
+	ws, release, err := a.session()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return ws.Notes.PinNote(id, position)
+}
+
+// UnpinNote removes a note from the pinned notes
+func (a *App) UnpinNote(id string) error {
+	ws, release, err := a.session()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return ws.Notes.UnpinNote(id)
+}
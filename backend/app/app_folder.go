@@ -0,0 +1,86 @@
+package app
+
+import (
+	"fuknotion/backend/internal/folder"
+	"fuknotion/backend/internal/models"
+)
+
+// CreateFolder creates a folder under parentID (empty for the workspace root)
+func (a *App) CreateFolder(name, parentID string) (*models.Folder, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Folders.CreateFolder(name, parentID)
+}
+
+// RenameFolder renames a folder
+func (a *App) RenameFolder(id, name string) error {
+	ws, release, err := a.session()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return ws.Folders.RenameFolder(id, name)
+}
+
+// DeleteFolder deletes a folder and its subfolders, moving their notes to the root
+func (a *App) DeleteFolder(id string) error {
+	ws, release, err := a.session()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return ws.Folders.DeleteFolder(id)
+}
+
+// MoveFolder reparents a folder; a negative position appends it
+func (a *App) MoveFolder(id, newParentID string, position int) error {
+	ws, release, err := a.session()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return ws.Folders.MoveFolder(id, newParentID, position)
+}
+
+// ReorderFolder changes a folder's position among its siblings
+func (a *App) ReorderFolder(id string, position int) error {
+	ws, release, err := a.session()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return ws.Folders.ReorderFolder(id, position)
+}
+
+// ListFolders lists all folders
+func (a *App) ListFolders() ([]*models.Folder, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Folders.ListFolders()
+}
+
+// GetFolderTree returns the nested folder hierarchy
+func (a *App) GetFolderTree() ([]*folder.TreeNode, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Folders.GetFolderTree()
+}
+
+// MoveNote moves a note into a folder (empty for the workspace root)
+func (a *App) MoveNote(noteID, folderID string) error {
+	ws, release, err := a.session()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return ws.Notes.MoveNote(noteID, folderID)
+}
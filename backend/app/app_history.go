@@ -0,0 +1,137 @@
+package app
+
+import (
+	"fmt"
+
+	"fuknotion/backend/internal/git"
+	"fuknotion/backend/internal/workspace"
+)
+
+// defaultLogLimit is how many commits ListGitCommits returns by default
+const defaultLogLimit = 50
+
+// GitHistoryStatus describes the active workspace's git repository
+type GitHistoryStatus struct {
+	Enabled bool   `json:"enabled"`
+	Branch  string `json:"branch,omitempty"`
+	Remote  string `json:"remote,omitempty"`
+}
+
+// EnableGitHistory keeps the active workspace in a git repository that
+// commits changes as they are saved
+func (a *App) EnableGitHistory() error {
+	if a.workspaces == nil {
+		return fmt.Errorf("workspace not initialized")
+	}
+
+	var who git.Identity
+	if a.storage != nil {
+		if profile, err := a.storage.LoadUserProfile(); err == nil && profile != nil {
+			who = git.Identity{Name: profile.Name, Email: profile.Email}
+		}
+	}
+	return a.workspaces.EnableHistory(a.ctx, who)
+}
+
+// GetGitHistoryStatus reports whether the active workspace is kept in git,
+// and where it syncs to
+func (a *App) GetGitHistoryStatus() (*GitHistoryStatus, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if ws.History == nil {
+		return &GitHistoryStatus{}, nil
+	}
+	repo := ws.History.Repo()
+	branch, err := repo.Branch(a.ctx)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := repo.Remote(a.ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &GitHistoryStatus{Enabled: true, Branch: branch, Remote: remote}, nil
+}
+
+// SetGitRemote sets the remote the workspace pushes to and pulls from, or
+// removes it when url is empty
+func (a *App) SetGitRemote(url string) error {
+	ws, release, err := a.gitSession()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return ws.History.Repo().SetRemote(a.ctx, url)
+}
+
+// ListGitCommits lists the workspace's commits, newest first, or only those
+// that changed a note when noteID is set
+func (a *App) ListGitCommits(noteID string, limit int) ([]*git.Commit, error) {
+	ws, release, err := a.gitSession()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	path := ""
+	if noteID != "" {
+		n, err := ws.Notes.GetNote(noteID)
+		if err != nil {
+			return nil, err
+		}
+		path = n.FilePath
+	}
+	if limit <= 0 {
+		limit = defaultLogLimit
+	}
+	return ws.History.Repo().Log(a.ctx, path, limit)
+}
+
+// CommitGitHistory commits pending changes without waiting for the next
+// automatic commit
+func (a *App) CommitGitHistory() (string, error) {
+	ws, release, err := a.gitSession()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	return ws.History.Commit(a.ctx)
+}
+
+// PullGitHistory merges the remote's changes into the workspace. Notes the
+// merge changed are listed in the report so the UI can refresh them.
+func (a *App) PullGitHistory() (*git.PullReport, error) {
+	ws, release, err := a.gitSession()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.History.Pull(a.ctx)
+}
+
+// PushGitHistory pulls the remote's changes, then pushes the workspace's
+func (a *App) PushGitHistory() (*git.PullReport, error) {
+	ws, release, err := a.gitSession()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.History.Sync(a.ctx)
+}
+
+// gitSession returns the active session, failing if it is not kept in git
+func (a *App) gitSession() (*workspace.Session, func(), error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, nil, err
+	}
+	if ws.History == nil {
+		release()
+		return nil, nil, fmt.Errorf("git history is not enabled for this workspace")
+	}
+	return ws, release, nil
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"fmt"
+
+	"fuknotion/backend/internal/importer"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// ImportDirectory asks for a Notion export, Obsidian vault or markdown
+// directory and imports it under folderID ("" for the top level). It returns
+// nil if the user cancelled the dialog.
+func (a *App) ImportDirectory(folderID string) (*importer.Report, error) {
+	source, err := runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Import notes from a folder",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to choose import folder: %w", err)
+	}
+	if source == "" {
+		return nil, nil
+	}
+	return a.ImportPath(source, folderID)
+}
+
+// ImportArchive asks for a zip archive, such as a Notion export, and imports
+// it under folderID. It returns nil if the user cancelled the dialog.
+func (a *App) ImportArchive(folderID string) (*importer.Report, error) {
+	source, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:   "Import notes from a zip archive",
+		Filters: []runtime.FileFilter{{DisplayName: "Zip archives (*.zip)", Pattern: "*.zip"}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to choose import archive: %w", err)
+	}
+	if source == "" {
+		return nil, nil
+	}
+	return a.ImportPath(source, folderID)
+}
+
+// ImportPath imports the directory or zip archive at source under folderID
+func (a *App) ImportPath(source, folderID string) (*importer.Report, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Importer.Import(source, folderID)
+}
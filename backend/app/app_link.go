@@ -0,0 +1,33 @@
+package app
+
+import "fuknotion/backend/internal/note"
+
+// GetBacklinks lists links from other notes to a note
+func (a *App) GetBacklinks(noteID string) ([]*note.Link, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.GetBacklinks(noteID)
+}
+
+// GetOutgoingLinks lists the links in a note
+func (a *App) GetOutgoingLinks(noteID string) ([]*note.Link, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.GetOutgoingLinks(noteID)
+}
+
+// FindBrokenLinks lists links that do not resolve to any note
+func (a *App) FindBrokenLinks() ([]*note.Link, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.FindBrokenLinks()
+}
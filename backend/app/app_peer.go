@@ -0,0 +1,270 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"fuknotion/backend/internal/note"
+	"fuknotion/backend/internal/peer"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// discoveryTimeout is how long DiscoverPeers listens for devices
+const discoveryTimeout = 3 * time.Second
+
+// PeerSyncStatus describes whether this device accepts LAN sync
+type PeerSyncStatus struct {
+	Running    bool   `json:"running"`
+	DeviceID   string `json:"deviceId,omitempty"`
+	DeviceName string `json:"deviceName,omitempty"`
+	Port       int    `json:"port,omitempty"`
+}
+
+// StartPeerSync lets paired devices on the local network sync with the
+// active workspace, and announces this device to others looking for it
+func (a *App) StartPeerSync() (*PeerSyncStatus, error) {
+	a.peerMu.Lock()
+	defer a.peerMu.Unlock()
+
+	if a.peerServer != nil {
+		return a.peerStatus(), nil
+	}
+	identity, err := a.peerIdentity()
+	if err != nil {
+		return nil, err
+	}
+	peers, err := a.peerStore()
+	if err != nil {
+		return nil, err
+	}
+
+	server := peer.NewServer(identity, peers, a.peerReplica, func(change *note.FileChange) {
+		runtime.EventsEmit(a.ctx, "note:external-change", change)
+	})
+	if err := server.Start(":" + strconv.Itoa(peer.DefaultPort)); err != nil {
+		// Another program has the usual port; devices find this one by mDNS
+		if err := server.Start(":0"); err != nil {
+			return nil, err
+		}
+	}
+	a.peerServer = server
+
+	advertiser, err := peer.Advertise(identity, server.Port())
+	if err != nil {
+		// Devices can still connect by address
+		fmt.Printf("Failed to advertise on the local network: %v\n", err)
+	}
+	a.peerAdvertiser = advertiser
+
+	return a.peerStatus(), nil
+}
+
+// StopPeerSync stops accepting LAN sync
+func (a *App) StopPeerSync() error {
+	a.peerMu.Lock()
+	defer a.peerMu.Unlock()
+	return a.stopPeerSync()
+}
+
+// GetPeerSyncStatus reports whether this device accepts LAN sync
+func (a *App) GetPeerSyncStatus() *PeerSyncStatus {
+	a.peerMu.Lock()
+	defer a.peerMu.Unlock()
+	return a.peerStatus()
+}
+
+// CreatePairingCode returns a one-time code another device can pair with
+// over the next few minutes
+func (a *App) CreatePairingCode() (string, error) {
+	a.peerMu.Lock()
+	defer a.peerMu.Unlock()
+
+	if a.peerServer == nil {
+		return "", fmt.Errorf("LAN sync is not running")
+	}
+	return a.peerServer.NewPairingCode()
+}
+
+// DiscoverPeers looks for devices on the local network accepting LAN sync
+func (a *App) DiscoverPeers() ([]*peer.Discovered, error) {
+	identity, err := a.peerIdentity()
+	if err != nil {
+		return nil, err
+	}
+	found, err := peer.Browse(a.ctx, discoveryTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := []*peer.Discovered{}
+	for _, d := range found {
+		if d.ID != identity.ID() {
+			devices = append(devices, d)
+		}
+	}
+	return devices, nil
+}
+
+// PairPeer pairs with the device at address using the code it shows
+func (a *App) PairPeer(address, code string) (*peer.Peer, error) {
+	client, err := a.peerClient()
+	if err != nil {
+		return nil, err
+	}
+	return client.Pair(a.ctx, address, code)
+}
+
+// ListPeers lists the paired devices
+func (a *App) ListPeers() ([]*peer.Peer, error) {
+	peers, err := a.peerStore()
+	if err != nil {
+		return nil, err
+	}
+	return peers.List()
+}
+
+// RemovePeer unpairs a device. It can no longer sync with this one.
+func (a *App) RemovePeer(id string) error {
+	peers, err := a.peerStore()
+	if err != nil {
+		return err
+	}
+	return peers.Remove(id)
+}
+
+// SyncWithPeer syncs the active workspace with a paired device. If the
+// device is no longer at the address it was last reached at, it is looked
+// for on the local network.
+func (a *App) SyncWithPeer(id string) (*peer.Report, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	client, err := a.peerClient()
+	if err != nil {
+		return nil, err
+	}
+	peers, err := a.peerStore()
+	if err != nil {
+		return nil, err
+	}
+	p, err := peers.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	replica := peer.NewReplica(ws.Workspace, ws.DB, ws.FS, ws.Notes, ws.Attachments)
+	unreachable := fmt.Errorf("%s is not reachable on the local network", p.Name)
+	if p.Address != "" {
+		report, err := client.Sync(a.ctx, p, p.Address, replica)
+		if report != nil || errors.Is(err, peer.ErrNotPaired) || errors.Is(err, peer.ErrOtherWorkspace) {
+			return report, err
+		}
+		unreachable = err
+	}
+
+	found, err := peer.Browse(a.ctx, discoveryTimeout)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range found {
+		if d.ID == p.ID && d.Address != p.Address {
+			return client.Sync(a.ctx, p, d.Address, replica)
+		}
+	}
+	return nil, unreachable
+}
+
+// peerReplica gives the LAN sync server the active workspace
+func (a *App) peerReplica() (*peer.Replica, func(), error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, nil, err
+	}
+	return peer.NewReplica(ws.Workspace, ws.DB, ws.FS, ws.Notes, ws.Attachments), release, nil
+}
+
+func (a *App) peerClient() (*peer.Client, error) {
+	identity, err := a.peerIdentity()
+	if err != nil {
+		return nil, err
+	}
+	peers, err := a.peerStore()
+	if err != nil {
+		return nil, err
+	}
+	return peer.NewClient(identity, peers), nil
+}
+
+func (a *App) peerStore() (*peer.Store, error) {
+	if a.userDB == nil {
+		return nil, fmt.Errorf("user database not initialized")
+	}
+	return peer.NewStore(a.userDB), nil
+}
+
+// peerIdentity loads this device's LAN sync identity from the OS keychain,
+// creating it on first use
+func (a *App) peerIdentity() (*peer.Identity, error) {
+	if a.storage == nil {
+		return nil, fmt.Errorf("secure storage not available")
+	}
+
+	data, err := a.storage.LoadPeerIdentity()
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		return peer.ParseIdentity(data)
+	}
+
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		name = "Fuknotion"
+	}
+	identity, err := peer.NewIdentity(name)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = identity.PEM(); err != nil {
+		return nil, err
+	}
+	if err := a.storage.SavePeerIdentity(data); err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// stopPeerSync stops the LAN sync server. Callers hold a.peerMu.
+func (a *App) stopPeerSync() error {
+	if a.peerAdvertiser != nil {
+		if err := a.peerAdvertiser.Close(); err != nil {
+			fmt.Printf("Failed to stop advertising on the local network: %v\n", err)
+		}
+		a.peerAdvertiser = nil
+	}
+	if a.peerServer == nil {
+		return nil
+	}
+	err := a.peerServer.Close()
+	a.peerServer = nil
+	return err
+}
+
+// peerStatus describes the LAN sync server. Callers hold a.peerMu.
+func (a *App) peerStatus() *PeerSyncStatus {
+	if a.peerServer == nil {
+		return &PeerSyncStatus{}
+	}
+	status := &PeerSyncStatus{Running: true, Port: a.peerServer.Port()}
+	if identity, err := a.peerIdentity(); err == nil {
+		status.DeviceID, status.DeviceName = identity.ID(), identity.Name
+	}
+	return status
+}
@@ -0,0 +1,46 @@
+package app
+
+import (
+	"fuknotion/backend/internal/models"
+	"fuknotion/backend/internal/revision"
+)
+
+// ListRevisions lists a note's revisions, newest first
+func (a *App) ListRevisions(noteID string) ([]*revision.Summary, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.Revisions().List(noteID)
+}
+
+// GetRevision retrieves a revision with its content
+func (a *App) GetRevision(id int64) (*revision.Revision, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.Revisions().Get(id)
+}
+
+// DiffRevisions compares two revisions of a note line by line
+func (a *App) DiffRevisions(fromID, toID int64) (*revision.Diff, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.Revisions().Diff(fromID, toID)
+}
+
+// RestoreRevision saves a past revision as the note's current version
+func (a *App) RestoreRevision(noteID string, revisionID int64) (*models.Note, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.RestoreRevision(noteID, revisionID)
+}
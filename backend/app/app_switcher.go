@@ -0,0 +1,23 @@
+package app
+
+import "fuknotion/backend/internal/note"
+
+// QuickSwitch fuzzy-matches note titles for the quick switcher
+func (a *App) QuickSwitch(query string, limit int) ([]*note.SwitcherResult, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.QuickSwitch(query, limit)
+}
+
+// MarkNoteOpened records that the user opened a note
+func (a *App) MarkNoteOpened(id string) error {
+	ws, release, err := a.session()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return ws.Notes.MarkOpened(id)
+}
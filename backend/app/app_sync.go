@@ -0,0 +1,115 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+
+	"fuknotion/backend/internal/auth"
+	"fuknotion/backend/internal/sync"
+	"fuknotion/backend/internal/workspace"
+
+	"golang.org/x/oauth2"
+)
+
+// SyncNow syncs the active workspace with its configured backend. Notes the
+// run changed are listed in the report so the UI can refresh them.
+func (a *App) SyncNow() (*sync.Report, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	backend, err := a.syncBackend(ws)
+	if err != nil {
+		return nil, err
+	}
+	return ws.Sync.Run(a.ctx, backend)
+}
+
+// SyncStatus reports the active workspace's unsynced changes and last sync
+func (a *App) SyncStatus() (*sync.Status, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Sync.Status()
+}
+
+// GetSyncSettings returns where the active workspace syncs to. Credentials
+// are never sent back to the UI.
+func (a *App) GetSyncSettings() (*sync.Settings, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return a.workspaces.SyncSettings(ws.Workspace.ID)
+}
+
+// SetSyncSettings changes where the active workspace syncs to, storing the
+// backend's credentials in the OS keychain. Empty credentials keep the
+// stored ones, so the UI can change settings without asking for them again.
+func (a *App) SetSyncSettings(settings sync.Settings, creds auth.SyncCredentials) error {
+	ws, release, err := a.session()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if creds != (auth.SyncCredentials{}) {
+		if a.storage == nil {
+			return fmt.Errorf("secure storage not available")
+		}
+		if err := a.storage.SaveSyncCredentials(ws.Workspace.ID, &creds); err != nil {
+			return err
+		}
+	}
+	return a.workspaces.SetSyncSettings(ws.Workspace.ID, &settings)
+}
+
+// syncBackend connects to the backend the workspace is configured to sync with
+func (a *App) syncBackend(ws *workspace.Session) (sync.SyncBackend, error) {
+	settings, err := a.workspaces.SyncSettings(ws.Workspace.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var creds auth.SyncCredentials
+	if a.storage != nil {
+		stored, err := a.storage.LoadSyncCredentials(ws.Workspace.ID)
+		if err != nil {
+			return nil, err
+		}
+		if stored != nil {
+			creds = *stored
+		}
+	}
+
+	switch settings.Backend {
+	case "", sync.KindDrive:
+		if a.sessionManager == nil || a.sessionManager.GetToken() == nil {
+			return nil, fmt.Errorf("sign in with Google to sync")
+		}
+		return sync.NewDrive(oauth2.NewClient(a.ctx, a.sessionManager), ws.Workspace.ID), nil
+	case sync.KindWebDAV:
+		return sync.NewWebDAV(http.DefaultClient, settings.URL, creds.Username, creds.Password)
+	case sync.KindS3:
+		if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+			return nil, fmt.Errorf("S3 access keys are not set")
+		}
+		return sync.NewS3(http.DefaultClient, sync.S3Config{
+			Endpoint:        settings.Endpoint,
+			Region:          settings.Region,
+			Bucket:          settings.Bucket,
+			Prefix:          settings.Prefix,
+			PathStyle:       settings.PathStyle,
+			AccessKeyID:     creds.AccessKeyID,
+			SecretAccessKey: creds.SecretAccessKey,
+		})
+	case sync.KindFolder:
+		return sync.NewFolder(settings.Folder)
+	}
+	return nil, fmt.Errorf("unknown sync backend: %q", settings.Backend)
+}
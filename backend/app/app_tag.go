@@ -0,0 +1,53 @@
+package app
+
+import "fuknotion/backend/internal/note"
+
+// AddTag tags a note
+func (a *App) AddTag(noteID, tag string) error {
+	ws, release, err := a.session()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return ws.Notes.AddTag(noteID, tag)
+}
+
+// RemoveTag removes a tag from a note
+func (a *App) RemoveTag(noteID, tag string) error {
+	ws, release, err := a.session()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return ws.Notes.RemoveTag(noteID, tag)
+}
+
+// ListTags lists all tags with the number of notes carrying each
+func (a *App) ListTags() ([]*note.TagCount, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.ListTags()
+}
+
+// RenameTag renames a tag across every note, merging it into newName if that tag exists
+func (a *App) RenameTag(oldName, newName string) (int, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	return ws.Notes.RenameTag(oldName, newName)
+}
+
+// MergeTags merges several tags into one across every note
+func (a *App) MergeTags(sources []string, target string) (int, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	return ws.Notes.MergeTags(sources, target)
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"fuknotion/backend/internal/models"
+	"fuknotion/backend/internal/note"
+)
+
+// ListTemplates lists the active workspace's note templates
+func (a *App) ListTemplates() ([]*note.Template, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.ListTemplates()
+}
+
+// GetTemplate retrieves a template by ID
+func (a *App) GetTemplate(id string) (*note.Template, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.GetTemplate(id)
+}
+
+// CreateTemplate saves a new template
+func (a *App) CreateTemplate(t note.Template) (*note.Template, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.CreateTemplate(&t)
+}
+
+// UpdateTemplate replaces an existing template
+func (a *App) UpdateTemplate(t note.Template) error {
+	ws, release, err := a.session()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return ws.Notes.UpdateTemplate(&t)
+}
+
+// DeleteTemplate deletes a template
+func (a *App) DeleteTemplate(id string) error {
+	ws, release, err := a.session()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return ws.Notes.DeleteTemplate(id)
+}
+
+// CreateNoteFromTemplate creates a note from a template. values answers the
+// template's prompts; {{user.name}} and {{user.email}} come from the signed-in
+// user's profile, and are empty when nobody is signed in.
+func (a *App) CreateNoteFromTemplate(templateID, title, folderID string, values map[string]string) (*models.Note, error) {
+	ctx := note.TemplateContext{Values: values}
+	if a.storage != nil {
+		if profile, err := a.storage.LoadUserProfile(); err == nil && profile != nil {
+			ctx.UserName, ctx.UserEmail = profile.Name, profile.Email
+		}
+	}
+
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.CreateNoteFromTemplate(templateID, title, folderID, ctx)
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"fuknotion/backend/internal/models"
+)
+
+// ListTrash lists notes in the trash
+func (a *App) ListTrash() ([]*models.Note, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.ListTrash()
+}
+
+// RestoreNote restores a note from the trash
+func (a *App) RestoreNote(id string) (*models.Note, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return ws.Notes.RestoreNote(id)
+}
+
+// DeleteNotePermanently deletes a trashed note for good
+func (a *App) DeleteNotePermanently(id string) error {
+	ws, release, err := a.session()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return ws.Notes.DeleteNotePermanently(id)
+}
+
+// EmptyTrash permanently deletes all trashed notes
+func (a *App) EmptyTrash() (int, error) {
+	ws, release, err := a.session()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	return ws.Notes.EmptyTrash()
+}
+
+// purgeExpiredTrash applies the configured trash retention to the active workspace
+func (a *App) purgeExpiredTrash() {
+	if a.config == nil || a.config.TrashRetention <= 0 {
+		return
+	}
+
+	ws, release, err := a.session()
+	if err != nil {
+		return
+	}
+	defer release()
+
+	retention := time.Duration(a.config.TrashRetention) * 24 * time.Hour
+	purged, err := ws.Notes.PurgeTrash(retention)
+	if err != nil {
+		fmt.Printf("Failed to purge trash: %v\n", err)
+		return
+	}
+	if purged > 0 {
+		fmt.Printf("Purged %d notes from trash older than %d days\n", purged, a.config.TrashRetention)
+	}
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"fuknotion/backend/internal/config"
+	"fuknotion/backend/internal/models"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// ListWorkspaces lists all workspaces
+func (a *App) ListWorkspaces() ([]*models.Workspace, error) {
+	if a.workspaces == nil {
+		return nil, fmt.Errorf("workspace not initialized")
+	}
+	return a.workspaces.ListWorkspaces()
+}
+
+// GetActiveWorkspace returns the currently open workspace
+func (a *App) GetActiveWorkspace() (*models.Workspace, error) {
+	if a.workspaces == nil {
+		return nil, fmt.Errorf("workspace not initialized")
+	}
+
+	ws := a.workspaces.Active()
+	if ws == nil {
+		return nil, fmt.Errorf("no workspace is open")
+	}
+	return ws, nil
+}
+
+// CreateWorkspace creates a new workspace without switching to it
+func (a *App) CreateWorkspace(name string) (*models.Workspace, error) {
+	if a.workspaces == nil {
+		return nil, fmt.Errorf("workspace not initialized")
+	}
+	return a.workspaces.CreateWorkspace(name)
+}
+
+// RenameWorkspace renames a workspace
+func (a *App) RenameWorkspace(id, name string) error {
+	if a.workspaces == nil {
+		return fmt.Errorf("workspace not initialized")
+	}
+	return a.workspaces.RenameWorkspace(id, name)
+}
+
+// SwitchWorkspace closes the current workspace and opens another one.
+// In-flight note operations finish before the old workspace is closed.
+// Emits "workspace:switched" so the frontend can reload its state.
+func (a *App) SwitchWorkspace(id string) (*models.Workspace, error) {
+	if a.workspaces == nil {
+		return nil, fmt.Errorf("workspace not initialized")
+	}
+
+	ws, err := a.workspaces.SwitchWorkspace(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remember the choice for the next launch
+	if a.config != nil {
+		a.config.ActiveWorkspace = ws.ID
+		configPath := filepath.Join(a.GetAppDataPath(), "config.json")
+		if err := config.SaveConfig(configPath, a.config); err != nil {
+			fmt.Printf("Failed to save config: %v\n", err)
+		}
+	}
+
+	a.purgeExpiredTrash()
+
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, "workspace:switched", ws)
+	}
+
+	return ws, nil
+}
+
+// DeleteWorkspace deletes a workspace and all of its notes
+func (a *App) DeleteWorkspace(id string) error {
+	if a.workspaces == nil {
+		return fmt.Errorf("workspace not initialized")
+	}
+	if err := a.workspaces.DeleteWorkspace(id); err != nil {
+		return err
+	}
+	if a.storage != nil {
+		if err := a.storage.DeleteSyncCredentials(id); err != nil {
+			fmt.Printf("Failed to delete sync credentials: %v\n", err)
+		}
+	}
+	return nil
+}
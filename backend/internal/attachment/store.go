@@ -0,0 +1,381 @@
+package attachment
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"fuknotion/backend/internal/database"
+	"fuknotion/backend/internal/filesystem"
+)
+
+const (
+	// Dir is the workspace directory holding attachment files
+	Dir = "attachments"
+
+	// URLPrefix is where the webview finds attachments; note content refers
+	// to them as URLPrefix + hash + "/" + name
+	URLPrefix = "/attachments/"
+
+	// MaxSize is the largest file accepted as an attachment
+	MaxSize = 64 << 20
+
+	// gcGracePeriod spares attachments added recently, since a pasted image
+	// is stored before the note referencing it is saved
+	gcGracePeriod = time.Hour
+)
+
+var (
+	hashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	refPattern  = regexp.MustCompile(regexp.QuoteMeta(URLPrefix) + `([0-9a-f]{64})`)
+)
+
+// Attachment is a stored binary file
+type Attachment struct {
+	Hash     string    `json:"hash"`
+	Name     string    `json:"name"`
+	MimeType string    `json:"mimeType"`
+	Size     int64     `json:"size"`
+	URL      string    `json:"url"`
+	AddedAt  time.Time `json:"addedAt"`
+}
+
+// GCReport summarizes a garbage collection of unreferenced attachments
+type GCReport struct {
+	Removed    int   `json:"removed"`
+	FreedBytes int64 `json:"freedBytes"`
+}
+
+// Store keeps attachments under the workspace, one file per distinct content,
+// so pasting the same image twice stores it once
+type Store struct {
+	db  *database.Database
+	fs  *filesystem.FileSystem
+	now func() time.Time
+}
+
+// NewStore creates an attachment store for a workspace
+func NewStore(db *database.Database, fs *filesystem.FileSystem) *Store {
+	return &Store{db: db, fs: fs, now: time.Now}
+}
+
+// filePath is where the attachment with hash is kept, fanned out by its
+// first two characters to keep directories small
+func filePath(hash string) string {
+	return filepath.Join(Dir, hash[:2], hash)
+}
+
+// URL returns the address note content uses to embed an attachment
+func URL(hash, name string) string {
+	return URLPrefix + hash + "/" + url.PathEscape(name)
+}
+
+// Refs returns the hashes of the attachments content refers to, in order of
+// first appearance
+func Refs(content string) []string {
+	var hashes []string
+	seen := make(map[string]bool)
+	for _, m := range refPattern.FindAllStringSubmatch(content, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			hashes = append(hashes, m[1])
+		}
+	}
+	return hashes
+}
+
+// IndexRefs replaces a note's rows in note_attachments with the attachments
+// its content refers to. q is normally the transaction writing the note.
+func IndexRefs(q database.Executor, noteID, content string) error {
+	if _, err := q.Exec(`DELETE FROM note_attachments WHERE note_id = ?`, noteID); err != nil {
+		return fmt.Errorf("failed to clear note attachments: %w", err)
+	}
+
+	for _, hash := range Refs(content) {
+		if _, err := q.Exec(`INSERT INTO note_attachments (note_id, hash) VALUES (?, ?)`, noteID, hash); err != nil {
+			return fmt.Errorf("failed to index attachment: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Save stores data as an attachment named name. Content already stored is
+// not written again; its row keeps the name it was first added under.
+func (s *Store) Save(name string, data []byte) (*Attachment, error) {
+	if len(data) > MaxSize {
+		return nil, fmt.Errorf("attachment is larger than %d MB", MaxSize>>20)
+	}
+
+	name = cleanName(name)
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	if !s.fs.FileExists(filePath(hash)) {
+		if err := s.fs.WriteFile(filePath(hash), data); err != nil {
+			return nil, fmt.Errorf("failed to store attachment: %w", err)
+		}
+	}
+
+	query := `
+		INSERT INTO attachments (hash, name, mime_type, size, added_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(hash) DO UPDATE SET added_at = excluded.added_at
+	`
+	if _, err := s.db.Exec(query, hash, name, mimeType(name, data), len(data), s.now()); err != nil {
+		return nil, fmt.Errorf("failed to record attachment: %w", err)
+	}
+
+	return s.Get(hash)
+}
+
+// Get returns an attachment's metadata
+func (s *Store) Get(hash string) (*Attachment, error) {
+	query := `SELECT hash, name, mime_type, size, added_at FROM attachments WHERE hash = ?`
+	a, err := scanAttachment(s.db.QueryRow(query, hash))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("attachment not found: %s", hash)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	return a, nil
+}
+
+// Hashes lists the hashes of the attachment files on disk
+func (s *Store) Hashes() ([]string, error) {
+	files, err := s.fs.WalkFiles(Dir)
+	if err != nil {
+		return nil, err
+	}
+	var hashes []string
+	for _, file := range files {
+		if hash := filepath.Base(file); hashPattern.MatchString(hash) && file == filePath(hash) {
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes, nil
+}
+
+// Read returns an attachment's contents
+func (s *Store) Read(hash string) ([]byte, error) {
+	if !hashPattern.MatchString(hash) {
+		return nil, fmt.Errorf("invalid attachment hash: %q", hash)
+	}
+	return s.fs.ReadFile(filePath(hash))
+}
+
+// ListForNote lists the attachments a note refers to
+func (s *Store) ListForNote(noteID string) ([]*Attachment, error) {
+	query := `
+		SELECT a.hash, a.name, a.mime_type, a.size, a.added_at
+		FROM note_attachments na JOIN attachments a ON a.hash = na.hash
+		WHERE na.note_id = ? ORDER BY a.name
+	`
+	rows, err := s.db.Query(query, noteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attachments: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := []*Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		attachments = append(attachments, a)
+	}
+
+	return attachments, rows.Err()
+}
+
+// CollectGarbage deletes attachments no note refers to any more, trashed
+// notes included. Files on disk without a row, left by a crash or a rebuilt
+// database, are collected too. Anything added within the grace period is
+// kept.
+func (s *Store) CollectGarbage() (*GCReport, error) {
+	report := &GCReport{}
+	cutoff := s.now().Add(-gcGracePeriod)
+
+	referenced := make(map[string]bool)
+	rows, err := s.db.Query(`SELECT DISTINCT hash FROM note_attachments`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load attachment references: %w", err)
+	}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan attachment reference: %w", err)
+		}
+		referenced[hash] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load attachment references: %w", err)
+	}
+
+	recorded := make(map[string]time.Time)
+	rows, err = s.db.Query(`SELECT hash, added_at FROM attachments`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attachments: %w", err)
+	}
+	for rows.Next() {
+		var hash string
+		var added time.Time
+		if err := rows.Scan(&hash, &added); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		recorded[hash] = added
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list attachments: %w", err)
+	}
+
+	files, err := s.fs.WalkFiles(Dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		hash := filepath.Base(file)
+		if !hashPattern.MatchString(hash) || referenced[hash] {
+			continue
+		}
+		info, err := s.fs.Stat(file)
+		if err != nil {
+			continue
+		}
+		added, ok := recorded[hash]
+		if !ok {
+			added = info.ModTime()
+		}
+		if added.After(cutoff) {
+			continue
+		}
+
+		if err := s.fs.DeleteFile(file); err != nil {
+			return report, err
+		}
+		report.Removed++
+		report.FreedBytes += info.Size()
+	}
+
+	// Drop rows for unreferenced attachments, whether or not the file was
+	// still there
+	_, err = s.db.Exec(`
+		DELETE FROM attachments
+		WHERE added_at < ? AND hash NOT IN (SELECT hash FROM note_attachments)
+	`, cutoff)
+	if err != nil {
+		return report, fmt.Errorf("failed to remove attachment records: %w", err)
+	}
+
+	return report, nil
+}
+
+// Remove deletes an attachment unless a note, trashed or not, still refers
+// to it, and reports whether it was deleted
+func (s *Store) Remove(hash string) (bool, error) {
+	if !hashPattern.MatchString(hash) {
+		return false, fmt.Errorf("invalid attachment hash: %q", hash)
+	}
+
+	var referenced bool
+	if err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM note_attachments WHERE hash = ?)`, hash).Scan(&referenced); err != nil {
+		return false, fmt.Errorf("failed to check attachment references: %w", err)
+	}
+	if referenced {
+		return false, nil
+	}
+
+	if s.fs.FileExists(filePath(hash)) {
+		if err := s.fs.DeleteFile(filePath(hash)); err != nil {
+			return false, err
+		}
+	}
+	if _, err := s.db.Exec(`DELETE FROM attachments WHERE hash = ?`, hash); err != nil {
+		return false, fmt.Errorf("failed to remove attachment record: %w", err)
+	}
+	return true, nil
+}
+
+// ServeHTTP serves an attachment at URLPrefix + hash, optionally followed by
+// "/" and a file name, which is ignored. Content never changes for a hash,
+// so responses may be cached indefinitely.
+func (s *Store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, URLPrefix)
+	hash, _, _ := strings.Cut(rest, "/")
+	if rest == r.URL.Path || !hashPattern.MatchString(hash) {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := s.fs.OpenFile(filePath(hash))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "failed to read attachment", http.StatusInternalServerError)
+		return
+	}
+
+	// A missing row only loses the name and type; sniff the type instead
+	name := hash
+	if a, err := s.Get(hash); err == nil {
+		name = a.Name
+		w.Header().Set("Content-Type", a.MimeType)
+	}
+
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// Attachments are user content: an SVG or HTML file must not run scripts
+	// in the app's origin
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src 'self' data:; style-src 'unsafe-inline'; sandbox")
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAttachment(row rowScanner) (*Attachment, error) {
+	var a Attachment
+	if err := row.Scan(&a.Hash, &a.Name, &a.MimeType, &a.Size, &a.AddedAt); err != nil {
+		return nil, err
+	}
+	a.URL = URL(a.Hash, a.Name)
+	return &a, nil
+}
+
+// cleanName keeps only the base name of a file
+func cleanName(name string) string {
+	name = strings.TrimSpace(path.Base(strings.ReplaceAll(name, `\`, "/")))
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	return name
+}
+
+// mimeType picks a content type from the file extension, falling back to
+// sniffing the data
+func mimeType(name string, data []byte) string {
+	if t := mime.TypeByExtension(strings.ToLower(filepath.Ext(name))); t != "" {
+		return t
+	}
+	return http.DetectContentType(data)
+}
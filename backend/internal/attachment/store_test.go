@@ -0,0 +1,197 @@
+package attachment
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fuknotion/backend/internal/database"
+	"fuknotion/backend/internal/filesystem"
+)
+
+func setupTestStore(t *testing.T) (*Store, *database.Database, string) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+
+	db, err := database.InitWorkspaceDB(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	fs, err := filesystem.NewFileSystem(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize filesystem: %v", err)
+	}
+
+	return NewStore(db, fs), db, tmpDir
+}
+
+// writeNote stands in for the note service, which indexes references as it
+// saves a note
+func writeNote(t *testing.T, db *database.Database, id, content string) {
+	t.Helper()
+
+	_, err := db.Exec(`
+		INSERT INTO notes (id, title, file_path, created_at, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO NOTHING
+	`, id, id, "notes/"+id+".md", time.Now(), time.Now())
+	if err != nil {
+		t.Fatalf("failed to insert note: %v", err)
+	}
+	if err := IndexRefs(db, id, content); err != nil {
+		t.Fatalf("IndexRefs() failed: %v", err)
+	}
+}
+
+var png = []byte("\x89PNG\r\n\x1a\n fake image data")
+
+func TestSaveDeduplicates(t *testing.T) {
+	store, _, tmpDir := setupTestStore(t)
+
+	first, err := store.Save("screenshot.png", png)
+	if err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if len(first.Hash) != 64 || first.MimeType != "image/png" || first.Size != int64(len(png)) {
+		t.Errorf("Save() = %+v", first)
+	}
+	if first.URL != "/attachments/"+first.Hash+"/screenshot.png" {
+		t.Errorf("URL = %q", first.URL)
+	}
+
+	second, err := store.Save(`C:\Users\me\copy of it.png`, png)
+	if err != nil {
+		t.Fatalf("Save() again failed: %v", err)
+	}
+	if second.Hash != first.Hash || second.Name != "screenshot.png" {
+		t.Errorf("second Save() = %+v, want the first attachment", second)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(tmpDir, Dir, "*", "*"))
+	if len(files) != 1 {
+		t.Errorf("stored %d files, want 1", len(files))
+	}
+
+	if _, err := store.Save("big.bin", make([]byte, MaxSize+1)); err == nil {
+		t.Error("Save() accepted a file over MaxSize")
+	}
+}
+
+func TestNoteReferencesAndGarbageCollection(t *testing.T) {
+	store, db, tmpDir := setupTestStore(t)
+
+	kept, _ := store.Save("kept.png", png)
+	dropped, _ := store.Save("dropped.txt", []byte("plain text"))
+
+	writeNote(t, db, "pictures", "![a]("+kept.URL+") and ![b]("+dropped.URL+")")
+
+	listed, err := store.ListForNote("pictures")
+	if err != nil {
+		t.Fatalf("ListForNote() failed: %v", err)
+	}
+	if len(listed) != 2 {
+		t.Fatalf("ListForNote() = %d attachments, want 2", len(listed))
+	}
+
+	writeNote(t, db, "pictures", "![a]("+kept.URL+")")
+
+	// Within the grace period nothing is collected
+	report, err := store.CollectGarbage()
+	if err != nil {
+		t.Fatalf("CollectGarbage() failed: %v", err)
+	}
+	if report.Removed != 0 {
+		t.Errorf("CollectGarbage() removed %d recent attachments", report.Removed)
+	}
+
+	// A file left without a row is collected too
+	orphan := filepath.Join(tmpDir, Dir, "ab", "ab"+kept.Hash[2:62]+"ff")
+	os.MkdirAll(filepath.Dir(orphan), 0700)
+	os.WriteFile(orphan, []byte("orphan"), 0600)
+	old := time.Now().Add(-2 * gcGracePeriod)
+	os.Chtimes(orphan, old, old)
+
+	store.now = func() time.Time { return time.Now().Add(2 * gcGracePeriod) }
+	report, err = store.CollectGarbage()
+	if err != nil {
+		t.Fatalf("CollectGarbage() failed: %v", err)
+	}
+	if report.Removed != 2 || report.FreedBytes != int64(len("plain text")+len("orphan")) {
+		t.Errorf("CollectGarbage() = %+v, want the dropped attachment and the orphan", report)
+	}
+	if _, err := store.Get(dropped.Hash); err == nil {
+		t.Error("collected attachment still has a row")
+	}
+	if _, err := store.Get(kept.Hash); err != nil {
+		t.Errorf("referenced attachment was collected: %v", err)
+	}
+
+	// Notes in the trash still hold on to their attachments
+	db.Exec(`UPDATE notes SET deleted_at = ? WHERE id = ?`, time.Now(), "pictures")
+	if report, _ := store.CollectGarbage(); report.Removed != 0 {
+		t.Errorf("CollectGarbage() removed an attachment of a trashed note")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	store, db, _ := setupTestStore(t)
+
+	used, _ := store.Save("used.png", png)
+	unused, _ := store.Save("unused.txt", []byte("unused"))
+	writeNote(t, db, "pictures", "![a]("+used.URL+")")
+
+	if removed, err := store.Remove(used.Hash); err != nil || removed {
+		t.Errorf("Remove() of a referenced attachment = %v, %v, want false", removed, err)
+	}
+	if removed, err := store.Remove(unused.Hash); err != nil || !removed {
+		t.Errorf("Remove() = %v, %v, want true", removed, err)
+	}
+	if _, err := store.Get(unused.Hash); err == nil {
+		t.Error("removed attachment still has a row")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	store, _, _ := setupTestStore(t)
+
+	a, _ := store.Save("diagram.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`))
+
+	rec := httptest.NewRecorder()
+	store.ServeHTTP(rec, httptest.NewRequest("GET", a.URL, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "image/svg+xml" {
+		t.Errorf("Content-Type = %q, want image/svg+xml", got)
+	}
+	if rec.Header().Get("Content-Security-Policy") == "" {
+		t.Error("attachment served without a content security policy")
+	}
+	if rec.Body.String() != `<svg xmlns="http://www.w3.org/2000/svg"></svg>` {
+		t.Errorf("body = %q", rec.Body.String())
+	}
+
+	for _, path := range []string{"/attachments/../workspace.db", "/attachments/" + a.Hash[:63], "/other/" + a.Hash} {
+		rec := httptest.NewRecorder()
+		store.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", path, rec.Code)
+		}
+	}
+}
+
+func TestRefs(t *testing.T) {
+	h1 := "1111111111111111111111111111111111111111111111111111111111111111"
+	h2 := "2222222222222222222222222222222222222222222222222222222222222222"
+	content := "![x](/attachments/" + h1 + "/x.png) [file](/attachments/" + h2 + ") ![again](/attachments/" + h1 + ")"
+
+	refs := Refs(content)
+	if len(refs) != 2 || refs[0] != h1 || refs[1] != h2 {
+		t.Errorf("Refs() = %v, want [%s %s]", refs, h1, h2)
+	}
+}
@@ -182,6 +182,12 @@ func (sm *SessionManager) GetValidToken() (*oauth2.Token, error) {
 	return token, nil
 }
 
+// Token returns a valid token, so the session can serve as an
+// oauth2.TokenSource for API clients
+func (sm *SessionManager) Token() (*oauth2.Token, error) {
+	return sm.GetValidToken()
+}
+
 // RestoreSession attempts to restore a session from storage
 func (sm *SessionManager) RestoreSession(onRefresh func(*oauth2.Token) error) error {
 	token, err := sm.storage.LoadGoogleToken()
@@ -159,6 +159,92 @@ func (s *SecureStorage) LoadUserProfile() (*UserProfile, error) {
 	return &profile, nil
 }
 
+// SyncCredentials are the secrets a workspace's sync backend signs in with
+type SyncCredentials struct {
+	Username        string `json:"username,omitempty"` // WebDAV
+	Password        string `json:"password,omitempty"`
+	AccessKeyID     string `json:"accessKeyId,omitempty"` // S3
+	SecretAccessKey string `json:"secretAccessKey,omitempty"`
+}
+
+func syncCredentialsKey(workspaceID string) string {
+	return "sync_credentials:" + workspaceID
+}
+
+// SaveSyncCredentials stores a workspace's sync credentials
+func (s *SecureStorage) SaveSyncCredentials(workspaceID string, creds *SyncCredentials) error {
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sync credentials: %w", err)
+	}
+
+	err = s.ring.Set(keyring.Item{
+		Key:  syncCredentialsKey(workspaceID),
+		Data: data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save sync credentials to keyring: %w", err)
+	}
+
+	return nil
+}
+
+// LoadSyncCredentials retrieves a workspace's sync credentials, or nil if
+// none were stored
+func (s *SecureStorage) LoadSyncCredentials(workspaceID string) (*SyncCredentials, error) {
+	item, err := s.ring.Get(syncCredentialsKey(workspaceID))
+	if err != nil {
+		if err == keyring.ErrKeyNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load sync credentials from keyring: %w", err)
+	}
+
+	var creds SyncCredentials
+	if err := json.Unmarshal(item.Data, &creds); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal sync credentials: %w", err)
+	}
+
+	return &creds, nil
+}
+
+// DeleteSyncCredentials removes a workspace's sync credentials
+func (s *SecureStorage) DeleteSyncCredentials(workspaceID string) error {
+	err := s.ring.Remove(syncCredentialsKey(workspaceID))
+	if err != nil && err != keyring.ErrKeyNotFound {
+		return fmt.Errorf("failed to delete sync credentials: %w", err)
+	}
+	return nil
+}
+
+// SavePeerIdentity stores this device's key and certificate for LAN sync, as
+// PEM
+func (s *SecureStorage) SavePeerIdentity(data []byte) error {
+	err := s.ring.Set(keyring.Item{
+		Key:  "peer_identity",
+		Data: data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save device identity to keyring: %w", err)
+	}
+
+	return nil
+}
+
+// LoadPeerIdentity retrieves this device's key and certificate for LAN sync,
+// or nil if none were stored
+func (s *SecureStorage) LoadPeerIdentity() ([]byte, error) {
+	item, err := s.ring.Get("peer_identity")
+	if err != nil {
+		if err == keyring.ErrKeyNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load device identity from keyring: %w", err)
+	}
+
+	return item.Data, nil
+}
+
 // DeleteAll removes all stored credentials
 func (s *SecureStorage) DeleteAll() error {
 	// Delete tokens
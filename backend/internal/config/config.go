@@ -12,6 +12,8 @@ type Config struct {
 	Theme            string `json:"theme"`
 	AutoSave         bool   `json:"autoSave"`
 	AutoSaveInterval int    `json:"autoSaveInterval"` // milliseconds
+	ActiveWorkspace  string `json:"activeWorkspace,omitempty"`
+	TrashRetention   int    `json:"trashRetentionDays"` // days; 0 keeps trashed notes forever
 }
 
 // DefaultConfig returns default configuration
@@ -20,6 +22,7 @@ func DefaultConfig() *Config {
 		Theme:            "system",
 		AutoSave:         true,
 		AutoSaveInterval: 3000, // 3 seconds
+		TrashRetention:   30,
 	}
 }
 
@@ -47,12 +50,13 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
+	// Start from defaults so settings added in newer versions get sane values
+	cfg := DefaultConfig()
+	if err := json.Unmarshal(data, cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
-	return &cfg, nil
+	return cfg, nil
 }
 
 // SaveConfig saves configuration to file
@@ -9,6 +9,14 @@ import (
 	_ "modernc.org/sqlite"
 )
 
+// Executor runs statements either directly on the database or inside a
+// transaction; both *Database and *sql.Tx implement it
+type Executor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 // Database wraps SQLite connection
 type Database struct {
 	db   *sql.DB
@@ -23,15 +31,18 @@ func Open(dbPath string) (*Database, error) {
 		return nil, fmt.Errorf("failed to create database directory: %w", err)
 	}
 
-	db, err := sql.Open("sqlite", dbPath)
+	// Pragmas in the DSN apply to every pooled connection, not just the first.
+	// busy_timeout lets background writers (e.g. the file watcher) wait for
+	// each other instead of failing with SQLITE_BUSY.
+	dsn := dbPath + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	// Enable foreign keys
-	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+	if err := db.Ping(); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	return &Database{db: db, path: dbPath}, nil
@@ -60,111 +71,48 @@ func (d *Database) QueryRow(query string, args ...interface{}) *sql.Row {
 	return d.db.QueryRow(query, args...)
 }
 
-// InitUserDB initializes the user database schema
-func InitUserDB(basePath string) (*Database, error) {
-	dbPath := filepath.Join(basePath, "user.db")
-	db, err := Open(dbPath)
+// Transaction runs fn inside a transaction. It commits when fn returns nil
+// and rolls back when fn returns an error or panics. Statements inside fn
+// must go through tx: the pool's other connections would wait on its lock.
+func (d *Database) Transaction(fn func(tx *sql.Tx) error) error {
+	tx, err := d.db.Begin()
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 
-	schema := `
-	CREATE TABLE IF NOT EXISTS user (
-		id TEXT PRIMARY KEY,
-		name TEXT NOT NULL,
-		email TEXT,
-		avatar_url TEXT,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE TABLE IF NOT EXISTS workspaces (
-		id TEXT PRIMARY KEY,
-		name TEXT NOT NULL,
-		path TEXT NOT NULL UNIQUE,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-	`
-
-	if _, err := db.Exec(schema); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to initialize user database: %w", err)
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
 	}
 
-	return db, nil
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	committed = true
+
+	return nil
 }
 
-// InitWorkspaceDB initializes a workspace database schema
-func InitWorkspaceDB(workspacePath string) (*Database, error) {
-	dbPath := filepath.Join(workspacePath, "workspace.db")
-	db, err := Open(dbPath)
+// InitUserDB opens user.db and applies pending user migrations
+func InitUserDB(basePath string) (*Database, error) {
+	db, err := initWithMigrations(filepath.Join(basePath, "user.db"), "user")
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to initialize user database: %w", err)
 	}
+	return db, nil
+}
 
-	schema := `
-	CREATE TABLE IF NOT EXISTS notes (
-		id TEXT PRIMARY KEY,
-		title TEXT NOT NULL,
-		folder_id TEXT,
-		file_path TEXT NOT NULL UNIQUE,
-		is_favorite BOOLEAN DEFAULT 0,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (folder_id) REFERENCES folders(id) ON DELETE SET NULL
-	);
-
-	CREATE TABLE IF NOT EXISTS folders (
-		id TEXT PRIMARY KEY,
-		name TEXT NOT NULL,
-		parent_id TEXT,
-		position INTEGER DEFAULT 0,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (parent_id) REFERENCES folders(id) ON DELETE CASCADE
-	);
-
-	CREATE TABLE IF NOT EXISTS members (
-		user_id TEXT PRIMARY KEY,
-		email TEXT NOT NULL,
-		name TEXT NOT NULL,
-		role TEXT NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
-		joined_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_notes_folder ON notes(folder_id);
-	CREATE INDEX IF NOT EXISTS idx_notes_favorite ON notes(is_favorite);
-	CREATE INDEX IF NOT EXISTS idx_notes_updated ON notes(updated_at DESC);
-	CREATE INDEX IF NOT EXISTS idx_folders_parent ON folders(parent_id);
-	CREATE INDEX IF NOT EXISTS idx_members_email ON members(email);
-
-	-- Full-text search table
-	CREATE VIRTUAL TABLE IF NOT EXISTS notes_fts USING fts5(
-		note_id UNINDEXED,
-		title,
-		content,
-		tokenize='porter unicode61'
-	);
-
-	-- Triggers to keep FTS in sync with notes table
-	CREATE TRIGGER IF NOT EXISTS notes_ai AFTER INSERT ON notes BEGIN
-		INSERT INTO notes_fts(rowid, note_id, title, content)
-		VALUES (new.rowid, new.id, new.title, '');
-	END;
-
-	CREATE TRIGGER IF NOT EXISTS notes_ad AFTER DELETE ON notes BEGIN
-		DELETE FROM notes_fts WHERE rowid = old.rowid;
-	END;
-
-	CREATE TRIGGER IF NOT EXISTS notes_au AFTER UPDATE ON notes BEGIN
-		UPDATE notes_fts SET title = new.title WHERE rowid = new.rowid;
-	END;
-	`
-
-	if _, err := db.Exec(schema); err != nil {
-		db.Close()
+// InitWorkspaceDB opens workspace.db and applies pending workspace migrations
+func InitWorkspaceDB(workspacePath string) (*Database, error) {
+	db, err := initWithMigrations(filepath.Join(workspacePath, "workspace.db"), "workspace")
+	if err != nil {
 		return nil, fmt.Errorf("failed to initialize workspace database: %w", err)
 	}
-
 	return db, nil
 }
@@ -1,6 +1,8 @@
 package database
 
 import (
+	"database/sql"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -268,3 +270,40 @@ func TestMembersTable(t *testing.T) {
 		t.Errorf("Member count = %d, want 3", count)
 	}
 }
+
+func TestTransaction(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE items (name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Transaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO items (name) VALUES ('kept')")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Transaction() failed: %v", err)
+	}
+
+	failure := errors.New("abort")
+	err = db.Transaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("INSERT INTO items (name) VALUES ('rolled back')"); err != nil {
+			return err
+		}
+		return failure
+	})
+	if err != failure {
+		t.Errorf("Transaction() error = %v, want %v", err, failure)
+	}
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM items").Scan(&count)
+	if count != 1 {
+		t.Errorf("row count = %d, want 1 after rollback", count)
+	}
+}
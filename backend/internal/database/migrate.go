@@ -0,0 +1,205 @@
+package database
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/user/*.sql migrations/workspace/*.sql
+var migrationFiles embed.FS
+
+// ErrSchemaTooNew is returned when a database was written by a newer build
+var ErrSchemaTooNew = errors.New("database schema is newer than this version of Fuknotion")
+
+// Migration is a single ordered, forward-only schema change
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// loadMigrations reads the embedded migrations for a database kind ("user" or "workspace")
+func loadMigrations(kind string) ([]Migration, error) {
+	dir := path.Join("migrations", kind)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s migrations: %w", kind, err)
+	}
+
+	var migrations []Migration
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+
+		// File names look like 0001_initial_schema.sql
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name: %s", name)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", name)
+		}
+
+		data, err := migrationFiles.ReadFile(path.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    strings.TrimSuffix(name, ".sql"),
+			SQL:     string(data),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("%s migrations are not contiguous: expected version %d, got %s", kind, i+1, m.Name)
+		}
+	}
+
+	return migrations, nil
+}
+
+// SchemaVersion returns the schema version stored in PRAGMA user_version
+func (d *Database) SchemaVersion() (int, error) {
+	var version int
+	if err := d.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// Migrate applies all pending migrations in order. Each migration runs in its
+// own transaction together with the user_version bump, so a failure leaves the
+// database at the last successfully applied version. Existing databases are
+// backed up before the first pending migration runs.
+func (d *Database) Migrate(migrations []Migration) error {
+	current, err := d.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	latest := 0
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].Version
+	}
+
+	if current > latest {
+		return fmt.Errorf("%w: %s is at version %d, this build supports up to %d",
+			ErrSchemaTooNew, d.path, current, latest)
+	}
+	if current == latest {
+		return nil
+	}
+
+	// Databases from before versioning sit at 0 with the baseline tables in
+	// place; only a truly empty one has nothing worth keeping
+	existing := current > 0
+	if !existing {
+		if existing, err = d.hasTables(); err != nil {
+			return err
+		}
+	}
+	if existing {
+		if _, err := d.backup(current); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := d.applyMigration(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyMigration runs a single migration and records its version atomically
+func (d *Database) applyMigration(m Migration) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %s: %w", m.Name, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.SQL); err != nil {
+		return fmt.Errorf("failed to apply migration %s: %w", m.Name, err)
+	}
+
+	// PRAGMA does not accept bound parameters
+	if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", m.Version)); err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", m.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %s: %w", m.Name, err)
+	}
+
+	return nil
+}
+
+// hasTables reports whether the database holds any tables of its own
+func (d *Database) hasTables() (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%')`
+	if err := d.db.QueryRow(query).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to inspect database: %w", err)
+	}
+	return exists, nil
+}
+
+// backup writes a consistent copy of the database next to the original,
+// named after the schema version it was taken at
+func (d *Database) backup(version int) (string, error) {
+	backupPath := fmt.Sprintf("%s.v%d.bak", d.path, version)
+
+	// VACUUM INTO refuses to overwrite an existing file
+	if err := os.Remove(backupPath); err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to remove old backup: %w", err)
+	}
+
+	if _, err := d.db.Exec("VACUUM INTO ?", backupPath); err != nil {
+		return "", fmt.Errorf("failed to back up database before migration: %w", err)
+	}
+
+	return backupPath, nil
+}
+
+// initWithMigrations opens the database at dbPath and brings it up to date
+func initWithMigrations(dbPath, kind string) (*Database, error) {
+	migrations, err := loadMigrations(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := Open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Migrate(migrations); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate %s database: %w", kind, err)
+	}
+
+	return db, nil
+}
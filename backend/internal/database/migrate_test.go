@@ -0,0 +1,160 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	for _, kind := range []string{"user", "workspace"} {
+		migrations, err := loadMigrations(kind)
+		if err != nil {
+			t.Fatalf("loadMigrations(%s) failed: %v", kind, err)
+		}
+		if len(migrations) == 0 {
+			t.Fatalf("loadMigrations(%s) returned no migrations", kind)
+		}
+		for i, m := range migrations {
+			if m.Version != i+1 {
+				t.Errorf("%s migration %d has version %d", kind, i, m.Version)
+			}
+		}
+	}
+}
+
+func TestInitSetsLatestVersion(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	db, err := InitWorkspaceDB(tmpDir)
+	if err != nil {
+		t.Fatalf("InitWorkspaceDB() failed: %v", err)
+	}
+	defer db.Close()
+
+	migrations, _ := loadMigrations("workspace")
+	version, err := db.SchemaVersion()
+	if err != nil {
+		t.Fatalf("SchemaVersion() failed: %v", err)
+	}
+	if want := migrations[len(migrations)-1].Version; version != want {
+		t.Errorf("SchemaVersion() = %d, want %d", version, want)
+	}
+
+	// Fresh databases are not backed up
+	matches, _ := filepath.Glob(filepath.Join(tmpDir, "workspace.db.v*.bak"))
+	if len(matches) != 0 {
+		t.Errorf("unexpected backups for fresh database: %v", matches)
+	}
+}
+
+func TestMigrateAppliesPendingWithBackup(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer db.Close()
+
+	migrations := []Migration{
+		{Version: 1, Name: "0001_items", SQL: "CREATE TABLE items (id TEXT PRIMARY KEY)"},
+	}
+	if err := db.Migrate(migrations); err != nil {
+		t.Fatalf("Migrate() v1 failed: %v", err)
+	}
+
+	migrations = append(migrations, Migration{
+		Version: 2, Name: "0002_items_name", SQL: "ALTER TABLE items ADD COLUMN name TEXT",
+	})
+	if err := db.Migrate(migrations); err != nil {
+		t.Fatalf("Migrate() v2 failed: %v", err)
+	}
+
+	if version, _ := db.SchemaVersion(); version != 2 {
+		t.Errorf("SchemaVersion() = %d, want 2", version)
+	}
+	if _, err := db.Exec("INSERT INTO items (id, name) VALUES ('a', 'b')"); err != nil {
+		t.Errorf("new column not available: %v", err)
+	}
+	if _, err := os.Stat(dbPath + ".v1.bak"); err != nil {
+		t.Errorf("backup of v1 not created: %v", err)
+	}
+}
+
+func TestMigrateBacksUpUnversionedDatabase(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Installs from before versioning have the baseline tables at version 0
+	migrations, _ := loadMigrations("workspace")
+	db, err := Open(filepath.Join(tmpDir, "workspace.db"))
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	if _, err := db.Exec(migrations[0].SQL); err != nil {
+		t.Fatalf("failed to create baseline schema: %v", err)
+	}
+	db.Close()
+
+	db, err = InitWorkspaceDB(tmpDir)
+	if err != nil {
+		t.Fatalf("InitWorkspaceDB() failed: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "workspace.db.v0.bak")); err != nil {
+		t.Errorf("backup of the unversioned database not created: %v", err)
+	}
+}
+
+func TestMigrateRollsBackFailedMigration(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	db, err := Open(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer db.Close()
+
+	migrations := []Migration{
+		{Version: 1, Name: "0001_items", SQL: "CREATE TABLE items (id TEXT PRIMARY KEY)"},
+		{Version: 2, Name: "0002_broken", SQL: "CREATE TABLE other (id TEXT); THIS IS NOT SQL"},
+	}
+	if err := db.Migrate(migrations); err == nil {
+		t.Fatal("Migrate() expected error for broken migration")
+	}
+
+	if version, _ := db.SchemaVersion(); version != 1 {
+		t.Errorf("SchemaVersion() = %d, want 1", version)
+	}
+
+	var name string
+	err = db.QueryRow("SELECT name FROM sqlite_master WHERE type='table' AND name='other'").Scan(&name)
+	if err == nil {
+		t.Error("partial migration was not rolled back")
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	db, err := Open(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("PRAGMA user_version = 99"); err != nil {
+		t.Fatalf("failed to set user_version: %v", err)
+	}
+
+	migrations := []Migration{
+		{Version: 1, Name: "0001_items", SQL: "CREATE TABLE items (id TEXT PRIMARY KEY)"},
+	}
+	err = db.Migrate(migrations)
+	if !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Migrate() error = %v, want ErrSchemaTooNew", err)
+	}
+}
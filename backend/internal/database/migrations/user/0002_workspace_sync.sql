@@ -0,0 +1,3 @@
+-- Where each workspace syncs to, as JSON. Empty means Google Drive. Passwords
+-- and keys are kept in the OS keychain, not here.
+ALTER TABLE workspaces ADD COLUMN sync_settings TEXT NOT NULL DEFAULT '';
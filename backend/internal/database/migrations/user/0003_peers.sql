@@ -0,0 +1,10 @@
+-- Devices paired for LAN sync. Each is known by the fingerprint of the
+-- certificate it presented when paired; any other certificate is refused.
+CREATE TABLE IF NOT EXISTS peers (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    fingerprint TEXT NOT NULL UNIQUE, -- hex SHA-256 of the certificate
+    address TEXT NOT NULL DEFAULT '', -- host:port it was last reached at
+    paired_at DATETIME NOT NULL,
+    last_sync_at DATETIME
+);
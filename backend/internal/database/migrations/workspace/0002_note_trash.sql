@@ -0,0 +1,6 @@
+-- Soft delete: trashed notes keep their row and their file moves to .trash/.
+-- trashed_from remembers the original file path so the note can be restored.
+ALTER TABLE notes ADD COLUMN deleted_at DATETIME;
+ALTER TABLE notes ADD COLUMN trashed_from TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_notes_deleted ON notes(deleted_at);
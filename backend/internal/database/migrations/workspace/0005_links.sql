@@ -0,0 +1,14 @@
+-- Wiki links parsed from note content. target holds the note title or ID as
+-- written; it is resolved against notes when queried, so links to notes that
+-- are created or renamed later resolve without reindexing the source.
+CREATE TABLE IF NOT EXISTS links (
+    source_id TEXT NOT NULL,
+    target TEXT NOT NULL COLLATE NOCASE,
+    heading TEXT NOT NULL DEFAULT '',
+    alias TEXT NOT NULL DEFAULT '',
+    line INTEGER NOT NULL,
+    FOREIGN KEY (source_id) REFERENCES notes(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_links_source ON links(source_id);
+CREATE INDEX IF NOT EXISTS idx_links_target ON links(target);
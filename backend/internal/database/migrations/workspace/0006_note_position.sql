@@ -0,0 +1,4 @@
+-- Manual ordering of notes within their folder, like folders.position
+ALTER TABLE notes ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_notes_folder_position ON notes(folder_id, position);
@@ -0,0 +1,4 @@
+-- Notes pinned to the top of the note list, in order from 1; 0 when not pinned
+ALTER TABLE notes ADD COLUMN pinned INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_notes_pinned ON notes(pinned);
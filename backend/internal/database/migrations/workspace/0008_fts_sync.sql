@@ -0,0 +1,11 @@
+-- The note service now writes each notes_fts row, content included, in the
+-- same transaction as the note, so the insert trigger that left content
+-- empty is dropped. Title sync is limited to title changes so updates to
+-- other columns no longer rewrite the search row. Rows indexed with empty
+-- content before this migration are repaired by RebuildSearchIndex.
+DROP TRIGGER IF EXISTS notes_ai;
+
+DROP TRIGGER IF EXISTS notes_au;
+CREATE TRIGGER IF NOT EXISTS notes_au AFTER UPDATE OF title ON notes BEGIN
+    UPDATE notes_fts SET title = new.title WHERE rowid = new.rowid;
+END;
@@ -0,0 +1,23 @@
+-- Search over plain text: content is stored with markdown stripped and
+-- headings get their own column so bm25 can rank them higher. FTS5 tables
+-- cannot gain columns, so notes_fts is recreated empty; the workspace
+-- rebuilds it from the note files when it is next opened.
+DROP TRIGGER IF EXISTS notes_ad;
+DROP TRIGGER IF EXISTS notes_au;
+DROP TABLE IF EXISTS notes_fts;
+
+CREATE VIRTUAL TABLE notes_fts USING fts5(
+    note_id UNINDEXED,
+    title,
+    headings,
+    content,
+    tokenize='porter unicode61'
+);
+
+CREATE TRIGGER notes_ad AFTER DELETE ON notes BEGIN
+    DELETE FROM notes_fts WHERE rowid = old.rowid;
+END;
+
+CREATE TRIGGER notes_au AFTER UPDATE OF title ON notes BEGIN
+    UPDATE notes_fts SET title = new.title WHERE rowid = new.rowid;
+END;
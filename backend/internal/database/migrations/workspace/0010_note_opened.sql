@@ -0,0 +1,2 @@
+-- When a note was last opened, so the quick switcher can favour recent notes
+ALTER TABLE notes ADD COLUMN opened_at DATETIME;
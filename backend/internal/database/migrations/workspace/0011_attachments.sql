@@ -0,0 +1,21 @@
+-- Binary files such as pasted images, stored once per content hash under
+-- attachments/ in the workspace
+CREATE TABLE IF NOT EXISTS attachments (
+    hash TEXT PRIMARY KEY, -- hex SHA-256 of the content
+    name TEXT NOT NULL,    -- file name it was first added under
+    mime_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    added_at DATETIME NOT NULL -- last time it was added; collection spares recent ones
+);
+
+-- Attachments referenced from note content. hash is not a foreign key, so a
+-- note referencing an attachment whose row is missing (e.g. after a rebuilt
+-- database) still keeps the file from being collected.
+CREATE TABLE IF NOT EXISTS note_attachments (
+    note_id TEXT NOT NULL,
+    hash TEXT NOT NULL,
+    PRIMARY KEY (note_id, hash),
+    FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_note_attachments_hash ON note_attachments(hash);
@@ -0,0 +1,18 @@
+-- Files of the workspace as they were when last synced with a remote. A file
+-- whose content or remote revision no longer matches has changed since, so a
+-- run interrupted part way through picks up where it stopped.
+CREATE TABLE IF NOT EXISTS sync_files (
+    path TEXT PRIMARY KEY,      -- slash-separated, relative to the workspace
+    remote_id TEXT NOT NULL,    -- the remote's ID for the file
+    revision TEXT NOT NULL,     -- the remote's revision or etag when synced
+    hash TEXT NOT NULL,         -- hex SHA-256 of the content when synced
+    size INTEGER NOT NULL,      -- size and mtime of the local file when synced,
+    mod_time DATETIME NOT NULL, -- to skip hashing files that did not change
+    synced_at DATETIME NOT NULL
+);
+
+-- Per-workspace sync settings and bookmarks such as the remote folder ID
+CREATE TABLE IF NOT EXISTS sync_meta (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
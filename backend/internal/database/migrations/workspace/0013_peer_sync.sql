@@ -0,0 +1,22 @@
+-- Notes deleted for good. A peer that still has such a note learns it was
+-- deleted here rather than taking it for a note this copy never had.
+CREATE TABLE IF NOT EXISTS note_tombstones (
+    note_id TEXT PRIMARY KEY,
+    deleted_at DATETIME NOT NULL
+);
+
+-- Each paired device's version of a note as of the last sync with it: the
+-- hex SHA-256 of the note file, or '' once deleted on both sides
+CREATE TABLE IF NOT EXISTS peer_notes (
+    peer_id TEXT NOT NULL,
+    note_id TEXT NOT NULL,
+    hash TEXT NOT NULL,
+    PRIMARY KEY (peer_id, note_id)
+);
+
+-- The workspace on each paired device this workspace syncs with, so notes
+-- are never exchanged with a different workspace that happens to be open
+CREATE TABLE IF NOT EXISTS peer_links (
+    peer_id TEXT PRIMARY KEY,
+    workspace_id TEXT NOT NULL
+);
@@ -0,0 +1,13 @@
+-- Notes a sync merged with conflicts the user has yet to look at: fields
+-- both sides changed, or content kept as a conflict copy
+CREATE TABLE IF NOT EXISTS note_conflicts (
+    note_id TEXT PRIMARY KEY,
+    copy_id TEXT NOT NULL DEFAULT '',     -- the conflict copy, if one was made
+    conflicts TEXT NOT NULL DEFAULT '[]', -- JSON list of what conflicted
+    detected_at DATETIME NOT NULL
+);
+
+-- The note file as last synced, which the next sync merges both sides' edits
+-- from. NULL for attachments and for state recorded before merging existed.
+ALTER TABLE sync_files ADD COLUMN base BLOB;
+ALTER TABLE peer_notes ADD COLUMN base BLOB;
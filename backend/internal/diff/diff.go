@@ -0,0 +1,185 @@
+package diff
+
+import "strings"
+
+// Op is the kind of change a diff line represents
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+// Line is one line of a line-level diff. OldLine and NewLine are 1-based line
+// numbers in the old and new text; 0 means the line is absent from that side.
+type Line struct {
+	Op      Op     `json:"op"`
+	Text    string `json:"text"`
+	OldLine int    `json:"oldLine,omitempty"`
+	NewLine int    `json:"newLine,omitempty"`
+}
+
+// maxTraceCells bounds the memory used by the Myers trace. Inputs that would
+// need more are reported as a full replacement instead.
+const maxTraceCells = 16 << 20
+
+// SplitLines splits text into lines without their trailing newlines
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// Text computes a line-level diff between two texts
+func Text(oldText, newText string) []Line {
+	return Lines(SplitLines(oldText), SplitLines(newText))
+}
+
+// Lines computes a minimal line-level diff using Myers' algorithm
+func Lines(a, b []string) []Line {
+	// Common prefix and suffix never take part in an edit
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var out []Line
+	for i := 0; i < prefix; i++ {
+		out = append(out, Line{Op: Equal, Text: a[i], OldLine: i + 1, NewLine: i + 1})
+	}
+
+	middle := myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	for _, l := range middle {
+		if l.OldLine > 0 {
+			l.OldLine += prefix
+		}
+		if l.NewLine > 0 {
+			l.NewLine += prefix
+		}
+		out = append(out, l)
+	}
+
+	for i := suffix; i > 0; i-- {
+		ai, bi := len(a)-i, len(b)-i
+		out = append(out, Line{Op: Equal, Text: a[ai], OldLine: ai + 1, NewLine: bi + 1})
+	}
+
+	return out
+}
+
+// Stats counts inserted and deleted lines
+func Stats(lines []Line) (added, removed int) {
+	for _, l := range lines {
+		switch l.Op {
+		case Insert:
+			added++
+		case Delete:
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// myers runs the greedy O(ND) algorithm and backtracks through its trace
+func myers(a, b []string) []Line {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+
+	max := n + m
+	width := 2*max + 1
+	v := make([]int, width)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		if (d+1)*width > maxTraceCells {
+			return replaceAll(a, b)
+		}
+
+		snapshot := make([]int, width)
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y := x - k
+
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[max+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, max)
+			}
+		}
+	}
+
+	return replaceAll(a, b)
+}
+
+func backtrack(trace [][]int, a, b []string, max int) []Line {
+	var out []Line
+	x, y := len(a), len(b)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[max+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			out = append(out, Line{Op: Equal, Text: a[x-1], OldLine: x, NewLine: y})
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				out = append(out, Line{Op: Insert, Text: b[y-1], NewLine: y})
+			} else {
+				out = append(out, Line{Op: Delete, Text: a[x-1], OldLine: x})
+			}
+		}
+
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// replaceAll reports every old line as deleted and every new line as inserted
+func replaceAll(a, b []string) []Line {
+	out := make([]Line, 0, len(a)+len(b))
+	for i, s := range a {
+		out = append(out, Line{Op: Delete, Text: s, OldLine: i + 1})
+	}
+	for i, s := range b {
+		out = append(out, Line{Op: Insert, Text: s, NewLine: i + 1})
+	}
+	return out
+}
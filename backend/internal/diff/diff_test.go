@@ -0,0 +1,87 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+// apply rebuilds the new text from a diff, checking the old side along the way
+func apply(t *testing.T, old []string, lines []Line) []string {
+	t.Helper()
+
+	var out []string
+	i := 0
+	for _, l := range lines {
+		switch l.Op {
+		case Equal:
+			if old[i] != l.Text {
+				t.Fatalf("equal line %q does not match old line %q", l.Text, old[i])
+			}
+			out = append(out, l.Text)
+			i++
+		case Delete:
+			if old[i] != l.Text {
+				t.Fatalf("deleted line %q does not match old line %q", l.Text, old[i])
+			}
+			i++
+		case Insert:
+			out = append(out, l.Text)
+		}
+	}
+	if i != len(old) {
+		t.Fatalf("diff consumed %d of %d old lines", i, len(old))
+	}
+	return out
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name        string
+		old, new    string
+		wantAdded   int
+		wantRemoved int
+	}{
+		{name: "identical", old: "a\nb\nc", new: "a\nb\nc"},
+		{name: "both empty", old: "", new: ""},
+		{name: "insert into empty", old: "", new: "a\nb", wantAdded: 2},
+		{name: "delete all", old: "a\nb", new: "", wantRemoved: 2},
+		{name: "change middle line", old: "a\nb\nc", new: "a\nx\nc", wantAdded: 1, wantRemoved: 1},
+		{name: "append", old: "a\nb", new: "a\nb\nc", wantAdded: 1},
+		{name: "interleaved", old: "a\nb\nc\na\nb\nb\na", new: "c\nb\na\nb\na\nc", wantAdded: 2, wantRemoved: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := Text(tt.old, tt.new)
+
+			got := strings.Join(apply(t, SplitLines(tt.old), lines), "\n")
+			if got != tt.new {
+				t.Errorf("applied diff = %q, want %q", got, tt.new)
+			}
+
+			added, removed := Stats(lines)
+			if added != tt.wantAdded || removed != tt.wantRemoved {
+				t.Errorf("Stats() = +%d -%d, want +%d -%d", added, removed, tt.wantAdded, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestLineNumbers(t *testing.T) {
+	lines := Text("a\nb\nc", "a\nx\nc")
+
+	want := []Line{
+		{Op: Equal, Text: "a", OldLine: 1, NewLine: 1},
+		{Op: Delete, Text: "b", OldLine: 2},
+		{Op: Insert, Text: "x", NewLine: 2},
+		{Op: Equal, Text: "c", OldLine: 3, NewLine: 3},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: %+v", len(lines), len(want), lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %+v, want %+v", i, lines[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,442 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"html"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"fuknotion/backend/internal/folder"
+	"fuknotion/backend/internal/models"
+	"fuknotion/backend/internal/note"
+)
+
+// Format is the kind of file an export produces
+type Format string
+
+const (
+	FormatHTML        Format = "html"         // one standalone HTML page with embedded CSS
+	FormatPrintHTML   Format = "print-html"   // HTML laid out for printing to PDF, a page per note
+	FormatMarkdownZip Format = "markdown-zip" // a zip of markdown files mirroring the folder tree
+	FormatMarkdown    Format = "markdown"     // all notes concatenated into one markdown file
+)
+
+// Scope is what an export covers
+type Scope string
+
+const (
+	ScopeNote      Scope = "note"
+	ScopeFolder    Scope = "folder"
+	ScopeWorkspace Scope = "workspace"
+)
+
+// Request describes an export. ID names the note or folder and is ignored for
+// a workspace export, which is titled Title instead.
+type Request struct {
+	Scope  Scope  `json:"scope"`
+	ID     string `json:"id,omitempty"`
+	Format Format `json:"format"`
+	// KeepFrontmatter keeps each note's YAML frontmatter in a markdown zip
+	KeepFrontmatter bool   `json:"keepFrontmatter,omitempty"`
+	Title           string `json:"title,omitempty"`
+}
+
+// Output is a rendered export and the file name it should be saved under
+type Output struct {
+	Filename string `json:"filename"`
+	Data     []byte `json:"-"`
+}
+
+// Extension returns the file extension for a format, without the dot
+func (f Format) Extension() string {
+	switch f {
+	case FormatMarkdownZip:
+		return "zip"
+	case FormatMarkdown:
+		return "md"
+	default:
+		return "html"
+	}
+}
+
+// Service renders notes into exportable files
+type Service struct {
+	notes   *note.Service
+	folders *folder.Service
+}
+
+// NewService creates a new export service
+func NewService(notes *note.Service, folders *folder.Service) *Service {
+	return &Service{notes: notes, folders: folders}
+}
+
+// entry is an exported note and the folders it sits in, relative to the
+// exported folder
+type entry struct {
+	note *models.Note
+	dir  []string
+	file string // path within a markdown zip
+}
+
+// bundle is the set of notes being exported, in document order
+type bundle struct {
+	title   string
+	entries []*entry
+	byID    map[string]*entry
+
+	// resolve maps a wiki link target to a live note ID the way the link
+	// index does: an ID match wins, otherwise the newest note with that title
+	ids    map[string]bool
+	titles map[string]string
+}
+
+// Export renders the notes selected by req
+func (s *Service) Export(req Request) (*Output, error) {
+	switch req.Format {
+	case FormatHTML, FormatPrintHTML, FormatMarkdownZip, FormatMarkdown:
+	default:
+		return nil, fmt.Errorf("unknown export format: %s", req.Format)
+	}
+
+	b, err := s.collect(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	switch req.Format {
+	case FormatHTML:
+		data = b.html(false)
+	case FormatPrintHTML:
+		data = b.html(true)
+	case FormatMarkdownZip:
+		if data, err = b.zip(req.KeepFrontmatter); err != nil {
+			return nil, err
+		}
+	case FormatMarkdown:
+		data = b.markdown()
+	}
+
+	return &Output{Filename: fileName(b.title) + "." + req.Format.Extension(), Data: data}, nil
+}
+
+// collect loads the notes in scope, ordered as in the sidebar: a folder's
+// notes by position, then its subfolders depth first
+func (s *Service) collect(req Request) (*bundle, error) {
+	all, err := s.notes.ListNotes("")
+	if err != nil {
+		return nil, err
+	}
+
+	b := &bundle{
+		byID:   make(map[string]*entry),
+		ids:    make(map[string]bool, len(all)),
+		titles: make(map[string]string, len(all)),
+	}
+	newest := make(map[string]*models.Note)
+	byFolder := make(map[string][]*models.Note)
+	for _, n := range all {
+		b.ids[n.ID] = true
+		key := strings.ToLower(n.Title)
+		if cur, ok := newest[key]; !ok || n.UpdatedAt.After(cur.UpdatedAt) {
+			newest[key] = n
+			b.titles[key] = n.ID
+		}
+		byFolder[n.FolderID] = append(byFolder[n.FolderID], n)
+	}
+	for _, notes := range byFolder {
+		sort.SliceStable(notes, func(i, j int) bool {
+			if notes[i].Position != notes[j].Position {
+				return notes[i].Position < notes[j].Position
+			}
+			return strings.ToLower(notes[i].Title) < strings.ToLower(notes[j].Title)
+		})
+	}
+
+	var selected []*entry
+	var walk func(node *folder.TreeNode, dir []string)
+	walk = func(node *folder.TreeNode, dir []string) {
+		for _, n := range byFolder[node.ID] {
+			selected = append(selected, &entry{note: n, dir: dir})
+		}
+		for _, child := range node.Children {
+			walk(child, append(dir[:len(dir):len(dir)], child.Name))
+		}
+	}
+
+	switch req.Scope {
+	case ScopeNote:
+		n, err := s.notes.GetNote(req.ID)
+		if err != nil {
+			return nil, err
+		}
+		b.title = n.Title
+		selected = []*entry{{note: n}}
+
+	case ScopeFolder:
+		tree, err := s.folders.GetFolderTree()
+		if err != nil {
+			return nil, err
+		}
+		node := findFolder(tree, req.ID)
+		if node == nil {
+			return nil, fmt.Errorf("folder not found: %s", req.ID)
+		}
+		b.title = node.Name
+		walk(node, nil)
+
+	case ScopeWorkspace:
+		tree, err := s.folders.GetFolderTree()
+		if err != nil {
+			return nil, err
+		}
+		b.title = req.Title
+		walk(&folder.TreeNode{Folder: &models.Folder{}, Children: tree}, nil)
+
+	default:
+		return nil, fmt.Errorf("unknown export scope: %s", req.Scope)
+	}
+
+	if b.title == "" {
+		b.title = "Export"
+	}
+
+	// Listed notes carry no content; load each one in full
+	used := make(map[string]bool)
+	for _, e := range selected {
+		if e.note.Content == "" {
+			n, err := s.notes.GetNote(e.note.ID)
+			if err != nil {
+				return nil, err
+			}
+			e.note = n
+		}
+		e.file = uniquePath(used, e.dir, e.note.Title)
+		b.entries = append(b.entries, e)
+		b.byID[e.note.ID] = e
+	}
+
+	return b, nil
+}
+
+func findFolder(nodes []*folder.TreeNode, id string) *folder.TreeNode {
+	for _, node := range nodes {
+		if node.ID == id {
+			return node
+		}
+		if found := findFolder(node.Children, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// target returns the exported note a wiki link points at, or nil if it
+// points outside the export or nowhere
+func (b *bundle) target(link note.WikiLink) *entry {
+	id := link.Target
+	if !b.ids[id] {
+		id = b.titles[strings.ToLower(link.Target)]
+	}
+	return b.byID[id]
+}
+
+// rewriteLinks replaces the wiki links in content with markdown links built
+// by href. Links to notes outside the export become their plain text.
+func (b *bundle) rewriteLinks(content string, href func(from, to *entry, heading string) string, from *entry) string {
+	return note.ReplaceLinks(content, func(link note.WikiLink) (string, bool) {
+		text := link.Alias
+		if text == "" {
+			text = link.Target
+			if link.Heading != "" {
+				text += " > " + link.Heading
+			}
+		}
+		text = escapeLinkText(text)
+
+		to := b.target(link)
+		if to == nil {
+			return text, true
+		}
+		return "[" + text + "](" + href(from, to, link.Heading) + ")", true
+	})
+}
+
+// html renders the bundle as one page; print lays it out a note per page
+func (b *bundle) html(print bool) []byte {
+	var buf bytes.Buffer
+
+	css := baseCSS
+	if print {
+		css += printCSS
+	}
+	fmt.Fprintf(&buf, "<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n"+
+		"<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n"+
+		"<title>%s</title>\n<style>\n%s</style>\n</head>\n<body>\n", html.EscapeString(b.title), css)
+
+	if len(b.entries) > 1 {
+		buf.WriteString("<nav class=\"toc\">\n<h1>" + html.EscapeString(b.title) + "</h1>\n<ol>\n")
+		for _, e := range b.entries {
+			fmt.Fprintf(&buf, "<li><a href=\"#%s\">%s</a></li>\n", noteAnchor(e), html.EscapeString(e.note.Title))
+		}
+		buf.WriteString("</ol>\n</nav>\n")
+	}
+
+	href := func(_, to *entry, heading string) string {
+		if heading == "" {
+			return "#" + noteAnchor(to)
+		}
+		return "#" + noteAnchor(to) + "-" + Slug(heading)
+	}
+
+	for _, e := range b.entries {
+		content := b.rewriteLinks(e.note.Content, href, e)
+		fmt.Fprintf(&buf, "<article id=\"%s\">\n<h1 class=\"note-title\">%s</h1>\n", noteAnchor(e), html.EscapeString(e.note.Title))
+		buf.WriteString(newRenderer(noteAnchor(e) + "-").render(content))
+		buf.WriteString("</article>\n")
+	}
+
+	buf.WriteString("</body>\n</html>\n")
+	return buf.Bytes()
+}
+
+// zip writes each note to its own file in folders mirroring the tree, with
+// wiki links turned into relative links between the files
+func (b *bundle) zip(keepFrontmatter bool) ([]byte, error) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+
+	href := func(from, to *entry, heading string) string {
+		rel := relativePath(from.file, to.file)
+		if heading != "" {
+			rel += "#" + Slug(heading)
+		}
+		return rel
+	}
+
+	for _, e := range b.entries {
+		content := b.rewriteLinks(e.note.Content, href, e)
+		if keepFrontmatter {
+			fm := &note.Frontmatter{
+				ID:         e.note.ID,
+				Title:      e.note.Title,
+				Created:    e.note.CreatedAt,
+				Modified:   e.note.UpdatedAt,
+				FolderID:   e.note.FolderID,
+				IsFavorite: e.note.IsFavorite,
+				Pinned:     e.note.Pinned,
+				Tags:       e.note.Tags,
+			}
+			var err error
+			if content, err = note.SerializeNote(fm, content); err != nil {
+				return nil, err
+			}
+		}
+
+		f, err := w.CreateHeader(&zip.FileHeader{
+			Name:     e.file,
+			Method:   zip.Deflate,
+			Modified: e.note.UpdatedAt,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s to export: %w", e.file, err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			return nil, fmt.Errorf("failed to write %s to export: %w", e.file, err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish export archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// markdown concatenates the notes under their titles, with an anchor before
+// each so links between them keep working within the file
+func (b *bundle) markdown() []byte {
+	var buf bytes.Buffer
+
+	href := func(_, to *entry, _ string) string {
+		return "#" + noteAnchor(to)
+	}
+
+	for i, e := range b.entries {
+		if i > 0 {
+			buf.WriteString("\n\n---\n\n")
+		}
+		fmt.Fprintf(&buf, "<a id=\"%s\"></a>\n\n# %s\n\n", noteAnchor(e), e.note.Title)
+		buf.WriteString(strings.TrimSpace(b.rewriteLinks(e.note.Content, href, e)))
+		buf.WriteString("\n")
+	}
+
+	return buf.Bytes()
+}
+
+func noteAnchor(e *entry) string {
+	return "note-" + e.note.ID
+}
+
+// uniquePath returns the zip path for a note titled title in dir, numbering
+// it if another note in the same folder already took the name
+func uniquePath(used map[string]bool, dir []string, title string) string {
+	parts := make([]string, len(dir))
+	for i, name := range dir {
+		parts[i] = fileName(name)
+	}
+	base := path.Join(append(parts, fileName(title))...)
+
+	candidate := base + ".md"
+	for n := 2; used[strings.ToLower(candidate)]; n++ {
+		candidate = fmt.Sprintf("%s (%d).md", base, n)
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+// relativePath returns the URL-escaped path from the file at from to the file at to
+func relativePath(from, to string) string {
+	fromDir := strings.Split(path.Dir(from), "/")
+	toParts := strings.Split(to, "/")
+	if fromDir[0] == "." {
+		fromDir = nil
+	}
+
+	common := 0
+	for common < len(fromDir) && common < len(toParts)-1 && fromDir[common] == toParts[common] {
+		common++
+	}
+
+	var parts []string
+	for range fromDir[common:] {
+		parts = append(parts, "..")
+	}
+	for _, part := range toParts[common:] {
+		parts = append(parts, url.PathEscape(part))
+	}
+	return strings.Join(parts, "/")
+}
+
+// fileName makes a title safe to use as a file or folder name on any platform
+func fileName(title string) string {
+	name := strings.Map(func(r rune) rune {
+		if r < 32 || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '-'
+		}
+		return r
+	}, title)
+
+	name = strings.Trim(strings.TrimSpace(name), ".")
+	if name == "" {
+		return "Untitled"
+	}
+	return name
+}
+
+func escapeLinkText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`).Replace(text)
+}
@@ -0,0 +1,225 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"fuknotion/backend/internal/database"
+	"fuknotion/backend/internal/filesystem"
+	"fuknotion/backend/internal/folder"
+	"fuknotion/backend/internal/note"
+)
+
+func setupTestService(t *testing.T) (*Service, *note.Service, *folder.Service) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+
+	db, err := database.InitWorkspaceDB(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	fs, err := filesystem.NewFileSystem(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize filesystem: %v", err)
+	}
+
+	notes := note.NewService(db, fs)
+	folders := folder.NewService(db, notes)
+	return NewService(notes, folders), notes, folders
+}
+
+func readZip(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("failed to open zip: %v", err)
+	}
+
+	files := make(map[string]string)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", f.Name, err)
+		}
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(body)
+	}
+	return files
+}
+
+func TestExportMarkdownZip(t *testing.T) {
+	service, notes, folders := setupTestService(t)
+
+	work, _ := folders.CreateFolder("Work", "")
+	projects, _ := folders.CreateFolder("Projects", work.ID)
+	notes.CreateNote("Home", "See [[Plan#Next Steps]] and [[Missing]]", "")
+	notes.CreateNote("Plan", "## Next Steps\n\nBack to [[Home|home]]", projects.ID)
+	notes.CreateNote("Plan", "A second plan", projects.ID)
+
+	out, err := service.Export(Request{Scope: ScopeWorkspace, Format: FormatMarkdownZip, Title: "My Notes"})
+	if err != nil {
+		t.Fatalf("Export() failed: %v", err)
+	}
+	if out.Filename != "My Notes.zip" {
+		t.Errorf("Filename = %q, want %q", out.Filename, "My Notes.zip")
+	}
+
+	files := readZip(t, out.Data)
+	if len(files) != 3 {
+		t.Fatalf("zip has %d files, want 3: %v", len(files), files)
+	}
+
+	home, ok := files["Home.md"]
+	if !ok {
+		t.Fatalf("zip is missing Home.md: %v", files)
+	}
+	if strings.HasPrefix(home, "---") {
+		t.Errorf("Home.md kept its frontmatter")
+	}
+	// Either note titled Plan may be the newest; both live in Work/Projects
+	if !strings.Contains(home, "[Plan > Next Steps](Work/Projects/Plan") || !strings.Contains(home, ".md#next-steps)") {
+		t.Errorf("Home.md link not rewritten: %q", home)
+	}
+	if !strings.Contains(home, "and Missing") {
+		t.Errorf("broken link not turned into text: %q", home)
+	}
+
+	plan := files["Work/Projects/Plan.md"]
+	if !strings.Contains(plan, "[home](../../Home.md)") {
+		t.Errorf("Plan.md link not rewritten: %q", plan)
+	}
+	if _, ok := files["Work/Projects/Plan (2).md"]; !ok {
+		t.Errorf("duplicate title not numbered: %v", files)
+	}
+}
+
+func TestExportKeepFrontmatter(t *testing.T) {
+	service, notes, _ := setupTestService(t)
+
+	n, _ := notes.CreateNote("Tagged", "Body #idea", "")
+
+	out, err := service.Export(Request{Scope: ScopeNote, ID: n.ID, Format: FormatMarkdownZip, KeepFrontmatter: true})
+	if err != nil {
+		t.Fatalf("Export() failed: %v", err)
+	}
+
+	content := readZip(t, out.Data)["Tagged.md"]
+	fm, body, err := note.ParseMarkdown(content)
+	if err != nil {
+		t.Fatalf("exported note has no frontmatter: %v", err)
+	}
+	if fm.ID != n.ID || fm.Title != "Tagged" {
+		t.Errorf("frontmatter = %+v, want note %s", fm, n.ID)
+	}
+	if body != "Body #idea" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestExportFolderHTML(t *testing.T) {
+	service, notes, folders := setupTestService(t)
+
+	work, _ := folders.CreateFolder("Work", "")
+	archive, _ := folders.CreateFolder("Archive", work.ID)
+	a, _ := notes.CreateNote("Alpha", "# Intro\n\nLinks to [[Beta#Details]] and [[Outside]]", work.ID)
+	b, _ := notes.CreateNote("Beta", "## Details\n\n<script>x</script>", archive.ID)
+	notes.CreateNote("Outside", "Not exported", "")
+
+	for _, format := range []Format{FormatHTML, FormatPrintHTML} {
+		out, err := service.Export(Request{Scope: ScopeFolder, ID: work.ID, Format: format})
+		if err != nil {
+			t.Fatalf("Export(%s) failed: %v", format, err)
+		}
+		if out.Filename != "Work.html" {
+			t.Errorf("Filename = %q, want Work.html", out.Filename)
+		}
+
+		page := string(out.Data)
+		for _, want := range []string{
+			"<title>Work</title>",
+			`<article id="note-` + a.ID + `">`,
+			`<h1 id="note-` + a.ID + `-intro">Intro</h1>`,
+			`<a href="#note-` + b.ID + `-details">Beta &gt; Details</a>`,
+			`<h2 id="note-` + b.ID + `-details">Details</h2>`,
+			"and Outside",
+			"&lt;script&gt;",
+		} {
+			if !strings.Contains(page, want) {
+				t.Errorf("%s export is missing %q", format, want)
+			}
+		}
+		if strings.Contains(page, "Not exported") {
+			t.Errorf("%s export includes a note outside the folder", format)
+		}
+		if strings.Index(page, "note-"+a.ID) > strings.Index(page, "<article id=\"note-"+b.ID) {
+			t.Errorf("%s export puts subfolder notes first", format)
+		}
+		if hasPrint := strings.Contains(page, "@page"); hasPrint != (format == FormatPrintHTML) {
+			t.Errorf("%s export print styles = %v", format, hasPrint)
+		}
+	}
+}
+
+func TestExportConcatenatedMarkdown(t *testing.T) {
+	service, notes, _ := setupTestService(t)
+
+	a, _ := notes.CreateNote("One", "Go to [[Two]]", "")
+	b, _ := notes.CreateNote("Two", "---\n\nBody", "")
+
+	out, err := service.Export(Request{Scope: ScopeWorkspace, Format: FormatMarkdown})
+	if err != nil {
+		t.Fatalf("Export() failed: %v", err)
+	}
+	if out.Filename != "Export.md" {
+		t.Errorf("Filename = %q, want Export.md", out.Filename)
+	}
+
+	doc := string(out.Data)
+	for _, want := range []string{
+		`<a id="note-` + a.ID + `"></a>`,
+		"# One",
+		"[Two](#note-" + b.ID + ")",
+		`<a id="note-` + b.ID + `"></a>`,
+	} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("export is missing %q:\n%s", want, doc)
+		}
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	service, _, _ := setupTestService(t)
+
+	if _, err := service.Export(Request{Scope: ScopeWorkspace, Format: "docx"}); err == nil {
+		t.Error("Export() with unknown format succeeded")
+	}
+	if _, err := service.Export(Request{Scope: ScopeFolder, ID: "missing", Format: FormatHTML}); err == nil {
+		t.Error("Export() of missing folder succeeded")
+	}
+	if _, err := service.Export(Request{Scope: ScopeNote, ID: "missing", Format: FormatHTML}); err == nil {
+		t.Error("Export() of missing note succeeded")
+	}
+}
+
+func TestRelativePath(t *testing.T) {
+	tests := []struct{ from, to, want string }{
+		{"A.md", "B.md", "B.md"},
+		{"A.md", "Work/B c.md", "Work/B%20c.md"},
+		{"Work/A.md", "B.md", "../B.md"},
+		{"Work/X/A.md", "Work/Y/B.md", "../Y/B.md"},
+		{"Work/A.md", "Work/B.md", "B.md"},
+	}
+	for _, tt := range tests {
+		if got := relativePath(tt.from, tt.to); got != tt.want {
+			t.Errorf("relativePath(%q, %q) = %q, want %q", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,662 @@
+package export
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	fencePattern      = regexp.MustCompile("^ {0,3}(```+|~~~+)\\s*([^`\\s]*)")
+	atxHeadingPattern = regexp.MustCompile(`^ {0,3}(#{1,6})(?:\s+(.*?))?(?:\s+#+)?\s*$`)
+	setextPattern     = regexp.MustCompile(`^ {0,3}(=+|-+)\s*$`)
+	rulePattern       = regexp.MustCompile(`^ {0,3}(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	quotePattern      = regexp.MustCompile(`^ {0,3}> ?`)
+	bulletPattern     = regexp.MustCompile(`^( {0,3})([-*+])(\s+|$)`)
+	orderedPattern    = regexp.MustCompile(`^( {0,3})(\d{1,9})([.)])(\s+|$)`)
+	taskPattern       = regexp.MustCompile(`^\[([ xX])\]\s+`)
+	tableRulePattern  = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+)
+
+// renderer turns note markdown into HTML. Heading IDs are slugs prefixed with
+// idPrefix so several notes can share one document.
+type renderer struct {
+	idPrefix string
+	slugs    map[string]int
+}
+
+func newRenderer(idPrefix string) *renderer {
+	return &renderer{idPrefix: idPrefix, slugs: make(map[string]int)}
+}
+
+// RenderHTML renders markdown as an HTML fragment. Raw HTML in the source is
+// escaped rather than passed through, so exports are safe to open anywhere.
+func RenderHTML(markdown string) string {
+	return newRenderer("").render(markdown)
+}
+
+func (r *renderer) render(markdown string) string {
+	var b strings.Builder
+	r.blocks(&b, strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n"))
+	return b.String()
+}
+
+// blocks renders a sequence of lines as block elements
+func (r *renderer) blocks(b *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		if strings.TrimSpace(line) == "" {
+			i++
+			continue
+		}
+
+		if m := fencePattern.FindStringSubmatch(line); m != nil {
+			i = r.fence(b, lines, i, m[1], m[2])
+			continue
+		}
+
+		if m := atxHeadingPattern.FindStringSubmatch(line); m != nil {
+			r.heading(b, len(m[1]), m[2])
+			i++
+			continue
+		}
+
+		if rulePattern.MatchString(line) {
+			b.WriteString("<hr>\n")
+			i++
+			continue
+		}
+
+		if quotePattern.MatchString(line) {
+			var inner []string
+			for ; i < len(lines) && quotePattern.MatchString(lines[i]); i++ {
+				inner = append(inner, quotePattern.ReplaceAllString(lines[i], ""))
+			}
+			b.WriteString("<blockquote>\n")
+			r.blocks(b, inner)
+			b.WriteString("</blockquote>\n")
+			continue
+		}
+
+		if isListItem(line) {
+			i = r.list(b, lines, i)
+			continue
+		}
+
+		if i+1 < len(lines) && strings.Contains(line, "|") && tableRulePattern.MatchString(lines[i+1]) {
+			i = r.table(b, lines, i)
+			continue
+		}
+
+		// Paragraph: up to a blank line or the start of another block
+		start := i
+		for i++; i < len(lines); i++ {
+			next := lines[i]
+			if strings.TrimSpace(next) == "" || fencePattern.MatchString(next) || atxHeadingPattern.MatchString(next) ||
+				quotePattern.MatchString(next) || isListItem(next) {
+				break
+			}
+			if m := setextPattern.FindStringSubmatch(next); m != nil {
+				level := 2
+				if m[1][0] == '=' {
+					level = 1
+				}
+				r.heading(b, level, strings.Join(trimAll(lines[start:i]), " "))
+				start = -1
+				i++
+				break
+			}
+			if rulePattern.MatchString(next) {
+				break
+			}
+		}
+		if start >= 0 {
+			b.WriteString("<p>")
+			b.WriteString(r.paragraph(lines[start:i]))
+			b.WriteString("</p>\n")
+		}
+	}
+}
+
+// fence renders a fenced code block starting at lines[i] and returns the
+// index after it
+func (r *renderer) fence(b *strings.Builder, lines []string, i int, marker, lang string) int {
+	var code []string
+	for i++; i < len(lines); i++ {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), marker) && strings.Trim(strings.TrimSpace(lines[i]), marker[:1]) == "" {
+			i++
+			break
+		}
+		code = append(code, lines[i])
+	}
+
+	b.WriteString("<pre><code")
+	if lang != "" {
+		fmt.Fprintf(b, ` class="language-%s"`, html.EscapeString(lang))
+	}
+	b.WriteString(">")
+	b.WriteString(html.EscapeString(strings.Join(code, "\n")))
+	if len(code) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("</code></pre>\n")
+	return i
+}
+
+func (r *renderer) heading(b *strings.Builder, level int, text string) {
+	id := r.uniqueSlug(text)
+	fmt.Fprintf(b, `<h%d id="%s">%s</h%d>`+"\n", level, html.EscapeString(id), r.inline(text), level)
+}
+
+// list renders a bullet or ordered list starting at lines[i] and returns the
+// index after it
+func (r *renderer) list(b *strings.Builder, lines []string, i int) int {
+	ordered, start, _ := listMarker(lines[i])
+
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	b.WriteString("<" + tag)
+	if ordered && start != "1" {
+		fmt.Fprintf(b, ` start="%s"`, strings.TrimLeft(start, "0"))
+	}
+	b.WriteString(">\n")
+
+	for i < len(lines) {
+		itemOrdered, _, width := listMarker(lines[i])
+		if width == 0 || itemOrdered != ordered {
+			break
+		}
+
+		// The item's first line, then continuation lines indented past the
+		// marker, with blank lines allowed between them
+		item := []string{lines[i][width:]}
+		loose := false
+		for i++; i < len(lines); i++ {
+			next := lines[i]
+			if strings.TrimSpace(next) == "" {
+				if i+1 < len(lines) && indentOf(lines[i+1]) >= width {
+					item = append(item, "")
+					loose = true
+					continue
+				}
+				break
+			}
+			if indentOf(next) >= width {
+				item = append(item, next[width:])
+				continue
+			}
+			if isListItem(next) || fencePattern.MatchString(next) || atxHeadingPattern.MatchString(next) || quotePattern.MatchString(next) {
+				break
+			}
+			// A lazy continuation of the item's paragraph
+			item = append(item, strings.TrimSpace(next))
+		}
+
+		b.WriteString("<li>")
+		if m := taskPattern.FindStringSubmatch(item[0]); m != nil {
+			if m[1] == " " {
+				b.WriteString(`<input type="checkbox" disabled> `)
+			} else {
+				b.WriteString(`<input type="checkbox" checked disabled> `)
+			}
+			item[0] = item[0][len(m[0]):]
+		}
+
+		// A tight item holding only text is rendered without a <p>
+		if !loose && len(item) > 0 && !hasBlock(item[1:]) {
+			b.WriteString(r.paragraph(item))
+		} else {
+			b.WriteString("\n")
+			r.blocks(b, item)
+		}
+		b.WriteString("</li>\n")
+
+		// Skip blank lines between items of the same list
+		j := i
+		for j < len(lines) && strings.TrimSpace(lines[j]) == "" {
+			j++
+		}
+		if j > i && j < len(lines) {
+			if o, _, w := listMarker(lines[j]); w > 0 && o == ordered {
+				i = j
+			}
+		}
+	}
+
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// table renders a pipe table whose header is lines[i] and returns the index after it
+func (r *renderer) table(b *strings.Builder, lines []string, i int) int {
+	header := splitRow(lines[i])
+	var aligns []string
+	for _, cell := range splitRow(lines[i+1]) {
+		left, right := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			aligns = append(aligns, "center")
+		case right:
+			aligns = append(aligns, "right")
+		case left:
+			aligns = append(aligns, "left")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+
+	cell := func(tag, text string, col int) {
+		b.WriteString("<" + tag)
+		if col < len(aligns) && aligns[col] != "" {
+			fmt.Fprintf(b, ` style="text-align: %s"`, aligns[col])
+		}
+		b.WriteString(">" + r.inline(text) + "</" + tag + ">")
+	}
+
+	b.WriteString("<table>\n<thead>\n<tr>")
+	for col, text := range header {
+		cell("th", text, col)
+	}
+	b.WriteString("</tr>\n</thead>\n<tbody>\n")
+
+	for i += 2; i < len(lines) && strings.TrimSpace(lines[i]) != "" && strings.Contains(lines[i], "|"); i++ {
+		b.WriteString("<tr>")
+		row := splitRow(lines[i])
+		for col := range header {
+			text := ""
+			if col < len(row) {
+				text = row[col]
+			}
+			cell("td", text, col)
+		}
+		b.WriteString("</tr>\n")
+	}
+
+	b.WriteString("</tbody>\n</table>\n")
+	return i
+}
+
+// paragraph renders lines of a paragraph, honouring hard line breaks
+func (r *renderer) paragraph(lines []string) string {
+	var parts []string
+	for idx, line := range lines {
+		text := strings.TrimLeft(line, " \t")
+		hard := false
+		if idx < len(lines)-1 {
+			if strings.HasSuffix(text, "  ") {
+				hard = true
+			} else if strings.HasSuffix(text, "\\") && !strings.HasSuffix(text, "\\\\") {
+				text = strings.TrimSuffix(text, "\\")
+				hard = true
+			}
+		}
+		text = r.inline(strings.TrimRight(text, " \t"))
+		if hard {
+			text += "<br>"
+		}
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// inline renders inline markdown: code, links, images, emphasis and escapes
+func (r *renderer) inline(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '\\' && i+1 < len(runes) && isPunct(runes[i+1]):
+			i++
+			b.WriteString(html.EscapeString(string(runes[i])))
+
+		case c == '`':
+			n := runLength(runes, i, '`')
+			if end := findRun(runes, i+n, '`', n); end >= 0 {
+				code := strings.TrimSpace(string(runes[i+n : end]))
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i = end + n - 1
+			} else {
+				b.WriteString(strings.Repeat("`", n))
+				i += n - 1
+			}
+
+		case c == '!' && i+1 < len(runes) && runes[i+1] == '[':
+			if text, dest, title, end, ok := parseLink(runes, i+1); ok {
+				fmt.Fprintf(&b, `<img src="%s" alt="%s"`, html.EscapeString(safeURL(dest)), html.EscapeString(plain(text)))
+				if title != "" {
+					fmt.Fprintf(&b, ` title="%s"`, html.EscapeString(title))
+				}
+				b.WriteString(">")
+				i = end
+			} else {
+				b.WriteRune(c)
+			}
+
+		case c == '[':
+			if text, dest, title, end, ok := parseLink(runes, i); ok {
+				fmt.Fprintf(&b, `<a href="%s"`, html.EscapeString(safeURL(dest)))
+				if title != "" {
+					fmt.Fprintf(&b, ` title="%s"`, html.EscapeString(title))
+				}
+				b.WriteString(">" + r.inline(text) + "</a>")
+				i = end
+			} else {
+				b.WriteRune(c)
+			}
+
+		case c == '<':
+			if end := indexFrom(runes, i+1, '>'); end > 0 {
+				target := string(runes[i+1 : end])
+				if isAutolink(target) {
+					href := target
+					if strings.Contains(target, "@") && !strings.Contains(target, ":") {
+						href = "mailto:" + target
+					}
+					fmt.Fprintf(&b, `<a href="%s">%s</a>`, html.EscapeString(safeURL(href)), html.EscapeString(target))
+					i = end
+					continue
+				}
+			}
+			b.WriteString("&lt;")
+
+		case c == '*' || c == '_' || c == '~':
+			n := runLength(runes, i, c)
+			if c == '~' && n != 2 {
+				b.WriteString(strings.Repeat("~", n))
+				i += n - 1
+				continue
+			}
+			if n > 2 {
+				n = 2
+			}
+			// _ only emphasizes at word boundaries, so snake_case stays put
+			if c == '_' && i > 0 && isWordRune(runes[i-1]) {
+				b.WriteString(strings.Repeat("_", n))
+				i += n - 1
+				continue
+			}
+			end := findClosing(runes, i+n, c, n)
+			if end < 0 || end == i+n {
+				b.WriteString(html.EscapeString(strings.Repeat(string(c), n)))
+				i += n - 1
+				continue
+			}
+			tag := "em"
+			if c == '~' {
+				tag = "del"
+			} else if n == 2 {
+				tag = "strong"
+			}
+			b.WriteString("<" + tag + ">" + r.inline(string(runes[i+n:end])) + "</" + tag + ">")
+			i = end + n - 1
+
+		default:
+			b.WriteString(html.EscapeString(string(c)))
+		}
+	}
+
+	return b.String()
+}
+
+// parseLink parses [text](dest "title") starting at the '[' at runes[i] and
+// returns the index of the closing ')'
+func parseLink(runes []rune, i int) (text, dest, title string, end int, ok bool) {
+	depth := 0
+	close := -1
+	for j := i; j < len(runes); j++ {
+		switch runes[j] {
+		case '\\':
+			j++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				close = j
+			}
+		}
+		if close >= 0 {
+			break
+		}
+	}
+	if close < 0 || close+1 >= len(runes) || runes[close+1] != '(' {
+		return "", "", "", 0, false
+	}
+
+	end = indexFrom(runes, close+2, ')')
+	if end < 0 {
+		return "", "", "", 0, false
+	}
+
+	inside := strings.TrimSpace(string(runes[close+2 : end]))
+	dest = inside
+	if sp := strings.IndexAny(inside, " \t"); sp >= 0 {
+		rest := strings.TrimSpace(inside[sp:])
+		if len(rest) >= 2 && (rest[0] == '"' || rest[0] == '\'') && rest[len(rest)-1] == rest[0] {
+			dest, title = inside[:sp], rest[1:len(rest)-1]
+		}
+	}
+	dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+
+	return string(runes[i+1 : close]), dest, title, end, true
+}
+
+// findClosing finds a closing run of exactly n delimiters c after i that is
+// not preceded by a space
+func findClosing(runes []rune, i int, c rune, n int) int {
+	for j := i; j < len(runes); j++ {
+		switch runes[j] {
+		case '\\':
+			j++
+			continue
+		case '`':
+			// Skip code spans, whose contents are literal
+			m := runLength(runes, j, '`')
+			if end := findRun(runes, j+m, '`', m); end >= 0 {
+				j = end + m - 1
+				continue
+			}
+		}
+		if runes[j] != c {
+			continue
+		}
+		m := runLength(runes, j, c)
+		if m >= n && j > i && !unicode.IsSpace(runes[j-1]) {
+			if c == '_' && j+n < len(runes) && isWordRune(runes[j+n]) {
+				j += m - 1
+				continue
+			}
+			return j
+		}
+		j += m - 1
+	}
+	return -1
+}
+
+// uniqueSlug returns the heading ID for text, numbering repeats
+func (r *renderer) uniqueSlug(text string) string {
+	slug := Slug(plain(text))
+	if slug == "" {
+		slug = "section"
+	}
+	if n := r.slugs[slug]; n > 0 {
+		r.slugs[slug] = n + 1
+		slug = fmt.Sprintf("%s-%d", slug, n)
+	} else {
+		r.slugs[slug] = 1
+	}
+	return r.idPrefix + slug
+}
+
+// Slug turns heading text into an anchor the way GitHub does: lower case,
+// spaces to hyphens, punctuation dropped
+func Slug(text string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '-':
+			b.WriteRune(c)
+		case c == ' ':
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
+
+// plain strips inline markup for use in attributes
+func plain(s string) string {
+	replacer := strings.NewReplacer("**", "", "__", "", "*", "", "`", "", "~~", "")
+	return replacer.Replace(s)
+}
+
+// safeURL blocks script URLs in links and images
+func safeURL(u string) string {
+	lower := strings.ToLower(strings.TrimSpace(u))
+	for _, scheme := range []string{"javascript:", "vbscript:", "data:text/html"} {
+		if strings.HasPrefix(lower, scheme) {
+			return "#"
+		}
+	}
+	return u
+}
+
+func isAutolink(s string) bool {
+	if strings.ContainsAny(s, " \t<") {
+		return false
+	}
+	for _, scheme := range []string{"http://", "https://", "ftp://", "mailto:"} {
+		if strings.HasPrefix(strings.ToLower(s), scheme) {
+			return true
+		}
+	}
+	at := strings.Index(s, "@")
+	return at > 0 && strings.Contains(s[at:], ".")
+}
+
+func listMarker(line string) (ordered bool, start string, width int) {
+	if m := bulletPattern.FindStringSubmatch(line); m != nil && !rulePattern.MatchString(line) {
+		return false, "", markerWidth(len(m[0]), m[3])
+	}
+	if m := orderedPattern.FindStringSubmatch(line); m != nil {
+		return true, m[2], markerWidth(len(m[0]), m[4])
+	}
+	return false, "", 0
+}
+
+// markerWidth is the indent that content under a list marker needs. A marker
+// followed by a long run of spaces counts as followed by one.
+func markerWidth(matched int, spaces string) int {
+	if len(spaces) > 4 {
+		return matched - len(spaces) + 1
+	}
+	if spaces == "" {
+		return matched + 1
+	}
+	return matched
+}
+
+func isListItem(line string) bool {
+	_, _, width := listMarker(line)
+	return width > 0
+}
+
+func hasBlock(lines []string) bool {
+	for _, line := range lines {
+		if isListItem(line) || fencePattern.MatchString(line) || quotePattern.MatchString(line) || atxHeadingPattern.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+func indentOf(line string) int {
+	n := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+func trimAll(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = strings.TrimSpace(line)
+	}
+	return out
+}
+
+func runLength(runes []rune, i int, c rune) int {
+	n := 0
+	for i+n < len(runes) && runes[i+n] == c {
+		n++
+	}
+	return n
+}
+
+// findRun finds a run of exactly n copies of c at or after i
+func findRun(runes []rune, i int, c rune, n int) int {
+	for j := i; j < len(runes); j++ {
+		if runes[j] != c {
+			continue
+		}
+		m := runLength(runes, j, c)
+		if m == n {
+			return j
+		}
+		j += m - 1
+	}
+	return -1
+}
+
+func indexFrom(runes []rune, i int, c rune) int {
+	for j := i; j < len(runes); j++ {
+		if runes[j] == c {
+			return j
+		}
+	}
+	return -1
+}
+
+func isPunct(r rune) bool {
+	return r < 128 && (unicode.IsPunct(r) || unicode.IsSymbol(r))
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
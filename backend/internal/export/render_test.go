@@ -0,0 +1,67 @@
+package export
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{"paragraph", "Hello\nworld", "<p>Hello\nworld</p>\n"},
+		{"hard break", "one  \ntwo", "<p>one<br>\ntwo</p>\n"},
+		{"emphasis", "**bold** *it* _em_ ~~del~~ snake_case_name", "<p><strong>bold</strong> <em>it</em> <em>em</em> <del>del</del> snake_case_name</p>\n"},
+		{"code span", "run `a <b>` now", "<p>run <code>a &lt;b&gt;</code> now</p>\n"},
+		{"escapes", `\*not em\*`, "<p>*not em*</p>\n"},
+		{"link", `[docs](https://x.org "Docs")`, `<p><a href="https://x.org" title="Docs">docs</a></p>` + "\n"},
+		{"image", "![alt text](img.png)", `<p><img src="img.png" alt="alt text"></p>` + "\n"},
+		{"script link", "[x](javascript:alert(1))", `<p><a href="#">x</a>)</p>` + "\n"},
+		{"autolink", "<https://x.org>", `<p><a href="https://x.org">https://x.org</a></p>` + "\n"},
+		{"raw html escaped", "<script>alert(1)</script>", "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n"},
+		{"atx heading", "## Hello *World* ##", `<h2 id="hello-world">Hello <em>World</em></h2>` + "\n"},
+		{"setext heading", "Title\n=====", `<h1 id="title">Title</h1>` + "\n"},
+		{"duplicate headings", "# A\n# A", `<h1 id="a">A</h1>` + "\n" + `<h1 id="a-1">A</h1>` + "\n"},
+		{"rule", "a\n\n---\n\nb", "<p>a</p>\n<hr>\n<p>b</p>\n"},
+		{"fence", "```go\nx := <-ch\n```", `<pre><code class="language-go">x := &lt;-ch` + "\n</code></pre>\n"},
+		{"quote", "> quoted\n> > nested", "<blockquote>\n<p>quoted</p>\n<blockquote>\n<p>nested</p>\n</blockquote>\n</blockquote>\n"},
+		{"bullets", "- one\n- two", "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n"},
+		{"ordered", "3. three\n4. four", "<ol start=\"3\">\n<li>three</li>\n<li>four</li>\n</ol>\n"},
+		{"nested list", "- a\n  - b\n- c", "<ul>\n<li>\n<p>a</p>\n<ul>\n<li>b</li>\n</ul>\n</li>\n<li>c</li>\n</ul>\n"},
+		{"tasks", "- [ ] todo\n- [x] done", "<ul>\n<li><input type=\"checkbox\" disabled> todo</li>\n<li><input type=\"checkbox\" checked disabled> done</li>\n</ul>\n"},
+		{"table", "| a | b |\n|:--|--:|\n| 1 | 2 |",
+			"<table>\n<thead>\n<tr><th style=\"text-align: left\">a</th><th style=\"text-align: right\">b</th></tr>\n</thead>\n" +
+				"<tbody>\n<tr><td style=\"text-align: left\">1</td><td style=\"text-align: right\">2</td></tr>\n</tbody>\n</table>\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RenderHTML(tt.markdown); got != tt.want {
+				t.Errorf("RenderHTML(%q) =\n%s\nwant\n%s", tt.markdown, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlug(t *testing.T) {
+	tests := map[string]string{
+		"Hello World":     "hello-world",
+		"What's new?":     "whats-new",
+		"  API v2 (beta)": "api-v2-beta",
+		"日本語":             "日本語",
+	}
+	for in, want := range tests {
+		if got := Slug(in); got != want {
+			t.Errorf("Slug(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRenderHeadingPrefix(t *testing.T) {
+	got := newRenderer("note-1-").render("# Intro")
+	if !strings.Contains(got, `id="note-1-intro"`) {
+		t.Errorf("render() = %q, want prefixed heading id", got)
+	}
+}
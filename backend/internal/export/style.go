@@ -0,0 +1,60 @@
+package export
+
+// baseCSS styles exported HTML so it reads well without the app
+const baseCSS = `:root { color-scheme: light dark; }
+body {
+	max-width: 46rem;
+	margin: 0 auto;
+	padding: 2rem 1.25rem 4rem;
+	font: 16px/1.6 -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, "Helvetica Neue", Arial, sans-serif;
+	color: #1f2328;
+	background: #fff;
+}
+h1, h2, h3, h4, h5, h6 { line-height: 1.25; margin: 1.6em 0 0.6em; }
+h1 { font-size: 2em; }
+h2 { font-size: 1.5em; border-bottom: 1px solid #d8dee4; padding-bottom: 0.2em; }
+h3 { font-size: 1.25em; }
+p, ul, ol, blockquote, pre, table { margin: 0 0 1em; }
+a { color: #0969da; text-decoration: none; }
+a:hover { text-decoration: underline; }
+img { max-width: 100%; }
+hr { border: 0; border-top: 1px solid #d8dee4; margin: 2em 0; }
+code {
+	font: 0.875em/1.45 ui-monospace, SFMono-Regular, Menlo, Consolas, monospace;
+	background: #f6f8fa;
+	border-radius: 4px;
+	padding: 0.15em 0.35em;
+}
+pre { background: #f6f8fa; border-radius: 6px; padding: 0.9em 1em; overflow-x: auto; }
+pre code { background: none; padding: 0; }
+blockquote { color: #59636e; border-left: 4px solid #d8dee4; margin-left: 0; padding: 0 1em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #d8dee4; padding: 0.35em 0.8em; }
+th { background: #f6f8fa; }
+li > input[type="checkbox"] { margin-right: 0.4em; }
+.toc ol { padding-left: 1.5em; }
+article + article { margin-top: 3rem; border-top: 2px solid #d8dee4; }
+.note-title { margin-top: 1em; }
+@media (prefers-color-scheme: dark) {
+	body { color: #e6edf3; background: #0d1117; }
+	h2, hr, th, td, blockquote, article + article { border-color: #30363d; }
+	code, pre, th { background: #161b22; }
+	blockquote { color: #9198a1; }
+	a { color: #4493f8; }
+}
+`
+
+// printCSS lays exported HTML out for printing or saving as PDF: a page per
+// note, no dark theme, and link targets spelled out
+const printCSS = `@page { size: A4; margin: 2cm; }
+@media print {
+	:root { color-scheme: light; }
+	body { max-width: none; padding: 0; font-size: 11pt; color: #000; background: #fff; }
+	.toc { break-after: page; }
+	article + article { break-before: page; margin-top: 0; border-top: 0; }
+	h1, h2, h3, h4, h5, h6 { break-after: avoid; }
+	pre, blockquote, table, img { break-inside: avoid; }
+	pre { white-space: pre-wrap; }
+	a[href^="http"]::after { content: " (" attr(href) ")"; font-size: 0.85em; color: #555; }
+}
+`
@@ -22,6 +22,11 @@ func NewFileSystem(basePath string) (*FileSystem, error) {
 	return &FileSystem{basePath: basePath}, nil
 }
 
+// BasePath returns the root directory of the file system
+func (fs *FileSystem) BasePath() string {
+	return fs.basePath
+}
+
 // validatePath ensures the path is safe and within basePath
 func (fs *FileSystem) validatePath(path string) error {
 	// Clean the path
@@ -76,7 +81,25 @@ func (fs *FileSystem) ReadFile(relativePath string) ([]byte, error) {
 	return data, nil
 }
 
-// WriteFile writes data to a file
+// OpenFile opens a file for reading, for callers that stream it rather than
+// load it whole
+func (fs *FileSystem) OpenFile(relativePath string) (*os.File, error) {
+	fullPath, err := fs.ResolvePath(relativePath)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	return f, nil
+}
+
+// WriteFile atomically replaces a file's contents. Data goes to a temporary
+// file in the same directory, which is synced and then renamed over the
+// target, so a crash leaves either the old or the new file, never a torn one.
 func (fs *FileSystem) WriteFile(relativePath string, data []byte) error {
 	fullPath, err := fs.ResolvePath(relativePath)
 	if err != nil {
@@ -89,10 +112,34 @@ func (fs *FileSystem) WriteFile(relativePath string, data []byte) error {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	if err := os.WriteFile(fullPath, data, 0600); err != nil {
+	// A hidden name keeps the watcher and reindex away from the temporary file
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(fullPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
 		return fmt.Errorf("failed to write file: %w", err)
 	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, fullPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
 
+	syncDir(dir)
 	return nil
 }
 
@@ -110,6 +157,44 @@ func (fs *FileSystem) DeleteFile(relativePath string) error {
 	return nil
 }
 
+// MoveFile moves a file within the base directory, creating the destination directory
+func (fs *FileSystem) MoveFile(fromPath, toPath string) error {
+	fullFrom, err := fs.ResolvePath(fromPath)
+	if err != nil {
+		return err
+	}
+
+	fullTo, err := fs.ResolvePath(toPath)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullTo), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := os.Rename(fullFrom, fullTo); err != nil {
+		return fmt.Errorf("failed to move file: %w", err)
+	}
+
+	syncDir(filepath.Dir(fullTo))
+	if filepath.Dir(fullFrom) != filepath.Dir(fullTo) {
+		syncDir(filepath.Dir(fullFrom))
+	}
+	return nil
+}
+
+// syncDir flushes a directory entry so a rename survives a crash. It is best
+// effort: some platforms, Windows among them, cannot sync directories.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
 // ListFiles lists all files in a directory
 func (fs *FileSystem) ListFiles(relativePath string) ([]string, error) {
 	fullPath, err := fs.ResolvePath(relativePath)
@@ -132,6 +217,55 @@ func (fs *FileSystem) ListFiles(relativePath string) ([]string, error) {
 	return files, nil
 }
 
+// WalkFiles returns the paths, relative to the base directory, of all files
+// under relativePath. Hidden directories such as .git are skipped.
+func (fs *FileSystem) WalkFiles(relativePath string) ([]string, error) {
+	root, err := fs.ResolvePath(relativePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(fs.basePath, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory: %w", err)
+	}
+
+	return files, nil
+}
+
+// Stat returns file info for a file
+func (fs *FileSystem) Stat(relativePath string) (os.FileInfo, error) {
+	fullPath, err := fs.ResolvePath(relativePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Stat(fullPath)
+}
+
 // FileExists checks if a file exists
 func (fs *FileSystem) FileExists(relativePath string) bool {
 	fullPath, err := fs.ResolvePath(relativePath)
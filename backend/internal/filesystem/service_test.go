@@ -0,0 +1,56 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileReplacesAtomically(t *testing.T) {
+	tmpDir := t.TempDir()
+	fs, err := NewFileSystem(tmpDir)
+	if err != nil {
+		t.Fatalf("NewFileSystem() failed: %v", err)
+	}
+
+	path := filepath.Join("notes", "a.md")
+	if err := fs.WriteFile(path, []byte("first")); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+	if err := fs.WriteFile(path, []byte("second")); err != nil {
+		t.Fatalf("WriteFile() overwrite failed: %v", err)
+	}
+
+	data, err := fs.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	if string(data) != "second" {
+		t.Errorf("content = %q, want second", data)
+	}
+
+	info, err := os.Stat(filepath.Join(tmpDir, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("permissions = %o, want 600", perm)
+	}
+
+	// No temporary files are left behind
+	entries, _ := os.ReadDir(filepath.Join(tmpDir, "notes"))
+	if len(entries) != 1 {
+		t.Errorf("notes directory holds %d entries, want 1", len(entries))
+	}
+}
+
+func TestWriteFileRejectsEscapingPaths(t *testing.T) {
+	fs, err := NewFileSystem(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileSystem() failed: %v", err)
+	}
+
+	if err := fs.WriteFile("../outside.md", []byte("x")); err == nil {
+		t.Error("Expected error writing outside the base directory")
+	}
+}
@@ -0,0 +1,393 @@
+package folder
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"fuknotion/backend/internal/database"
+	"fuknotion/backend/internal/models"
+	"fuknotion/backend/internal/note"
+
+	"github.com/google/uuid"
+)
+
+// Service handles folder operations
+type Service struct {
+	db    *database.Database
+	notes *note.Service
+}
+
+// TreeNode is a folder together with its nested child folders
+type TreeNode struct {
+	*models.Folder
+	Children []*TreeNode `json:"children"`
+}
+
+// NewService creates a new folder service
+func NewService(db *database.Database, notes *note.Service) *Service {
+	return &Service{db: db, notes: notes}
+}
+
+// CreateFolder creates a folder at the end of its parent's children
+func (s *Service) CreateFolder(name, parentID string) (*models.Folder, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("folder name is required")
+	}
+
+	if parentID != "" {
+		if _, err := s.GetFolder(parentID); err != nil {
+			return nil, err
+		}
+	}
+
+	siblings, err := s.childIDs(parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	folder := &models.Folder{
+		ID:        uuid.New().String(),
+		Name:      name,
+		ParentID:  parentID,
+		Position:  len(siblings),
+		CreatedAt: time.Now(),
+	}
+
+	query := `INSERT INTO folders (id, name, parent_id, position, created_at) VALUES (?, ?, ?, ?, ?)`
+	_, err = s.db.Exec(query, folder.ID, folder.Name, nullable(parentID), folder.Position, folder.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert folder: %w", err)
+	}
+
+	return folder, nil
+}
+
+// GetFolder retrieves a folder by ID
+func (s *Service) GetFolder(id string) (*models.Folder, error) {
+	query := `SELECT id, name, parent_id, position, created_at FROM folders WHERE id = ?`
+
+	folder, err := scanFolder(s.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("folder not found: %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get folder: %w", err)
+	}
+
+	return folder, nil
+}
+
+// RenameFolder changes a folder's name
+func (s *Service) RenameFolder(id, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("folder name is required")
+	}
+
+	result, err := s.db.Exec(`UPDATE folders SET name = ? WHERE id = ?`, name, id)
+	if err != nil {
+		return fmt.Errorf("failed to rename folder: %w", err)
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("folder not found: %s", id)
+	}
+
+	return nil
+}
+
+// DeleteFolder deletes a folder and its subfolders. Notes inside the deleted
+// folders are moved to the workspace root rather than deleted.
+func (s *Service) DeleteFolder(id string) error {
+	folder, err := s.GetFolder(id)
+	if err != nil {
+		return err
+	}
+
+	subtree, err := s.subtreeIDs(id)
+	if err != nil {
+		return err
+	}
+
+	// Move notes out first so their frontmatter no longer points at the folder
+	noteIDs, err := s.noteIDsIn(subtree)
+	if err != nil {
+		return err
+	}
+	for _, noteID := range noteIDs {
+		if err := s.notes.MoveNote(noteID, ""); err != nil {
+			return fmt.Errorf("failed to move note %s out of folder: %w", noteID, err)
+		}
+	}
+
+	placeholders, args := inClause(subtree)
+	query := `DELETE FROM folders WHERE id IN (` + placeholders + `)`
+	if _, err := s.db.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to delete folder: %w", err)
+	}
+
+	// Close the gap left in the parent's ordering
+	siblings, err := s.childIDs(folder.ParentID)
+	if err != nil {
+		return err
+	}
+	return s.writePositions(siblings)
+}
+
+// MoveFolder reparents a folder and places it at position among its new siblings.
+// A negative position appends it at the end.
+func (s *Service) MoveFolder(id, newParentID string, position int) error {
+	folder, err := s.GetFolder(id)
+	if err != nil {
+		return err
+	}
+
+	if newParentID != "" {
+		if _, err := s.GetFolder(newParentID); err != nil {
+			return err
+		}
+
+		cycle, err := s.isDescendant(newParentID, id)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return fmt.Errorf("cannot move folder into itself or one of its subfolders")
+		}
+	}
+
+	if folder.ParentID != newParentID {
+		query := `UPDATE folders SET parent_id = ? WHERE id = ?`
+		if _, err := s.db.Exec(query, nullable(newParentID), id); err != nil {
+			return fmt.Errorf("failed to move folder: %w", err)
+		}
+
+		// Renumber the old parent's remaining children
+		oldSiblings, err := s.childIDs(folder.ParentID)
+		if err != nil {
+			return err
+		}
+		if err := s.writePositions(oldSiblings); err != nil {
+			return err
+		}
+	}
+
+	return s.place(id, newParentID, position)
+}
+
+// ReorderFolder moves a folder to position among its current siblings
+func (s *Service) ReorderFolder(id string, position int) error {
+	folder, err := s.GetFolder(id)
+	if err != nil {
+		return err
+	}
+
+	return s.place(id, folder.ParentID, position)
+}
+
+// ListFolders returns all folders ordered by parent and position
+func (s *Service) ListFolders() ([]*models.Folder, error) {
+	query := `
+		SELECT id, name, parent_id, position, created_at
+		FROM folders ORDER BY parent_id, position, name
+	`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list folders: %w", err)
+	}
+	defer rows.Close()
+
+	var folders []*models.Folder
+	for rows.Next() {
+		folder, err := scanFolder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan folder: %w", err)
+		}
+		folders = append(folders, folder)
+	}
+
+	return folders, rows.Err()
+}
+
+// GetFolderTree returns root folders with their children nested, in position order
+func (s *Service) GetFolderTree() ([]*TreeNode, error) {
+	folders, err := s.ListFolders()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[string]*TreeNode, len(folders))
+	for _, f := range folders {
+		nodes[f.ID] = &TreeNode{Folder: f, Children: []*TreeNode{}}
+	}
+
+	roots := []*TreeNode{}
+	for _, f := range folders {
+		node := nodes[f.ID]
+		if parent, ok := nodes[f.ParentID]; ok && f.ParentID != "" {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	return roots, nil
+}
+
+// place inserts a folder at position within parentID's ordered children
+func (s *Service) place(id, parentID string, position int) error {
+	siblings, err := s.childIDs(parentID)
+	if err != nil {
+		return err
+	}
+
+	ordered := make([]string, 0, len(siblings))
+	for _, sibling := range siblings {
+		if sibling != id {
+			ordered = append(ordered, sibling)
+		}
+	}
+
+	if position < 0 || position > len(ordered) {
+		position = len(ordered)
+	}
+
+	ordered = append(ordered, "")
+	copy(ordered[position+1:], ordered[position:])
+	ordered[position] = id
+
+	return s.writePositions(ordered)
+}
+
+// writePositions stores consecutive positions for the given folder IDs in one
+// transaction, so siblings are never left half renumbered
+func (s *Service) writePositions(ids []string) error {
+	return s.db.Transaction(func(tx *sql.Tx) error {
+		for i, id := range ids {
+			if _, err := tx.Exec(`UPDATE folders SET position = ? WHERE id = ?`, i, id); err != nil {
+				return fmt.Errorf("failed to update folder position: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// childIDs returns the IDs of a folder's direct children in position order
+func (s *Service) childIDs(parentID string) ([]string, error) {
+	var rows *sql.Rows
+	var err error
+	if parentID == "" {
+		rows, err = s.db.Query(`SELECT id FROM folders WHERE parent_id IS NULL ORDER BY position, name`)
+	} else {
+		rows, err = s.db.Query(`SELECT id FROM folders WHERE parent_id = ? ORDER BY position, name`, parentID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list child folders: %w", err)
+	}
+	defer rows.Close()
+
+	return scanIDs(rows)
+}
+
+// subtreeIDs returns a folder's ID followed by all of its descendants
+func (s *Service) subtreeIDs(id string) ([]string, error) {
+	query := `
+		WITH RECURSIVE subtree(id) AS (
+			SELECT id FROM folders WHERE id = ?
+			UNION
+			SELECT f.id FROM folders f JOIN subtree ON f.parent_id = subtree.id
+		)
+		SELECT id FROM subtree
+	`
+
+	rows, err := s.db.Query(query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect subfolders: %w", err)
+	}
+	defer rows.Close()
+
+	return scanIDs(rows)
+}
+
+// isDescendant reports whether folderID is ancestorID or lies beneath it
+func (s *Service) isDescendant(folderID, ancestorID string) (bool, error) {
+	subtree, err := s.subtreeIDs(ancestorID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, id := range subtree {
+		if id == folderID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// noteIDsIn returns the IDs of live notes stored directly in any of the given
+// folders. Trashed notes are detached by the foreign key and fixed up on restore.
+func (s *Service) noteIDsIn(folderIDs []string) ([]string, error) {
+	placeholders, args := inClause(folderIDs)
+
+	query := `SELECT id FROM notes WHERE deleted_at IS NULL AND folder_id IN (` + placeholders + `)`
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list folder notes: %w", err)
+	}
+	defer rows.Close()
+
+	return scanIDs(rows)
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanFolder(row rowScanner) (*models.Folder, error) {
+	var folder models.Folder
+	var parentID *string
+
+	if err := row.Scan(&folder.ID, &folder.Name, &parentID, &folder.Position, &folder.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	if parentID != nil {
+		folder.ParentID = *parentID
+	}
+
+	return &folder, nil
+}
+
+func scanIDs(rows *sql.Rows) ([]string, error) {
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// inClause builds "?, ?, ?" placeholders and matching args for an IN list
+func inClause(ids []string) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "), args
+}
+
+// nullable converts an empty ID to NULL to satisfy foreign key constraints
+func nullable(id string) interface{} {
+	if id == "" {
+		return nil
+	}
+	return id
+}
@@ -0,0 +1,220 @@
+package folder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fuknotion/backend/internal/database"
+	"fuknotion/backend/internal/filesystem"
+	"fuknotion/backend/internal/models"
+	"fuknotion/backend/internal/note"
+)
+
+func setupTestService(t *testing.T) (*Service, *note.Service, string) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+
+	db, err := database.InitWorkspaceDB(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	fs, err := filesystem.NewFileSystem(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize filesystem: %v", err)
+	}
+
+	notes := note.NewService(db, fs)
+	return NewService(db, notes), notes, tmpDir
+}
+
+// backdate makes a note look last touched, in the index and on disk, an hour ago
+func backdate(t *testing.T, service *Service, tmpDir string, n *models.Note) {
+	t.Helper()
+
+	hourAgo := time.Now().Add(-time.Hour)
+	if _, err := service.db.Exec(`UPDATE notes SET updated_at = ? WHERE id = ?`, hourAgo, n.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(tmpDir, n.FilePath), hourAgo, hourAgo); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateFolder(t *testing.T) {
+	service, _, _ := setupTestService(t)
+
+	root, err := service.CreateFolder("Work", "")
+	if err != nil {
+		t.Fatalf("CreateFolder() failed: %v", err)
+	}
+	if root.Position != 0 {
+		t.Errorf("Position = %d, want 0", root.Position)
+	}
+
+	second, err := service.CreateFolder("Personal", "")
+	if err != nil {
+		t.Fatalf("CreateFolder() failed: %v", err)
+	}
+	if second.Position != 1 {
+		t.Errorf("Position = %d, want 1", second.Position)
+	}
+
+	child, err := service.CreateFolder("Projects", root.ID)
+	if err != nil {
+		t.Fatalf("CreateFolder() child failed: %v", err)
+	}
+	if child.ParentID != root.ID {
+		t.Errorf("ParentID = %v, want %v", child.ParentID, root.ID)
+	}
+
+	if _, err := service.CreateFolder("  ", ""); err == nil {
+		t.Error("Expected error for empty name, got nil")
+	}
+	if _, err := service.CreateFolder("Orphan", "missing"); err == nil {
+		t.Error("Expected error for missing parent, got nil")
+	}
+}
+
+func TestRenameFolder(t *testing.T) {
+	service, _, _ := setupTestService(t)
+
+	folder, _ := service.CreateFolder("Old", "")
+	if err := service.RenameFolder(folder.ID, "New"); err != nil {
+		t.Fatalf("RenameFolder() failed: %v", err)
+	}
+
+	got, err := service.GetFolder(folder.ID)
+	if err != nil {
+		t.Fatalf("GetFolder() failed: %v", err)
+	}
+	if got.Name != "New" {
+		t.Errorf("Name = %v, want New", got.Name)
+	}
+
+	if err := service.RenameFolder("missing", "X"); err == nil {
+		t.Error("Expected error for missing folder, got nil")
+	}
+}
+
+func TestMoveFolderDetectsCycles(t *testing.T) {
+	service, _, _ := setupTestService(t)
+
+	a, _ := service.CreateFolder("A", "")
+	b, _ := service.CreateFolder("B", a.ID)
+	c, _ := service.CreateFolder("C", b.ID)
+
+	if err := service.MoveFolder(a.ID, c.ID, -1); err == nil {
+		t.Error("Expected error moving folder under its descendant, got nil")
+	}
+	if err := service.MoveFolder(a.ID, a.ID, -1); err == nil {
+		t.Error("Expected error moving folder under itself, got nil")
+	}
+
+	if err := service.MoveFolder(c.ID, "", 0); err != nil {
+		t.Fatalf("MoveFolder() to root failed: %v", err)
+	}
+
+	tree, err := service.GetFolderTree()
+	if err != nil {
+		t.Fatalf("GetFolderTree() failed: %v", err)
+	}
+	if len(tree) != 2 || tree[0].ID != c.ID || tree[1].ID != a.ID {
+		t.Fatalf("unexpected root order after move")
+	}
+	if len(tree[1].Children) != 1 || tree[1].Children[0].ID != b.ID {
+		t.Errorf("B should remain under A")
+	}
+}
+
+func TestReorderFolder(t *testing.T) {
+	service, _, _ := setupTestService(t)
+
+	a, _ := service.CreateFolder("A", "")
+	b, _ := service.CreateFolder("B", "")
+	c, _ := service.CreateFolder("C", "")
+
+	if err := service.ReorderFolder(c.ID, 0); err != nil {
+		t.Fatalf("ReorderFolder() failed: %v", err)
+	}
+
+	tree, _ := service.GetFolderTree()
+	want := []string{c.ID, a.ID, b.ID}
+	for i, node := range tree {
+		if node.ID != want[i] {
+			t.Errorf("tree[%d] = %v, want %v", i, node.Name, want[i])
+		}
+		if node.Position != i {
+			t.Errorf("tree[%d].Position = %d, want %d", i, node.Position, i)
+		}
+	}
+}
+
+func TestDeleteFolderMovesNotesToRoot(t *testing.T) {
+	service, notes, tmpDir := setupTestService(t)
+
+	parent, _ := service.CreateFolder("Parent", "")
+	child, _ := service.CreateFolder("Child", parent.ID)
+
+	n, err := notes.CreateNote("Nested", "content", child.ID)
+	if err != nil {
+		t.Fatalf("CreateNote() failed: %v", err)
+	}
+
+	backdate(t, service, tmpDir, n)
+	if err := service.DeleteFolder(parent.ID); err != nil {
+		t.Fatalf("DeleteFolder() failed: %v", err)
+	}
+	if drift, _ := notes.NeedsReindex(); drift {
+		t.Error("NeedsReindex() = true after DeleteFolder, want false")
+	}
+
+	if _, err := service.GetFolder(child.ID); err == nil {
+		t.Error("Subfolder still exists after deleting parent")
+	}
+
+	got, err := notes.GetNote(n.ID)
+	if err != nil {
+		t.Fatalf("GetNote() failed: %v", err)
+	}
+	if got.FolderID != "" {
+		t.Errorf("FolderID = %v, want empty", got.FolderID)
+	}
+}
+
+func TestMoveNoteUpdatesFrontmatter(t *testing.T) {
+	service, notes, tmpDir := setupTestService(t)
+
+	folder, _ := service.CreateFolder("Inbox", "")
+	n, _ := notes.CreateNote("Movable", "content", "")
+
+	backdate(t, service, tmpDir, n)
+	if err := notes.MoveNote(n.ID, folder.ID); err != nil {
+		t.Fatalf("MoveNote() failed: %v", err)
+	}
+	if drift, _ := notes.NeedsReindex(); drift {
+		t.Error("NeedsReindex() = true after MoveNote, want false")
+	}
+
+	got, _ := notes.GetNote(n.ID)
+	if got.FolderID != folder.ID {
+		t.Errorf("FolderID = %v, want %v", got.FolderID, folder.ID)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(tmpDir, got.FilePath))
+	if err != nil {
+		t.Fatalf("Failed to read note file: %v", err)
+	}
+	if !strings.Contains(string(raw), "folder_id: "+folder.ID) {
+		t.Error("frontmatter folder_id was not updated")
+	}
+
+	if err := notes.MoveNote(n.ID, "missing"); err == nil {
+		t.Error("Expected error moving note to missing folder, got nil")
+	}
+}
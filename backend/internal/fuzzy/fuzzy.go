@@ -0,0 +1,190 @@
+package fuzzy
+
+import (
+	"unicode"
+)
+
+// Range is a run of matched characters, as rune offsets [Start, End) into
+// the matched text
+type Range struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Result is a successful match. Higher scores are better matches.
+type Result struct {
+	Score  int     `json:"score"`
+	Ranges []Range `json:"ranges"`
+}
+
+// Scoring weights. Every matched character earns scoreMatch plus any bonus
+// for where it sits. A gap between matches costs scoreGapStart for its first
+// skipped character and scoreGapExtension for each one after that.
+const (
+	scoreMatch        = 16
+	scoreGapStart     = -3
+	scoreGapExtension = -1
+	scoreLeadingGap   = -1 // per character skipped before the first match
+	maxLeadingGap     = 8  // characters counted towards the leading gap penalty
+	bonusFirst        = 12 // the first character of the text
+	bonusBoundary     = 10 // the start of a word: after a space, -, _, / or .
+	bonusCamel        = 8  // an upper-case letter after a lower-case one, or a letter after a digit
+	bonusConsecutive  = 10 // immediately after the previous match
+	bonusCase         = 1  // same case as typed
+)
+
+// maxTextLen bounds the scoring table; longer texts are matched on their prefix
+const maxTextLen = 512
+
+// Match reports whether every non-space character of pattern appears in text
+// in order, ignoring case, and scores the best such alignment. Matches at
+// word starts, camel-case humps and in unbroken runs score higher, so
+// "qs" prefers "Quick Switcher" and "meetng" still finds "meeting".
+func Match(pattern, text string) (Result, bool) {
+	var p []rune
+	for _, r := range pattern {
+		if !unicode.IsSpace(r) {
+			p = append(p, r)
+		}
+	}
+	t := []rune(text)
+	if len(t) > maxTextLen {
+		t = t[:maxTextLen]
+	}
+	if len(p) == 0 {
+		return Result{Ranges: []Range{}}, true
+	}
+	if len(p) > len(t) || !isSubsequence(p, t) {
+		return Result{}, false
+	}
+
+	m, n := len(p), len(t)
+	bonus := make([]int, n)
+	for j := range t {
+		bonus[j] = positionBonus(t, j)
+	}
+
+	// score[i][j] is the best score for p[:i+1] with p[i] matched at t[j];
+	// from[i][j] is where p[i-1] was matched in that alignment
+	const none = -1 << 30
+	score := make([][]int, m)
+	from := make([][]int, m)
+	for i := range score {
+		score[i] = make([]int, n)
+		from[i] = make([]int, n)
+		for j := range score[i] {
+			score[i][j] = none
+		}
+	}
+
+	for j := 0; j < n; j++ {
+		if !equalFold(p[0], t[j]) {
+			continue
+		}
+		score[0][j] = scoreMatch + bonus[j] + caseBonus(p[0], t[j]) + scoreLeadingGap*min(j, maxLeadingGap)
+		from[0][j] = -1
+	}
+
+	for i := 1; i < m; i++ {
+		// best is the best score[i-1][k] over k < j-1 less the gap up to j,
+		// kept without its j term so it can be carried along as j grows
+		best, bestK := none, -1
+		for j := i; j < n; j++ {
+			if k := j - 2; k >= 0 && score[i-1][k] != none {
+				if v := score[i-1][k] - scoreGapExtension*(k+1); v > best {
+					best, bestK = v, k
+				}
+			}
+			if !equalFold(p[i], t[j]) {
+				continue
+			}
+
+			gain := scoreMatch + bonus[j] + caseBonus(p[i], t[j])
+			if prev := score[i-1][j-1]; prev != none {
+				score[i][j] = prev + gain + bonusConsecutive
+				from[i][j] = j - 1
+			}
+			if best != none {
+				gap := scoreGapStart - scoreGapExtension + scoreGapExtension*j
+				if v := best + gap + gain; v > score[i][j] {
+					score[i][j] = v
+					from[i][j] = bestK
+				}
+			}
+		}
+	}
+
+	end := -1
+	for j := 0; j < n; j++ {
+		if score[m-1][j] != none && (end < 0 || score[m-1][j] > score[m-1][end]) {
+			end = j
+		}
+	}
+	if end < 0 {
+		return Result{}, false
+	}
+
+	positions := make([]int, m)
+	for i, j := m-1, end; i >= 0; i-- {
+		positions[i] = j
+		j = from[i][j]
+	}
+
+	return Result{Score: score[m-1][end], Ranges: ranges(positions)}, true
+}
+
+// isSubsequence is a quick check before the full scoring pass
+func isSubsequence(p, t []rune) bool {
+	i := 0
+	for _, r := range t {
+		if i < len(p) && equalFold(p[i], r) {
+			i++
+		}
+	}
+	return i == len(p)
+}
+
+// positionBonus rewards matching t[j] where a word starts
+func positionBonus(t []rune, j int) int {
+	if j == 0 {
+		return bonusFirst
+	}
+	prev, cur := t[j-1], t[j]
+	switch {
+	case isSeparator(prev) && !isSeparator(cur):
+		return bonusBoundary
+	case unicode.IsLower(prev) && unicode.IsUpper(cur):
+		return bonusCamel
+	case unicode.IsDigit(prev) && unicode.IsLetter(cur):
+		return bonusCamel
+	}
+	return 0
+}
+
+func isSeparator(r rune) bool {
+	return unicode.IsSpace(r) || r == '-' || r == '_' || r == '/' || r == '.' || r == ':' || r == '(' || r == '['
+}
+
+func equalFold(a, b rune) bool {
+	return a == b || unicode.ToLower(a) == unicode.ToLower(b)
+}
+
+func caseBonus(a, b rune) int {
+	if a == b {
+		return bonusCase
+	}
+	return 0
+}
+
+// ranges merges sorted match positions into runs
+func ranges(positions []int) []Range {
+	var out []Range
+	for _, pos := range positions {
+		if len(out) > 0 && out[len(out)-1].End == pos {
+			out[len(out)-1].End++
+			continue
+		}
+		out = append(out, Range{Start: pos, End: pos + 1})
+	}
+	return out
+}
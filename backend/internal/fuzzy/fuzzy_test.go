@@ -0,0 +1,58 @@
+package fuzzy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+		ok      bool
+		ranges  []Range
+	}{
+		{"", "anything", true, []Range{}},
+		{"meet", "Meeting notes", true, []Range{{0, 4}}},
+		{"meetng notes", "Meeting notes", true, []Range{{0, 4}, {5, 7}, {8, 13}}},
+		{"qs", "QuickSwitcher", true, []Range{{0, 1}, {5, 6}}},
+		{"qs", "quick switcher", true, []Range{{0, 1}, {6, 7}}},
+		{"nts", "Meeting notes", true, []Range{{8, 9}, {10, 11}, {12, 13}}},
+		{"xyz", "Meeting notes", false, nil},
+		{"notesx", "notes", false, nil},
+		{"日記", "今日の日記", true, []Range{{3, 5}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"/"+tt.text, func(t *testing.T) {
+			got, ok := Match(tt.pattern, tt.text)
+			if ok != tt.ok {
+				t.Fatalf("Match() ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && !reflect.DeepEqual(got.Ranges, tt.ranges) {
+				t.Errorf("Ranges = %v, want %v", got.Ranges, tt.ranges)
+			}
+		})
+	}
+}
+
+func TestMatchPrefersWordStartsAndRuns(t *testing.T) {
+	better := []struct{ pattern, better, worse string }{
+		{"qs", "Quick Switcher", "questions"},
+		{"qs", "QuickSwitcher", "quicksort"},
+		{"note", "Notes", "Another note"},
+		{"note", "note", "n o t e"},
+		{"plan", "Plan 2026", "explanation"},
+	}
+
+	for _, tt := range better {
+		a, okA := Match(tt.pattern, tt.better)
+		b, okB := Match(tt.pattern, tt.worse)
+		if !okA || !okB {
+			t.Fatalf("%q should match both %q and %q", tt.pattern, tt.better, tt.worse)
+		}
+		if a.Score <= b.Score {
+			t.Errorf("%q: %q scored %d, want more than %q (%d)", tt.pattern, tt.better, a.Score, tt.worse, b.Score)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"fuknotion/backend/internal/attachment"
+	"fuknotion/backend/internal/note"
+)
+
+// maxSubject is how long a commit subject may grow before the remaining
+// changes are only counted
+const maxSubject = 72
+
+// change is a file staged for commit
+type change struct {
+	Status byte // A, M, D or R
+	Path   string
+	From   string // the old path of a rename
+}
+
+// CommitAll commits every change in the workspace with a message naming the
+// notes that changed. It returns the new commit's hash, or "" when there was
+// nothing to commit.
+func (r *Repo) CommitAll(ctx context.Context) (string, error) {
+	if _, err := r.run(ctx, "add", "--all"); err != nil {
+		return "", err
+	}
+
+	changes, err := r.staged(ctx)
+	if err != nil || len(changes) == 0 {
+		return "", err
+	}
+
+	subject, body := r.describe(ctx, changes)
+	args := []string{"commit", "--quiet", "--no-verify", "-m", subject}
+	if body != "" {
+		args = append(args, "-m", body)
+	}
+	if _, err := r.run(ctx, args...); err != nil {
+		return "", err
+	}
+	return r.Head(ctx)
+}
+
+// staged lists the changes in the index, with renames detected
+func (r *Repo) staged(ctx context.Context) ([]change, error) {
+	// Before the first commit this diffs against the empty tree
+	out, err := r.run(ctx, "diff", "--cached", "--name-status", "-z", "-M")
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []change
+	fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); {
+		status := fields[i]
+		if status == "" {
+			break
+		}
+		c := change{Status: status[0], Path: fields[i+1]}
+		i += 2
+		if (c.Status == 'R' || c.Status == 'C') && i < len(fields) {
+			c.From, c.Path = c.Path, fields[i]
+			i++
+		}
+		if c.Status == 'C' {
+			c.Status = 'A'
+		}
+		if c.Status == 'T' {
+			c.Status = 'M'
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+// describe writes a commit message for changes: a subject naming as many
+// notes as fit, and a body listing every change when there is more than one
+func (r *Repo) describe(ctx context.Context, changes []change) (string, string) {
+	var lines []string
+	var addedAttachments, removedAttachments int
+	for _, c := range changes {
+		switch {
+		case isAttachment(c.Path) && c.Status == 'D':
+			removedAttachments++
+		case isAttachment(c.Path):
+			addedAttachments++
+		default:
+			lines = append(lines, r.describeChange(ctx, c))
+		}
+	}
+	if addedAttachments > 0 {
+		lines = append(lines, "Add "+plural(addedAttachments, "attachment"))
+	}
+	if removedAttachments > 0 {
+		lines = append(lines, "Remove "+plural(removedAttachments, "attachment"))
+	}
+
+	subject := lines[0]
+	for i := 1; i < len(lines); i++ {
+		next := subject + ", " + lowerFirst(lines[i])
+		// Leave room to count the lines that come after this one
+		if left := len(lines) - i - 1; left > 0 {
+			next += fmt.Sprintf(" and %d more", left)
+		}
+		if len(next) > maxSubject {
+			subject += fmt.Sprintf(" and %d more", len(lines)-i)
+			break
+		}
+		subject += ", " + lowerFirst(lines[i])
+	}
+
+	if len(lines) == 1 {
+		return subject, ""
+	}
+	return subject, "- " + strings.Join(lines, "\n- ")
+}
+
+// describeChange is one line of a commit message, such as `Update "Plan"`
+func (r *Repo) describeChange(ctx context.Context, c change) string {
+	switch c.Status {
+	case 'A':
+		return "Add " + r.nameOf(ctx, ":0", c.Path)
+	case 'D':
+		return "Delete " + r.nameOf(ctx, "HEAD", c.Path)
+	}
+
+	from := c.Path
+	if c.Status == 'R' {
+		from = c.From
+	}
+	oldName, newName := r.nameOf(ctx, "HEAD", from), r.nameOf(ctx, ":0", c.Path)
+	if oldName != newName {
+		return fmt.Sprintf("Rename %s to %s", oldName, newName)
+	}
+	return "Update " + newName
+}
+
+// nameOf names the file at p as of rev: a note by its quoted title, a
+// template as such, anything else by its path
+func (r *Repo) nameOf(ctx context.Context, rev, p string) string {
+	if dir, file := path.Split(p); dir == note.TemplateDir+"/" {
+		return fmt.Sprintf("template %q", strings.TrimSuffix(file, path.Ext(file)))
+	}
+	if !strings.EqualFold(path.Ext(p), ".md") {
+		return p
+	}
+
+	data, err := r.Show(ctx, rev, p)
+	if err != nil {
+		return p
+	}
+	fm, _, err := note.ParseMarkdown(string(data))
+	if err != nil || fm.Title == "" {
+		return p
+	}
+	return fmt.Sprintf("%q", fm.Title)
+}
+
+func isAttachment(p string) bool {
+	return strings.HasPrefix(p, attachment.Dir+"/")
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,355 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"fuknotion/backend/internal/note"
+)
+
+// DefaultCommitWindow is how often pending changes are committed. Every save
+// made within one window lands in the same commit.
+const DefaultCommitWindow = 2 * time.Minute
+
+// History keeps a workspace's history in its git repository: it commits
+// changes as they are saved, and pulls and pushes them to the remote
+type History struct {
+	repo    *Repo
+	notes   *note.Service
+	window  time.Duration
+	onError func(error)
+	now     func() time.Time
+
+	mu   sync.Mutex // one git operation at a time; they share the index
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Conflict is a note both sides changed in ways that did not merge. Fields
+// both changed keep the local value; if the content clashed, the local
+// version stays in place and the remote one becomes a new note.
+type Conflict struct {
+	Path   string               `json:"path"`
+	NoteID string               `json:"noteId"`
+	CopyID string               `json:"copyId,omitempty"`
+	Title  string               `json:"title"` // title of the copy, if one was made
+	Hunks  []note.MergeConflict `json:"hunks,omitempty"`
+}
+
+// PullReport summarizes a pull
+type PullReport struct {
+	Merged    bool               `json:"merged"` // whether the remote had commits to bring in
+	Changes   []*note.FileChange `json:"changes"`
+	Conflicts []Conflict         `json:"conflicts"`
+}
+
+// NewHistory creates the history of the workspace whose notes are in repo.
+// onError, which may be nil, hears about failed automatic commits.
+func NewHistory(repo *Repo, notes *note.Service, onError func(error)) *History {
+	return &History{
+		repo:    repo,
+		notes:   notes,
+		window:  DefaultCommitWindow,
+		onError: onError,
+		now:     time.Now,
+	}
+}
+
+// Repo returns the workspace's repository
+func (h *History) Repo() *Repo {
+	return h.repo
+}
+
+// Start begins committing pending changes once per window
+func (h *History) Start() {
+	h.stop = make(chan struct{})
+	h.done = make(chan struct{})
+
+	go func() {
+		defer close(h.done)
+		ticker := time.NewTicker(h.window)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-h.stop:
+				return
+			case <-ticker.C:
+				if _, err := h.Commit(context.Background()); err != nil && h.onError != nil {
+					h.onError(err)
+				}
+			}
+		}
+	}()
+}
+
+// Close stops committing and commits whatever is still pending
+func (h *History) Close() error {
+	if h.stop != nil {
+		close(h.stop)
+		<-h.done
+		h.stop = nil
+	}
+	_, err := h.Commit(context.Background())
+	return err
+}
+
+// Commit commits pending changes now. It returns the commit's hash, or ""
+// when nothing changed.
+func (h *History) Commit(ctx context.Context) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.repo.CommitAll(ctx)
+}
+
+// Pull commits pending changes, then merges the remote's. Where both sides
+// changed the same part of a note, the local version is kept and the remote
+// one is added as a conflict copy, so merges never stop half way.
+func (h *History) Pull(ctx context.Context) (*PullReport, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	branch, err := h.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := h.repo.run(ctx, "fetch", "--quiet", RemoteName); err != nil {
+		return nil, fmt.Errorf("failed to fetch: %w", err)
+	}
+	upstream := "refs/remotes/" + RemoteName + "/" + branch
+	if _, err := h.repo.run(ctx, "rev-parse", "--verify", "--quiet", upstream); err != nil {
+		// Nothing has been pushed to the branch yet
+		if isExit(err, 1) {
+			return &PullReport{}, nil
+		}
+		return nil, err
+	}
+
+	before, err := h.repo.Head(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PullReport{}
+	_, err = h.repo.run(ctx, "merge", "--quiet", "--no-edit", "--no-verify", "--allow-unrelated-histories",
+		"-m", "Merge changes from "+RemoteName, upstream)
+	if err != nil {
+		if !isExit(err, 1) {
+			return nil, fmt.Errorf("failed to merge: %w", err)
+		}
+		if err := h.resolve(ctx, report); err != nil {
+			h.repo.run(ctx, "merge", "--abort")
+			return nil, err
+		}
+	}
+
+	after, err := h.repo.Head(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if after == before {
+		return report, nil
+	}
+	report.Merged = true
+
+	if err := h.reindex(ctx, before, after, report); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// Push commits pending changes and pushes them to the remote. It fails when
+// the remote has commits not pulled yet.
+func (h *History) Push(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	branch, err := h.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	if head, err := h.repo.Head(ctx); err != nil || head == "" {
+		return err
+	}
+
+	if _, err := h.repo.run(ctx, "push", "--quiet", RemoteName, "HEAD:refs/heads/"+branch); err != nil {
+		if strings.Contains(err.Error(), "rejected") {
+			return fmt.Errorf("the remote has changes that are not here yet; pull first")
+		}
+		return fmt.Errorf("failed to push: %w", err)
+	}
+	return nil
+}
+
+// Sync pulls the remote's changes and pushes the merged result
+func (h *History) Sync(ctx context.Context) (*PullReport, error) {
+	report, err := h.Pull(ctx)
+	if err != nil {
+		return report, err
+	}
+	return report, h.Push(ctx)
+}
+
+// prepare checks a remote is set and commits pending changes, returning the
+// branch to sync
+func (h *History) prepare(ctx context.Context) (string, error) {
+	remote, err := h.repo.Remote(ctx)
+	if err != nil {
+		return "", err
+	}
+	if remote == "" {
+		return "", fmt.Errorf("no git remote is configured")
+	}
+	if _, err := h.repo.CommitAll(ctx); err != nil {
+		return "", fmt.Errorf("failed to commit pending changes: %w", err)
+	}
+	return h.repo.Branch(ctx)
+}
+
+// resolve settles every conflicted file of a stopped merge and commits it
+func (h *History) resolve(ctx context.Context, report *PullReport) error {
+	out, err := h.repo.run(ctx, "ls-files", "--unmerged", "-z")
+	if err != nil {
+		return err
+	}
+
+	// Entries are "<mode> <hash> <stage>\t<path>"; 2 is ours and 3 theirs
+	stages := make(map[string]map[string]bool)
+	var paths []string
+	for _, entry := range strings.Split(out, "\x00") {
+		info, p, ok := strings.Cut(entry, "\t")
+		fields := strings.Fields(info)
+		if !ok || len(fields) != 3 {
+			continue
+		}
+		if stages[p] == nil {
+			stages[p] = make(map[string]bool)
+			paths = append(paths, p)
+		}
+		stages[p][fields[2]] = true
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("merge stopped without conflicts")
+	}
+
+	for _, p := range paths {
+		ours, theirs := stages[p]["2"], stages[p]["3"]
+		switch {
+		case ours && theirs && isNote(p):
+			if err := h.reconcile(ctx, p, report); err != nil {
+				return err
+			}
+		case ours:
+			// Keep our version, including edits to a file they deleted
+			if _, err := h.repo.run(ctx, "checkout", "--ours", "--", p); err != nil {
+				return err
+			}
+		default:
+			// We deleted what they changed; their edits win
+			if _, err := h.repo.run(ctx, "checkout", "--theirs", "--", p); err != nil {
+				return err
+			}
+		}
+		if _, err := h.repo.run(ctx, "add", "--", p); err != nil {
+			return err
+		}
+	}
+
+	// Conflict copies are new files
+	if _, err := h.repo.run(ctx, "add", "--all"); err != nil {
+		return err
+	}
+	if _, err := h.repo.run(ctx, "commit", "--quiet", "--no-edit", "--no-verify"); err != nil {
+		return fmt.Errorf("failed to commit merge: %w", err)
+	}
+	return nil
+}
+
+// reconcile merges both sides' versions of a conflicted note. Every save
+// rewrites the modified time, so git sees a conflict in the frontmatter even
+// when the edits do not overlap; the note package merges the frontmatter field
+// by field and the body line by line. Where the bodies clash, our version
+// stays in place and theirs becomes a conflict copy.
+func (h *History) reconcile(ctx context.Context, p string, report *PullReport) error {
+	ours, err := h.repo.Show(ctx, ":2", p)
+	if err != nil {
+		return err
+	}
+	theirs, err := h.repo.Show(ctx, ":3", p)
+	if err != nil {
+		return err
+	}
+	// Added on both sides, there is no base to merge from
+	base, err := h.repo.Show(ctx, ":1", p)
+	if err != nil {
+		base = nil
+	}
+
+	label := "conflict copy " + h.now().Format("2006-01-02 15:04")
+	result, err := h.notes.Reconcile(base, ours, theirs, label)
+	if err != nil {
+		return fmt.Errorf("failed to merge %s: %w", p, err)
+	}
+	if err := os.WriteFile(filepath.Join(h.repo.dir, filepath.FromSlash(p)), result.Data, 0600); err != nil {
+		return fmt.Errorf("failed to write merged %s: %w", p, err)
+	}
+	if result.Copy == nil && len(result.Conflicts) == 0 {
+		return nil
+	}
+
+	conflict := Conflict{Path: p, Hunks: result.Conflicts}
+	if fm, _, err := note.ParseMarkdown(string(result.Data)); err == nil {
+		conflict.NoteID, conflict.Title = fm.ID, fm.Title
+	}
+	if conflict.NoteID == "" {
+		conflict.NoteID = strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+	}
+	if result.Copy != nil {
+		conflict.CopyID, conflict.Title = result.Copy.ID, result.Copy.Title
+	}
+	report.Conflicts = append(report.Conflicts, conflict)
+	return nil
+}
+
+// reindex brings the index in line with the notes a merge changed
+func (h *History) reindex(ctx context.Context, before, after string, report *PullReport) error {
+	// Without a commit before, everything the merge brought in is new
+	args := []string{"ls-tree", "-r", "--name-only", "-z", after}
+	if before != "" {
+		args = []string{"diff", "--name-only", "-z", "--no-renames", before, after}
+	}
+	out, err := h.repo.run(ctx, args...)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, p := range strings.Split(out, "\x00") {
+		if !isNote(p) {
+			continue
+		}
+		change, err := h.notes.SyncFile(p)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", p, err))
+			continue
+		}
+		if change != nil {
+			report.Changes = append(report.Changes, change)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to index merged notes: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// isNote reports whether p is a note file
+func isNote(p string) bool {
+	return strings.HasPrefix(p, "notes/") && strings.EqualFold(filepath.Ext(p), ".md")
+}
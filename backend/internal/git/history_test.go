@@ -0,0 +1,312 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fuknotion/backend/internal/attachment"
+	"fuknotion/backend/internal/database"
+	"fuknotion/backend/internal/filesystem"
+	"fuknotion/backend/internal/note"
+)
+
+// workspace is one copy of a workspace kept in git
+type workspace struct {
+	dir         string
+	notes       *note.Service
+	attachments *attachment.Store
+	history     *History
+}
+
+func newWorkspace(t *testing.T, remote string) *workspace {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	// Keep the developer's git configuration out of the tests
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	dir := t.TempDir()
+	db, err := database.InitWorkspaceDB(dir)
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	fs, err := filesystem.NewFileSystem(dir)
+	if err != nil {
+		t.Fatalf("Failed to initialize filesystem: %v", err)
+	}
+
+	repo, err := Init(context.Background(), dir, Identity{Name: "Ada", Email: "ada@example.com"})
+	if err != nil {
+		t.Fatalf("Init() failed: %v", err)
+	}
+	if remote != "" {
+		if err := repo.SetRemote(context.Background(), remote); err != nil {
+			t.Fatalf("SetRemote() failed: %v", err)
+		}
+	}
+
+	notes := note.NewService(db, fs)
+	return &workspace{
+		dir:         dir,
+		notes:       notes,
+		attachments: attachment.NewStore(db, fs),
+		history:     NewHistory(repo, notes, nil),
+	}
+}
+
+// newRemote creates a bare repository to push to
+func newRemote(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "init", "--quiet", "--bare", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init --bare failed: %v: %s", err, out)
+	}
+	return dir
+}
+
+func (w *workspace) commit(t *testing.T) *Commit {
+	t.Helper()
+
+	hash, err := w.history.Commit(context.Background())
+	if err != nil {
+		t.Fatalf("Commit() failed: %v", err)
+	}
+	if hash == "" {
+		return nil
+	}
+	log, err := w.history.Repo().Log(context.Background(), "", 1)
+	if err != nil || len(log) != 1 || log[0].Hash != hash {
+		t.Fatalf("Log() = %+v, %v; want the new commit", log, err)
+	}
+	return log[0]
+}
+
+func (w *workspace) tracked(t *testing.T) []string {
+	t.Helper()
+
+	out, err := w.history.Repo().run(context.Background(), "ls-files")
+	if err != nil {
+		t.Fatalf("git ls-files failed: %v", err)
+	}
+	return strings.Fields(out)
+}
+
+func TestCommitMessagesNameNotes(t *testing.T) {
+	ws := newWorkspace(t, "")
+	// A migration left a backup of the database behind
+	if err := os.WriteFile(filepath.Join(ws.dir, "workspace.db.v1.bak"), []byte("SQLite format 3"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, _ := ws.notes.CreateNote("Plan", "First draft", "")
+	if c := ws.commit(t); c == nil || c.Subject != `Add .gitignore, add "Plan"` {
+		t.Errorf("first commit = %+v", c)
+	}
+	if c := ws.commit(t); c != nil {
+		t.Errorf("commit without changes = %+v, want none", c)
+	}
+
+	ws.notes.UpdateNote(plan.ID, "Plan", "Second draft", false)
+	if c := ws.commit(t); c == nil || c.Subject != `Update "Plan"` || c.Body != "" {
+		t.Errorf("edit commit = %+v", c)
+	}
+
+	ws.notes.UpdateNote(plan.ID, "Roadmap", "Second draft", false)
+	image, _ := ws.attachments.Save("chart.png", []byte("\x89PNG\r\n\x1a\n chart"))
+	ws.notes.CreateNote("Ideas", "![chart]("+image.URL+")", "")
+	c := ws.commit(t)
+	if c == nil || !strings.Contains(strings.ToLower(c.Subject), `rename "plan" to "roadmap"`) {
+		t.Fatalf("rename commit = %+v", c)
+	}
+	for _, line := range []string{`- Add "Ideas"`, `- Rename "Plan" to "Roadmap"`, "- Add 1 attachment"} {
+		if !strings.Contains(c.Body, line) {
+			t.Errorf("body %q is missing %q", c.Body, line)
+		}
+	}
+
+	ws.notes.DeleteNote(plan.ID)
+	if c := ws.commit(t); c == nil || c.Subject != `Delete "Roadmap"` {
+		t.Errorf("delete commit = %+v", c)
+	}
+
+	// Derived and local files stay out of the repository
+	for _, p := range ws.tracked(t) {
+		if strings.HasPrefix(p, "workspace.db") || strings.HasPrefix(p, ".trash/") {
+			t.Errorf("%s is tracked", p)
+		}
+	}
+}
+
+func TestCommitSubjectStaysShort(t *testing.T) {
+	ws := newWorkspace(t, "")
+	ws.commit(t)
+
+	for i := 0; i < 8; i++ {
+		ws.notes.CreateNote(strings.Repeat("Quarterly planning ", 2), "", "")
+	}
+	c := ws.commit(t)
+	if c == nil || len(c.Subject) > maxSubject || !strings.HasSuffix(c.Subject, " more") {
+		t.Errorf("subject = %q, want at most %d characters counting the rest", c.Subject, maxSubject)
+	}
+	if lines := strings.Count(c.Body, "\n") + 1; lines != 8 {
+		t.Errorf("body lists %d changes, want 8", lines)
+	}
+}
+
+func TestAutoCommit(t *testing.T) {
+	ws := newWorkspace(t, "")
+	ws.history.window = 20 * time.Millisecond
+	ws.history.Start()
+
+	ws.notes.CreateNote("Plan", "Draft", "")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		log, _ := ws.history.Repo().Log(context.Background(), "", 10)
+		if len(log) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("nothing was committed automatically")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Whatever is still pending is committed on close
+	ws.notes.CreateNote("Ideas", "", "")
+	if err := ws.history.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if c := ws.commit(t); c != nil {
+		t.Errorf("commit after Close() = %+v, want nothing left", c)
+	}
+}
+
+func TestPullAndPush(t *testing.T) {
+	remote := newRemote(t)
+	laptop, desktop := newWorkspace(t, remote), newWorkspace(t, remote)
+	ctx := context.Background()
+
+	plan, _ := laptop.notes.CreateNote("Plan", "One\n\nTwo\n\nThree", "")
+	if err := laptop.history.Push(ctx); err != nil {
+		t.Fatalf("Push() failed: %v", err)
+	}
+
+	report, err := desktop.history.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull() failed: %v", err)
+	}
+	if !report.Merged || len(report.Changes) != 1 || report.Changes[0].NoteID != plan.ID {
+		t.Errorf("Pull() = %+v, want the laptop's note", report)
+	}
+	if got, err := desktop.notes.GetNote(plan.ID); err != nil || got.Content != plan.Content {
+		t.Errorf("desktop note = %+v, %v", got, err)
+	}
+
+	// Edits to different parts of the note merge
+	desktop.notes.UpdateNote(plan.ID, "Plan", "One (desktop)\n\nTwo\n\nThree", false)
+	if err := desktop.history.Push(ctx); err != nil {
+		t.Fatalf("Push() failed: %v", err)
+	}
+	laptop.notes.UpdateNote(plan.ID, "Plan", "One\n\nTwo\n\nThree (laptop)", false)
+	if err := laptop.history.Push(ctx); err == nil {
+		t.Error("Push() succeeded without pulling the desktop's change")
+	}
+	if _, err := laptop.history.Sync(ctx); err != nil {
+		t.Fatalf("Sync() failed: %v", err)
+	}
+
+	if _, err := desktop.history.Pull(ctx); err != nil {
+		t.Fatalf("Pull() failed: %v", err)
+	}
+	for _, ws := range []*workspace{laptop, desktop} {
+		got, _ := ws.notes.GetNote(plan.ID)
+		if got == nil || !strings.Contains(got.Content, "One (desktop)") || !strings.Contains(got.Content, "Three (laptop)") {
+			t.Errorf("merged note = %+v, want both edits", got)
+		}
+	}
+}
+
+func TestPullConflictKeepsBothVersions(t *testing.T) {
+	remote := newRemote(t)
+	laptop, desktop := newWorkspace(t, remote), newWorkspace(t, remote)
+	ctx := context.Background()
+
+	plan, _ := laptop.notes.CreateNote("Plan", "Original", "")
+	laptop.history.Push(ctx)
+	desktop.history.Pull(ctx)
+
+	laptop.notes.UpdateNote(plan.ID, "Plan", "Laptop edit", false)
+	laptop.history.Push(ctx)
+	desktop.notes.UpdateNote(plan.ID, "Plan", "Desktop edit", false)
+
+	report, err := desktop.history.Sync(ctx)
+	if err != nil {
+		t.Fatalf("Sync() failed: %v", err)
+	}
+	if len(report.Conflicts) != 1 {
+		t.Fatalf("Conflicts = %+v, want 1", report.Conflicts)
+	}
+	conflict := report.Conflicts[0]
+	if conflict.NoteID != plan.ID || !strings.HasPrefix(conflict.Title, "Plan (conflict copy ") {
+		t.Errorf("conflict = %+v", conflict)
+	}
+	if got, _ := desktop.notes.GetNote(plan.ID); got == nil || got.Content != "Desktop edit" {
+		t.Errorf("local version = %+v, want the desktop edit kept", got)
+	}
+	if copied, _ := desktop.notes.GetNote(conflict.CopyID); copied == nil || copied.Content != "Laptop edit" {
+		t.Errorf("conflict copy = %+v, want the laptop edit", copied)
+	}
+
+	// The merge and the copy reach the laptop
+	report, err = laptop.history.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull() failed: %v", err)
+	}
+	if len(report.Conflicts) != 0 {
+		t.Errorf("laptop conflicts = %+v, want none", report.Conflicts)
+	}
+	if got, _ := laptop.notes.GetNote(plan.ID); got == nil || got.Content != "Desktop edit" {
+		t.Errorf("laptop note = %+v, want the desktop edit", got)
+	}
+	if _, err := laptop.notes.GetNote(conflict.CopyID); err != nil {
+		t.Errorf("conflict copy did not reach the laptop: %v", err)
+	}
+}
+
+func TestPullWithoutRemote(t *testing.T) {
+	ws := newWorkspace(t, "")
+	if _, err := ws.history.Pull(context.Background()); err == nil {
+		t.Error("Pull() without a remote succeeded")
+	}
+}
+
+func TestRejectsOptionsAsArguments(t *testing.T) {
+	ws := newWorkspace(t, "")
+	repo := ws.history.Repo()
+	ctx := context.Background()
+
+	if err := repo.SetRemote(ctx, "--upload-pack=touch pwned"); err == nil {
+		t.Error("SetRemote() with an option as the URL succeeded")
+	}
+	if url, _ := repo.Remote(ctx); url != "" {
+		t.Errorf("Remote() = %q, want none", url)
+	}
+
+	// Parsed as an option, this makes git write to a file named "pwned:"
+	repo.Show(ctx, "--output=pwned", "")
+	if _, err := os.Stat(filepath.Join(ws.dir, "pwned:")); err == nil {
+		t.Error("Show() passed its revision to git as an option")
+	}
+}
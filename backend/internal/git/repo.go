@@ -0,0 +1,309 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RemoteName is the remote a workspace pushes to and pulls from
+const RemoteName = "origin"
+
+// DefaultBranch is the branch a new repository starts on
+const DefaultBranch = "main"
+
+// ignoreRules keep derived and machine-local files out of the repository
+var ignoreRules = []string{
+	"# Rebuilt from the notes when missing",
+	"workspace.db",
+	"workspace.db-*",
+	"# Backups taken before database migrations",
+	"workspace.db.*",
+	"# Trashed notes can only be restored through this copy's database",
+	"/.trash/",
+	"# Partial writes",
+	".*.tmp",
+	".DS_Store",
+}
+
+// ErrNotRepo is returned by Open when the directory is not a git repository
+var ErrNotRepo = errors.New("not a git repository")
+
+// Identity names the author of commits
+type Identity struct {
+	Name  string
+	Email string
+}
+
+// Repo runs git commands in a workspace directory. It needs the git binary.
+type Repo struct {
+	dir string
+}
+
+// IsRepo reports whether dir is the top of a git repository
+func IsRepo(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil && info.IsDir()
+}
+
+// Open opens the repository at dir
+func Open(dir string) (*Repo, error) {
+	if !IsRepo(dir) {
+		return nil, ErrNotRepo
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git is not installed: %w", err)
+	}
+	return &Repo{dir: dir}, nil
+}
+
+// Init turns dir into a git repository, or adopts the one already there, and
+// makes sure derived files are ignored. who is used as the author when git
+// has no identity configured.
+func Init(ctx context.Context, dir string, who Identity) (*Repo, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git is not installed: %w", err)
+	}
+	r := &Repo{dir: dir}
+
+	if !IsRepo(dir) {
+		if _, err := r.run(ctx, "init", "--initial-branch="+DefaultBranch); err != nil {
+			return nil, err
+		}
+	}
+
+	if email, _ := r.run(ctx, "config", "user.email"); strings.TrimSpace(email) == "" {
+		if who.Name == "" {
+			who.Name = "Fuknotion"
+		}
+		if who.Email == "" {
+			who.Email = "fuknotion@localhost"
+		}
+		if _, err := r.run(ctx, "config", "user.name", who.Name); err != nil {
+			return nil, err
+		}
+		if _, err := r.run(ctx, "config", "user.email", who.Email); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := r.writeIgnore(); err != nil {
+		return nil, err
+	}
+	// Files committed before they were ignored stay tracked otherwise
+	if _, err := r.run(ctx, "rm", "-r", "--cached", "--ignore-unmatch", "--quiet",
+		"workspace.db", "workspace.db-wal", "workspace.db-shm", "workspace.db.*", ".trash"); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// writeIgnore adds the ignore rules missing from .gitignore, keeping any the
+// user added
+func (r *Repo) writeIgnore() error {
+	path := filepath.Join(r.dir, ".gitignore")
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read .gitignore: %w", err)
+	}
+
+	have := make(map[string]bool)
+	for _, line := range strings.Split(string(existing), "\n") {
+		have[strings.TrimSpace(line)] = true
+	}
+
+	var missing []string
+	for _, rule := range ignoreRules {
+		if !have[rule] {
+			missing = append(missing, rule)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	data := existing
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	data = append(data, strings.Join(missing, "\n")+"\n"...)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write .gitignore: %w", err)
+	}
+	return nil
+}
+
+// Dir returns the repository's directory
+func (r *Repo) Dir() string {
+	return r.dir
+}
+
+// Branch returns the checked out branch
+func (r *Repo) Branch(ctx context.Context) (string, error) {
+	out, err := r.run(ctx, "symbolic-ref", "--short", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// Head returns the commit checked out, or "" before the first commit
+func (r *Repo) Head(ctx context.Context) (string, error) {
+	out, err := r.run(ctx, "rev-parse", "--verify", "--quiet", "HEAD")
+	if err != nil {
+		if isExit(err, 1) {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// Remote returns the URL of the remote, or "" if none is set
+func (r *Repo) Remote(ctx context.Context) (string, error) {
+	out, err := r.run(ctx, "config", "--get", "remote."+RemoteName+".url")
+	if err != nil {
+		if isExit(err, 1) {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// SetRemote points the remote at url, or removes it when url is empty
+func (r *Repo) SetRemote(ctx context.Context, url string) error {
+	if err := checkArg("remote url", url); err != nil {
+		return err
+	}
+	current, err := r.Remote(ctx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case url == current:
+		return nil
+	case url == "":
+		_, err = r.run(ctx, "remote", "remove", RemoteName)
+	case current == "":
+		_, err = r.run(ctx, "remote", "add", "--", RemoteName, url)
+	default:
+		_, err = r.run(ctx, "remote", "set-url", "--", RemoteName, url)
+	}
+	return err
+}
+
+// Commit is a commit in the workspace's history
+type Commit struct {
+	Hash    string    `json:"hash"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body,omitempty"`
+}
+
+// Log lists up to limit commits, newest first. With a path, only commits
+// that touched it are listed, following renames.
+func (r *Repo) Log(ctx context.Context, path string, limit int) ([]*Commit, error) {
+	head, err := r.Head(ctx)
+	if err != nil || head == "" {
+		return nil, err
+	}
+
+	args := []string{"log", "-z", "--format=%H%x1f%an%x1f%aI%x1f%s%x1f%b", fmt.Sprintf("-n%d", limit)}
+	if path != "" {
+		args = append(args, "--follow", "--", path)
+	}
+	out, err := r.run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []*Commit
+	for _, record := range strings.Split(out, "\x00") {
+		fields := strings.SplitN(strings.TrimLeft(record, "\n"), "\x1f", 5)
+		if len(fields) != 5 {
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, fields[2])
+		commits = append(commits, &Commit{
+			Hash:    fields[0],
+			Author:  fields[1],
+			Date:    date,
+			Subject: fields[3],
+			Body:    strings.TrimSpace(fields[4]),
+		})
+	}
+	return commits, nil
+}
+
+// Show returns a file's content at a commit
+func (r *Repo) Show(ctx context.Context, rev, path string) ([]byte, error) {
+	// rev:path cannot follow "--", which would make it a path
+	if err := checkArg("revision", rev); err != nil {
+		return nil, err
+	}
+	out, err := r.run(ctx, "show", rev+":"+path)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(out), nil
+}
+
+// checkArg rejects a user-supplied value git would parse as an option, such
+// as a remote URL of --upload-pack=...
+func checkArg(kind, value string) error {
+	if strings.HasPrefix(value, "-") {
+		return fmt.Errorf("invalid %s: %q", kind, value)
+	}
+	return nil
+}
+
+// exitError is a git command that failed
+type exitError struct {
+	Args   []string
+	Code   int
+	Stderr string
+}
+
+func (e *exitError) Error() string {
+	msg := strings.TrimSpace(e.Stderr)
+	if msg == "" {
+		msg = fmt.Sprintf("exit status %d", e.Code)
+	}
+	return fmt.Sprintf("git %s: %s", e.Args[0], msg)
+}
+
+func isExit(err error, code int) bool {
+	var ee *exitError
+	return errors.As(err, &ee) && ee.Code == code
+}
+
+// run runs git with args in the repository and returns its output. git is
+// never allowed to prompt: there is no terminal to answer it.
+func (r *Repo) run(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "LC_ALL=C")
+	if os.Getenv("GIT_SSH_COMMAND") == "" {
+		cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) {
+			return stdout.String(), &exitError{Args: args, Code: ee.ExitCode(), Stderr: stderr.String()}
+		}
+		return "", fmt.Errorf("failed to run git: %w", err)
+	}
+	return stdout.String(), nil
+}
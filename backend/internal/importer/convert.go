@@ -0,0 +1,188 @@
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"fuknotion/backend/internal/note"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Frontmatter keys other apps use for the fields we keep
+var (
+	createdKeys  = []string{"created", "created_at", "date", "date created"}
+	modifiedKeys = []string{"modified", "updated", "updated_at", "last_modified", "date modified"}
+	timeLayouts  = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+)
+
+// markdownLinkPattern matches an inline [text](destination) link
+var markdownLinkPattern = regexp.MustCompile(`\[([^\[\]]*)\]\(([^()\s]+|<[^<>]+>)\)`)
+
+// splitFrontmatter separates YAML frontmatter, which any app may have
+// written, from the body. Keys we have no field for are dropped.
+func splitFrontmatter(raw string) (map[string]interface{}, string, error) {
+	raw = strings.TrimPrefix(strings.ReplaceAll(raw, "\r\n", "\n"), "\ufeff")
+	if !strings.HasPrefix(raw, "---\n") {
+		return nil, raw, nil
+	}
+
+	yamlText, body, ok := strings.Cut(raw[4:], "\n---\n")
+	if !ok {
+		if !strings.HasSuffix(raw, "\n---") {
+			return nil, raw, nil
+		}
+		yamlText, body = strings.TrimSuffix(raw[4:], "\n---"), ""
+	}
+
+	fields := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(yamlText), &fields); err != nil {
+		return nil, "", fmt.Errorf("invalid frontmatter: %w", err)
+	}
+	return fields, strings.TrimPrefix(body, "\n"), nil
+}
+
+// frontmatterFrom builds a note's frontmatter from whatever fields the source
+// file had, filling in times from the file itself
+func frontmatterFrom(fields map[string]interface{}, modTime time.Time) *note.Frontmatter {
+	fm := &note.Frontmatter{
+		ID:       stringField(fields, "id"),
+		Title:    stringField(fields, "title"),
+		Created:  timeField(fields, createdKeys),
+		Modified: timeField(fields, modifiedKeys),
+		Tags:     listField(fields, "tags", "tag"),
+	}
+	if favorite, ok := fields["is_favorite"].(bool); ok {
+		fm.IsFavorite = favorite
+	}
+
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+	if fm.Modified.IsZero() {
+		fm.Modified = modTime
+	}
+	if fm.Created.IsZero() || fm.Created.After(fm.Modified) {
+		fm.Created = fm.Modified
+	}
+	return fm
+}
+
+func stringField(fields map[string]interface{}, key string) string {
+	if s, ok := fields[key].(string); ok {
+		return strings.TrimSpace(s)
+	}
+	return ""
+}
+
+func timeField(fields map[string]interface{}, keys []string) time.Time {
+	for _, key := range keys {
+		switch v := fields[key].(type) {
+		case time.Time:
+			return v
+		case string:
+			for _, layout := range timeLayouts {
+				if t, err := time.ParseInLocation(layout, strings.TrimSpace(v), time.Local); err == nil {
+					return t
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// listField reads a list that may also be written as one comma or space
+// separated string, as Obsidian allows for tags
+func listField(fields map[string]interface{}, keys ...string) []string {
+	var out []string
+	for _, key := range keys {
+		switch v := fields[key].(type) {
+		case []interface{}:
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					out = append(out, s)
+				}
+			}
+		case string:
+			out = append(out, strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })...)
+		}
+	}
+	return out
+}
+
+// stripTitleHeading drops a leading "# Title" line repeating the note's
+// title, which Notion writes at the top of every page
+func stripTitleHeading(body, title string) string {
+	trimmed := strings.TrimLeft(body, "\n")
+	first, rest, _ := strings.Cut(trimmed, "\n")
+	if strings.TrimSpace(strings.TrimPrefix(first, "# ")) == title && strings.HasPrefix(first, "# ") {
+		return strings.TrimLeft(rest, "\n")
+	}
+	return body
+}
+
+// replaceMarkdownLinks rewrites inline links outside code. replace gets the
+// link text and destination and returns the replacement, or false to keep
+// the link.
+func replaceMarkdownLinks(content string, replace func(text, dest string) (string, bool)) string {
+	lines := strings.SplitAfter(content, "\n")
+	inFence := false
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+
+		// Even segments lie outside `inline code`
+		segments := strings.Split(line, "`")
+		for j := 0; j < len(segments); j += 2 {
+			segments[j] = replaceInline(segments[j], replace)
+		}
+		lines[i] = strings.Join(segments, "`")
+	}
+
+	return strings.Join(lines, "")
+}
+
+func replaceInline(text string, replace func(text, dest string) (string, bool)) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range markdownLinkPattern.FindAllStringSubmatchIndex(text, -1) {
+		// Images stay as they are
+		if m[0] > 0 && text[m[0]-1] == '!' {
+			continue
+		}
+		dest := strings.TrimSuffix(strings.TrimPrefix(text[m[4]:m[5]], "<"), ">")
+		out, ok := replace(text[m[2]:m[3]], dest)
+		if !ok {
+			continue
+		}
+		b.WriteString(text[last:m[0]])
+		b.WriteString(out)
+		last = m[1]
+	}
+	if last == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// wikiLink formats a link to target, which may be a title or an ID
+func wikiLink(target, heading, alias string) string {
+	inner := target
+	if heading != "" {
+		inner += "#" + heading
+	}
+	if alias != "" && alias != target {
+		inner += "|" + alias
+	}
+	return "[[" + inner + "]]"
+}
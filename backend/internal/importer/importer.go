@@ -0,0 +1,326 @@
+package importer
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"fuknotion/backend/internal/folder"
+	"fuknotion/backend/internal/note"
+
+	"github.com/google/uuid"
+)
+
+// Issue is a file the import skipped or failed to bring in
+type Issue struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// Report summarizes an import
+type Report struct {
+	Source   Source  `json:"source"`
+	Imported int     `json:"imported"`
+	Folders  int     `json:"folders"`
+	Skipped  []Issue `json:"skipped"`
+	Failed   []Issue `json:"failed"`
+}
+
+// Service brings notes in from Notion exports, Obsidian vaults and plain
+// markdown directories
+type Service struct {
+	notes   *note.Service
+	folders *folder.Service
+}
+
+// NewService creates a new import service
+func NewService(notes *note.Service, folders *folder.Service) *Service {
+	return &Service{notes: notes, folders: folders}
+}
+
+// pending is a markdown file parsed and waiting to be imported
+type pending struct {
+	file *sourceFile
+	dir  string // slash-separated directory within the import
+	fm   *note.Frontmatter
+	body string
+}
+
+// Import brings in every markdown file in the directory or zip archive at
+// source, recreating its directories as folders under folderID ("" for the
+// top level). Links between the imported files are rewritten into wiki
+// links. A file that cannot be imported is reported rather than stopping
+// the rest.
+func (s *Service) Import(source, folderID string) (*Report, error) {
+	if folderID != "" {
+		if _, err := s.folders.GetFolder(folderID); err != nil {
+			return nil, err
+		}
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open import source: %w", err)
+	}
+
+	var t *tree
+	switch {
+	case info.IsDir():
+		t, err = readDir(source)
+	case strings.EqualFold(filepath.Ext(source), ".zip"):
+		t, err = readZip(source)
+	default:
+		return nil, fmt.Errorf("import source must be a directory or a zip archive: %s", source)
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.detect()
+	t.sortFiles()
+
+	report := &Report{Source: t.source, Skipped: []Issue{}, Failed: []Issue{}}
+
+	notes, err := s.parse(t, report)
+	if err != nil {
+		return nil, err
+	}
+
+	folderIDs, err := s.createFolders(notes, folderID, t.source, report)
+	if err != nil {
+		return report, err
+	}
+
+	links, err := s.newLinker(notes)
+	if err != nil {
+		return report, err
+	}
+
+	for _, p := range notes {
+		p.fm.FolderID = folderIDs[p.dir]
+		content := links.convert(p)
+
+		if _, err := s.notes.ImportNote(p.fm, content); err != nil {
+			report.Failed = append(report.Failed, Issue{Path: p.file.path, Reason: err.Error()})
+			continue
+		}
+		report.Imported++
+	}
+
+	return report, nil
+}
+
+// parse reads the markdown files, settling each note's ID, title and times
+func (s *Service) parse(t *tree, report *Report) ([]*pending, error) {
+	var notes []*pending
+	ids := make(map[string]bool)
+
+	for _, f := range t.files {
+		if !isMarkdown(f.path) {
+			report.Skipped = append(report.Skipped, Issue{Path: f.path, Reason: "not a markdown file"})
+			continue
+		}
+		if f.err != nil {
+			report.Failed = append(report.Failed, Issue{Path: f.path, Reason: f.err.Error()})
+			continue
+		}
+		if !utf8.Valid(f.data) {
+			report.Failed = append(report.Failed, Issue{Path: f.path, Reason: "file is not UTF-8 text"})
+			continue
+		}
+
+		fields, body, err := splitFrontmatter(string(f.data))
+		if err != nil {
+			report.Failed = append(report.Failed, Issue{Path: f.path, Reason: err.Error()})
+			continue
+		}
+
+		fm := frontmatterFrom(fields, f.modTime)
+		if fm.Title == "" {
+			fm.Title = cleanName(stem(f.path), t.source)
+		}
+		if t.source == SourceNotion {
+			body = stripTitleHeading(body, fm.Title)
+		}
+
+		// Keep an ID from our own frontmatter unless it is already taken,
+		// e.g. when a workspace export is imported back into it
+		if _, err := uuid.Parse(fm.ID); err != nil || ids[fm.ID] {
+			fm.ID = uuid.New().String()
+		} else if taken, err := s.notes.HasNote(fm.ID); err != nil {
+			return nil, err
+		} else if taken {
+			fm.ID = uuid.New().String()
+		}
+		ids[fm.ID] = true
+
+		dir := path.Dir(f.path)
+		if dir == "." {
+			dir = ""
+		}
+		notes = append(notes, &pending{file: f, dir: dir, fm: fm, body: body})
+	}
+
+	return notes, nil
+}
+
+// createFolders creates a folder for every directory holding notes, at any
+// depth, and maps each directory to its folder ID
+func (s *Service) createFolders(notes []*pending, rootID string, source Source, report *Report) (map[string]string, error) {
+	dirs := make(map[string]bool)
+	for _, p := range notes {
+		for dir := p.dir; dir != ""; dir = parentDir(dir) {
+			dirs[dir] = true
+		}
+	}
+
+	sorted := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	// Parents sort before their children
+	sort.Slice(sorted, func(i, j int) bool {
+		return strings.Count(sorted[i], "/") < strings.Count(sorted[j], "/") ||
+			strings.Count(sorted[i], "/") == strings.Count(sorted[j], "/") && strings.ToLower(sorted[i]) < strings.ToLower(sorted[j])
+	})
+
+	ids := map[string]string{"": rootID}
+	for _, dir := range sorted {
+		f, err := s.folders.CreateFolder(cleanName(path.Base(dir), source), ids[parentDir(dir)])
+		if err != nil {
+			return nil, fmt.Errorf("failed to create folder for %s: %w", dir, err)
+		}
+		ids[dir] = f.ID
+		report.Folders++
+	}
+
+	return ids, nil
+}
+
+func parentDir(dir string) string {
+	parent := path.Dir(dir)
+	if parent == "." {
+		return ""
+	}
+	return parent
+}
+
+// linker resolves links between the imported files
+type linker struct {
+	byPath  map[string]*pending   // lower-cased path without extension
+	byName  map[string][]*pending // lower-cased file name without extension
+	byTitle map[string][]*pending
+
+	// ambiguous titles are shared with another note, so links to them use IDs
+	ambiguous map[string]bool
+}
+
+func (s *Service) newLinker(notes []*pending) (*linker, error) {
+	l := &linker{
+		byPath:    make(map[string]*pending),
+		byName:    make(map[string][]*pending),
+		byTitle:   make(map[string][]*pending),
+		ambiguous: make(map[string]bool),
+	}
+
+	existing, err := s.notes.ListNotes("")
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range existing {
+		l.ambiguous[strings.ToLower(n.Title)] = true
+	}
+
+	for _, p := range notes {
+		key := strings.ToLower(strings.TrimSuffix(p.file.path, path.Ext(p.file.path)))
+		name := strings.ToLower(stem(p.file.path))
+		title := strings.ToLower(p.fm.Title)
+
+		l.byPath[key] = p
+		l.byName[name] = append(l.byName[name], p)
+		if len(l.byTitle[title]) > 0 {
+			l.ambiguous[title] = true
+		}
+		l.byTitle[title] = append(l.byTitle[title], p)
+	}
+
+	return l, nil
+}
+
+// convert rewrites a note's wiki links and its markdown links to other
+// imported files so they point at the imported notes
+func (l *linker) convert(from *pending) string {
+	content := note.ReplaceLinks(from.body, func(link note.WikiLink) (string, bool) {
+		to := l.resolveWiki(link.Target, from)
+		if to == nil {
+			return "", false
+		}
+		return l.link(to, link.Heading, link.Alias), true
+	})
+
+	return replaceMarkdownLinks(content, func(text, dest string) (string, bool) {
+		if strings.Contains(dest, ":") || strings.HasPrefix(dest, "#") {
+			return "", false
+		}
+		dest, heading, _ := strings.Cut(dest, "#")
+		if unescaped, err := url.PathUnescape(dest); err == nil {
+			dest = unescaped
+		}
+		if !isMarkdown(dest) {
+			return "", false
+		}
+
+		to := l.byPath[strings.ToLower(strings.TrimSuffix(path.Join(from.dir, dest), path.Ext(dest)))]
+		if to == nil {
+			return "", false
+		}
+		return l.link(to, heading, text), true
+	})
+}
+
+// resolveWiki finds the note an Obsidian-style [[target]] means: a path
+// within the vault or relative to the linking note, or a bare name, which
+// Obsidian resolves to the nearest file of that name
+func (l *linker) resolveWiki(target string, from *pending) *pending {
+	target = strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(target, ".md"), ".markdown"))
+
+	if strings.Contains(target, "/") {
+		if p := l.byPath[strings.TrimPrefix(path.Clean(target), "/")]; p != nil {
+			return p
+		}
+		return l.byPath[strings.ToLower(path.Join(from.dir, target))]
+	}
+
+	candidates := l.byName[target]
+	if len(candidates) == 0 {
+		candidates = l.byTitle[target]
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	best := candidates[0]
+	for _, c := range candidates[1:] {
+		if c.dir == from.dir && best.dir != from.dir {
+			best = c
+		}
+	}
+	return best
+}
+
+// link formats a wiki link to an imported note, by title where that is
+// unambiguous and safe to write inside [[...]], otherwise by ID
+func (l *linker) link(to *pending, heading, alias string) string {
+	title := to.fm.Title
+	if l.ambiguous[strings.ToLower(title)] || strings.ContainsAny(title, "[]|#") {
+		if alias == "" {
+			alias = title
+		}
+		return wikiLink(to.fm.ID, heading, alias)
+	}
+	return wikiLink(title, heading, alias)
+}
@@ -0,0 +1,265 @@
+package importer
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fuknotion/backend/internal/database"
+	"fuknotion/backend/internal/filesystem"
+	"fuknotion/backend/internal/folder"
+	"fuknotion/backend/internal/models"
+	"fuknotion/backend/internal/note"
+)
+
+func setupTestService(t *testing.T) (*Service, *note.Service, *folder.Service) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+
+	db, err := database.InitWorkspaceDB(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	fs, err := filesystem.NewFileSystem(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize filesystem: %v", err)
+	}
+
+	notes := note.NewService(db, fs)
+	folders := folder.NewService(db, notes)
+	return NewService(notes, folders), notes, folders
+}
+
+// writeTree creates files under a new directory and returns it
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func notesByTitle(t *testing.T, notes *note.Service) map[string]*models.Note {
+	t.Helper()
+
+	list, err := notes.ListNotes("")
+	if err != nil {
+		t.Fatalf("ListNotes() failed: %v", err)
+	}
+	byTitle := make(map[string]*models.Note)
+	for _, n := range list {
+		full, err := notes.GetNote(n.ID)
+		if err != nil {
+			t.Fatalf("GetNote() failed: %v", err)
+		}
+		byTitle[n.Title] = full
+	}
+	return byTitle
+}
+
+func TestImportObsidianVault(t *testing.T) {
+	service, notes, folders := setupTestService(t)
+
+	root := writeTree(t, map[string]string{
+		".obsidian/app.json":   "{}",
+		"Home.md":              "---\ntags: [start, \"#idea\"]\ncreated: 2023-04-05\n---\nSee [[Plan]], [[Projects/Plan#Goals|the goals]] and [[Nowhere]]",
+		"Projects/Plan.md":     "## Goals\n\nBack [[Home]] or ![[diagram.png]]",
+		"Projects/diagram.png": "png",
+		"Daily/2024-01-01.md":  "Went to [the plan](../Projects/Plan.md#goals)",
+	})
+	mtime := time.Date(2024, 2, 3, 4, 5, 6, 0, time.Local)
+	os.Chtimes(filepath.Join(root, "Projects", "Plan.md"), mtime, mtime)
+
+	report, err := service.Import(root, "")
+	if err != nil {
+		t.Fatalf("Import() failed: %v", err)
+	}
+	if report.Source != SourceObsidian {
+		t.Errorf("Source = %q, want obsidian", report.Source)
+	}
+	if report.Imported != 3 || report.Folders != 2 {
+		t.Errorf("report = %+v, want 3 notes in 2 folders", report)
+	}
+	if len(report.Skipped) != 1 || report.Skipped[0].Path != "Projects/diagram.png" {
+		t.Errorf("Skipped = %v, want the png", report.Skipped)
+	}
+
+	tree, _ := folders.GetFolderTree()
+	if len(tree) != 2 || tree[0].Name != "Daily" || tree[1].Name != "Projects" {
+		t.Fatalf("folders = %v, want Daily and Projects", tree)
+	}
+
+	byTitle := notesByTitle(t, notes)
+	home, plan, daily := byTitle["Home"], byTitle["Plan"], byTitle["2024-01-01"]
+	if home == nil || plan == nil || daily == nil {
+		t.Fatalf("imported notes = %v", byTitle)
+	}
+
+	if want := "See [[Plan]], [[Plan#Goals|the goals]] and [[Nowhere]]"; home.Content != want {
+		t.Errorf("Home content = %q, want %q", home.Content, want)
+	}
+	if strings.Join(home.Tags, ",") != "idea,start" {
+		t.Errorf("Home tags = %v, want [idea start]", home.Tags)
+	}
+	if got := home.CreatedAt.Format("2006-01-02"); got != "2023-04-05" {
+		t.Errorf("Home created = %s, want 2023-04-05", got)
+	}
+
+	if plan.FolderID != tree[1].ID {
+		t.Errorf("Plan folder = %q, want Projects", plan.FolderID)
+	}
+	if !plan.UpdatedAt.Equal(mtime) {
+		t.Errorf("Plan modified = %v, want file mtime %v", plan.UpdatedAt, mtime)
+	}
+	if !strings.Contains(plan.Content, "![[diagram.png]]") {
+		t.Errorf("embed of a non-note was changed: %q", plan.Content)
+	}
+
+	if want := "Went to [[Plan#goals|the plan]]"; daily.Content != want {
+		t.Errorf("daily content = %q, want %q", daily.Content, want)
+	}
+
+	links, _ := notes.GetBacklinks(plan.ID)
+	if len(links) != 3 {
+		t.Errorf("Plan has %d backlinks, want 3", len(links))
+	}
+
+	if drift, _ := notes.NeedsReindex(); drift {
+		t.Error("import left the index out of date with the files")
+	}
+}
+
+func TestImportNotionZip(t *testing.T) {
+	service, notes, folders := setupTestService(t)
+
+	id1 := "0123456789abcdef0123456789abcdef"
+	id2 := "fedcba9876543210fedcba9876543210"
+	archive := filepath.Join(t.TempDir(), "Export.zip")
+	f, _ := os.Create(archive)
+	w := zip.NewWriter(f)
+	for name, content := range map[string]string{
+		"Export-1/Team " + id1 + ".md":                     "# Team\n\nOur [Roadmap](Team%20" + id1 + "/Roadmap%20" + id2 + ".md) and [site](https://x.org)",
+		"Export-1/Team " + id1 + "/Roadmap " + id2 + ".md": "# Roadmap\n\nQ1 goals",
+		"Export-1/Team " + id1 + "/Tasks " + id2 + ".csv":  "a,b",
+		"Export-1/Team " + id1 + "/Broken " + id2 + "2.md": "---\n: bad: [\n---\nbody",
+	} {
+		fw, _ := w.Create(name)
+		fw.Write([]byte(content))
+	}
+	w.Close()
+	f.Close()
+
+	report, err := service.Import(archive, "")
+	if err != nil {
+		t.Fatalf("Import() failed: %v", err)
+	}
+	if report.Source != SourceNotion {
+		t.Errorf("Source = %q, want notion", report.Source)
+	}
+	if report.Imported != 2 || len(report.Skipped) != 1 || len(report.Failed) != 1 {
+		t.Errorf("report = %+v, want 2 imported, 1 skipped, 1 failed", report)
+	}
+
+	tree, _ := folders.GetFolderTree()
+	if len(tree) != 1 || tree[0].Name != "Team" {
+		t.Fatalf("folders = %v, want Team", tree)
+	}
+
+	byTitle := notesByTitle(t, notes)
+	team, roadmap := byTitle["Team"], byTitle["Roadmap"]
+	if team == nil || roadmap == nil {
+		t.Fatalf("imported notes = %v", byTitle)
+	}
+	if want := "Our [[Roadmap]] and [site](https://x.org)"; team.Content != want {
+		t.Errorf("Team content = %q, want %q", team.Content, want)
+	}
+	if roadmap.Content != "Q1 goals" || roadmap.FolderID != tree[0].ID {
+		t.Errorf("Roadmap = %q in %q", roadmap.Content, roadmap.FolderID)
+	}
+}
+
+func TestImportKeepsFrontmatterAndAvoidsCollisions(t *testing.T) {
+	service, notes, folders := setupTestService(t)
+
+	existing, _ := notes.CreateNote("Ideas", "already here", "")
+	target, _ := folders.CreateFolder("Imported", "")
+
+	keptID := "5f0e8a8e-8f7c-4c55-9e51-3f3d0a1c2b4d"
+	root := writeTree(t, map[string]string{
+		"ours.md":  "---\nid: " + keptID + "\ntitle: Kept Title\nis_favorite: true\nmodified: 2024-05-06T07:08:09Z\n---\nLink to [[Ideas]]",
+		"clash.md": "---\nid: " + existing.ID + "\ntitle: Ideas\n---\nA second note called Ideas",
+	})
+
+	report, err := service.Import(root, target.ID)
+	if err != nil {
+		t.Fatalf("Import() failed: %v", err)
+	}
+	if report.Imported != 2 || report.Folders != 0 {
+		t.Fatalf("report = %+v", report)
+	}
+
+	kept, err := notes.GetNote(keptID)
+	if err != nil {
+		t.Fatalf("note did not keep its frontmatter ID: %v", err)
+	}
+	if kept.Title != "Kept Title" || !kept.IsFavorite || kept.FolderID != target.ID {
+		t.Errorf("kept note = %+v", kept)
+	}
+	if !kept.UpdatedAt.Equal(time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)) {
+		t.Errorf("UpdatedAt = %v, want the frontmatter time", kept.UpdatedAt)
+	}
+
+	// The imported Ideas got a new ID, and the link names it by ID since
+	// the title is shared with the existing note
+	list, _ := notes.ListNotes("")
+	var clash *models.Note
+	for _, n := range list {
+		if n.Title == "Ideas" && n.ID != existing.ID {
+			clash = n
+		}
+	}
+	if clash == nil {
+		t.Fatal("note with a taken ID was not imported")
+	}
+	if want := "Link to [[" + clash.ID + "|Ideas]]"; kept.Content != want {
+		t.Errorf("content = %q, want %q", kept.Content, want)
+	}
+}
+
+func TestImportRejectsOtherFiles(t *testing.T) {
+	service, _, _ := setupTestService(t)
+
+	file := filepath.Join(t.TempDir(), "note.txt")
+	os.WriteFile(file, []byte("x"), 0644)
+
+	if _, err := service.Import(file, ""); err == nil {
+		t.Error("Import() of a plain file succeeded")
+	}
+	if _, err := service.Import(t.TempDir(), "missing"); err == nil {
+		t.Error("Import() into a missing folder succeeded")
+	}
+}
+
+func TestReplaceMarkdownLinks(t *testing.T) {
+	in := "[a](x.md)[b](y.md) ![img](z.md) `[c](x.md)`\n```\n[d](x.md)\n```\n"
+	got := replaceMarkdownLinks(in, func(text, dest string) (string, bool) {
+		return "<" + text + ">", true
+	})
+	if want := "<a><b> ![img](z.md) `[c](x.md)`\n```\n[d](x.md)\n```\n"; got != want {
+		t.Errorf("replaceMarkdownLinks() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,226 @@
+package importer
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Source is the app an import was exported from, which decides how file
+// names are read
+type Source string
+
+const (
+	SourceNotion   Source = "notion"
+	SourceObsidian Source = "obsidian"
+	SourceMarkdown Source = "markdown"
+)
+
+// maxNoteSize is the largest markdown file imported; anything bigger is
+// almost certainly not a note
+const maxNoteSize = 16 << 20
+
+// notionIDPattern matches the page ID Notion appends to exported names,
+// e.g. "Meeting notes 0a1b2c3d4e5f60718293a4b5c6d7e8f9"
+var notionIDPattern = regexp.MustCompile(`^(.*?)\s+[0-9a-f]{32}$`)
+
+// sourceFile is a file found in the import, with a slash-separated path
+// relative to its root. Data is only read for markdown files.
+type sourceFile struct {
+	path    string
+	data    []byte
+	modTime time.Time
+	err     error
+}
+
+// tree is the content of a directory or zip being imported
+type tree struct {
+	files  []*sourceFile
+	source Source
+}
+
+func isMarkdown(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return ext == ".md" || ext == ".markdown"
+}
+
+// isHidden reports whether a path lies in a dot directory such as .obsidian
+// or .git, or is an OS artifact, none of which hold notes
+func isHidden(p string) bool {
+	for _, part := range strings.Split(p, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
+
+// readDir loads the files under root
+func readDir(root string) (*tree, error) {
+	t := &tree{}
+
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if d.Name() == ".obsidian" {
+				t.source = SourceObsidian
+			}
+			if isHidden(rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isHidden(rel) || !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			t.files = append(t.files, &sourceFile{path: rel, err: err})
+			return nil
+		}
+		f := &sourceFile{path: rel, modTime: info.ModTime()}
+		if isMarkdown(rel) {
+			if info.Size() > maxNoteSize {
+				f.err = fmt.Errorf("file is larger than %d MB", maxNoteSize>>20)
+			} else {
+				f.data, f.err = os.ReadFile(p)
+			}
+		}
+		t.files = append(t.files, f)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import directory: %w", err)
+	}
+
+	return t, nil
+}
+
+// readZip loads the files in a zip archive. An archive holding a single
+// top-level directory is read as if that directory were the root.
+func readZip(name string) (*tree, error) {
+	r, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open import archive: %w", err)
+	}
+	defer r.Close()
+
+	t := &tree{}
+	for _, zf := range r.File {
+		p := strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(zf.Name, `\`, "/")), "/")
+		if strings.Contains("/"+p+"/", "/.obsidian/") {
+			t.source = SourceObsidian
+		}
+		if zf.FileInfo().IsDir() || p == "" || isHidden(p) {
+			continue
+		}
+
+		f := &sourceFile{path: p, modTime: zf.Modified}
+		switch {
+		case !isMarkdown(p):
+		case zf.UncompressedSize64 > maxNoteSize:
+			f.err = fmt.Errorf("file is larger than %d MB", maxNoteSize>>20)
+		default:
+			f.data, f.err = readZipFile(zf)
+		}
+		t.files = append(t.files, f)
+	}
+
+	t.stripCommonRoot()
+	return t, nil
+}
+
+func readZipFile(zf *zip.File) ([]byte, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxNoteSize+1))
+	if err == nil && len(data) > maxNoteSize {
+		err = fmt.Errorf("file is larger than %d MB", maxNoteSize>>20)
+	}
+	return data, err
+}
+
+// stripCommonRoot drops a directory that every file sits in, as zipping a
+// folder produces
+func (t *tree) stripCommonRoot() {
+	if len(t.files) == 0 {
+		return
+	}
+	root, _, ok := strings.Cut(t.files[0].path, "/")
+	if !ok {
+		return
+	}
+	for _, f := range t.files {
+		if !strings.HasPrefix(f.path, root+"/") {
+			return
+		}
+	}
+	for _, f := range t.files {
+		f.path = strings.TrimPrefix(f.path, root+"/")
+	}
+}
+
+// detect works out where the files came from, if the walk did not already
+// find an Obsidian vault: Notion suffixes every page with its ID
+func (t *tree) detect() {
+	if t.source != "" {
+		return
+	}
+	t.source = SourceMarkdown
+	for _, f := range t.files {
+		if isMarkdown(f.path) && notionIDPattern.MatchString(stem(f.path)) {
+			t.source = SourceNotion
+			return
+		}
+	}
+}
+
+// sortFiles orders files by path so folders and notes are created in a
+// stable order
+func (t *tree) sortFiles() {
+	sort.Slice(t.files, func(i, j int) bool {
+		return strings.ToLower(t.files[i].path) < strings.ToLower(t.files[j].path)
+	})
+}
+
+// stem is a file's name without directory or extension
+func stem(p string) string {
+	base := path.Base(p)
+	return strings.TrimSuffix(base, path.Ext(base))
+}
+
+// cleanName turns a file or directory name into a title, dropping the page
+// ID from Notion names
+func cleanName(name string, source Source) string {
+	if source == SourceNotion {
+		if m := notionIDPattern.FindStringSubmatch(name); m != nil {
+			name = m[1]
+		}
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "Untitled"
+	}
+	return name
+}
@@ -4,14 +4,18 @@ import "time"
 
 // Note represents a note entity
 type Note struct {
-	ID         string    `json:"id"`
-	Title      string    `json:"title"`
-	FolderID   string    `json:"folderId,omitempty"`
-	FilePath   string    `json:"filePath"`
-	IsFavorite bool      `json:"isFavorite"`
-	Content    string    `json:"content"` // Markdown content
-	CreatedAt  time.Time `json:"createdAt"`
-	UpdatedAt  time.Time `json:"updatedAt"`
+	ID         string     `json:"id"`
+	Title      string     `json:"title"`
+	FolderID   string     `json:"folderId,omitempty"`
+	FilePath   string     `json:"filePath"`
+	IsFavorite bool       `json:"isFavorite"`
+	Pinned     int        `json:"pinned,omitempty"` // Order among pinned notes from 1; 0 if not pinned
+	Position   int        `json:"position"`         // Manual order within the folder
+	Tags       []string   `json:"tags,omitempty"`
+	Content    string     `json:"content"` // Markdown content
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	DeletedAt  *time.Time `json:"deletedAt,omitempty"` // Set while the note is in the trash
 }
 
 // Workspace represents a workspace entity
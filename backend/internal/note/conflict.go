@@ -0,0 +1,171 @@
+package note
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"fuknotion/backend/internal/models"
+)
+
+// CreateConflictCopy adds data, a version of a note that clashed with the
+// local one, as a new note titled "<title> (<label>)" so neither version is
+// lost. The copy keeps the version's folder if it still exists, and its tags.
+func (s *Service) CreateConflictCopy(data []byte, label string) (*models.Note, error) {
+	fm, content, err := parseNoteFile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	folderID := fm.FolderID
+	if s.checkFolder(folderID) != nil {
+		folderID = ""
+	}
+	return s.createNote(fmt.Sprintf("%s (%s)", fm.Title, label), content, folderID, fm.Tags)
+}
+
+// Reconciled is how a note changed on two sides was settled
+type Reconciled struct {
+	Data      []byte          // what the note holds now, on both sides
+	Merged    bool            // whether both sides' edits were merged
+	Copy      *models.Note    // their version, if it was kept as a conflict copy
+	Conflicts []MergeConflict // what clashed, if anything
+}
+
+// Reconcile settles a note changed both here (ours) and elsewhere (theirs)
+// since base, the version both were edited from. Edits that do not overlap
+// are merged. Content both sides changed differently cannot be merged
+// safely, so ours is kept and theirs saved as a conflict copy labelled
+// label; so are two versions with no base to merge from. What clashed is
+// recorded for the user to look at. The caller writes Data to both sides.
+func (s *Service) Reconcile(base, ours, theirs []byte, label string) (*Reconciled, error) {
+	fm, _, err := parseNoteFile(theirs)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []MergeConflict
+	if base != nil {
+		result, err := Merge(base, ours, theirs)
+		if err == nil && !result.ContentConflicts() {
+			if len(result.Conflicts) > 0 {
+				if err := s.recordConflict(fm.ID, "", result.Conflicts); err != nil {
+					return nil, err
+				}
+			}
+			return &Reconciled{Data: result.Data, Merged: true, Conflicts: result.Conflicts}, nil
+		}
+		if err == nil {
+			conflicts = result.Conflicts
+		}
+	}
+
+	copied, err := s.CreateConflictCopy(theirs, label)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.recordConflict(fm.ID, copied.ID, conflicts); err != nil {
+		return nil, err
+	}
+	return &Reconciled{Data: ours, Copy: copied, Conflicts: conflicts}, nil
+}
+
+// NoteConflict is a note whose versions could not be fully merged
+type NoteConflict struct {
+	NoteID     string          `json:"noteId"`
+	Title      string          `json:"title"`
+	CopyID     string          `json:"copyId,omitempty"` // the note holding the other version, if one was made
+	Conflicts  []MergeConflict `json:"conflicts"`
+	DetectedAt time.Time       `json:"detectedAt"`
+}
+
+// ListConflicts lists the notes with conflicts the user has yet to resolve,
+// most recent first
+func (s *Service) ListConflicts() ([]*NoteConflict, error) {
+	query := `
+		SELECT c.note_id, n.title, c.copy_id, c.conflicts, c.detected_at
+		FROM note_conflicts c JOIN notes n ON n.id = c.note_id
+		WHERE n.deleted_at IS NULL
+		ORDER BY c.detected_at DESC
+	`
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conflicts: %w", err)
+	}
+	defer rows.Close()
+
+	conflicts := []*NoteConflict{}
+	for rows.Next() {
+		var c NoteConflict
+		var data string
+		if err := rows.Scan(&c.NoteID, &c.Title, &c.CopyID, &data, &c.DetectedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan conflict: %w", err)
+		}
+		if err := json.Unmarshal([]byte(data), &c.Conflicts); err != nil {
+			return nil, fmt.Errorf("failed to parse conflict: %w", err)
+		}
+		conflicts = append(conflicts, &c)
+	}
+	return conflicts, rows.Err()
+}
+
+// ResolveConflict forgets a note's conflicts once the user has dealt with
+// them
+func (s *Service) ResolveConflict(noteID string) error {
+	if _, err := s.db.Exec(`DELETE FROM note_conflicts WHERE note_id = ?`, noteID); err != nil {
+		return fmt.Errorf("failed to resolve conflict: %w", err)
+	}
+	return nil
+}
+
+// recordConflict remembers what clashed when a note's versions were
+// reconciled, replacing anything recorded before
+func (s *Service) recordConflict(noteID, copyID string, conflicts []MergeConflict) error {
+	if conflicts == nil {
+		conflicts = []MergeConflict{}
+	}
+	data, err := json.Marshal(conflicts)
+	if err != nil {
+		return fmt.Errorf("failed to encode conflict: %w", err)
+	}
+
+	query := `
+		INSERT INTO note_conflicts (note_id, copy_id, conflicts, detected_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(note_id) DO UPDATE SET
+			copy_id = excluded.copy_id,
+			conflicts = excluded.conflicts,
+			detected_at = excluded.detected_at
+	`
+	if _, err := s.db.Exec(query, noteID, copyID, string(data), time.Now()); err != nil {
+		return fmt.Errorf("failed to record conflict: %w", err)
+	}
+	return nil
+}
+
+// EditMerge is an edit merged with changes saved meanwhile
+type EditMerge struct {
+	Content   string          `json:"content"`
+	Conflicts []MergeConflict `json:"conflicts"`
+}
+
+// Labels on the conflict markers MergeEdit writes
+const (
+	editLabel  = "your edit"
+	savedLabel = "saved version"
+)
+
+// MergeEdit merges content, an edit of a note made from base, with what
+// the note holds now, which may have changed meanwhile, e.g. in an external
+// editor. Lines both changed differently are left between conflict markers
+// for the user to resolve before saving. Nothing is saved.
+func (s *Service) MergeEdit(id, base, content string) (*EditMerge, error) {
+	current, err := s.GetNote(id)
+	if err != nil {
+		return nil, err
+	}
+	merged, conflicts := MergeText(base, content, current.Content, editLabel, savedLabel)
+	if conflicts == nil {
+		conflicts = []MergeConflict{}
+	}
+	return &EditMerge{Content: merged, Conflicts: conflicts}, nil
+}
@@ -0,0 +1,50 @@
+package note
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyFileAndConflictCopy(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	fm := &Frontmatter{ID: "remote-1", Title: "Remote", Tags: []string{"synced"}}
+	data, err := SerializeNote(fm, "From another device")
+	if err != nil {
+		t.Fatalf("SerializeNote() failed: %v", err)
+	}
+
+	path := filepath.Join("notes", "remote-1.md")
+	change, err := service.ApplyFile(path, []byte(data))
+	if err != nil {
+		t.Fatalf("ApplyFile() failed: %v", err)
+	}
+	if change.Kind != FileCreated || change.NoteID != "remote-1" {
+		t.Errorf("ApplyFile() = %+v", change)
+	}
+	if _, err := service.ApplyFile(filepath.Join("notes", "bad.md"), []byte("no frontmatter")); err == nil {
+		t.Error("ApplyFile() accepted a file without an id")
+	}
+
+	copied, err := service.CreateConflictCopy([]byte(data), "conflict copy")
+	if err != nil {
+		t.Fatalf("CreateConflictCopy() failed: %v", err)
+	}
+	if copied.ID == "remote-1" || copied.Title != "Remote (conflict copy)" || copied.Content != "From another device" {
+		t.Errorf("CreateConflictCopy() = %+v", copied)
+	}
+	if len(copied.Tags) != 1 || copied.Tags[0] != "synced" {
+		t.Errorf("Tags = %v, want [synced]", copied.Tags)
+	}
+
+	change, err = service.TrashFile(path)
+	if err != nil {
+		t.Fatalf("TrashFile() failed: %v", err)
+	}
+	if change == nil || change.Kind != FileDeleted {
+		t.Errorf("TrashFile() = %+v", change)
+	}
+	if trash, _ := service.ListTrash(); len(trash) != 1 || trash[0].ID != "remote-1" {
+		t.Errorf("ListTrash() = %+v, want the trashed note", trash)
+	}
+}
@@ -0,0 +1,304 @@
+package note
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// FileChangeKind describes how a note file changed on disk
+type FileChangeKind string
+
+const (
+	FileCreated  FileChangeKind = "created"
+	FileModified FileChangeKind = "modified"
+	FileDeleted  FileChangeKind = "deleted"
+)
+
+// FileChange is an external change to a note file that has been applied to the index
+type FileChange struct {
+	NoteID string         `json:"noteId"`
+	Title  string         `json:"title"`
+	Path   string         `json:"path"`
+	Kind   FileChangeKind `json:"kind"`
+}
+
+// SyncFile brings the index in line with a single note file after it changed
+// outside the app. It returns nil when there is nothing to do, e.g. when the
+// file still holds exactly what we last wrote.
+func (s *Service) SyncFile(path string) (*FileChange, error) {
+	existingID, err := s.noteIDByPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.fs.FileExists(path) {
+		s.forgetHash(path)
+		if existingID == "" {
+			return nil, nil
+		}
+
+		change, _, err := s.trashVanished(existingID, path)
+		return change, err
+	}
+
+	data, err := s.fs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.hashMatches(path, data) {
+		return nil, nil
+	}
+
+	fm, content, err := parseNoteFile(data)
+	if err != nil {
+		return nil, err
+	}
+	return s.indexFile(existingID, path, fm, content, data)
+}
+
+// ApplyFile writes a note file received from elsewhere, such as a sync
+// remote, and indexes it. Data that does not parse as a note is not written.
+func (s *Service) ApplyFile(path string, data []byte) (*FileChange, error) {
+	fm, content, err := parseNoteFile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	existingID, err := s.noteIDByPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.writeNoteFile(path, data); err != nil {
+		return nil, fmt.Errorf("failed to write note file: %w", err)
+	}
+	return s.indexFile(existingID, path, fm, content, data)
+}
+
+// TrashFile moves the note at path to the trash, for a note deleted elsewhere.
+// It returns nil if no note is indexed from path.
+func (s *Service) TrashFile(path string) (*FileChange, error) {
+	id, err := s.noteIDByPath(path)
+	if err != nil || id == "" {
+		return nil, err
+	}
+
+	var title string
+	if err := s.db.QueryRow(`SELECT title FROM notes WHERE id = ?`, id).Scan(&title); err != nil {
+		return nil, fmt.Errorf("failed to get note: %w", err)
+	}
+	if err := s.DeleteNote(id); err != nil {
+		return nil, err
+	}
+	return &FileChange{NoteID: id, Title: title, Path: path, Kind: FileDeleted}, nil
+}
+
+// trashVanished moves a note whose file disappeared to the trash, rebuilding
+// the file there from its latest revision and the row's metadata. That is the
+// last content saved through the app or picked up from disk, not necessarily
+// the file's final state, and the search index holds only plain text so it
+// is no better a source. Editors and synced folders rename or move a file as
+// a delete then a create, so the note keeps its row and with it its history,
+// tags and attachments: when the file turns up again, indexFile binds it back
+// to the note by its ID. A note with no revision to rebuild it from, such as
+// one only ever indexed by Reindex, is removed; trashed reports which
+// happened.
+func (s *Service) trashVanished(id, path string) (change *FileChange, trashed bool, err error) {
+	var fm Frontmatter
+	var folderID *string
+	query := `SELECT id, title, folder_id, is_favorite, pinned, created_at, updated_at FROM notes WHERE id = ?`
+	err = s.db.QueryRow(query, id).Scan(&fm.ID, &fm.Title, &folderID, &fm.IsFavorite, &fm.Pinned, &fm.Created, &fm.Modified)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get deleted note: %w", err)
+	}
+	if folderID != nil {
+		fm.FolderID = *folderID
+	}
+	change = &FileChange{NoteID: id, Title: fm.Title, Path: path, Kind: FileDeleted}
+
+	rev, err := s.revisions.Latest(id)
+	if err != nil {
+		return nil, false, err
+	}
+	if rev == nil {
+		if err := recordTombstone(s.db, id); err != nil {
+			return nil, false, err
+		}
+		if _, err := s.db.Exec(`DELETE FROM notes WHERE id = ?`, id); err != nil {
+			return nil, false, fmt.Errorf("failed to remove deleted note: %w", err)
+		}
+		s.titles.remove(id)
+		return change, false, nil
+	}
+
+	fm.Pinned = 0
+	if fm.Tags, err = s.noteTags(id); err != nil {
+		return nil, false, err
+	}
+	markdown, err := SerializeNote(&fm, rev.Content)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to serialize note: %w", err)
+	}
+	if err := s.fs.WriteFile(trashPath(id), []byte(markdown)); err != nil {
+		return nil, false, fmt.Errorf("failed to move deleted note to trash: %w", err)
+	}
+
+	query = `UPDATE notes SET deleted_at = ?, trashed_from = ?, file_path = ?, pinned = 0 WHERE id = ?`
+	if _, err := s.db.Exec(query, time.Now(), path, trashPath(id), id); err != nil {
+		s.fs.DeleteFile(trashPath(id))
+		return nil, false, fmt.Errorf("failed to trash deleted note: %w", err)
+	}
+	s.titles.remove(id)
+	return change, true, nil
+}
+
+// parseNoteFile parses a note file, which must carry an ID
+func parseNoteFile(data []byte) (*Frontmatter, string, error) {
+	fm, content, err := parseNoteData(data)
+	if err != nil {
+		return nil, "", err
+	}
+	if fm.ID == "" {
+		return nil, "", fmt.Errorf("failed to parse note file: frontmatter has no id")
+	}
+	return fm, content, nil
+}
+
+// indexFile indexes a note file's contents. existingID is the note indexed
+// from path before, if any.
+func (s *Service) indexFile(existingID, path string, fm *Frontmatter, content string, data []byte) (*FileChange, error) {
+	if _, err := s.ensureFolder(fm.FolderID); err != nil {
+		return nil, err
+	}
+	// A trashed note comes back when its file turns up again, e.g. after a
+	// rename; its copy in the trash then goes
+	var trashed string
+	query := `SELECT file_path FROM notes WHERE id = ? AND deleted_at IS NOT NULL`
+	if err := s.db.QueryRow(query, fm.ID).Scan(&trashed); err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to look up trashed note: %w", err)
+	}
+
+	err := s.db.Transaction(func(tx *sql.Tx) error {
+		if err := s.upsertIndex(tx, fm, content, path); err != nil {
+			return err
+		}
+		// External edits are kept in the history as separate revisions
+		return s.revisions.Record(tx, fm.ID, fm.Title, content, false)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.recordHash(path, data)
+	s.titles.invalidate()
+	if trashed != "" && trashed != path && s.fs.FileExists(trashed) {
+		if err := s.fs.DeleteFile(trashed); err != nil {
+			fmt.Printf("Failed to remove trashed copy of note %s: %v\n", fm.ID, err)
+		}
+	}
+
+	kind := FileModified
+	if existingID == "" {
+		kind = FileCreated
+	}
+
+	return &FileChange{NoteID: fm.ID, Title: fm.Title, Path: path, Kind: kind}, nil
+}
+
+// noteIDByPath returns the ID of the live note indexed from path, or "" if none
+func (s *Service) noteIDByPath(path string) (string, error) {
+	var id string
+	err := s.db.QueryRow(`SELECT id FROM notes WHERE file_path = ? AND deleted_at IS NULL`, path).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up note by path: %w", err)
+	}
+	return id, nil
+}
+
+// writeNoteFile writes a note file and remembers its hash
+func (s *Service) writeNoteFile(path string, data []byte) error {
+	if err := s.fs.WriteFile(path, data); err != nil {
+		return err
+	}
+	s.recordHash(path, data)
+	return nil
+}
+
+// replaceNoteFile writes a note file and returns a function that undoes the
+// write, putting back the previous contents or removing a file that is new.
+// Callers run it when the matching database update fails.
+func (s *Service) replaceNoteFile(path string, data []byte) (func(), error) {
+	var previous []byte
+	existed := s.fs.FileExists(path)
+	if existed {
+		var err error
+		if previous, err = s.fs.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.writeNoteFile(path, data); err != nil {
+		return nil, fmt.Errorf("failed to write note file: %w", err)
+	}
+
+	return func() {
+		if existed {
+			s.writeNoteFile(path, previous)
+			return
+		}
+		s.fs.DeleteFile(path)
+		s.forgetHash(path)
+	}, nil
+}
+
+// moveNoteFile moves a note file, carrying its hash along so the watcher
+// treats the file at its new path as ours, and returns a function that moves
+// it back
+func (s *Service) moveNoteFile(from, to string) (func(), error) {
+	data, err := s.fs.ReadFile(from)
+	if err != nil {
+		return nil, err
+	}
+
+	// Record the hash first so the watcher treats the reappearing file as ours
+	s.recordHash(to, data)
+	if err := s.fs.MoveFile(from, to); err != nil {
+		s.forgetHash(to)
+		return nil, err
+	}
+	s.forgetHash(from)
+
+	return func() {
+		s.recordHash(from, data)
+		if err := s.fs.MoveFile(to, from); err == nil {
+			s.forgetHash(to)
+		}
+	}, nil
+}
+
+func (s *Service) recordHash(path string, data []byte) {
+	sum := sha256.Sum256(data)
+
+	s.hashMu.Lock()
+	s.hashes[path] = hex.EncodeToString(sum[:])
+	s.hashMu.Unlock()
+}
+
+func (s *Service) forgetHash(path string) {
+	s.hashMu.Lock()
+	delete(s.hashes, path)
+	s.hashMu.Unlock()
+}
+
+func (s *Service) hashMatches(path string, data []byte) bool {
+	sum := sha256.Sum256(data)
+
+	s.hashMu.Lock()
+	defer s.hashMu.Unlock()
+	return s.hashes[path] == hex.EncodeToString(sum[:])
+}
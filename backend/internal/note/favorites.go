@@ -0,0 +1,233 @@
+package note
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"fuknotion/backend/internal/models"
+)
+
+// SetFavorite marks or unmarks a note as a favorite in its frontmatter and the index
+func (s *Service) SetFavorite(id string, favorite bool) error {
+	note, err := s.GetNote(id)
+	if err != nil {
+		return err
+	}
+
+	if note.IsFavorite == favorite {
+		return nil
+	}
+
+	// The file changes, so the row's update time must follow or the index
+	// looks stale next to it
+	now := time.Now()
+	undo, err := s.rewriteFrontmatter(note.FilePath, func(fm *Frontmatter) {
+		fm.IsFavorite = favorite
+		fm.Modified = now
+	})
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE notes SET is_favorite = ?, updated_at = ? WHERE id = ?`
+	if _, err := s.db.Exec(query, favorite, now, id); err != nil {
+		undo()
+		return fmt.Errorf("failed to update favorite: %w", err)
+	}
+
+	return nil
+}
+
+// ListFavorites lists live favorite notes, pinned ones first, then by title.
+// Content is left empty.
+func (s *Service) ListFavorites() ([]*models.Note, error) {
+	query := `
+		SELECT id, title, folder_id, file_path, is_favorite, pinned, position, created_at, updated_at
+		FROM notes
+		WHERE is_favorite = 1 AND deleted_at IS NULL
+		ORDER BY pinned = 0, pinned, title COLLATE NOCASE, id
+	`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list favorites: %w", err)
+	}
+	defer rows.Close()
+
+	notes := []*models.Note{}
+	for rows.Next() {
+		var note models.Note
+		var folderID *string
+
+		err := rows.Scan(
+			&note.ID,
+			&note.Title,
+			&folderID,
+			&note.FilePath,
+			&note.IsFavorite,
+			&note.Pinned,
+			&note.Position,
+			&note.CreatedAt,
+			&note.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan note: %w", err)
+		}
+
+		if folderID != nil {
+			note.FolderID = *folderID
+		}
+
+		notes = append(notes, &note)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list favorites: %w", err)
+	}
+
+	if err := s.fillTags(notes); err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+// pinnedNote is a pinned note's place in the pin order
+type pinnedNote struct {
+	id, path string
+	pinned   int
+}
+
+// PinNote pins a note to the top of the note list at position among the
+// pinned notes (0 first). A negative position adds it after them. Pinning an
+// already pinned note moves it.
+func (s *Service) PinNote(id string, position int) error {
+	note, err := s.GetNote(id)
+	if err != nil {
+		return err
+	}
+
+	pins, err := s.pinnedNotes()
+	if err != nil {
+		return err
+	}
+
+	ordered := make([]pinnedNote, 0, len(pins)+1)
+	for _, pin := range pins {
+		if pin.id != id {
+			ordered = append(ordered, pin)
+		}
+	}
+
+	if position < 0 || position > len(ordered) {
+		position = len(ordered)
+	}
+	ordered = append(ordered, pinnedNote{})
+	copy(ordered[position+1:], ordered[position:])
+	ordered[position] = pinnedNote{id: id, path: note.FilePath, pinned: note.Pinned}
+
+	return s.writePins(ordered, nil)
+}
+
+// UnpinNote removes a note from the pinned notes
+func (s *Service) UnpinNote(id string) error {
+	note, err := s.GetNote(id)
+	if err != nil {
+		return err
+	}
+
+	if note.Pinned == 0 {
+		return nil
+	}
+
+	pins, err := s.pinnedNotes()
+	if err != nil {
+		return err
+	}
+
+	var ordered []pinnedNote
+	for _, pin := range pins {
+		if pin.id != id {
+			ordered = append(ordered, pin)
+		}
+	}
+
+	return s.writePins(ordered, &pinnedNote{id: id, path: note.FilePath, pinned: note.Pinned})
+}
+
+// pinnedNotes returns the live pinned notes in pin order
+func (s *Service) pinnedNotes() ([]pinnedNote, error) {
+	query := `SELECT id, file_path, pinned FROM notes WHERE pinned > 0 AND deleted_at IS NULL ORDER BY pinned, id`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pinned notes: %w", err)
+	}
+	defer rows.Close()
+
+	var pins []pinnedNote
+	for rows.Next() {
+		var pin pinnedNote
+		if err := rows.Scan(&pin.id, &pin.path, &pin.pinned); err != nil {
+			return nil, fmt.Errorf("failed to scan pinned note: %w", err)
+		}
+		pins = append(pins, pin)
+	}
+
+	return pins, rows.Err()
+}
+
+// writePins numbers the ordered notes from 1 and unpins removed, rewriting
+// the frontmatter and update time of every note whose pin changes. If any
+// step fails the files already rewritten are put back.
+func (s *Service) writePins(ordered []pinnedNote, removed *pinnedNote) error {
+	changes := make(map[string]int)
+	var changed []pinnedNote
+	for i, pin := range ordered {
+		if pin.pinned != i+1 {
+			changes[pin.id] = i + 1
+			changed = append(changed, pin)
+		}
+	}
+	if removed != nil {
+		changes[removed.id] = 0
+		changed = append(changed, *removed)
+	}
+
+	now := time.Now()
+	var undos []func()
+	undoAll := func() {
+		for i := len(undos) - 1; i >= 0; i-- {
+			undos[i]()
+		}
+	}
+
+	for _, pin := range changed {
+		pinned := changes[pin.id]
+		undo, err := s.rewriteFrontmatter(pin.path, func(fm *Frontmatter) {
+			fm.Pinned = pinned
+			fm.Modified = now
+		})
+		if err != nil {
+			undoAll()
+			return err
+		}
+		undos = append(undos, undo)
+	}
+
+	err := s.db.Transaction(func(tx *sql.Tx) error {
+		for _, pin := range changed {
+			query := `UPDATE notes SET pinned = ?, updated_at = ? WHERE id = ?`
+			if _, err := tx.Exec(query, changes[pin.id], now, pin.id); err != nil {
+				return fmt.Errorf("failed to update pin: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		undoAll()
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,268 @@
+package note
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fuknotion/backend/internal/models"
+)
+
+// fileFrontmatter reads a note file's frontmatter
+func fileFrontmatter(t *testing.T, tmpDir, filePath string) *Frontmatter {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, filePath))
+	if err != nil {
+		t.Fatalf("Failed to read note file: %v", err)
+	}
+	fm, _, err := ParseMarkdown(string(data))
+	if err != nil {
+		t.Fatalf("Failed to parse note file: %v", err)
+	}
+	return fm
+}
+
+func TestSetFavorite(t *testing.T) {
+	service, tmpDir := setupTestService(t)
+
+	note, _ := service.CreateNote("Starred", "content", "")
+	service.CreateNote("Plain", "content", "")
+
+	if err := service.SetFavorite(note.ID, true); err != nil {
+		t.Fatalf("SetFavorite() failed: %v", err)
+	}
+	if !fileFrontmatter(t, tmpDir, note.FilePath).IsFavorite {
+		t.Error("frontmatter is_favorite = false, want true")
+	}
+
+	// Saving from the editor keeps the favorite
+	if err := service.UpdateNote(note.ID, "Starred", "edited", false); err != nil {
+		t.Fatalf("UpdateNote() failed: %v", err)
+	}
+
+	favorites, err := service.ListFavorites()
+	if err != nil {
+		t.Fatalf("ListFavorites() failed: %v", err)
+	}
+	if len(favorites) != 1 || favorites[0].ID != note.ID {
+		t.Errorf("ListFavorites() = %v, want the starred note", favorites)
+	}
+
+	// The favorite survives a rebuild of the index from disk
+	service.db.Exec("DELETE FROM notes")
+	if _, err := service.Reindex(); err != nil {
+		t.Fatalf("Reindex() failed: %v", err)
+	}
+	got, _ := service.GetNote(note.ID)
+	if !got.IsFavorite {
+		t.Error("IsFavorite after reindex = false, want true")
+	}
+
+	if err := service.SetFavorite(note.ID, false); err != nil {
+		t.Fatalf("SetFavorite(false) failed: %v", err)
+	}
+	if fileFrontmatter(t, tmpDir, note.FilePath).IsFavorite {
+		t.Error("frontmatter is_favorite = true after unfavorite")
+	}
+	if favorites, _ := service.ListFavorites(); len(favorites) != 0 {
+		t.Errorf("ListFavorites() after unfavorite = %d notes, want 0", len(favorites))
+	}
+}
+
+func TestPinNote(t *testing.T) {
+	service, tmpDir := setupTestService(t)
+
+	a, _ := service.CreateNote("a", "content", "")
+	b, _ := service.CreateNote("b", "content", "")
+	c, _ := service.CreateNote("c", "content", "")
+
+	pinOrder := func() string {
+		notes, err := service.ListNotes("")
+		if err != nil {
+			t.Fatalf("ListNotes() failed: %v", err)
+		}
+		var pinned []string
+		for _, note := range notes {
+			if note.Pinned > 0 {
+				pinned = append(pinned, fmt.Sprintf("%s%d", note.Title, note.Pinned))
+			}
+		}
+		return fmt.Sprint(pinned)
+	}
+
+	service.PinNote(b.ID, -1)
+	service.PinNote(c.ID, -1)
+	if got := pinOrder(); got != "[b1 c2]" {
+		t.Errorf("pins = %v, want [b1 c2]", got)
+	}
+
+	// Pinning at the front shifts the others down
+	if err := service.PinNote(a.ID, 0); err != nil {
+		t.Fatalf("PinNote() failed: %v", err)
+	}
+	if got := pinOrder(); got != "[a1 b2 c3]" {
+		t.Errorf("pins = %v, want [a1 b2 c3]", got)
+	}
+	if fm := fileFrontmatter(t, tmpDir, c.FilePath); fm.Pinned != 3 {
+		t.Errorf("frontmatter pinned = %d, want 3", fm.Pinned)
+	}
+
+	// Pinned notes come first in the note list
+	notes, _ := service.ListNotes("")
+	if notes[0].ID != a.ID {
+		t.Errorf("first note = %s, want a", notes[0].Title)
+	}
+
+	// Repinning moves a note
+	service.PinNote(a.ID, 5)
+	if got := pinOrder(); got != "[b1 c2 a3]" {
+		t.Errorf("pins = %v, want [b1 c2 a3]", got)
+	}
+
+	if err := service.UnpinNote(b.ID); err != nil {
+		t.Fatalf("UnpinNote() failed: %v", err)
+	}
+	if got := pinOrder(); got != "[c1 a2]" {
+		t.Errorf("pins = %v, want [c1 a2]", got)
+	}
+	if fm := fileFrontmatter(t, tmpDir, b.FilePath); fm.Pinned != 0 {
+		t.Errorf("frontmatter pinned after unpin = %d, want 0", fm.Pinned)
+	}
+
+	// Pins survive a rebuild of the index from disk
+	service.db.Exec("DELETE FROM notes")
+	if _, err := service.Reindex(); err != nil {
+		t.Fatalf("Reindex() failed: %v", err)
+	}
+	if got := pinOrder(); got != "[c1 a2]" {
+		t.Errorf("pins after reindex = %v, want [c1 a2]", got)
+	}
+
+	results, err := service.SearchNotes("is:pinned")
+	if err != nil {
+		t.Fatalf("SearchNotes(is:pinned) failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("is:pinned returned %d notes, want 2", len(results))
+	}
+}
+
+func TestTrashedNoteLosesPin(t *testing.T) {
+	service, tmpDir := setupTestService(t)
+
+	a, _ := service.CreateNote("a", "content", "")
+	b, _ := service.CreateNote("b", "content", "")
+	service.PinNote(a.ID, -1)
+
+	if err := service.DeleteNote(a.ID); err != nil {
+		t.Fatalf("DeleteNote() failed: %v", err)
+	}
+	if fm := fileFrontmatter(t, tmpDir, trashPath(a.ID)); fm.Pinned != 0 {
+		t.Errorf("trashed frontmatter pinned = %d, want 0", fm.Pinned)
+	}
+
+	// Another note takes the first pin while a is in the trash
+	service.PinNote(b.ID, -1)
+
+	restored, err := service.RestoreNote(a.ID)
+	if err != nil {
+		t.Fatalf("RestoreNote() failed: %v", err)
+	}
+	if restored.Pinned != 0 {
+		t.Errorf("restored pinned = %d, want 0", restored.Pinned)
+	}
+	if fm := fileFrontmatter(t, tmpDir, restored.FilePath); fm.Pinned != 0 {
+		t.Errorf("restored frontmatter pinned = %d, want 0", fm.Pinned)
+	}
+	if got, _ := service.GetNote(b.ID); got.Pinned != 1 {
+		t.Errorf("b pinned = %d, want 1", got.Pinned)
+	}
+}
+
+func TestRestoreClearsPinLeftInTrash(t *testing.T) {
+	service, tmpDir := setupTestService(t)
+
+	note, _ := service.CreateNote("a", "content", "")
+	if err := service.DeleteNote(note.ID); err != nil {
+		t.Fatalf("DeleteNote() failed: %v", err)
+	}
+
+	// Older builds trashed notes with their pin still set
+	if _, err := service.rewriteFrontmatter(trashPath(note.ID), func(fm *Frontmatter) {
+		fm.Pinned = 1
+	}); err != nil {
+		t.Fatalf("rewriteFrontmatter() failed: %v", err)
+	}
+	hourAgo := time.Now().Add(-time.Hour)
+	service.db.Exec(`UPDATE notes SET updated_at = ?`, hourAgo)
+	os.Chtimes(filepath.Join(tmpDir, trashPath(note.ID)), hourAgo, hourAgo)
+
+	restored, err := service.RestoreNote(note.ID)
+	if err != nil {
+		t.Fatalf("RestoreNote() failed: %v", err)
+	}
+	if fm := fileFrontmatter(t, tmpDir, restored.FilePath); fm.Pinned != 0 {
+		t.Errorf("restored frontmatter pinned = %d, want 0", fm.Pinned)
+	}
+	drift, err := service.NeedsReindex()
+	if err != nil {
+		t.Fatalf("NeedsReindex() failed: %v", err)
+	}
+	if drift {
+		t.Error("NeedsReindex() = true after restore, want false")
+	}
+}
+
+func TestFavoriteAndPinKeepIndexFresh(t *testing.T) {
+	service, tmpDir := setupTestService(t)
+
+	a, _ := service.CreateNote("a", "content", "")
+	b, _ := service.CreateNote("b", "content", "")
+
+	for _, step := range []struct {
+		name   string
+		change func() error
+	}{
+		{"SetFavorite", func() error { return service.SetFavorite(a.ID, true) }},
+		{"PinNote", func() error { return service.PinNote(b.ID, -1) }},
+		{"UnpinNote", func() error { return service.UnpinNote(b.ID) }},
+	} {
+		backdate(t, service, tmpDir, a, b)
+		if err := step.change(); err != nil {
+			t.Fatalf("%s() failed: %v", step.name, err)
+		}
+		checkFresh(t, service, step.name)
+	}
+}
+
+// backdate makes notes look last touched, in the index and on disk, well
+// before whatever the test does next
+func backdate(t *testing.T, service *Service, tmpDir string, notes ...*models.Note) {
+	t.Helper()
+
+	hourAgo := time.Now().Add(-time.Hour)
+	for _, note := range notes {
+		if _, err := service.db.Exec(`UPDATE notes SET updated_at = ? WHERE id = ?`, hourAgo, note.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filepath.Join(tmpDir, note.FilePath), hourAgo, hourAgo); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkFresh fails the test if the index looks stale next to the files
+func checkFresh(t *testing.T, service *Service, after string) {
+	t.Helper()
+
+	drift, err := service.NeedsReindex()
+	if err != nil {
+		t.Fatalf("NeedsReindex() failed: %v", err)
+	}
+	if drift {
+		t.Errorf("NeedsReindex() = true after %s, want false", after)
+	}
+}
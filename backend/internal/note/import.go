@@ -0,0 +1,121 @@
+package note
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fuknotion/backend/internal/attachment"
+	"fuknotion/backend/internal/models"
+)
+
+// HasNote reports whether a note with id exists, live or in the trash
+func (s *Service) HasNote(id string) (bool, error) {
+	var exists bool
+	if err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM notes WHERE id = ?)`, id).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check note: %w", err)
+	}
+	return exists, nil
+}
+
+// ImportNote adds a note brought in from elsewhere, keeping the ID, title,
+// timestamps, favorite flag and tags given in fm rather than generating them.
+// It fails if a note with that ID already exists, even in the trash.
+func (s *Service) ImportNote(fm *Frontmatter, content string) (*models.Note, error) {
+	if fm.ID == "" || strings.ContainsAny(fm.ID, `/\`) || fm.ID != strings.TrimSpace(fm.ID) {
+		return nil, fmt.Errorf("invalid note id: %q", fm.ID)
+	}
+	if exists, err := s.HasNote(fm.ID); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, fmt.Errorf("note already exists: %s", fm.ID)
+	}
+	if err := s.checkFolder(fm.FolderID); err != nil {
+		return nil, err
+	}
+
+	if fm.Created.IsZero() {
+		fm.Created = time.Now()
+	}
+	if fm.Modified.IsZero() {
+		fm.Modified = fm.Created
+	}
+	fm.Pinned = max(fm.Pinned, 0)
+	fm.Tags = normalizeTags(fm.Tags)
+
+	filePath := filepath.Join("notes", fm.ID+".md")
+
+	markdown, err := SerializeNote(fm, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize note: %w", err)
+	}
+
+	undo, err := s.replaceNoteFile(filePath, []byte(markdown))
+	if err != nil {
+		return nil, err
+	}
+
+	var folderIDPtr *string
+	if fm.FolderID != "" {
+		folderIDPtr = &fm.FolderID
+	}
+
+	var position int
+	err = s.db.Transaction(func(tx *sql.Tx) error {
+		var err error
+		if position, err = nextPosition(tx, fm.FolderID); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO notes (id, title, folder_id, file_path, is_favorite, pinned, position, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`
+		_, err = tx.Exec(query, fm.ID, fm.Title, folderIDPtr, filePath, fm.IsFavorite, fm.Pinned, position, fm.Created, fm.Modified)
+		if err != nil {
+			return fmt.Errorf("failed to insert note: %w", err)
+		}
+
+		if err := indexContent(tx, fm.ID, content); err != nil {
+			return err
+		}
+		if err := s.indexTags(tx, fm.ID, fm.Tags); err != nil {
+			return err
+		}
+		if err := s.indexLinks(tx, fm.ID, content); err != nil {
+			return err
+		}
+		if err := attachment.IndexRefs(tx, fm.ID, content); err != nil {
+			return err
+		}
+		return s.revisions.Record(tx, fm.ID, fm.Title, content, false)
+	})
+	if err != nil {
+		undo()
+		return nil, err
+	}
+
+	// Date the file like the note, or the drift check would see it as edited
+	// since it was indexed
+	if abs, err := s.fs.ResolvePath(filePath); err == nil {
+		os.Chtimes(abs, fm.Modified, fm.Modified)
+	}
+	s.titles.put(fm.ID, fm.Title, fm.FolderID)
+
+	return &models.Note{
+		ID:         fm.ID,
+		Title:      fm.Title,
+		FolderID:   fm.FolderID,
+		FilePath:   filePath,
+		IsFavorite: fm.IsFavorite,
+		Pinned:     fm.Pinned,
+		Position:   position,
+		Tags:       fm.Tags,
+		Content:    content,
+		CreatedAt:  fm.Created,
+		UpdatedAt:  fm.Modified,
+	}, nil
+}
@@ -0,0 +1,51 @@
+package note
+
+import (
+	"testing"
+	"time"
+)
+
+func TestImportNote(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	created := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	fm := &Frontmatter{
+		ID:       "imported-1",
+		Title:    "From elsewhere",
+		Created:  created,
+		Modified: created.Add(time.Hour),
+		Tags:     []string{"#old", "old", "new"},
+	}
+
+	imported, err := service.ImportNote(fm, "Body with [[Other]]")
+	if err != nil {
+		t.Fatalf("ImportNote() failed: %v", err)
+	}
+
+	got, err := service.GetNote("imported-1")
+	if err != nil {
+		t.Fatalf("GetNote() failed: %v", err)
+	}
+	if got.Title != "From elsewhere" || got.Content != "Body with [[Other]]" || got.Position != imported.Position {
+		t.Errorf("GetNote() = %+v", got)
+	}
+	if !got.CreatedAt.Equal(created) || !got.UpdatedAt.Equal(created.Add(time.Hour)) {
+		t.Errorf("times = %v, %v, want the imported ones", got.CreatedAt, got.UpdatedAt)
+	}
+	if len(got.Tags) != 2 {
+		t.Errorf("Tags = %v, want old and new", got.Tags)
+	}
+	if results, _ := service.SearchNotes("elsewhere"); len(results) != 1 {
+		t.Errorf("SearchNotes() = %d results, want 1", len(results))
+	}
+	if drift, _ := service.NeedsReindex(); drift {
+		t.Error("NeedsReindex() = true after import")
+	}
+
+	if _, err := service.ImportNote(&Frontmatter{ID: "imported-1", Title: "Again"}, ""); err == nil {
+		t.Error("ImportNote() with a taken ID succeeded")
+	}
+	if _, err := service.ImportNote(&Frontmatter{ID: "../escape", Title: "Bad"}, ""); err == nil {
+		t.Error("ImportNote() with a path in its ID succeeded")
+	}
+}
@@ -0,0 +1,294 @@
+package note
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fuknotion/backend/internal/attachment"
+	"fuknotion/backend/internal/database"
+)
+
+// ReindexFailure describes a markdown file that could not be indexed
+type ReindexFailure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// ReindexReport summarizes a rebuild of the notes index from disk
+type ReindexReport struct {
+	Indexed          int              `json:"indexed"`
+	Trashed          int              `json:"trashed"`
+	Removed          int              `json:"removed"`
+	RecoveredFolders []string         `json:"recoveredFolders"`
+	Failed           []ReindexFailure `json:"failed"`
+}
+
+// indexedRow is the subset of a notes row used to detect drift
+type indexedRow struct {
+	id        string
+	filePath  string
+	updatedAt time.Time
+}
+
+// Reindex rebuilds the notes and notes_fts tables from the markdown files on
+// disk, which are the source of truth. Files that cannot be parsed are
+// reported and their existing rows, if any, are left alone.
+func (s *Service) Reindex() (*ReindexReport, error) {
+	// Titles change in bulk, so the quick switcher reloads them afterwards
+	defer s.titles.invalidate()
+
+	report := &ReindexReport{RecoveredFolders: []string{}, Failed: []ReindexFailure{}}
+
+	files, err := s.markdownFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	onDisk := make(map[string]bool, len(files))
+	seen := make(map[string]string, len(files))
+
+	for _, path := range files {
+		onDisk[path] = true
+
+		data, err := s.fs.ReadFile(path)
+		if err != nil {
+			report.Failed = append(report.Failed, ReindexFailure{Path: path, Error: err.Error()})
+			continue
+		}
+
+		fm, content, err := parseNoteData(data)
+		if err != nil {
+			report.Failed = append(report.Failed, ReindexFailure{Path: path, Error: err.Error()})
+			continue
+		}
+		if fm.ID == "" {
+			report.Failed = append(report.Failed, ReindexFailure{Path: path, Error: "frontmatter has no id"})
+			continue
+		}
+		if other, dup := seen[fm.ID]; dup {
+			report.Failed = append(report.Failed, ReindexFailure{
+				Path:  path,
+				Error: fmt.Sprintf("duplicate note id %s (already indexed from %s)", fm.ID, other),
+			})
+			continue
+		}
+		seen[fm.ID] = path
+
+		recovered, err := s.ensureFolder(fm.FolderID)
+		if err != nil {
+			return nil, err
+		}
+		if recovered {
+			report.RecoveredFolders = append(report.RecoveredFolders, fm.FolderID)
+		}
+
+		err = s.db.Transaction(func(tx *sql.Tx) error {
+			return s.upsertIndex(tx, fm, content, path)
+		})
+		if err != nil {
+			report.Failed = append(report.Failed, ReindexFailure{Path: path, Error: err.Error()})
+			continue
+		}
+		s.recordHash(path, data)
+		report.Indexed++
+	}
+
+	// Notes whose files are gone go to the trash, as in SyncFile
+	rows, err := s.indexedRows()
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if _, ok := seen[row.id]; ok || onDisk[row.filePath] {
+			continue
+		}
+		_, trashed, err := s.trashVanished(row.id, row.filePath)
+		if err != nil {
+			return nil, err
+		}
+		if trashed {
+			report.Trashed++
+		} else {
+			report.Removed++
+		}
+	}
+
+	if err := s.reindexTrash(report); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// NeedsReindex reports whether the index has drifted from the files on disk:
+// a file without a row, a row without a file, or a file modified after its
+// row was last updated.
+func (s *Service) NeedsReindex() (bool, error) {
+	files, err := s.markdownFiles()
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := s.indexedRows()
+	if err != nil {
+		return false, err
+	}
+
+	if len(files) != len(rows) {
+		return true, nil
+	}
+
+	trashFiles, err := s.fs.WalkFiles(TrashDir)
+	if err != nil {
+		return false, err
+	}
+	var trashed int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM notes WHERE deleted_at IS NOT NULL`).Scan(&trashed); err != nil {
+		return false, fmt.Errorf("failed to count trashed notes: %w", err)
+	}
+	if len(trashFiles) != trashed {
+		return true, nil
+	}
+
+	byPath := make(map[string]indexedRow, len(rows))
+	for _, row := range rows {
+		byPath[row.filePath] = row
+	}
+
+	for _, path := range files {
+		row, ok := byPath[path]
+		if !ok {
+			return true, nil
+		}
+
+		info, err := s.fs.Stat(path)
+		if err != nil {
+			return true, nil
+		}
+		// Allow for filesystem timestamp granularity
+		if info.ModTime().After(row.updatedAt.Add(2 * time.Second)) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// markdownFiles lists the note files in the workspace
+func (s *Service) markdownFiles() ([]string, error) {
+	files, err := s.fs.WalkFiles(".")
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []string
+	for _, path := range files {
+		if strings.EqualFold(filepath.Ext(path), ".md") {
+			notes = append(notes, path)
+		}
+	}
+	return notes, nil
+}
+
+// indexedRows loads the id, path and update time of every live indexed note
+func (s *Service) indexedRows() ([]indexedRow, error) {
+	rows, err := s.db.Query(`SELECT id, file_path, updated_at FROM notes WHERE deleted_at IS NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexed notes: %w", err)
+	}
+	defer rows.Close()
+
+	var result []indexedRow
+	for rows.Next() {
+		var row indexedRow
+		if err := rows.Scan(&row.id, &row.filePath, &row.updatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan indexed note: %w", err)
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// ensureFolder creates a placeholder row for a folder referenced from
+// frontmatter but missing from the database, so notes keep their grouping.
+// It reports whether a placeholder was created.
+func (s *Service) ensureFolder(folderID string) (bool, error) {
+	if folderID == "" {
+		return false, nil
+	}
+
+	result, err := s.db.Exec(
+		`INSERT OR IGNORE INTO folders (id, name, position) VALUES (?, ?, (SELECT COUNT(*) FROM folders WHERE parent_id IS NULL))`,
+		folderID, "Recovered folder",
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to recover folder: %w", err)
+	}
+
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// upsertIndex writes a note's metadata, searchable content, tags and links
+// from its file. q is normally a transaction covering all of them.
+func (s *Service) upsertIndex(q database.Executor, fm *Frontmatter, content, path string) error {
+	// A different note may have previously been indexed from this path
+	if _, err := q.Exec(`DELETE FROM notes WHERE file_path = ? AND id != ?`, path, fm.ID); err != nil {
+		return fmt.Errorf("failed to clear previous note at path: %w", err)
+	}
+	// A note deleted for good may come back, e.g. from a peer that edited it
+	if _, err := q.Exec(`DELETE FROM note_tombstones WHERE note_id = ?`, fm.ID); err != nil {
+		return fmt.Errorf("failed to clear deleted note: %w", err)
+	}
+
+	var folderIDPtr *string
+	if fm.FolderID != "" {
+		folderIDPtr = &fm.FolderID
+	}
+
+	created, modified := fm.Created, fm.Modified
+	if created.IsZero() {
+		created = time.Now()
+	}
+	if modified.IsZero() {
+		modified = created
+	}
+	// External edits may not touch the frontmatter, so trust the newer mtime
+	if info, err := s.fs.Stat(path); err == nil && info.ModTime().After(modified) {
+		modified = info.ModTime()
+	}
+
+	query := `
+		INSERT INTO notes (id, title, folder_id, file_path, is_favorite, pinned, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			title = excluded.title,
+			folder_id = excluded.folder_id,
+			file_path = excluded.file_path,
+			is_favorite = excluded.is_favorite,
+			pinned = excluded.pinned,
+			created_at = excluded.created_at,
+			updated_at = excluded.updated_at,
+			deleted_at = NULL,
+			trashed_from = NULL
+	`
+	_, err := q.Exec(query, fm.ID, fm.Title, folderIDPtr, path, fm.IsFavorite, max(fm.Pinned, 0), created, modified)
+	if err != nil {
+		return fmt.Errorf("failed to upsert note: %w", err)
+	}
+
+	if err := indexContent(q, fm.ID, content); err != nil {
+		return err
+	}
+
+	if err := s.indexTags(q, fm.ID, normalizeTags(fm.Tags)); err != nil {
+		return err
+	}
+	if err := s.indexLinks(q, fm.ID, content); err != nil {
+		return err
+	}
+	return attachment.IndexRefs(q, fm.ID, content)
+}
@@ -0,0 +1,109 @@
+package note
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReindexRebuildsFromFiles(t *testing.T) {
+	service, tmpDir := setupTestService(t)
+
+	first, err := service.CreateNote("First", "alpha content", "")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	second, err := service.CreateNote("Second", "beta content", "")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	// Simulate a lost index
+	if _, err := service.db.Exec("DELETE FROM notes"); err != nil {
+		t.Fatalf("Failed to clear notes: %v", err)
+	}
+
+	drift, err := service.NeedsReindex()
+	if err != nil {
+		t.Fatalf("NeedsReindex() failed: %v", err)
+	}
+	if !drift {
+		t.Error("NeedsReindex() = false with empty index, want true")
+	}
+
+	// An unparseable file is reported, not fatal
+	broken := filepath.Join(tmpDir, "notes", "broken.md")
+	if err := os.WriteFile(broken, []byte("no frontmatter here"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := service.Reindex()
+	if err != nil {
+		t.Fatalf("Reindex() failed: %v", err)
+	}
+	if report.Indexed != 2 {
+		t.Errorf("Indexed = %d, want 2", report.Indexed)
+	}
+	if len(report.Failed) != 1 || report.Failed[0].Path != filepath.Join("notes", "broken.md") {
+		t.Errorf("Failed = %v, want broken.md", report.Failed)
+	}
+
+	got, err := service.GetNote(first.ID)
+	if err != nil {
+		t.Fatalf("GetNote() after reindex failed: %v", err)
+	}
+	if got.Title != "First" {
+		t.Errorf("Title = %v, want First", got.Title)
+	}
+
+	results, err := service.SearchNotes("beta")
+	if err != nil {
+		t.Fatalf("SearchNotes() failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Note.ID != second.ID {
+		t.Errorf("search after reindex did not find second note")
+	}
+}
+
+func TestReindexTrashesMissingFiles(t *testing.T) {
+	service, tmpDir := setupTestService(t)
+
+	note, err := service.CreateNote("Gone", "content", "")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(tmpDir, note.FilePath)); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := service.Reindex()
+	if err != nil {
+		t.Fatalf("Reindex() failed: %v", err)
+	}
+	if report.Trashed != 1 || report.Removed != 0 {
+		t.Errorf("Trashed, Removed = %d, %d, want 1, 0", report.Trashed, report.Removed)
+	}
+
+	if _, err := service.GetNote(note.ID); err == nil {
+		t.Error("Note is still live after its file was removed")
+	}
+	restored, err := service.RestoreNote(note.ID)
+	if err != nil {
+		t.Fatalf("RestoreNote() failed: %v", err)
+	}
+	if restored.FilePath != note.FilePath {
+		t.Errorf("FilePath = %q, want %q", restored.FilePath, note.FilePath)
+	}
+	if restored.Content != "content" {
+		t.Errorf("Content = %q, want content", restored.Content)
+	}
+
+	drift, err := service.NeedsReindex()
+	if err != nil {
+		t.Fatalf("NeedsReindex() failed: %v", err)
+	}
+	if drift {
+		t.Error("NeedsReindex() = true after reindex, want false")
+	}
+}
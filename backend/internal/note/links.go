@@ -0,0 +1,274 @@
+package note
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"fuknotion/backend/internal/database"
+)
+
+// WikiLink is a [[target#heading|alias]] reference found in note content
+type WikiLink struct {
+	Target  string `json:"target"` // note title or ID
+	Heading string `json:"heading,omitempty"`
+	Alias   string `json:"alias,omitempty"`
+	Line    int    `json:"line"` // 1-based
+
+	start, end int // byte offsets of the whole [[...]] in the content
+}
+
+// Link is an indexed wiki link between two notes. TargetID is empty when the
+// link does not resolve to a live note.
+type Link struct {
+	SourceID    string `json:"sourceId"`
+	SourceTitle string `json:"sourceTitle"`
+	TargetID    string `json:"targetId,omitempty"`
+	TargetTitle string `json:"targetTitle,omitempty"`
+	WikiLink
+}
+
+var wikiLinkPattern = regexp.MustCompile(`\[\[([^\[\]\n]+)\]\]`)
+
+// ParseLinks extracts wiki links from markdown, ignoring code blocks and inline code
+func ParseLinks(content string) []WikiLink {
+	masked := maskCode(content)
+
+	var links []WikiLink
+	for _, m := range wikiLinkPattern.FindAllStringSubmatchIndex(masked, -1) {
+		inner := content[m[2]:m[3]]
+
+		var link WikiLink
+		target := inner
+		if i := strings.Index(inner, "|"); i >= 0 {
+			target, link.Alias = inner[:i], strings.TrimSpace(inner[i+1:])
+		}
+		if i := strings.Index(target, "#"); i >= 0 {
+			target, link.Heading = target[:i], strings.TrimSpace(target[i+1:])
+		}
+		link.Target = strings.TrimSpace(target)
+
+		// [[#heading]] points within the same note
+		if link.Target == "" {
+			continue
+		}
+
+		link.Line = strings.Count(content[:m[0]], "\n") + 1
+		link.start, link.end = m[0], m[1]
+		links = append(links, link)
+	}
+
+	return links
+}
+
+// maskCode blanks out fenced code blocks and inline code spans, keeping byte
+// offsets intact, so links inside code are not picked up
+func maskCode(content string) string {
+	out := []byte(content)
+	inFence := false
+	offset := 0
+
+	for _, line := range strings.SplitAfter(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		isFence := strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
+
+		if inFence || isFence {
+			blank(out[offset : offset+len(line)])
+			if isFence {
+				inFence = !inFence
+			}
+		} else {
+			// Blank matched pairs of backticks on the line
+			open := -1
+			for i := 0; i < len(line); i++ {
+				if line[i] != '`' {
+					continue
+				}
+				if open < 0 {
+					open = i
+				} else {
+					blank(out[offset+open : offset+i+1])
+					open = -1
+				}
+			}
+		}
+
+		offset += len(line)
+	}
+
+	return string(out)
+}
+
+func blank(b []byte) {
+	for i := range b {
+		if b[i] != '\n' {
+			b[i] = ' '
+		}
+	}
+}
+
+// ReplaceLinks replaces each wiki link in content, brackets included, with
+// the text replace returns for it. Links for which replace returns false are
+// left as they are.
+func ReplaceLinks(content string, replace func(link WikiLink) (string, bool)) string {
+	var b strings.Builder
+	last := 0
+
+	for _, link := range ParseLinks(content) {
+		text, ok := replace(link)
+		if !ok {
+			continue
+		}
+		b.WriteString(content[last:link.start])
+		b.WriteString(text)
+		last = link.end
+	}
+
+	if last == 0 {
+		return content
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+// rewriteLinkTarget points every link to oldTarget at newTarget, keeping
+// headings and aliases. It reports how many links were changed.
+func rewriteLinkTarget(content, oldTarget, newTarget string) (string, int) {
+	changed := 0
+	content = ReplaceLinks(content, func(link WikiLink) (string, bool) {
+		if !strings.EqualFold(link.Target, oldTarget) {
+			return "", false
+		}
+
+		inner := newTarget
+		if link.Heading != "" {
+			inner += "#" + link.Heading
+		}
+		if link.Alias != "" {
+			inner += "|" + link.Alias
+		}
+
+		changed++
+		return "[[" + inner + "]]", true
+	})
+	return content, changed
+}
+
+// resolvedLinks resolves each indexed link to a live note: an ID match wins,
+// otherwise the most recently updated note with that title
+const resolvedLinks = `
+	WITH resolved AS (
+		SELECT l.source_id, l.target, l.heading, l.alias, l.line,
+			COALESCE(
+				(SELECT id FROM notes WHERE id = l.target AND deleted_at IS NULL),
+				(SELECT id FROM notes WHERE title = l.target COLLATE NOCASE AND deleted_at IS NULL
+					ORDER BY updated_at DESC LIMIT 1)
+			) AS target_id
+		FROM links l
+		JOIN notes src ON src.id = l.source_id AND src.deleted_at IS NULL
+	)
+	SELECT r.source_id, src.title, r.target, r.heading, r.alias, r.line,
+		COALESCE(r.target_id, ''), COALESCE(t.title, '')
+	FROM resolved r
+	JOIN notes src ON src.id = r.source_id
+	LEFT JOIN notes t ON t.id = r.target_id
+`
+
+// GetBacklinks lists the links from other live notes that resolve to noteID
+func (s *Service) GetBacklinks(noteID string) ([]*Link, error) {
+	return s.queryLinks(resolvedLinks+` WHERE r.target_id = ? ORDER BY src.title COLLATE NOCASE, r.line`, noteID)
+}
+
+// GetOutgoingLinks lists the links in a note, resolved where possible
+func (s *Service) GetOutgoingLinks(noteID string) ([]*Link, error) {
+	return s.queryLinks(resolvedLinks+` WHERE r.source_id = ? ORDER BY r.line`, noteID)
+}
+
+// FindBrokenLinks lists links in live notes that do not resolve to any live note
+func (s *Service) FindBrokenLinks() ([]*Link, error) {
+	return s.queryLinks(resolvedLinks + ` WHERE r.target_id IS NULL ORDER BY src.title COLLATE NOCASE, r.line`)
+}
+
+func (s *Service) queryLinks(query string, args ...interface{}) ([]*Link, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query links: %w", err)
+	}
+	defer rows.Close()
+
+	links := []*Link{}
+	for rows.Next() {
+		var l Link
+		err := rows.Scan(
+			&l.SourceID, &l.SourceTitle,
+			&l.Target, &l.Heading, &l.Alias, &l.Line,
+			&l.TargetID, &l.TargetTitle,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan link: %w", err)
+		}
+		links = append(links, &l)
+	}
+
+	return links, rows.Err()
+}
+
+// indexLinks replaces a note's rows in links with those parsed from content
+func (s *Service) indexLinks(q database.Executor, noteID, content string) error {
+	if _, err := q.Exec(`DELETE FROM links WHERE source_id = ?`, noteID); err != nil {
+		return fmt.Errorf("failed to clear note links: %w", err)
+	}
+
+	query := `INSERT INTO links (source_id, target, heading, alias, line) VALUES (?, ?, ?, ?, ?)`
+	for _, link := range ParseLinks(content) {
+		if _, err := q.Exec(query, noteID, link.Target, link.Heading, link.Alias, link.Line); err != nil {
+			return fmt.Errorf("failed to index link: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// titleLinkSources returns the live notes linking to noteID by its title
+// rather than its ID
+func (s *Service) titleLinkSources(noteID, title string) ([]string, error) {
+	links, err := s.GetBacklinks(noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	var sources []string
+	seen := make(map[string]bool)
+	for _, link := range links {
+		if strings.EqualFold(link.Target, title) && !seen[link.SourceID] {
+			seen[link.SourceID] = true
+			sources = append(sources, link.SourceID)
+		}
+	}
+	return sources, nil
+}
+
+// rewriteLinks updates links in the given notes that point at oldTitle. Each
+// note is rewritten on its own, so one that fails does not stop the rest;
+// the error lists every note left pointing at the old title.
+func (s *Service) rewriteLinks(sources []string, oldTitle, newTitle string) error {
+	var errs []error
+	for _, sourceID := range sources {
+		source, err := s.GetNote(sourceID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to rewrite links in note %s: %w", sourceID, err))
+			continue
+		}
+
+		content, changed := rewriteLinkTarget(source.Content, oldTitle, newTitle)
+		if changed == 0 {
+			continue
+		}
+		if err := s.updateNote(sourceID, source.Title, content, false); err != nil {
+			errs = append(errs, fmt.Errorf("failed to rewrite links in note %s: %w", sourceID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
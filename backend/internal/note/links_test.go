@@ -0,0 +1,154 @@
+package note
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fuknotion/backend/internal/models"
+)
+
+func TestParseLinks(t *testing.T) {
+	content := "See [[Project Plan]] and [[abc-123|the spec]].\n" +
+		"Jump to [[Ideas#Later|later ideas]] or [[#Local heading]].\n" +
+		"```\n[[Not a link]]\n```\n" +
+		"Inline `[[code]]` is ignored, [[ Spaced ]] is trimmed.\n"
+
+	links := ParseLinks(content)
+
+	want := []WikiLink{
+		{Target: "Project Plan", Line: 1},
+		{Target: "abc-123", Alias: "the spec", Line: 1},
+		{Target: "Ideas", Heading: "Later", Alias: "later ideas", Line: 2},
+		{Target: "Spaced", Line: 6},
+	}
+	if len(links) != len(want) {
+		t.Fatalf("ParseLinks() returned %d links, want %d: %+v", len(links), len(want), links)
+	}
+	for i, w := range want {
+		l := links[i]
+		if l.Target != w.Target || l.Heading != w.Heading || l.Alias != w.Alias || l.Line != w.Line {
+			t.Errorf("link %d = %+v, want %+v", i, l, w)
+		}
+	}
+}
+
+func TestRewriteLinkTarget(t *testing.T) {
+	content := "[[old]] [[Old#h|alias]] [[other]] `[[old]]`"
+
+	got, changed := rewriteLinkTarget(content, "old", "New")
+	if want := "[[New]] [[New#h|alias]] [[other]] `[[old]]`"; got != want {
+		t.Errorf("rewriteLinkTarget() = %q, want %q", got, want)
+	}
+	if changed != 2 {
+		t.Errorf("changed = %d, want 2", changed)
+	}
+}
+
+func TestBacklinksAndBrokenLinks(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	target, _ := service.CreateNote("Target", "", "")
+	byTitle, _ := service.CreateNote("By title", "Links to [[target]] and [[Missing]]", "")
+	byID, _ := service.CreateNote("By ID", "Links to [["+target.ID+"|it]]", "")
+
+	backlinks, err := service.GetBacklinks(target.ID)
+	if err != nil {
+		t.Fatalf("GetBacklinks() failed: %v", err)
+	}
+	if len(backlinks) != 2 {
+		t.Fatalf("GetBacklinks() returned %d links, want 2", len(backlinks))
+	}
+	sources := map[string]bool{backlinks[0].SourceID: true, backlinks[1].SourceID: true}
+	if !sources[byTitle.ID] || !sources[byID.ID] {
+		t.Errorf("GetBacklinks() sources = %v, want both linking notes", sources)
+	}
+
+	outgoing, err := service.GetOutgoingLinks(byTitle.ID)
+	if err != nil {
+		t.Fatalf("GetOutgoingLinks() failed: %v", err)
+	}
+	if len(outgoing) != 2 || outgoing[0].TargetID != target.ID || outgoing[1].TargetID != "" {
+		t.Errorf("GetOutgoingLinks() = %+v, want one resolved and one broken link", outgoing)
+	}
+
+	broken, err := service.FindBrokenLinks()
+	if err != nil {
+		t.Fatalf("FindBrokenLinks() failed: %v", err)
+	}
+	if len(broken) != 1 || broken[0].Target != "Missing" {
+		t.Errorf("FindBrokenLinks() = %+v, want the link to Missing", broken)
+	}
+
+	// Creating the missing note fixes the link without touching its source
+	service.CreateNote("Missing", "", "")
+	if broken, _ := service.FindBrokenLinks(); len(broken) != 0 {
+		t.Errorf("FindBrokenLinks() after creating the target = %+v, want none", broken)
+	}
+
+	// Trashing the target breaks both links to it
+	service.DeleteNote(target.ID)
+	if broken, _ := service.FindBrokenLinks(); len(broken) != 2 {
+		t.Errorf("FindBrokenLinks() after trashing the target returned %d links, want 2", len(broken))
+	}
+}
+
+func TestUpdateNoteRewritesLinks(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	target, _ := service.CreateNote("Old name", "", "")
+	source, _ := service.CreateNote("Source", "See [[Old name#Intro|intro]] and [["+target.ID+"]]", "")
+	other, _ := service.CreateNote("Other", "See [[Old name]]", "")
+
+	if err := service.UpdateNote(target.ID, "New name", "", true); err != nil {
+		t.Fatalf("UpdateNote() failed: %v", err)
+	}
+
+	got, _ := service.GetNote(source.ID)
+	if want := "See [[New name#Intro|intro]] and [[" + target.ID + "]]"; got.Content != want {
+		t.Errorf("source content = %q, want %q", got.Content, want)
+	}
+	got, _ = service.GetNote(other.ID)
+	if want := "See [[New name]]"; got.Content != want {
+		t.Errorf("second source content = %q, want %q", got.Content, want)
+	}
+
+	backlinks, _ := service.GetBacklinks(target.ID)
+	if len(backlinks) != 3 {
+		t.Errorf("GetBacklinks() after rename returned %d links, want 3", len(backlinks))
+	}
+
+	if err := service.UpdateNote(target.ID, "Newer name", "", false); err != nil {
+		t.Fatalf("UpdateNote() failed: %v", err)
+	}
+	// Without rewriting, title links break on rename
+	if broken, _ := service.FindBrokenLinks(); len(broken) != 2 {
+		t.Errorf("FindBrokenLinks() after rename without rewrite returned %d links, want 2", len(broken))
+	}
+}
+
+func TestRewriteLinksReportsEveryFailure(t *testing.T) {
+	service, tmpDir := setupTestService(t)
+
+	target, _ := service.CreateNote("Old name", "", "")
+	first, _ := service.CreateNote("First", "See [[Old name]]", "")
+	broken, _ := service.CreateNote("Broken", "See [[Old name]]", "")
+	last, _ := service.CreateNote("Last", "See [[Old name]]", "")
+
+	if err := os.Remove(filepath.Join(tmpDir, broken.FilePath)); err != nil {
+		t.Fatal(err)
+	}
+
+	err := service.UpdateNote(target.ID, "New name", "", true)
+	if err == nil || !strings.Contains(err.Error(), broken.ID) {
+		t.Fatalf("UpdateNote() error = %v, want one naming %s", err, broken.ID)
+	}
+
+	for _, n := range []*models.Note{first, last} {
+		got, _ := service.GetNote(n.ID)
+		if want := "See [[New name]]"; got.Content != want {
+			t.Errorf("%s content = %q, want %q", n.Title, got.Content, want)
+		}
+	}
+}
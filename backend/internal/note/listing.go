@@ -0,0 +1,364 @@
+package note
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"fuknotion/backend/internal/database"
+	"fuknotion/backend/internal/models"
+)
+
+// RootFolderID selects notes at the workspace root in ListOptions.FolderID
+const RootFolderID = "root"
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// ListOptions selects, orders and pages the notes returned by ListNotePage
+type ListOptions struct {
+	FolderID      string     `json:"folderId,omitempty"` // RootFolderID for root notes, "" for all
+	FavoritesOnly bool       `json:"favoritesOnly,omitempty"`
+	Tag           string     `json:"tag,omitempty"`
+	DateField     string     `json:"dateField,omitempty"` // "created" or "updated" (default) for From/To
+	From          *time.Time `json:"from,omitempty"`      // inclusive
+	To            *time.Time `json:"to,omitempty"`        // exclusive
+	Sort          string     `json:"sort,omitempty"`      // "title", "created", "updated" (default) or "position"
+	Order         string     `json:"order,omitempty"`     // "asc" or "desc"; defaults to desc for dates, asc otherwise
+	Limit         int        `json:"limit,omitempty"`     // defaults to 50, at most 500
+	Cursor        string     `json:"cursor,omitempty"`    // NextCursor from the previous page
+}
+
+// NotePage is one page of notes. NextCursor is empty on the last page.
+type NotePage struct {
+	Notes      []*models.Note `json:"notes"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
+
+// pinKey orders pinned notes first, by pin, ahead of every sort
+const pinKey = "(n.pinned = 0, n.pinned)"
+
+// sortColumns maps sort names to the expressions notes are ordered by
+var sortColumns = map[string]string{
+	"title":    "n.title COLLATE NOCASE",
+	"created":  "n.created_at",
+	"updated":  "n.updated_at",
+	"position": "n.position",
+}
+
+// pageCursor marks where a page ended. Listings keep the pin, sort key and
+// ID of the last note, the key as SQLite returned it so it compares exactly
+// on reuse; ranked search results keep the query and an offset instead.
+type pageCursor struct {
+	Sort   string      `json:"s"`
+	Order  string      `json:"o,omitempty"`
+	Pinned int         `json:"p,omitempty"`
+	Key    interface{} `json:"k,omitempty"`
+	ID     string      `json:"id,omitempty"`
+	Query  string      `json:"q,omitempty"`
+	Offset int         `json:"off,omitempty"`
+}
+
+// ListNotePage lists live notes one page at a time using keyset pagination,
+// so pages stay stable while notes are added. Pinned notes come first in pin
+// order, as in ListNotes, whatever the sort. It reads only the index, never
+// the note files, so Content is left empty.
+func (s *Service) ListNotePage(opts ListOptions) (*NotePage, error) {
+	sortName := opts.Sort
+	if sortName == "" {
+		sortName = "updated"
+	}
+	column, ok := sortColumns[sortName]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort: %s", opts.Sort)
+	}
+
+	order := strings.ToLower(opts.Order)
+	if order == "" {
+		order = "asc"
+		if sortName == "created" || sortName == "updated" {
+			order = "desc"
+		}
+	}
+	if order != "asc" && order != "desc" {
+		return nil, fmt.Errorf("unknown sort order: %s", opts.Order)
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	where, args, err := opts.filters()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.Sort != sortName || c.Order != order || c.ID == "" {
+			return nil, fmt.Errorf("cursor does not match the requested sort order")
+		}
+
+		cmp := ">"
+		if order == "desc" {
+			cmp = "<"
+		}
+		unpinned := 0
+		if c.Pinned == 0 {
+			unpinned = 1
+		}
+		where = append(where, fmt.Sprintf(
+			"(%s > (?, ?) OR (%s = (?, ?) AND (%s %s ? OR (%s = ? AND n.id %s ?))))",
+			pinKey, pinKey, column, cmp, column, cmp,
+		))
+		args = append(args, unpinned, c.Pinned, unpinned, c.Pinned, c.Key, c.Key, c.ID)
+	}
+
+	// The sort key is selected as stored (timestamps as text) for the cursor
+	sortKey := column
+	if sortName == "created" || sortName == "updated" {
+		sortKey = "CAST(" + column + " AS TEXT)"
+	}
+
+	query := `
+		SELECT n.id, n.title, n.folder_id, n.file_path, n.is_favorite, n.pinned, n.position,
+			n.created_at, n.updated_at, ` + sortKey + `
+		FROM notes n
+		WHERE ` + strings.Join(where, " AND ") + `
+		ORDER BY n.pinned = 0, n.pinned, ` + column + ` ` + order + `, n.id ` + order + `
+		LIMIT ?
+	`
+	args = append(args, limit+1)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notes: %w", err)
+	}
+	defer rows.Close()
+
+	page := &NotePage{Notes: []*models.Note{}}
+	var lastKey interface{}
+	for rows.Next() {
+		var note models.Note
+		var folderID *string
+		var key interface{}
+
+		err := rows.Scan(
+			&note.ID,
+			&note.Title,
+			&folderID,
+			&note.FilePath,
+			&note.IsFavorite,
+			&note.Pinned,
+			&note.Position,
+			&note.CreatedAt,
+			&note.UpdatedAt,
+			&key,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan note: %w", err)
+		}
+
+		if folderID != nil {
+			note.FolderID = *folderID
+		}
+
+		if len(page.Notes) == limit {
+			// The extra row only tells us there is another page
+			last := page.Notes[limit-1]
+			page.NextCursor = encodeCursor(pageCursor{
+				Sort: sortName, Order: order, Pinned: last.Pinned, Key: lastKey, ID: last.ID,
+			})
+			break
+		}
+
+		page.Notes = append(page.Notes, &note)
+		lastKey = key
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list notes: %w", err)
+	}
+
+	if err := s.fillTags(page.Notes); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// filters turns the options into predicates over notes n
+func (opts ListOptions) filters() ([]string, []interface{}, error) {
+	where := []string{"n.deleted_at IS NULL"}
+	var args []interface{}
+
+	switch opts.FolderID {
+	case "":
+	case RootFolderID:
+		where = append(where, "n.folder_id IS NULL")
+	default:
+		where = append(where, "n.folder_id = ?")
+		args = append(args, opts.FolderID)
+	}
+
+	if opts.FavoritesOnly {
+		where = append(where, "n.is_favorite = 1")
+	}
+
+	if opts.Tag != "" {
+		name, err := normalizeTag(opts.Tag)
+		if err != nil {
+			return nil, nil, err
+		}
+		where = append(where, `n.id IN (SELECT nt.note_id FROM note_tags nt JOIN tags t ON t.id = nt.tag_id WHERE t.name = ?)`)
+		args = append(args, name)
+	}
+
+	dateColumn := "n.updated_at"
+	switch opts.DateField {
+	case "", "updated":
+	case "created":
+		dateColumn = "n.created_at"
+	default:
+		return nil, nil, fmt.Errorf("unknown date field: %s", opts.DateField)
+	}
+	if opts.From != nil {
+		where = append(where, dateColumn+" >= ?")
+		args = append(args, *opts.From)
+	}
+	if opts.To != nil {
+		where = append(where, dateColumn+" < ?")
+		args = append(args, *opts.To)
+	}
+
+	return where, args, nil
+}
+
+// ReorderNote moves a note to position among the live notes in its folder.
+// A negative position appends it at the end.
+func (s *Service) ReorderNote(id string, position int) error {
+	note, err := s.GetNote(id)
+	if err != nil {
+		return err
+	}
+
+	var rows *sql.Rows
+	query := `SELECT id FROM notes WHERE deleted_at IS NULL AND id != ? AND `
+	if note.FolderID == "" {
+		rows, err = s.db.Query(query+`folder_id IS NULL ORDER BY position, id`, id)
+	} else {
+		rows, err = s.db.Query(query+`folder_id = ? ORDER BY position, id`, id, note.FolderID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list folder notes: %w", err)
+	}
+
+	var ordered []string
+	for rows.Next() {
+		var sibling string
+		if err := rows.Scan(&sibling); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan note: %w", err)
+		}
+		ordered = append(ordered, sibling)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list folder notes: %w", err)
+	}
+
+	if position < 0 || position > len(ordered) {
+		position = len(ordered)
+	}
+	ordered = append(ordered, "")
+	copy(ordered[position+1:], ordered[position:])
+	ordered[position] = id
+
+	return s.db.Transaction(func(tx *sql.Tx) error {
+		for i, noteID := range ordered {
+			if _, err := tx.Exec(`UPDATE notes SET position = ? WHERE id = ?`, i, noteID); err != nil {
+				return fmt.Errorf("failed to update note position: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// nextPosition returns the position after the last live note in a folder
+func nextPosition(q database.Executor, folderID string) (int, error) {
+	var next int
+	var err error
+	query := `SELECT COALESCE(MAX(position) + 1, 0) FROM notes WHERE deleted_at IS NULL AND `
+	if folderID == "" {
+		err = q.QueryRow(query + `folder_id IS NULL`).Scan(&next)
+	} else {
+		err = q.QueryRow(query+`folder_id = ?`, folderID).Scan(&next)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get next note position: %w", err)
+	}
+	return next, nil
+}
+
+// fillTags loads the tags of the given notes in one query
+func (s *Service) fillTags(notes []*models.Note) error {
+	if len(notes) == 0 {
+		return nil
+	}
+
+	byID := make(map[string]*models.Note, len(notes))
+	args := make([]interface{}, len(notes))
+	for i, note := range notes {
+		byID[note.ID] = note
+		args[i] = note.ID
+	}
+
+	query := `
+		SELECT nt.note_id, t.name FROM note_tags nt JOIN tags t ON t.id = nt.tag_id
+		WHERE nt.note_id IN (` + strings.TrimSuffix(strings.Repeat("?, ", len(notes)), ", ") + `)
+		ORDER BY t.name COLLATE NOCASE
+	`
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to get note tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var noteID, tag string
+		if err := rows.Scan(&noteID, &tag); err != nil {
+			return fmt.Errorf("failed to scan tag: %w", err)
+		}
+		byID[noteID].Tags = append(byID[noteID].Tags, tag)
+	}
+
+	return rows.Err()
+}
+
+func encodeCursor(c pageCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var c pageCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort == "" {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &c, nil
+}
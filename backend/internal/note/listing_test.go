@@ -0,0 +1,251 @@
+package note
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// collectPages follows NextCursor until the last page and returns the titles in order
+func collectPages(t *testing.T, service *Service, opts ListOptions) []string {
+	t.Helper()
+
+	var titles []string
+	for pages := 0; ; pages++ {
+		if pages > 20 {
+			t.Fatal("Too many pages")
+		}
+		page, err := service.ListNotePage(opts)
+		if err != nil {
+			t.Fatalf("ListNotePage() failed: %v", err)
+		}
+		for _, note := range page.Notes {
+			titles = append(titles, note.Title)
+		}
+		if page.NextCursor == "" {
+			return titles
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+func TestListNotePagePagination(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	for _, title := range []string{"delta", "Alpha", "charlie", "Bravo", "echo"} {
+		if _, err := service.CreateNote(title, "content", ""); err != nil {
+			t.Fatalf("CreateNote() failed: %v", err)
+		}
+	}
+
+	got := collectPages(t, service, ListOptions{Sort: "title", Limit: 2})
+	want := []string{"Alpha", "Bravo", "charlie", "delta", "echo"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("title asc = %v, want %v", got, want)
+	}
+
+	got = collectPages(t, service, ListOptions{Sort: "title", Order: "desc", Limit: 3})
+	want = []string{"echo", "delta", "charlie", "Bravo", "Alpha"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("title desc = %v, want %v", got, want)
+	}
+
+	// Notes created in the same instant still page without gaps or repeats
+	got = collectPages(t, service, ListOptions{Sort: "created", Limit: 2})
+	if len(got) != 5 {
+		t.Errorf("created desc returned %d notes, want 5: %v", len(got), got)
+	}
+	seen := make(map[string]bool)
+	for _, title := range got {
+		if seen[title] {
+			t.Errorf("note %q listed twice", title)
+		}
+		seen[title] = true
+	}
+
+	page, err := service.ListNotePage(ListOptions{Sort: "title", Limit: 2})
+	if err != nil {
+		t.Fatalf("ListNotePage() failed: %v", err)
+	}
+	if _, err := service.ListNotePage(ListOptions{Sort: "updated", Cursor: page.NextCursor}); err == nil {
+		t.Error("Expected error reusing a cursor with another sort")
+	}
+	if _, err := service.ListNotePage(ListOptions{Cursor: "not a cursor"}); err == nil {
+		t.Error("Expected error for an invalid cursor")
+	}
+	if _, err := service.ListNotePage(ListOptions{Sort: "size"}); err == nil {
+		t.Error("Expected error for an unknown sort")
+	}
+}
+
+func TestListNotePagePinnedFirst(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	ids := make(map[string]string)
+	for _, title := range []string{"delta", "Alpha", "charlie", "Bravo", "echo"} {
+		note, err := service.CreateNote(title, "content", "")
+		if err != nil {
+			t.Fatalf("CreateNote() failed: %v", err)
+		}
+		ids[title] = note.ID
+	}
+	service.PinNote(ids["echo"], -1)
+	service.PinNote(ids["charlie"], -1)
+
+	got := collectPages(t, service, ListOptions{Sort: "title", Limit: 1})
+	want := []string{"echo", "charlie", "Alpha", "Bravo", "delta"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("title asc = %v, want %v", got, want)
+	}
+
+	got = collectPages(t, service, ListOptions{Sort: "title", Order: "desc", Limit: 2})
+	want = []string{"echo", "charlie", "delta", "Bravo", "Alpha"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("title desc = %v, want %v", got, want)
+	}
+
+	// The default order agrees with ListNotes
+	notes, _ := service.ListNotes("")
+	want = nil
+	for _, note := range notes {
+		want = append(want, note.Title)
+	}
+	if got := collectPages(t, service, ListOptions{Limit: 2}); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("updated desc = %v, want %v as in ListNotes", got, want)
+	}
+}
+
+func TestListNotePageFilters(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	_, err := service.db.Exec(`INSERT INTO folders (id, name, position) VALUES ('f1', 'Work', 0)`)
+	if err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+
+	root, _ := service.CreateNote("Root", "content", "")
+	inFolder, _ := service.CreateNote("In folder", "content", "f1")
+	tagged, _ := service.CreateNote("Tagged", "content", "f1")
+	service.AddTag(tagged.ID, "todo")
+	service.db.Exec(`UPDATE notes SET is_favorite = 1 WHERE id = ?`, root.ID)
+
+	tests := []struct {
+		name string
+		opts ListOptions
+		want []string
+	}{
+		{"all", ListOptions{}, []string{"In folder", "Root", "Tagged"}},
+		{"root", ListOptions{FolderID: RootFolderID}, []string{"Root"}},
+		{"folder", ListOptions{FolderID: "f1"}, []string{"In folder", "Tagged"}},
+		{"favorites", ListOptions{FavoritesOnly: true}, []string{"Root"}},
+		{"tag", ListOptions{Tag: "#TODO"}, []string{"Tagged"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Sort = "title"
+			got := collectPages(t, service, tt.opts)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Date ranges
+	old := time.Now().AddDate(0, -1, 0)
+	service.db.Exec(`UPDATE notes SET created_at = ?, updated_at = ? WHERE id = ?`, old, old, inFolder.ID)
+
+	cutoff := time.Now().AddDate(0, 0, -7)
+	got := collectPages(t, service, ListOptions{Sort: "title", DateField: "created", To: &cutoff})
+	if fmt.Sprint(got) != "[In folder]" {
+		t.Errorf("created before cutoff = %v, want [In folder]", got)
+	}
+	got = collectPages(t, service, ListOptions{Sort: "title", From: &cutoff})
+	if fmt.Sprint(got) != "[Root Tagged]" {
+		t.Errorf("updated after cutoff = %v, want [Root Tagged]", got)
+	}
+
+	// Trashed notes are not listed
+	service.DeleteNote(root.ID)
+	got = collectPages(t, service, ListOptions{Sort: "title"})
+	if fmt.Sprint(got) != "[In folder Tagged]" {
+		t.Errorf("after delete = %v, want [In folder Tagged]", got)
+	}
+
+	page, _ := service.ListNotePage(ListOptions{Tag: "todo"})
+	if len(page.Notes) != 1 || len(page.Notes[0].Tags) != 1 || page.Notes[0].Content != "" {
+		t.Errorf("listed note = %+v, want tags and no content", page.Notes[0])
+	}
+}
+
+func TestReorderNote(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	a, _ := service.CreateNote("a", "content", "")
+	b, _ := service.CreateNote("b", "content", "")
+	c, _ := service.CreateNote("c", "content", "")
+
+	if got := collectPages(t, service, ListOptions{Sort: "position"}); fmt.Sprint(got) != "[a b c]" {
+		t.Errorf("creation order = %v, want [a b c]", got)
+	}
+
+	if err := service.ReorderNote(c.ID, 0); err != nil {
+		t.Fatalf("ReorderNote() failed: %v", err)
+	}
+	if got := collectPages(t, service, ListOptions{Sort: "position"}); fmt.Sprint(got) != "[c a b]" {
+		t.Errorf("after moving c first = %v, want [c a b]", got)
+	}
+
+	if err := service.ReorderNote(c.ID, -1); err != nil {
+		t.Fatalf("ReorderNote() failed: %v", err)
+	}
+	if got := collectPages(t, service, ListOptions{Sort: "position", Limit: 1}); fmt.Sprint(got) != "[a b c]" {
+		t.Errorf("after moving c last = %v, want [a b c]", got)
+	}
+
+	// Moving to another folder appends to its order
+	service.db.Exec(`INSERT INTO folders (id, name, position) VALUES ('f1', 'Work', 0)`)
+	service.MoveNote(b.ID, "f1")
+	service.MoveNote(a.ID, "f1")
+	if got := collectPages(t, service, ListOptions{FolderID: "f1", Sort: "position"}); fmt.Sprint(got) != "[b a]" {
+		t.Errorf("folder order = %v, want [b a]", got)
+	}
+}
+
+func TestSearchNotesPage(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	for i := 0; i < 5; i++ {
+		service.CreateNote(fmt.Sprintf("Note %d", i), "common words", "")
+	}
+
+	seen := make(map[string]bool)
+	cursor := ""
+	for pages := 0; ; pages++ {
+		page, err := service.SearchNotesPage("common", 2, cursor)
+		if err != nil {
+			t.Fatalf("SearchNotesPage() failed: %v", err)
+		}
+		for _, r := range page.Results {
+			if seen[r.Note.ID] {
+				t.Errorf("result %s returned twice", r.Note.Title)
+			}
+			seen[r.Note.ID] = true
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		if pages > 5 {
+			t.Fatal("Too many pages")
+		}
+		cursor = page.NextCursor
+	}
+	if len(seen) != 5 {
+		t.Errorf("got %d results across pages, want 5", len(seen))
+	}
+
+	first, _ := service.SearchNotesPage("common", 2, "")
+	if _, err := service.SearchNotesPage("other", 2, first.NextCursor); err == nil {
+		t.Error("Expected error reusing a cursor with another query")
+	}
+}
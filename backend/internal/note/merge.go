@@ -0,0 +1,245 @@
+package note
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fuknotion/backend/internal/diff"
+)
+
+// ContentField names the note body in a MergeConflict
+const ContentField = "content"
+
+// Labels on the conflict markers Merge writes
+const (
+	OursLabel   = "local"
+	TheirsLabel = "remote"
+)
+
+// MergeConflict is a part of a note two versions changed differently: a
+// frontmatter field, or a run of lines in the content
+type MergeConflict struct {
+	Field  string `json:"field"`          // frontmatter field, or ContentField
+	Line   int    `json:"line,omitempty"` // for content, the 1-based line of the merged content its markers start at
+	Base   string `json:"base"`
+	Ours   string `json:"ours"`
+	Theirs string `json:"theirs"`
+}
+
+// MergeResult is two versions of a note merged with the version both started
+// from
+type MergeResult struct {
+	// Data is the merged note file. Content both sides changed differently
+	// is written out between git-style conflict markers; a frontmatter field
+	// both changed keeps our value.
+	Data      []byte          `json:"-"`
+	Conflicts []MergeConflict `json:"conflicts"`
+}
+
+// ContentConflicts reports whether any lines of the content conflict, so
+// that Data carries conflict markers
+func (r *MergeResult) ContentConflicts() bool {
+	for _, c := range r.Conflicts {
+		if c.Field == ContentField {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge merges ours and theirs, two versions of a note file, with base, the
+// version both were edited from. Frontmatter is merged field by field and the
+// content line by line, so edits to different fields or different parts of
+// the text both survive. Only changes that overlap conflict.
+func Merge(base, ours, theirs []byte) (*MergeResult, error) {
+	var versions [3]*Frontmatter
+	var contents [3]string
+	for i, data := range [][]byte{base, ours, theirs} {
+		var err error
+		if versions[i], contents[i], err = parseNoteFile(data); err != nil {
+			return nil, err
+		}
+	}
+	if versions[0].ID != versions[1].ID || versions[1].ID != versions[2].ID {
+		return nil, fmt.Errorf("cannot merge versions of different notes")
+	}
+
+	fm, conflicts := mergeFrontmatter(versions[0], versions[1], versions[2])
+	content, contentConflicts := MergeText(contents[0], contents[1], contents[2], OursLabel, TheirsLabel)
+	conflicts = append(conflicts, contentConflicts...)
+
+	data, err := SerializeNote(fm, content)
+	if err != nil {
+		return nil, err
+	}
+	return &MergeResult{Data: []byte(data), Conflicts: conflicts}, nil
+}
+
+// mergeFrontmatter takes each field from whichever side changed it. A field
+// both changed differently keeps our value and is reported; tags merge as a
+// set, and the modified time is the later of the two.
+func mergeFrontmatter(base, ours, theirs *Frontmatter) (*Frontmatter, []MergeConflict) {
+	fm := *ours
+	var conflicts []MergeConflict
+
+	field := func(name, b, o, t string) bool {
+		switch {
+		case o == t || t == b:
+			return false
+		case o == b:
+			return true
+		}
+		conflicts = append(conflicts, MergeConflict{Field: name, Base: b, Ours: o, Theirs: t})
+		return false
+	}
+	if field("title", base.Title, ours.Title, theirs.Title) {
+		fm.Title = theirs.Title
+	}
+	if field("folder_id", base.FolderID, ours.FolderID, theirs.FolderID) {
+		fm.FolderID = theirs.FolderID
+	}
+	if field("is_favorite", strconv.FormatBool(base.IsFavorite), strconv.FormatBool(ours.IsFavorite), strconv.FormatBool(theirs.IsFavorite)) {
+		fm.IsFavorite = theirs.IsFavorite
+	}
+	if field("pinned", strconv.Itoa(base.Pinned), strconv.Itoa(ours.Pinned), strconv.Itoa(theirs.Pinned)) {
+		fm.Pinned = theirs.Pinned
+	}
+
+	fm.Tags = mergeTags(base.Tags, ours.Tags, theirs.Tags)
+	if fm.Created.IsZero() {
+		fm.Created = theirs.Created
+	}
+	if theirs.Modified.After(fm.Modified) {
+		fm.Modified = theirs.Modified
+	}
+	return &fm, conflicts
+}
+
+// mergeTags keeps our tags, adds those they added and drops those they
+// removed
+func mergeTags(base, ours, theirs []string) []string {
+	inBase := make(map[string]bool, len(base))
+	for _, tag := range base {
+		inBase[tag] = true
+	}
+	inTheirs := make(map[string]bool, len(theirs))
+	for _, tag := range theirs {
+		inTheirs[tag] = true
+	}
+
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range ours {
+		if (inTheirs[tag] || !inBase[tag]) && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	for _, tag := range theirs {
+		if !inBase[tag] && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// MergeText merges two edits of base line by line, as diff3 does. Lines
+// neither side changed anchor the merge; between them, a side's change is
+// taken if the other side left the lines alone or made the same change.
+// Otherwise the lines conflict and both versions are written out between
+// conflict markers carrying the labels.
+func MergeText(base, ours, theirs, oursLabel, theirsLabel string) (string, []MergeConflict) {
+	b, o, t := splitLines(base), splitLines(ours), splitLines(theirs)
+	inOurs, inTheirs := matches(b, o), matches(b, t)
+
+	var out strings.Builder
+	var conflicts []MergeConflict
+	outLine := 1
+	emit := func(lines []string) {
+		for _, l := range lines {
+			out.WriteString(l)
+			outLine++
+		}
+	}
+
+	i, oi, ti := 0, 0, 0
+	for {
+		// The next base line both sides kept, with whatever lies before it
+		next := i
+		for next < len(b) && (inOurs[next] < 0 || inTheirs[next] < 0) {
+			next++
+		}
+		oNext, tNext := len(o), len(t)
+		if next < len(b) {
+			oNext, tNext = inOurs[next], inTheirs[next]
+		}
+
+		if next > i || oNext > oi || tNext > ti {
+			bc, oc, tc := b[i:next], o[oi:oNext], t[ti:tNext]
+			switch {
+			case slices.Equal(oc, bc):
+				emit(tc)
+			case slices.Equal(tc, bc), slices.Equal(oc, tc):
+				emit(oc)
+			default:
+				conflicts = append(conflicts, MergeConflict{
+					Field:  ContentField,
+					Line:   outLine,
+					Base:   strings.Join(bc, ""),
+					Ours:   strings.Join(oc, ""),
+					Theirs: strings.Join(tc, ""),
+				})
+				emit([]string{"<<<<<<< " + oursLabel + "\n"})
+				emit(terminated(oc))
+				emit([]string{"=======\n"})
+				emit(terminated(tc))
+				emit([]string{">>>>>>> " + theirsLabel + "\n"})
+			}
+		}
+
+		if next == len(b) {
+			break
+		}
+		emit(b[next : next+1])
+		i, oi, ti = next+1, oNext+1, tNext+1
+	}
+	return out.String(), conflicts
+}
+
+// splitLines splits text into lines, each keeping its newline
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// matches maps each line of base to the line of edited it was kept as, or
+// -1 if it was changed or removed
+func matches(base, edited []string) []int {
+	m := make([]int, len(base))
+	for i := range m {
+		m[i] = -1
+	}
+	for _, l := range diff.Lines(base, edited) {
+		if l.Op == diff.Equal {
+			m[l.OldLine-1] = l.NewLine - 1
+		}
+	}
+	return m
+}
+
+// terminated makes sure the last line ends in a newline, so a conflict
+// marker after it starts a line of its own
+func terminated(lines []string) []string {
+	if len(lines) == 0 || strings.HasSuffix(lines[len(lines)-1], "\n") {
+		return lines
+	}
+	out := append([]string(nil), lines...)
+	out[len(out)-1] += "\n"
+	return out
+}
@@ -0,0 +1,220 @@
+package note
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMergeText(t *testing.T) {
+	base := "One\nTwo\nThree\nFour\nFive\n"
+
+	tests := []struct {
+		name          string
+		ours, theirs  string
+		want          string
+		wantConflicts int
+	}{
+		{"unchanged", base, base, base, 0},
+		{"one side", "One\nTwo (ours)\nThree\nFour\nFive\n", base, "One\nTwo (ours)\nThree\nFour\nFive\n", 0},
+		{"other side", base, "One\nTwo\nThree\nFour\n", "One\nTwo\nThree\nFour\n", 0},
+		{"different lines", "One (ours)\nTwo\nThree\nFour\nFive\n", "One\nTwo\nThree\nFour\nFive (theirs)\n", "One (ours)\nTwo\nThree\nFour\nFive (theirs)\n", 0},
+		{"adjacent lines", "One\nTwo (ours)\nThree\nFour\nFive\n", "One\nTwo\nThree (theirs)\nFour\nFive\n", "One\n<<<<<<< mine\nTwo (ours)\nThree\n=======\nTwo\nThree (theirs)\n>>>>>>> yours\nFour\nFive\n", 1},
+		{"same change", "One\nTwo (both)\nThree\nFour\nFive\n", "One\nTwo (both)\nThree\nFour\nFive\n", "One\nTwo (both)\nThree\nFour\nFive\n", 0},
+		{"same line", "One\nTwo\nThree (ours)\nFour\nFive\n", "One\nTwo\nThree (theirs)\nFour\nFive\n", "One\nTwo\n<<<<<<< mine\nThree (ours)\n=======\nThree (theirs)\n>>>>>>> yours\nFour\nFive\n", 1},
+		{"both append", base + "Six\n", base + "Seven\n", base + "<<<<<<< mine\nSix\n=======\nSeven\n>>>>>>> yours\n", 1},
+		{"no final newline", "One\nTwo\nThree\nFour\nFive (ours)", "One\nTwo\nThree\nFour\nFive (theirs)", "One\nTwo\nThree\nFour\n<<<<<<< mine\nFive (ours)\n=======\nFive (theirs)\n>>>>>>> yours\n", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflicts := MergeText(base, tt.ours, tt.theirs, "mine", "yours")
+			if got != tt.want {
+				t.Errorf("MergeText() = %q, want %q", got, tt.want)
+			}
+			if len(conflicts) != tt.wantConflicts {
+				t.Errorf("conflicts = %+v, want %d", conflicts, tt.wantConflicts)
+			}
+		})
+	}
+}
+
+func TestMergeTextReportsWhereConflictsAre(t *testing.T) {
+	merged, conflicts := MergeText("a\nb\nc\n", "a\nB\nc\n", "a\nbee\nc\n", "mine", "yours")
+	if len(conflicts) != 1 {
+		t.Fatalf("conflicts = %+v, want 1", conflicts)
+	}
+	c := conflicts[0]
+	if c.Field != ContentField || c.Base != "b\n" || c.Ours != "B\n" || c.Theirs != "bee\n" {
+		t.Errorf("conflict = %+v", c)
+	}
+	if lines := strings.Split(merged, "\n"); lines[c.Line-1] != "<<<<<<< mine" {
+		t.Errorf("line %d = %q, want the conflict marker", c.Line, lines[c.Line-1])
+	}
+}
+
+func TestMerge(t *testing.T) {
+	created := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	version := func(fm Frontmatter, content string) []byte {
+		fm.ID, fm.Created = "n1", created
+		data, err := SerializeNote(&fm, content)
+		if err != nil {
+			t.Fatalf("SerializeNote() failed: %v", err)
+		}
+		return []byte(data)
+	}
+
+	base := version(Frontmatter{Title: "Plan", Tags: []string{"work", "draft"}, Modified: created}, "Intro\n\nMiddle\n\nEnd\n")
+	ours := version(Frontmatter{Title: "Plan", Tags: []string{"work", "draft", "urgent"}, Pinned: 1, Modified: created.Add(time.Hour)}, "Intro (ours)\n\nMiddle\n\nEnd\n")
+	theirs := version(Frontmatter{Title: "Plan v2", Tags: []string{"work"}, FolderID: "f1", Modified: created.Add(2 * time.Hour)}, "Intro\n\nMiddle\n\nEnd (theirs)\n")
+
+	result, err := Merge(base, ours, theirs)
+	if err != nil {
+		t.Fatalf("Merge() failed: %v", err)
+	}
+	if len(result.Conflicts) != 0 || result.ContentConflicts() {
+		t.Errorf("Conflicts = %+v, want none", result.Conflicts)
+	}
+
+	fm, content, err := parseNoteFile(result.Data)
+	if err != nil {
+		t.Fatalf("merged note does not parse: %v", err)
+	}
+	if fm.Title != "Plan v2" || fm.FolderID != "f1" || fm.Pinned != 1 {
+		t.Errorf("merged frontmatter = %+v, want every field's change", fm)
+	}
+	if strings.Join(fm.Tags, ",") != "work,urgent" {
+		t.Errorf("Tags = %v, want draft removed and urgent added", fm.Tags)
+	}
+	if !fm.Modified.Equal(created.Add(2 * time.Hour)) {
+		t.Errorf("Modified = %v, want the later edit", fm.Modified)
+	}
+	if content != "Intro (ours)\n\nMiddle\n\nEnd (theirs)\n" {
+		t.Errorf("content = %q, want both edits", content)
+	}
+}
+
+func TestMergeConflicts(t *testing.T) {
+	version := func(title, content string) []byte {
+		data, _ := SerializeNote(&Frontmatter{ID: "n1", Title: title}, content)
+		return []byte(data)
+	}
+
+	// Fields both changed keep ours without touching the content
+	result, err := Merge(version("Plan", "Text\n"), version("Ours", "Text\n"), version("Theirs", "Text\n"))
+	if err != nil {
+		t.Fatalf("Merge() failed: %v", err)
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Field != "title" || result.ContentConflicts() {
+		t.Errorf("Conflicts = %+v, want the title", result.Conflicts)
+	}
+	if fm, _, _ := parseNoteFile(result.Data); fm.Title != "Ours" {
+		t.Errorf("Title = %q, want ours", fm.Title)
+	}
+
+	// Content both changed is marked
+	result, err = Merge(version("Plan", "Text\n"), version("Plan", "Our text\n"), version("Plan", "Their text\n"))
+	if err != nil {
+		t.Fatalf("Merge() failed: %v", err)
+	}
+	if !result.ContentConflicts() {
+		t.Errorf("Conflicts = %+v, want the content", result.Conflicts)
+	}
+	if _, content, _ := parseNoteFile(result.Data); content != "<<<<<<< local\nOur text\n=======\nTheir text\n>>>>>>> remote\n" {
+		t.Errorf("content = %q, want conflict markers", content)
+	}
+
+	// Different notes do not merge
+	other, _ := SerializeNote(&Frontmatter{ID: "n2", Title: "Plan"}, "Text\n")
+	if _, err := Merge(version("Plan", "Text\n"), version("Plan", "Text\n"), []byte(other)); err == nil {
+		t.Error("Merge() of different notes succeeded")
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	n, err := service.CreateNote("Plan", "One\n\nTwo\n\nThree\n", "")
+	if err != nil {
+		t.Fatalf("CreateNote() failed: %v", err)
+	}
+	version := func(title, content string) []byte {
+		data, _ := SerializeNote(&Frontmatter{ID: n.ID, Title: title}, content)
+		return []byte(data)
+	}
+	base := version("Plan", "One\n\nTwo\n\nThree\n")
+
+	// Edits that do not overlap merge without a copy
+	result, err := service.Reconcile(base, version("Plan", "One!\n\nTwo\n\nThree\n"), version("Plan", "One\n\nTwo\n\nThree!\n"), "conflict copy")
+	if err != nil {
+		t.Fatalf("Reconcile() failed: %v", err)
+	}
+	if !result.Merged || result.Copy != nil || !strings.Contains(string(result.Data), "One!\n\nTwo\n\nThree!\n") {
+		t.Errorf("Reconcile() = %+v, want both edits merged", result)
+	}
+	if conflicts, _ := service.ListConflicts(); len(conflicts) != 0 {
+		t.Errorf("ListConflicts() = %+v, want none", conflicts)
+	}
+
+	// A field both changed is recorded, with ours kept
+	result, err = service.Reconcile(base, version("Ours", "One\n\nTwo\n\nThree\n"), version("Theirs", "One\n\nTwo\n\nThree\n"), "conflict copy")
+	if err != nil {
+		t.Fatalf("Reconcile() failed: %v", err)
+	}
+	if !result.Merged || result.Copy != nil || len(result.Conflicts) != 1 {
+		t.Errorf("Reconcile() = %+v, want the title conflict recorded", result)
+	}
+
+	// Clashing content keeps ours and copies theirs
+	ours := version("Plan", "One\n\nTwo (ours)\n\nThree\n")
+	result, err = service.Reconcile(base, ours, version("Plan", "One\n\nTwo (theirs)\n\nThree\n"), "conflict copy")
+	if err != nil {
+		t.Fatalf("Reconcile() failed: %v", err)
+	}
+	if result.Merged || string(result.Data) != string(ours) || result.Copy == nil || result.Copy.Content != "One\n\nTwo (theirs)\n\nThree\n" {
+		t.Errorf("Reconcile() = %+v, want ours kept and theirs copied", result)
+	}
+
+	conflicts, err := service.ListConflicts()
+	if err != nil {
+		t.Fatalf("ListConflicts() failed: %v", err)
+	}
+	if len(conflicts) != 1 || conflicts[0].NoteID != n.ID || conflicts[0].CopyID != result.Copy.ID || conflicts[0].Title != "Plan" {
+		t.Fatalf("ListConflicts() = %+v, want the latest conflict", conflicts)
+	}
+	if c := conflicts[0].Conflicts; len(c) != 1 || c[0].Ours != "Two (ours)\n" || c[0].Theirs != "Two (theirs)\n" {
+		t.Errorf("recorded conflicts = %+v", c)
+	}
+
+	if err := service.ResolveConflict(n.ID); err != nil {
+		t.Fatalf("ResolveConflict() failed: %v", err)
+	}
+	if conflicts, _ := service.ListConflicts(); len(conflicts) != 0 {
+		t.Errorf("ListConflicts() after resolving = %+v, want none", conflicts)
+	}
+
+	// Without a base, differing versions are both kept
+	if result, err := service.Reconcile(nil, ours, base, "conflict copy"); err != nil || result.Merged || result.Copy == nil {
+		t.Errorf("Reconcile() without a base = %+v, %v, want a copy", result, err)
+	}
+}
+
+func TestMergeEdit(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	n, _ := service.CreateNote("Plan", "One\nTwo\nThree\n", "")
+	service.UpdateNote(n.ID, "Plan", "One\nTwo\nThree (saved)\n", false)
+
+	merged, err := service.MergeEdit(n.ID, "One\nTwo\nThree\n", "One (edited)\nTwo\nThree\n")
+	if err != nil {
+		t.Fatalf("MergeEdit() failed: %v", err)
+	}
+	if merged.Content != "One (edited)\nTwo\nThree (saved)\n" || len(merged.Conflicts) != 0 {
+		t.Errorf("MergeEdit() = %+v, want both changes", merged)
+	}
+
+	merged, _ = service.MergeEdit(n.ID, "One\nTwo\nThree\n", "One\nTwo\nThree (edited)\n")
+	if len(merged.Conflicts) != 1 || !strings.Contains(merged.Content, "<<<<<<< your edit\nThree (edited)\n=======\nThree (saved)\n>>>>>>> saved version\n") {
+		t.Errorf("MergeEdit() = %+v, want the clash marked", merged)
+	}
+}
@@ -17,6 +17,7 @@ type Frontmatter struct {
 	Modified   time.Time `yaml:"modified"`
 	FolderID   string    `yaml:"folder_id,omitempty"`
 	IsFavorite bool      `yaml:"is_favorite"`
+	Pinned     int       `yaml:"pinned,omitempty"`
 	Tags       []string  `yaml:"tags,omitempty"`
 }
 
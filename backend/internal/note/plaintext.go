@@ -0,0 +1,159 @@
+package note
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	atxHeadingPattern    = regexp.MustCompile(`^ {0,3}#{1,6}(?:\s+(.*?))?(?:\s+#+)?\s*$`)
+	setextPattern        = regexp.MustCompile(`^ {0,3}(?:=+|-+)\s*$`)
+	rulePattern          = regexp.MustCompile(`^ {0,3}(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	fencePattern         = regexp.MustCompile("^ {0,3}(?:```|~~~)")
+	quotePattern         = regexp.MustCompile(`^ {0,3}(?:>\s?)+`)
+	listPattern          = regexp.MustCompile(`^\s*(?:[-*+]|\d{1,9}[.)])\s+(?:\[[ xX]\]\s+)?`)
+	linkDefPattern       = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:\s*\S`)
+	tableRulePattern     = regexp.MustCompile(`^[\s|:-]*\|[\s|:-]*$`)
+	imagePattern         = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	inlineLinkPattern    = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	referenceLinkPattern = regexp.MustCompile(`\[([^\]]+)\]\[[^\]]*\]`)
+	autolinkPattern      = regexp.MustCompile(`<((?:https?|ftp|mailto):[^>\s]*)>`)
+	htmlCommentPattern   = regexp.MustCompile(`<!--.*?-->`)
+	htmlTagPattern       = regexp.MustCompile(`</?[A-Za-z][^<>]*>`)
+)
+
+// PlainText strips markdown syntax from note content for search. Headings are
+// returned separately so they can be weighted; text holds everything else.
+// Link and image targets are dropped in favour of their text, and code is
+// kept without its fences and backticks.
+func PlainText(markdown string) (headings, text string) {
+	var headingLines, textLines []string
+	inFence := false
+	// Whether the previous line was paragraph text, which a setext
+	// underline turns into a heading
+	paragraph := false
+
+	for _, line := range strings.Split(markdown, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+
+		if fencePattern.MatchString(line) {
+			inFence = !inFence
+			paragraph = false
+			continue
+		}
+		if inFence {
+			if line != "" {
+				textLines = append(textLines, line)
+			}
+			continue
+		}
+
+		line = quotePattern.ReplaceAllString(line, "")
+		if strings.TrimSpace(line) == "" {
+			paragraph = false
+			continue
+		}
+
+		if paragraph && setextPattern.MatchString(line) {
+			last := textLines[len(textLines)-1]
+			textLines = textLines[:len(textLines)-1]
+			headingLines = append(headingLines, last)
+			paragraph = false
+			continue
+		}
+
+		if m := atxHeadingPattern.FindStringSubmatch(line); m != nil {
+			if heading := inlineText(m[1]); heading != "" {
+				headingLines = append(headingLines, heading)
+			}
+			paragraph = false
+			continue
+		}
+
+		if rulePattern.MatchString(line) || linkDefPattern.MatchString(line) || tableRulePattern.MatchString(line) {
+			paragraph = false
+			continue
+		}
+
+		// Table cell separators go after inline markup, which may use | itself
+		plain := inlineText(listPattern.ReplaceAllString(line, ""))
+		if strings.Contains(plain, "|") {
+			plain = strings.TrimSpace(strings.ReplaceAll(plain, "|", " "))
+		}
+
+		if plain != "" {
+			textLines = append(textLines, plain)
+			paragraph = true
+		} else {
+			paragraph = false
+		}
+	}
+
+	return strings.Join(headingLines, "\n"), strings.Join(textLines, "\n")
+}
+
+// inlineText strips inline markdown from a single line
+func inlineText(s string) string {
+	s = imagePattern.ReplaceAllString(s, "$1")
+	s = wikiLinkPattern.ReplaceAllStringFunc(s, func(m string) string {
+		inner := m[2 : len(m)-2]
+		if i := strings.Index(inner, "|"); i >= 0 {
+			return strings.TrimSpace(inner[i+1:])
+		}
+		if i := strings.Index(inner, "#"); i >= 0 {
+			return strings.TrimSpace(inner[:i] + " " + inner[i+1:])
+		}
+		return strings.TrimSpace(inner)
+	})
+	s = inlineLinkPattern.ReplaceAllString(s, "$1")
+	s = referenceLinkPattern.ReplaceAllString(s, "$1")
+	s = autolinkPattern.ReplaceAllString(s, "$1")
+	s = htmlCommentPattern.ReplaceAllString(s, "")
+	s = htmlTagPattern.ReplaceAllString(s, "")
+	s = stripMarkers(s)
+	return strings.TrimSpace(html.UnescapeString(s))
+}
+
+// stripMarkers removes emphasis, strikethrough and code markers, keeping
+// backslash-escaped characters and underscores inside words
+func stripMarkers(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	b.Grow(len(s))
+
+	isWord := func(i int) bool {
+		return i >= 0 && i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]))
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && i+1 < len(runes) && (unicode.IsPunct(runes[i+1]) || unicode.IsSymbol(runes[i+1])):
+			i++
+			b.WriteRune(runes[i])
+		case r == '*' || r == '`':
+			// Dropped, but keep a lone operator such as "2 * 3"
+			if r == '*' && i > 0 && i+1 < len(runes) && runes[i-1] == ' ' && runes[i+1] == ' ' {
+				b.WriteRune(r)
+			}
+		case r == '~' && i+1 < len(runes) && runes[i+1] == '~':
+			i++
+		case r == '_':
+			// snake_case keeps its underscores, _emphasis_ loses them
+			j := i
+			for j+1 < len(runes) && runes[j+1] == '_' {
+				j++
+			}
+			if isWord(i-1) && isWord(j+1) {
+				b.WriteString(string(runes[i : j+1]))
+			}
+			i = j
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
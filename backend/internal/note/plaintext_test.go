@@ -0,0 +1,82 @@
+package note
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		headings string
+		text     string
+	}{
+		{"emphasis", "Some **bold**, *italic*, __strong__ and ~~gone~~ text", "", "Some bold, italic, strong and gone text"},
+		{"snake case", "call my_func_name or _this_", "", "call my_func_name or this"},
+		{"escapes", `a \*literal\* star and 2 * 3`, "", "a *literal* star and 2 * 3"},
+		{"links", "See [the docs](https://example.com/docs) and ![diagram](img/arch.png)", "", "See the docs and diagram"},
+		{"reference links", "Read [this][ref]\n\n[ref]: https://example.com", "", "Read this"},
+		{"wiki links", "Go to [[Other Note]], [[Target|alias]] or [[Page#Section]]", "", "Go to Other Note, alias or Page Section"},
+		{"autolinks and html", "Mail <mailto:me@x.org> <b>now</b> <!-- hidden --> &amp; more", "", "Mail mailto:me@x.org now  & more"},
+		{"inline code", "Run `go test` now", "", "Run go test now"},
+		{"atx headings", "# Title\ntext\n## Sub **bold** ##", "Title\nSub bold", "text"},
+		{"setext headings", "Big\n===\nSmall\n---\nbody", "Big\nSmall", "body"},
+		{"hashtag is not a heading", "#tag here", "", "#tag here"},
+		{"rules", "above\n\n---\n\n***\nbelow", "", "above\nbelow"},
+		{"lists and quotes", "- one\n* two\n1. three\n- [x] done\n> quoted\n>> nested", "", "one\ntwo\nthree\ndone\nquoted\nnested"},
+		{"code fences", "```go\nfunc main() {}\n```\nafter", "", "func main() {}\nafter"},
+		{"tables", "| a | b |\n|---|:-:|\n| 1 | 2 |", "", "a   b\n1   2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headings, text := PlainText(tt.markdown)
+			if headings != tt.headings {
+				t.Errorf("headings = %q, want %q", headings, tt.headings)
+			}
+			if text != tt.text {
+				t.Errorf("text = %q, want %q", text, tt.text)
+			}
+		})
+	}
+}
+
+func TestSearchIgnoresMarkdownSyntax(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	service.CreateNote("Links", "Read [the guide](https://example.com/secretpath) **carefully**", "")
+
+	if results, _ := service.SearchNotes("secretpath"); len(results) != 0 {
+		t.Errorf("SearchNotes(secretpath) matched a link URL")
+	}
+
+	results, err := service.SearchNotes("guide")
+	if err != nil {
+		t.Fatalf("SearchNotes() failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("SearchNotes(guide) = %d results, want 1", len(results))
+	}
+	if snippet := results[0].Snippet; strings.Contains(snippet, "**") || strings.Contains(snippet, "](") {
+		t.Errorf("snippet %q contains markdown syntax", snippet)
+	}
+}
+
+func TestSearchRanksHeadingsHigher(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	body, _ := service.CreateNote("First", "Some text that mentions kayaks once.\n\nAnd more text.", "")
+	heading, _ := service.CreateNote("Second", "## Kayaks\n\nSome text about boats.\n\nAnd more text.", "")
+
+	results, err := service.SearchNotes("kayaks")
+	if err != nil {
+		t.Fatalf("SearchNotes() failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("SearchNotes(kayaks) = %d results, want 2", len(results))
+	}
+	if results[0].Note.ID != heading.ID || results[1].Note.ID != body.ID {
+		t.Errorf("heading match ranked below body match")
+	}
+}
@@ -0,0 +1,305 @@
+package note
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Term is a word, prefix or phrase matched against note text
+type Term struct {
+	Text    string `json:"text"`
+	Phrase  bool   `json:"phrase,omitempty"`
+	Prefix  bool   `json:"prefix,omitempty"`
+	Field   string `json:"field,omitempty"` // "" for any column, "title" for title:
+	Negated bool   `json:"negated,omitempty"`
+}
+
+// Filter is a qualifier such as tag:work that is applied as a SQL predicate
+type Filter struct {
+	Field   string `json:"field"` // tag, folder, is, created or updated
+	Op      string `json:"op"`    // =, >, >=, < or <=; always = except for dates
+	Value   string `json:"value"`
+	Negated bool   `json:"negated,omitempty"`
+
+	day time.Time // parsed date for created and updated
+}
+
+// Query is a parsed search query
+type Query struct {
+	Terms   []Term   `json:"terms"`
+	Filters []Filter `json:"filters"`
+}
+
+// QueryError reports a malformed search query. Pos is the 0-based rune
+// offset of the problem in the input.
+type QueryError struct {
+	Pos int
+	Msg string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid search query at position %d: %s", e.Pos, e.Msg)
+}
+
+// qualifiers are the field names recognised before a colon. Anything else,
+// e.g. "http:", is searched as plain text.
+var qualifiers = map[string]bool{
+	"tag": true, "folder": true, "is": true, "created": true, "updated": true, "title": true,
+}
+
+// ParseQuery parses a search query. Supported syntax:
+//
+//	word          notes containing word
+//	"two words"   the exact phrase
+//	wor*          words starting with wor
+//	-word         notes not containing word (also -"phrase", -tag:x, ...)
+//	title:word    word in the title (title:"a phrase" and title:wor* also work)
+//	tag:name      notes tagged name
+//	folder:name   notes in the folder with that name or ID, or its subfolders
+//	is:favorite   favorite notes (is:pinned for pinned notes)
+//	created:>2026-01-01, updated:<=2026-02-01, created:2026-03-04
+func ParseQuery(input string) (*Query, error) {
+	q := &Query{Terms: []Term{}, Filters: []Filter{}}
+	runes := []rune(input)
+	i := 0
+
+	for {
+		for i < len(runes) && unicode.IsSpace(runes[i]) {
+			i++
+		}
+		if i >= len(runes) {
+			break
+		}
+		negated := false
+		if runes[i] == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			negated = true
+			i++
+		}
+
+		// A known qualifier followed by a colon
+		field := ""
+		if j := indexRune(runes[i:], ':'); j > 0 {
+			name := strings.ToLower(string(runes[i : i+j]))
+			if qualifiers[name] && !containsSpaceOrQuote(runes[i:i+j]) {
+				field = name
+				i += j + 1
+			}
+		}
+
+		valueStart := i
+		text, phrase, next, err := readValue(runes, i)
+		if err != nil {
+			return nil, err
+		}
+		i = next
+
+		prefix := false
+		if !phrase && strings.HasSuffix(text, "*") {
+			text = strings.TrimRight(text, "*")
+			prefix = true
+		} else if phrase && i < len(runes) && runes[i] == '*' {
+			prefix = true
+			i++
+		}
+
+		if field != "" && field != "title" {
+			if text == "" {
+				return nil, &QueryError{Pos: valueStart, Msg: fmt.Sprintf("%s: needs a value", field)}
+			}
+			filter, err := parseFilter(field, text, valueStart)
+			if err != nil {
+				return nil, err
+			}
+			filter.Negated = negated
+			q.Filters = append(q.Filters, filter)
+			continue
+		}
+
+		if text == "" {
+			if field == "title" {
+				return nil, &QueryError{Pos: valueStart, Msg: "title: needs a value"}
+			}
+			// Empty phrases and a bare "*" match nothing in particular
+			continue
+		}
+
+		q.Terms = append(q.Terms, Term{
+			Text:    text,
+			Phrase:  phrase,
+			Prefix:  prefix,
+			Field:   field,
+			Negated: negated,
+		})
+	}
+
+	return q, nil
+}
+
+// readValue reads a bare word or a quoted phrase starting at i
+func readValue(runes []rune, i int) (text string, phrase bool, next int, err error) {
+	if i < len(runes) && runes[i] == '"' {
+		end := indexRune(runes[i+1:], '"')
+		if end < 0 {
+			return "", false, 0, &QueryError{Pos: i, Msg: "unterminated quote"}
+		}
+		return strings.TrimSpace(string(runes[i+1 : i+1+end])), true, i + end + 2, nil
+	}
+
+	start := i
+	for i < len(runes) && !unicode.IsSpace(runes[i]) {
+		i++
+	}
+	return string(runes[start:i]), false, i, nil
+}
+
+// parseFilter validates a qualifier value
+func parseFilter(field, value string, pos int) (Filter, error) {
+	filter := Filter{Field: field, Op: "=", Value: value}
+
+	switch field {
+	case "tag":
+		name, err := normalizeTag(value)
+		if err != nil {
+			return filter, &QueryError{Pos: pos, Msg: err.Error()}
+		}
+		filter.Value = name
+
+	case "is":
+		filter.Value = strings.ToLower(value)
+		if filter.Value != "favorite" && filter.Value != "pinned" {
+			return filter, &QueryError{Pos: pos, Msg: fmt.Sprintf("unknown is: value %q (expected favorite or pinned)", value)}
+		}
+
+	case "created", "updated":
+		for _, op := range []string{">=", "<=", ">", "<", "="} {
+			if strings.HasPrefix(value, op) {
+				filter.Op = op
+				value = value[len(op):]
+				break
+			}
+		}
+		day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return filter, &QueryError{Pos: pos, Msg: fmt.Sprintf("%s: expects a date like 2026-01-31, got %q", field, value)}
+		}
+		filter.Value = value
+		filter.day = day
+	}
+
+	return filter, nil
+}
+
+// IsEmpty reports whether the query has nothing to match or filter on
+func (q *Query) IsEmpty() bool {
+	return len(q.Terms) == 0 && len(q.Filters) == 0
+}
+
+// compile turns the query into an FTS5 MATCH expression for the positive
+// terms (empty if there are none) and SQL predicates over notes n for the
+// rest. User text only ever reaches FTS5 as quoted strings.
+func (q *Query) compile() (match string, where []string, args []interface{}) {
+	var positive []string
+
+	for _, term := range q.Terms {
+		expr := term.fts()
+		if expr == "" {
+			continue
+		}
+		if term.Negated {
+			where = append(where, `n.id NOT IN (SELECT note_id FROM notes_fts WHERE notes_fts MATCH ?)`)
+			args = append(args, expr)
+			continue
+		}
+		positive = append(positive, expr)
+	}
+
+	for _, f := range q.Filters {
+		predicate, fargs := f.sql()
+		if f.Negated {
+			predicate = "NOT (" + predicate + ")"
+		}
+		where = append(where, predicate)
+		args = append(args, fargs...)
+	}
+
+	return strings.Join(positive, " AND "), where, args
+}
+
+// fts renders a term as an FTS5 expression, or "" if it has nothing the
+// tokenizer would index (e.g. "++")
+func (t Term) fts() string {
+	if !strings.ContainsFunc(t.Text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) {
+		return ""
+	}
+
+	expr := `"` + strings.ReplaceAll(t.Text, `"`, `""`) + `"`
+	if t.Prefix {
+		expr += "*"
+	}
+	if t.Field != "" {
+		expr = t.Field + " : " + expr
+	}
+	return expr
+}
+
+// sql renders a filter as a predicate over notes n
+func (f Filter) sql() (string, []interface{}) {
+	switch f.Field {
+	case "tag":
+		return `n.id IN (SELECT nt.note_id FROM note_tags nt JOIN tags t ON t.id = nt.tag_id WHERE t.name = ?)`,
+			[]interface{}{f.Value}
+
+	case "folder":
+		// The IS NOT NULL keeps root notes in -folder: results
+		return `n.folder_id IS NOT NULL AND n.folder_id IN (
+			WITH RECURSIVE sub(id) AS (
+				SELECT id FROM folders WHERE id = ? OR name = ? COLLATE NOCASE
+				UNION
+				SELECT f.id FROM folders f JOIN sub ON f.parent_id = sub.id
+			)
+			SELECT id FROM sub
+		)`, []interface{}{f.Value, f.Value}
+
+	case "is":
+		if f.Value == "pinned" {
+			return `n.pinned > 0`, nil
+		}
+		return `n.is_favorite = 1`, nil
+
+	default: // created, updated
+		column := "n." + f.Field + "_at"
+		next := f.day.AddDate(0, 0, 1)
+		switch f.Op {
+		case ">":
+			return column + " >= ?", []interface{}{next}
+		case ">=":
+			return column + " >= ?", []interface{}{f.day}
+		case "<":
+			return column + " < ?", []interface{}{f.day}
+		case "<=":
+			return column + " < ?", []interface{}{next}
+		default:
+			return "(" + column + " >= ? AND " + column + " < ?)", []interface{}{f.day, next}
+		}
+	}
+}
+
+func indexRune(runes []rune, r rune) int {
+	for i, c := range runes {
+		if c == r {
+			return i
+		}
+	}
+	return -1
+}
+
+func containsSpaceOrQuote(runes []rune) bool {
+	for _, r := range runes {
+		if unicode.IsSpace(r) || r == '"' {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package note
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseQuery(t *testing.T) {
+	q, err := ParseQuery(`go "error handling" wrap* -java title:intro -tag:old folder:Work is:favorite created:>2026-01-01 http://x`)
+	if err != nil {
+		t.Fatalf("ParseQuery() failed: %v", err)
+	}
+
+	wantTerms := []Term{
+		{Text: "go"},
+		{Text: "error handling", Phrase: true},
+		{Text: "wrap", Prefix: true},
+		{Text: "java", Negated: true},
+		{Text: "intro", Field: "title"},
+		{Text: "http://x"},
+	}
+	if len(q.Terms) != len(wantTerms) {
+		t.Fatalf("got %d terms, want %d: %+v", len(q.Terms), len(wantTerms), q.Terms)
+	}
+	for i, want := range wantTerms {
+		if q.Terms[i] != want {
+			t.Errorf("term %d = %+v, want %+v", i, q.Terms[i], want)
+		}
+	}
+
+	wantFilters := []struct {
+		field, op, value string
+		negated          bool
+	}{
+		{"tag", "=", "old", true},
+		{"folder", "=", "Work", false},
+		{"is", "=", "favorite", false},
+		{"created", ">", "2026-01-01", false},
+	}
+	if len(q.Filters) != len(wantFilters) {
+		t.Fatalf("got %d filters, want %d: %+v", len(q.Filters), len(wantFilters), q.Filters)
+	}
+	for i, want := range wantFilters {
+		f := q.Filters[i]
+		if f.Field != want.field || f.Op != want.op || f.Value != want.value || f.Negated != want.negated {
+			t.Errorf("filter %d = %+v, want %+v", i, f, want)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantPos int
+	}{
+		{input: `hello "unbalanced`, wantPos: 6},
+		{input: `tag:`, wantPos: 4},
+		{input: `created:>yesterday`, wantPos: 8},
+		{input: `is:archived`, wantPos: 3},
+		{input: `title:`, wantPos: 6},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseQuery(tt.input)
+		var qerr *QueryError
+		if !errors.As(err, &qerr) {
+			t.Errorf("ParseQuery(%q) error = %v, want a *QueryError", tt.input, err)
+			continue
+		}
+		if qerr.Pos != tt.wantPos {
+			t.Errorf("ParseQuery(%q) error position = %d, want %d", tt.input, qerr.Pos, tt.wantPos)
+		}
+	}
+}
+
+func TestSearchQueryLanguage(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	folder := "f1"
+	if _, err := service.db.Exec(`INSERT INTO folders (id, name, position) VALUES (?, 'Work', 0)`, folder); err != nil {
+		t.Fatal(err)
+	}
+
+	intro, _ := service.CreateNote("Intro to C++", "templates and classes", folder)
+	golang, _ := service.CreateNote("Go errors", "error handling with wrapping", "")
+	java, _ := service.CreateNote("Java errors", "error handling with exceptions", "")
+	service.AddTag(java.ID, "old")
+
+	search := func(query string) map[string]bool {
+		t.Helper()
+		results, err := service.SearchNotes(query)
+		if err != nil {
+			t.Fatalf("SearchNotes(%q) failed: %v", query, err)
+		}
+		ids := make(map[string]bool)
+		for _, r := range results {
+			ids[r.Note.ID] = true
+		}
+		return ids
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: `c++`, want: []string{intro.ID}},
+		{query: `"error handling"`, want: []string{golang.ID, java.ID}},
+		{query: `error -exceptions`, want: []string{golang.ID}},
+		{query: `wrap*`, want: []string{golang.ID}},
+		{query: `title:errors`, want: []string{golang.ID, java.ID}},
+		{query: `tag:old`, want: []string{java.ID}},
+		{query: `errors -tag:old`, want: []string{golang.ID}},
+		{query: `folder:work`, want: []string{intro.ID}},
+		{query: `-folder:work`, want: []string{golang.ID, java.ID}},
+		{query: `created:>` + time.Now().AddDate(0, 0, -1).Format("2006-01-02"), want: []string{intro.ID, golang.ID, java.ID}},
+		{query: `created:<2000-01-01`, want: nil},
+		{query: `++`, want: nil},
+	}
+
+	for _, tt := range tests {
+		got := search(tt.query)
+		if len(got) != len(tt.want) {
+			t.Errorf("SearchNotes(%q) returned %d notes, want %d", tt.query, len(got), len(tt.want))
+			continue
+		}
+		for _, id := range tt.want {
+			if !got[id] {
+				t.Errorf("SearchNotes(%q) missing note %s", tt.query, id)
+			}
+		}
+	}
+
+	var qerr *QueryError
+	if _, err := service.SearchNotes(`"unbalanced`); !errors.As(err, &qerr) {
+		t.Errorf("SearchNotes() with an unbalanced quote error = %v, want a *QueryError", err)
+	}
+}
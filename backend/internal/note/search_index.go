@@ -0,0 +1,147 @@
+package note
+
+import (
+	"database/sql"
+	"fmt"
+
+	"fuknotion/backend/internal/database"
+)
+
+// SearchIndexReport summarizes a rebuild of the full-text search index
+type SearchIndexReport struct {
+	Indexed int              `json:"indexed"`
+	Failed  []ReindexFailure `json:"failed"` // notes indexed by title only
+}
+
+// SearchIndexCheck is the result of checking the full-text search index
+type SearchIndexCheck struct {
+	Intact   bool   `json:"intact"` // FTS5 integrity-check passed
+	Error    string `json:"error,omitempty"`
+	Missing  int    `json:"missing"`  // notes without a search row
+	Orphaned int    `json:"orphaned"` // search rows without a note
+}
+
+// OK reports whether the index is intact and matches the notes table
+func (c *SearchIndexCheck) OK() bool {
+	return c.Intact && c.Missing == 0 && c.Orphaned == 0
+}
+
+// indexContent writes a note's notes_fts row from its notes row and the
+// plain text of its content. The row shares the note's rowid so the notes
+// triggers can keep its title in sync and delete it with the note.
+func indexContent(q database.Executor, noteID, content string) error {
+	headings, text := PlainText(content)
+
+	if _, err := q.Exec(`DELETE FROM notes_fts WHERE rowid = (SELECT rowid FROM notes WHERE id = ?)`, noteID); err != nil {
+		return fmt.Errorf("failed to clear search index: %w", err)
+	}
+
+	query := `
+		INSERT INTO notes_fts (rowid, note_id, title, headings, content)
+		SELECT rowid, id, title, ?, ? FROM notes WHERE id = ?
+	`
+	result, err := q.Exec(query, headings, text, noteID)
+	if err != nil {
+		return fmt.Errorf("failed to index note content: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n != 1 {
+		return fmt.Errorf("failed to index note content: note not found: %s", noteID)
+	}
+
+	return nil
+}
+
+// RebuildSearchIndex rebuilds notes_fts from the note files, trashed notes
+// included, then optimizes it. Notes whose file cannot be read are reported
+// and stay searchable by title.
+func (s *Service) RebuildSearchIndex() (*SearchIndexReport, error) {
+	rows, err := s.db.Query(`SELECT id, file_path FROM notes`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notes: %w", err)
+	}
+	type indexed struct{ id, path, content string }
+	var notes []indexed
+	for rows.Next() {
+		var n indexed
+		if err := rows.Scan(&n.id, &n.path); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan note: %w", err)
+		}
+		notes = append(notes, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list notes: %w", err)
+	}
+
+	report := &SearchIndexReport{Failed: []ReindexFailure{}}
+	for i := range notes {
+		_, content, err := s.readNoteFile(notes[i].path)
+		if err != nil {
+			report.Failed = append(report.Failed, ReindexFailure{Path: notes[i].path, Error: err.Error()})
+			continue
+		}
+		notes[i].content = content
+	}
+
+	err = s.db.Transaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM notes_fts`); err != nil {
+			return fmt.Errorf("failed to clear search index: %w", err)
+		}
+		for _, n := range notes {
+			if err := indexContent(tx, n.id, n.content); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.Indexed = len(notes) - len(report.Failed)
+
+	if err := s.OptimizeSearchIndex(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// OptimizeSearchIndex merges the FTS5 index segments, which speeds up
+// queries after many edits
+func (s *Service) OptimizeSearchIndex() error {
+	if _, err := s.db.Exec(`INSERT INTO notes_fts (notes_fts) VALUES ('optimize')`); err != nil {
+		return fmt.Errorf("failed to optimize search index: %w", err)
+	}
+	return nil
+}
+
+// CheckSearchIndex runs the FTS5 integrity check and compares the search
+// rows with the notes table. Problems are reported in the result; the error
+// is only for failures to run the check.
+func (s *Service) CheckSearchIndex() (*SearchIndexCheck, error) {
+	check := &SearchIndexCheck{Intact: true}
+
+	if _, err := s.db.Exec(`INSERT INTO notes_fts (notes_fts) VALUES ('integrity-check')`); err != nil {
+		check.Intact = false
+		check.Error = err.Error()
+	}
+
+	missing := `
+		SELECT COUNT(*) FROM notes n
+		WHERE NOT EXISTS (SELECT 1 FROM notes_fts f WHERE f.rowid = n.rowid AND f.note_id = n.id)
+	`
+	if err := s.db.QueryRow(missing).Scan(&check.Missing); err != nil {
+		return nil, fmt.Errorf("failed to check search index: %w", err)
+	}
+
+	orphaned := `
+		SELECT COUNT(*) FROM notes_fts f
+		WHERE NOT EXISTS (SELECT 1 FROM notes n WHERE n.rowid = f.rowid AND n.id = f.note_id)
+	`
+	if err := s.db.QueryRow(orphaned).Scan(&check.Orphaned); err != nil {
+		return nil, fmt.Errorf("failed to check search index: %w", err)
+	}
+
+	return check, nil
+}
@@ -0,0 +1,99 @@
+package note
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNoteContentIsSearchable(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	note, err := service.CreateNote("Plain title", "zebra stripes", "")
+	if err != nil {
+		t.Fatalf("CreateNote() failed: %v", err)
+	}
+	if results, _ := service.SearchNotes("zebra"); len(results) != 1 {
+		t.Fatalf("SearchNotes(zebra) after create = %d results, want 1", len(results))
+	}
+
+	if err := service.UpdateNote(note.ID, "Plain title", "giraffe spots", false); err != nil {
+		t.Fatalf("UpdateNote() failed: %v", err)
+	}
+	if results, _ := service.SearchNotes("zebra"); len(results) != 0 {
+		t.Errorf("SearchNotes(zebra) after update = %d results, want 0", len(results))
+	}
+	if results, _ := service.SearchNotes("giraffe"); len(results) != 1 {
+		t.Errorf("SearchNotes(giraffe) after update = %d results, want 1", len(results))
+	}
+
+	// Changing other columns leaves the search row alone
+	if err := service.SetFavorite(note.ID, true); err != nil {
+		t.Fatalf("SetFavorite() failed: %v", err)
+	}
+	if results, _ := service.SearchNotes("giraffe"); len(results) != 1 {
+		t.Errorf("SearchNotes(giraffe) after favorite = %d results, want 1", len(results))
+	}
+
+	check, err := service.CheckSearchIndex()
+	if err != nil {
+		t.Fatalf("CheckSearchIndex() failed: %v", err)
+	}
+	if !check.OK() {
+		t.Errorf("CheckSearchIndex() = %+v, want OK", check)
+	}
+}
+
+func TestIndexContentSurfacesErrors(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	if err := indexContent(service.db, "missing", "content"); err == nil {
+		t.Error("Expected error indexing content for a note that does not exist")
+	}
+}
+
+func TestRebuildSearchIndex(t *testing.T) {
+	service, tmpDir := setupTestService(t)
+
+	first, _ := service.CreateNote("First", "alpha content", "")
+	second, _ := service.CreateNote("Second", "beta content", "")
+
+	// Simulate a search index damaged by the old empty-content trigger
+	service.db.Exec(`UPDATE notes_fts SET content = ''`)
+	service.db.Exec(`DELETE FROM notes_fts WHERE note_id = ?`, second.ID)
+
+	check, _ := service.CheckSearchIndex()
+	if check.Missing != 1 {
+		t.Errorf("Missing = %d, want 1", check.Missing)
+	}
+
+	// An unreadable file is reported but its note stays searchable by title
+	os.Remove(filepath.Join(tmpDir, second.FilePath))
+
+	report, err := service.RebuildSearchIndex()
+	if err != nil {
+		t.Fatalf("RebuildSearchIndex() failed: %v", err)
+	}
+	if report.Indexed != 1 || len(report.Failed) != 1 {
+		t.Errorf("report = %+v, want 1 indexed and 1 failed", report)
+	}
+
+	if results, _ := service.SearchNotes("alpha"); len(results) != 1 || results[0].Note.ID != first.ID {
+		t.Error("SearchNotes(alpha) did not find the first note after rebuild")
+	}
+	if results, _ := service.SearchNotes("second"); len(results) != 1 {
+		t.Error("SearchNotes(second) did not find the unreadable note by title")
+	}
+
+	check, err = service.CheckSearchIndex()
+	if err != nil {
+		t.Fatalf("CheckSearchIndex() failed: %v", err)
+	}
+	if !check.OK() {
+		t.Errorf("CheckSearchIndex() after rebuild = %+v, want OK", check)
+	}
+
+	if err := service.OptimizeSearchIndex(); err != nil {
+		t.Errorf("OptimizeSearchIndex() failed: %v", err)
+	}
+}
@@ -1,30 +1,72 @@
 package note
 
 import (
+	"database/sql"
 	"fmt"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
+	"fuknotion/backend/internal/attachment"
 	"fuknotion/backend/internal/database"
 	"fuknotion/backend/internal/filesystem"
 	"fuknotion/backend/internal/models"
+	"fuknotion/backend/internal/revision"
 
 	"github.com/google/uuid"
 )
 
 // Service handles note operations
 type Service struct {
-	db *database.Database
-	fs *filesystem.FileSystem
+	db        *database.Database
+	fs        *filesystem.FileSystem
+	revisions *revision.Store
+	titles    *titleIndex
+
+	// hashes records the content hash of every file we last wrote or indexed,
+	// so the watcher can tell external edits apart from our own writes
+	hashMu sync.Mutex
+	hashes map[string]string
 }
 
 // NewService creates a new note service
 func NewService(db *database.Database, fs *filesystem.FileSystem) *Service {
-	return &Service{db: db, fs: fs}
+	return &Service{
+		db:        db,
+		fs:        fs,
+		revisions: revision.NewStore(db),
+		titles:    newTitleIndex(),
+		hashes:    make(map[string]string),
+	}
+}
+
+// Revisions returns the store holding the history of this workspace's notes
+func (s *Service) Revisions() *revision.Store {
+	return s.revisions
 }
 
-// CreateNote creates a new note
+// CreateNote creates a new note. The file is written first and removed again
+// if the database insert fails, so a failed create leaves nothing behind.
 func (s *Service) CreateNote(title, content, folderID string) (*models.Note, error) {
+	return s.createNote(title, content, folderID, nil)
+}
+
+// createNote creates a note carrying tags, which are written to its
+// frontmatter and indexed with the rest of the note
+func (s *Service) createNote(title, content, folderID string, tags []string) (*models.Note, error) {
+	if err := s.checkFolder(folderID); err != nil {
+		return nil, err
+	}
+
+	tags = normalizeTags(tags)
+	for i, tag := range tags {
+		var err error
+		if tags[i], err = s.canonicalTag(tag); err != nil {
+			return nil, err
+		}
+	}
+
 	id := uuid.New().String()
 	now := time.Now()
 
@@ -38,6 +80,7 @@ func (s *Service) CreateNote(title, content, folderID string) (*models.Note, err
 		Modified:   now,
 		FolderID:   folderID,
 		IsFavorite: false,
+		Tags:       tags,
 	}
 
 	// Serialize to markdown
@@ -47,8 +90,9 @@ func (s *Service) CreateNote(title, content, folderID string) (*models.Note, err
 	}
 
 	// Save to file
-	if err := s.fs.WriteFile(filePath, []byte(markdown)); err != nil {
-		return nil, fmt.Errorf("failed to write note file: %w", err)
+	undo, err := s.replaceNoteFile(filePath, []byte(markdown))
+	if err != nil {
+		return nil, err
 	}
 
 	// Save metadata to database
@@ -58,22 +102,41 @@ func (s *Service) CreateNote(title, content, folderID string) (*models.Note, err
 		folderIDPtr = &folderID
 	}
 
-	query := `
-		INSERT INTO notes (id, title, folder_id, file_path, is_favorite, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`
-	result, err := s.db.Exec(query, id, title, folderIDPtr, filePath, false, now, now)
-	if err != nil {
-		return nil, fmt.Errorf("failed to insert note: %w", err)
-	}
+	var position int
+	err = s.db.Transaction(func(tx *sql.Tx) error {
+		// New notes go to the end of their folder's manual order
+		var err error
+		if position, err = nextPosition(tx, folderID); err != nil {
+			return err
+		}
 
-	// Get the rowid for FTS
-	rowid, err := result.LastInsertId()
-	if err == nil {
-		// Update FTS with content
-		ftsQuery := `UPDATE notes_fts SET content = ? WHERE rowid = ?`
-		s.db.Exec(ftsQuery, content, rowid)
+		query := `
+			INSERT INTO notes (id, title, folder_id, file_path, is_favorite, position, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`
+		if _, err := tx.Exec(query, id, title, folderIDPtr, filePath, false, position, now, now); err != nil {
+			return fmt.Errorf("failed to insert note: %w", err)
+		}
+
+		if err := indexContent(tx, id, content); err != nil {
+			return err
+		}
+		if err := s.indexTags(tx, id, tags); err != nil {
+			return err
+		}
+		if err := s.indexLinks(tx, id, content); err != nil {
+			return err
+		}
+		if err := attachment.IndexRefs(tx, id, content); err != nil {
+			return err
+		}
+		return s.revisions.Record(tx, id, title, content, false)
+	})
+	if err != nil {
+		undo()
+		return nil, err
 	}
+	s.titles.put(id, title, folderID)
 
 	return &models.Note{
 		ID:         id,
@@ -81,6 +144,8 @@ func (s *Service) CreateNote(title, content, folderID string) (*models.Note, err
 		FolderID:   folderID,
 		FilePath:   filePath,
 		IsFavorite: false,
+		Position:   position,
+		Tags:       tags,
 		Content:    content,
 		CreatedAt:  now,
 		UpdatedAt:  now,
@@ -90,8 +155,8 @@ func (s *Service) CreateNote(title, content, folderID string) (*models.Note, err
 // GetNote retrieves a note by ID
 func (s *Service) GetNote(id string) (*models.Note, error) {
 	query := `
-		SELECT id, title, folder_id, file_path, is_favorite, created_at, updated_at
-		FROM notes WHERE id = ?
+		SELECT id, title, folder_id, file_path, is_favorite, pinned, position, created_at, updated_at
+		FROM notes WHERE id = ? AND deleted_at IS NULL
 	`
 	var note models.Note
 	var folderID *string
@@ -102,6 +167,8 @@ func (s *Service) GetNote(id string) (*models.Note, error) {
 		&folderID,
 		&note.FilePath,
 		&note.IsFavorite,
+		&note.Pinned,
+		&note.Position,
 		&note.CreatedAt,
 		&note.UpdatedAt,
 	)
@@ -113,6 +180,10 @@ func (s *Service) GetNote(id string) (*models.Note, error) {
 		note.FolderID = *folderID
 	}
 
+	if note.Tags, err = s.noteTags(id); err != nil {
+		return nil, err
+	}
+
 	// Read file content
 	data, err := s.fs.ReadFile(note.FilePath)
 	if err != nil {
@@ -131,8 +202,57 @@ func (s *Service) GetNote(id string) (*models.Note, error) {
 	return &note, nil
 }
 
-// UpdateNote updates an existing note
-func (s *Service) UpdateNote(id, title, content string) error {
+// UpdateNote updates an existing note. Saves in quick succession are
+// coalesced into a single revision, so autosave does not flood the history.
+// With rewriteLinks set, a title change also updates [[links]] in other notes
+// that referred to the note by its old title. The save stands even if some of
+// them cannot be rewritten; the error then names each of those.
+func (s *Service) UpdateNote(id, title, content string, rewriteLinks bool) error {
+	var oldTitle string
+	var sources []string
+	if rewriteLinks {
+		note, err := s.GetNote(id)
+		if err != nil {
+			return err
+		}
+		oldTitle = note.Title
+
+		// Collect them before the rename, while the old title still resolves here
+		if !strings.EqualFold(oldTitle, title) {
+			if sources, err = s.titleLinkSources(id, oldTitle); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := s.updateNote(id, title, content, true); err != nil {
+		return err
+	}
+
+	return s.rewriteLinks(sources, oldTitle, title)
+}
+
+// RestoreRevision saves a past revision's title and content as the note's
+// current version. The restore is recorded as a new revision of its own.
+func (s *Service) RestoreRevision(noteID string, revisionID int64) (*models.Note, error) {
+	rev, err := s.revisions.Get(revisionID)
+	if err != nil {
+		return nil, err
+	}
+	if rev.NoteID != noteID {
+		return nil, fmt.Errorf("revision %d does not belong to note %s", revisionID, noteID)
+	}
+
+	if err := s.updateNote(noteID, rev.Title, rev.Content, false); err != nil {
+		return nil, err
+	}
+
+	return s.GetNote(noteID)
+}
+
+// updateNote writes a note and records a revision of the save. If the
+// database update fails the file's previous contents are put back.
+func (s *Service) updateNote(id, title, content string, coalesce bool) error {
 	// Get existing note
 	note, err := s.GetNote(id)
 	if err != nil {
@@ -141,15 +261,21 @@ func (s *Service) UpdateNote(id, title, content string) error {
 
 	now := time.Now()
 
-	// Update frontmatter
-	fm := &Frontmatter{
-		ID:         id,
-		Title:      title,
-		Created:    note.CreatedAt,
-		Modified:   now,
-		FolderID:   note.FolderID,
-		IsFavorite: note.IsFavorite,
+	// Update frontmatter, keeping fields such as tags that the editor does not send
+	fm, _, err := s.readNoteFile(note.FilePath)
+	if err != nil {
+		// Rebuild it from the index if the file's own frontmatter is unreadable
+		fm = &Frontmatter{
+			ID:         id,
+			Created:    note.CreatedAt,
+			FolderID:   note.FolderID,
+			IsFavorite: note.IsFavorite,
+			Pinned:     note.Pinned,
+			Tags:       note.Tags,
+		}
 	}
+	fm.Title = title
+	fm.Modified = now
 
 	// Serialize to markdown
 	markdown, err := SerializeNote(fm, content)
@@ -158,25 +284,46 @@ func (s *Service) UpdateNote(id, title, content string) error {
 	}
 
 	// Save to file
-	if err := s.fs.WriteFile(note.FilePath, []byte(markdown)); err != nil {
-		return fmt.Errorf("failed to write note file: %w", err)
+	undo, err := s.replaceNoteFile(note.FilePath, []byte(markdown))
+	if err != nil {
+		return err
 	}
 
-	// Update metadata in database
-	query := `UPDATE notes SET title = ?, updated_at = ? WHERE id = ?`
-	_, err = s.db.Exec(query, title, now, id)
+	err = s.db.Transaction(func(tx *sql.Tx) error {
+		// Update metadata in database
+		query := `UPDATE notes SET title = ?, updated_at = ? WHERE id = ?`
+		if _, err := tx.Exec(query, title, now, id); err != nil {
+			return fmt.Errorf("failed to update note: %w", err)
+		}
+
+		if err := indexContent(tx, id, content); err != nil {
+			return err
+		}
+
+		// The file may carry tag edits made outside the app
+		if err := s.indexTags(tx, id, normalizeTags(fm.Tags)); err != nil {
+			return err
+		}
+		if err := s.indexLinks(tx, id, content); err != nil {
+			return err
+		}
+		if err := attachment.IndexRefs(tx, id, content); err != nil {
+			return err
+		}
+
+		return s.revisions.Record(tx, id, title, content, coalesce)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update note: %w", err)
+		undo()
+		return err
 	}
-
-	// Update FTS content
-	ftsQuery := `UPDATE notes_fts SET content = ? WHERE note_id = ?`
-	s.db.Exec(ftsQuery, content, id)
+	s.titles.put(id, title, note.FolderID)
 
 	return nil
 }
 
-// DeleteNote deletes a note
+// DeleteNote moves a note to the trash. The file is moved to .trash/ and the
+// row is kept with deleted_at set, so the note can be restored.
 func (s *Service) DeleteNote(id string) error {
 	// Get note to find file path
 	note, err := s.GetNote(id)
@@ -184,29 +331,170 @@ func (s *Service) DeleteNote(id string) error {
 		return err
 	}
 
-	// Delete file
-	if err := s.fs.DeleteFile(note.FilePath); err != nil {
-		return fmt.Errorf("failed to delete note file: %w", err)
+	// A trashed note gives up its pin, so it does not come back holding a
+	// place another note has taken since
+	undoUnpin := func() {}
+	if note.Pinned > 0 {
+		undoUnpin, err = s.rewriteFrontmatter(note.FilePath, func(fm *Frontmatter) {
+			fm.Pinned = 0
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Move file out of the live workspace
+	undo, err := s.moveNoteFile(note.FilePath, trashPath(id))
+	if err != nil {
+		undoUnpin()
+		return fmt.Errorf("failed to move note to trash: %w", err)
+	}
+
+	query := `UPDATE notes SET deleted_at = ?, trashed_from = file_path, file_path = ?, pinned = 0 WHERE id = ?`
+	_, err = s.db.Exec(query, time.Now(), trashPath(id), id)
+	if err != nil {
+		undo()
+		undoUnpin()
+		return fmt.Errorf("failed to trash note: %w", err)
+	}
+	s.titles.remove(id)
+
+	return nil
+}
+
+// MoveNote moves a note into a folder, or to the workspace root when folderID is empty
+func (s *Service) MoveNote(id, folderID string) error {
+	if err := s.checkFolder(folderID); err != nil {
+		return err
+	}
+
+	note, err := s.GetNote(id)
+	if err != nil {
+		return err
+	}
+
+	if note.FolderID == folderID {
+		return nil
 	}
 
-	// Delete from database
-	query := `DELETE FROM notes WHERE id = ?`
-	_, err = s.db.Exec(query, id)
+	// Rewrite frontmatter so the folder survives a reindex from disk
+	now := time.Now()
+	undo, err := s.rewriteFrontmatter(note.FilePath, func(fm *Frontmatter) {
+		fm.FolderID = folderID
+		fm.Modified = now
+	})
 	if err != nil {
-		return fmt.Errorf("failed to delete note: %w", err)
+		return err
 	}
 
+	var folderIDPtr *string
+	if folderID != "" {
+		folderIDPtr = &folderID
+	}
+
+	err = s.db.Transaction(func(tx *sql.Tx) error {
+		// The note goes to the end of the new folder's manual order
+		position, err := nextPosition(tx, folderID)
+		if err != nil {
+			return err
+		}
+
+		query := `UPDATE notes SET folder_id = ?, position = ?, updated_at = ? WHERE id = ?`
+		if _, err := tx.Exec(query, folderIDPtr, position, now, id); err != nil {
+			return fmt.Errorf("failed to move note: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		undo()
+		return err
+	}
+	s.titles.put(id, note.Title, folderID)
+
 	return nil
 }
 
-// ListNotes lists all notes
-func (s *Service) ListNotes() ([]*models.Note, error) {
+// checkFolder verifies that a non-empty folder ID refers to an existing folder
+func (s *Service) checkFolder(folderID string) error {
+	if folderID == "" {
+		return nil
+	}
+
+	var exists bool
+	err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM folders WHERE id = ?)`, folderID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check folder: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("folder not found: %s", folderID)
+	}
+
+	return nil
+}
+
+// rewriteFrontmatter applies edit to a note file's frontmatter, leaving its
+// content untouched, and returns a function that undoes the rewrite
+func (s *Service) rewriteFrontmatter(filePath string, edit func(fm *Frontmatter)) (func(), error) {
+	fm, content, err := s.readNoteFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	edit(fm)
+
+	markdown, err := SerializeNote(fm, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize note: %w", err)
+	}
+
+	return s.replaceNoteFile(filePath, []byte(markdown))
+}
+
+// readNoteFile reads and parses a note file, keeping every frontmatter field
+func (s *Service) readNoteFile(filePath string) (*Frontmatter, string, error) {
+	data, err := s.fs.ReadFile(filePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read note file: %w", err)
+	}
+
+	return parseNoteData(data)
+}
+
+// parseNoteData parses raw note file bytes
+func parseNoteData(data []byte) (*Frontmatter, string, error) {
+	fm, content, err := ParseMarkdown(string(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse note file: %w", err)
+	}
+
+	return fm, content, nil
+}
+
+// ListNotes lists all notes, or only those carrying tag when it is not empty
+func (s *Service) ListNotes(tag string) ([]*models.Note, error) {
 	query := `
-		SELECT id, title, folder_id, file_path, is_favorite, created_at, updated_at
-		FROM notes ORDER BY updated_at DESC
+		SELECT id, title, folder_id, file_path, is_favorite, pinned, position, created_at, updated_at
+		FROM notes WHERE deleted_at IS NULL
 	`
+	var args []interface{}
+	if tag != "" {
+		name, err := normalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		query += ` AND id IN (
+			SELECT nt.note_id FROM note_tags nt JOIN tags t ON t.id = nt.tag_id WHERE t.name = ?
+		)`
+		args = append(args, name)
+	}
+	// Pinned notes come first, in pin order
+	query += ` ORDER BY pinned = 0, pinned, updated_at DESC`
+
+	tags, err := s.tagsByNote()
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := s.db.Query(query)
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list notes: %w", err)
 	}
@@ -223,6 +511,8 @@ func (s *Service) ListNotes() ([]*models.Note, error) {
 			&folderID,
 			&note.FilePath,
 			&note.IsFavorite,
+			&note.Pinned,
+			&note.Position,
 			&note.CreatedAt,
 			&note.UpdatedAt,
 		)
@@ -233,6 +523,7 @@ func (s *Service) ListNotes() ([]*models.Note, error) {
 		if folderID != nil {
 			note.FolderID = *folderID
 		}
+		note.Tags = tags[note.ID]
 
 		notes = append(notes, &note)
 	}
@@ -247,33 +538,109 @@ type SearchResult struct {
 	Rank    float64
 }
 
-// SearchNotes searches for notes using full-text search
+// searchWeights are the bm25 weights of the title, headings and content
+// columns of notes_fts: a match in a title counts most, then in a heading
+const searchWeights = "10.0, 5.0, 1.0"
+
+// SearchPage is one page of search results. NextCursor is empty on the last page.
+type SearchPage struct {
+	Results    []*SearchResult
+	NextCursor string
+}
+
+// SearchNotes returns the first page of results for a query in the language
+// described at ParseQuery. A malformed query returns a *QueryError rather
+// than an SQLite error.
 func (s *Service) SearchNotes(query string) ([]*SearchResult, error) {
-	if query == "" {
-		return []*SearchResult{}, nil
-	}
-
-	// Use FTS5 MATCH query with bm25 ranking
-	searchQuery := `
-		SELECT
-			n.id, n.title, n.folder_id, n.file_path, n.is_favorite, n.created_at, n.updated_at,
-			snippet(notes_fts, 2, '<mark>', '</mark>', '...', 32) as snippet,
-			bm25(notes_fts) as rank
-		FROM notes_fts
-		JOIN notes n ON notes_fts.note_id = n.id
-		WHERE notes_fts MATCH ?
-		ORDER BY rank
-		LIMIT 50
-	`
+	page, err := s.SearchNotesPage(query, defaultPageSize, "")
+	if err != nil {
+		return nil, err
+	}
+	return page.Results, nil
+}
+
+// SearchNotesPage returns one page of search results. Results are ranked,
+// so pages are addressed by offset; pass the previous NextCursor to continue.
+func (s *Service) SearchNotesPage(query string, limit int, cursor string) (*SearchPage, error) {
+	parsed, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &SearchPage{Results: []*SearchResult{}}
+	if parsed.IsEmpty() {
+		return page, nil
+	}
+
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	offset := 0
+	if cursor != "" {
+		c, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.Sort != "search" || c.Query != query || c.Offset < 0 {
+			return nil, fmt.Errorf("cursor does not match the search query")
+		}
+		offset = c.Offset
+	}
+
+	match, where, args := parsed.compile()
+	if match == "" && len(where) == 0 {
+		// Only terms the tokenizer ignores, such as "++"
+		return page, nil
+	}
+	where = append([]string{"n.deleted_at IS NULL"}, where...)
+
+	var searchQuery string
+	if match != "" {
+		// Use FTS5 MATCH query with bm25 ranking
+		searchQuery = `
+			SELECT
+				n.id, n.title, n.folder_id, n.file_path, n.is_favorite, n.pinned, n.position, n.created_at, n.updated_at,
+				snippet(notes_fts, 3, '<mark>', '</mark>', '...', 32) as snippet,
+				bm25(notes_fts, 0, ` + searchWeights + `) as rank
+			FROM notes_fts
+			JOIN notes n ON notes_fts.note_id = n.id
+			WHERE notes_fts MATCH ? AND ` + strings.Join(where, " AND ") + `
+			ORDER BY rank, n.id
+			LIMIT ? OFFSET ?
+		`
+		args = append([]interface{}{match}, args...)
+	} else {
+		// Only filters and exclusions: nothing to rank by, so newest first
+		searchQuery = `
+			SELECT
+				n.id, n.title, n.folder_id, n.file_path, n.is_favorite, n.pinned, n.position, n.created_at, n.updated_at,
+				'' as snippet, 0.0 as rank
+			FROM notes n
+			WHERE ` + strings.Join(where, " AND ") + `
+			ORDER BY n.updated_at DESC, n.id
+			LIMIT ? OFFSET ?
+		`
+	}
+	// One extra row tells us whether there is another page
+	args = append(args, limit+1, offset)
 
-	rows, err := s.db.Query(searchQuery, query)
+	rows, err := s.db.Query(searchQuery, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search notes: %w", err)
 	}
 	defer rows.Close()
 
-	var results []*SearchResult
 	for rows.Next() {
+		if len(page.Results) == limit {
+			next := pageCursor{Sort: "search", Query: query, Offset: offset + limit}
+			page.NextCursor = encodeCursor(next)
+			break
+		}
+
 		var note models.Note
 		var folderID *string
 		var snippet string
@@ -285,6 +652,8 @@ func (s *Service) SearchNotes(query string) ([]*SearchResult, error) {
 			&folderID,
 			&note.FilePath,
 			&note.IsFavorite,
+			&note.Pinned,
+			&note.Position,
 			&note.CreatedAt,
 			&note.UpdatedAt,
 			&snippet,
@@ -298,12 +667,15 @@ func (s *Service) SearchNotes(query string) ([]*SearchResult, error) {
 			note.FolderID = *folderID
 		}
 
-		results = append(results, &SearchResult{
+		page.Results = append(page.Results, &SearchResult{
 			Note:    &note,
 			Snippet: snippet,
 			Rank:    rank,
 		})
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search notes: %w", err)
+	}
 
-	return results, nil
+	return page, nil
 }
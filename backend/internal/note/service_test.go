@@ -8,6 +8,7 @@ import (
 
 	"fuknotion/backend/internal/database"
 	"fuknotion/backend/internal/filesystem"
+	"fuknotion/backend/internal/revision"
 )
 
 func setupTestService(t *testing.T) (*Service, string) {
@@ -168,7 +169,7 @@ func TestUpdateNote(t *testing.T) {
 	// Update the note
 	newTitle := "Updated Title"
 	newContent := "Updated content with more text"
-	err = service.UpdateNote(note.ID, newTitle, newContent)
+	err = service.UpdateNote(note.ID, newTitle, newContent, false)
 	if err != nil {
 		t.Fatalf("UpdateNote() failed: %v", err)
 	}
@@ -192,7 +193,7 @@ func TestUpdateNote(t *testing.T) {
 	}
 
 	// Test updating non-existent note
-	err = service.UpdateNote("non_existent_id", "Title", "Content")
+	err = service.UpdateNote("non_existent_id", "Title", "Content", false)
 	if err == nil {
 		t.Error("Expected error for non-existent note, got nil")
 	}
@@ -242,7 +243,7 @@ func TestListNotes(t *testing.T) {
 	service, _ := setupTestService(t)
 
 	// Test empty list
-	notes, err := service.ListNotes()
+	notes, err := service.ListNotes("")
 	if err != nil {
 		t.Fatalf("ListNotes() failed: %v", err)
 	}
@@ -262,7 +263,7 @@ func TestListNotes(t *testing.T) {
 	}
 
 	// List all notes
-	notes, err = service.ListNotes()
+	notes, err = service.ListNotes("")
 	if err != nil {
 		t.Fatalf("ListNotes() failed: %v", err)
 	}
@@ -345,3 +346,100 @@ func TestNotesFavorite(t *testing.T) {
 	// Note: Favoriting functionality would be implemented in a separate method
 	// This test just verifies the field exists and defaults correctly
 }
+
+func TestRestoreRevision(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	// Keep every save as its own revision
+	policy := revision.DefaultPolicy
+	policy.CoalesceWindow = 0
+	service.Revisions().SetPolicy(policy)
+
+	note, err := service.CreateNote("Draft", "first version", "")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	if err := service.UpdateNote(note.ID, "Final", "second version", false); err != nil {
+		t.Fatalf("UpdateNote() failed: %v", err)
+	}
+
+	revisions, err := service.Revisions().List(note.ID)
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("List() returned %d revisions, want 2", len(revisions))
+	}
+
+	restored, err := service.RestoreRevision(note.ID, revisions[1].ID)
+	if err != nil {
+		t.Fatalf("RestoreRevision() failed: %v", err)
+	}
+	if restored.Title != "Draft" || restored.Content != "first version" {
+		t.Errorf("restored note = %q/%q, want Draft/first version", restored.Title, restored.Content)
+	}
+
+	revisions, _ = service.Revisions().List(note.ID)
+	if len(revisions) != 3 {
+		t.Errorf("List() after restore returned %d revisions, want 3", len(revisions))
+	}
+
+	other, _ := service.CreateNote("Other", "content", "")
+	if _, err := service.RestoreRevision(other.ID, revisions[0].ID); err == nil {
+		t.Error("Expected error restoring another note's revision")
+	}
+}
+
+// failWrites makes every write to the notes table fail, simulating a
+// database error after the note file has been written
+func failWrites(t *testing.T, service *Service) {
+	t.Helper()
+
+	for _, op := range []string{"INSERT", "UPDATE"} {
+		trigger := `CREATE TRIGGER fail_` + strings.ToLower(op) + ` BEFORE ` + op + ` ON notes
+			BEGIN SELECT RAISE(ABORT, 'simulated failure'); END`
+		if _, err := service.db.Exec(trigger); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFailedWritesLeaveNoTrace(t *testing.T) {
+	service, tmpDir := setupTestService(t)
+
+	note, err := service.CreateNote("Stable", "original", "")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	path := filepath.Join(tmpDir, note.FilePath)
+	original, _ := os.ReadFile(path)
+
+	failWrites(t, service)
+
+	if _, err := service.CreateNote("Doomed", "content", ""); err == nil {
+		t.Fatal("Expected CreateNote() to fail")
+	}
+	entries, _ := os.ReadDir(filepath.Join(tmpDir, "notes"))
+	if len(entries) != 1 {
+		t.Errorf("notes directory holds %d files after a failed create, want 1", len(entries))
+	}
+
+	if err := service.UpdateNote(note.ID, "Changed", "changed", false); err == nil {
+		t.Fatal("Expected UpdateNote() to fail")
+	}
+	if data, _ := os.ReadFile(path); string(data) != string(original) {
+		t.Errorf("note file changed after a failed update:\n%s", data)
+	}
+
+	if err := service.DeleteNote(note.ID); err == nil {
+		t.Fatal("Expected DeleteNote() to fail")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("note file not back in place after a failed delete: %v", err)
+	}
+
+	revisions, _ := service.Revisions().List(note.ID)
+	if len(revisions) != 1 {
+		t.Errorf("List() returned %d revisions, want only the original", len(revisions))
+	}
+}
@@ -0,0 +1,223 @@
+package note
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fuknotion/backend/internal/database"
+	"fuknotion/backend/internal/fuzzy"
+)
+
+// SwitcherResult is a note offered by the quick switcher. Ranges are the
+// matched characters of Title as rune offsets, for highlighting.
+type SwitcherResult struct {
+	ID       string        `json:"id"`
+	Title    string        `json:"title"`
+	FolderID string        `json:"folderId,omitempty"`
+	Score    int           `json:"score"`
+	Ranges   []fuzzy.Range `json:"ranges"`
+}
+
+const (
+	defaultSwitcherLimit = 20
+
+	// Opening a note adds up to recentBonus to its score, halving every
+	// recentHalfLife, so a recent note beats a slightly better match
+	recentBonus    = 48.0
+	recentHalfLife = 24 * time.Hour
+)
+
+// titleEntry is a live note as the quick switcher sees it
+type titleEntry struct {
+	id       string
+	title    string
+	folderID string
+	opened   time.Time
+}
+
+// titleIndex keeps the titles of live notes in memory so the quick switcher
+// can match on every keystroke without touching the database. The note
+// service updates it after each write; bulk changes such as a reindex
+// invalidate it and it is reloaded on the next query.
+type titleIndex struct {
+	mu      sync.Mutex
+	loaded  bool
+	entries map[string]*titleEntry
+}
+
+func newTitleIndex() *titleIndex {
+	return &titleIndex{entries: make(map[string]*titleEntry)}
+}
+
+// put adds or updates a note, keeping when it was last opened
+func (t *titleIndex) put(id, title, folderID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if e, ok := t.entries[id]; ok {
+		e.title, e.folderID = title, folderID
+		return
+	}
+	t.entries[id] = &titleEntry{id: id, title: title, folderID: folderID}
+}
+
+func (t *titleIndex) remove(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.entries, id)
+}
+
+func (t *titleIndex) touch(id string, opened time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e, ok := t.entries[id]; ok {
+		e.opened = opened
+	}
+}
+
+// invalidate drops the index so the next query reloads it
+func (t *titleIndex) invalidate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.loaded = false
+	t.entries = make(map[string]*titleEntry)
+}
+
+// snapshot returns the entries, loading them from q first if needed. The
+// lock is held while loading so writes made meanwhile are applied after it.
+func (t *titleIndex) snapshot(q database.Executor) ([]titleEntry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.loaded {
+		entries, err := loadTitles(q)
+		if err != nil {
+			return nil, err
+		}
+		t.entries = entries
+		t.loaded = true
+	}
+
+	out := make([]titleEntry, 0, len(t.entries))
+	for _, e := range t.entries {
+		out = append(out, *e)
+	}
+	return out, nil
+}
+
+func loadTitles(q database.Executor) (map[string]*titleEntry, error) {
+	rows, err := q.Query(`SELECT id, title, folder_id, opened_at FROM notes WHERE deleted_at IS NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load note titles: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make(map[string]*titleEntry)
+	for rows.Next() {
+		var e titleEntry
+		var folderID *string
+		var opened *time.Time
+		if err := rows.Scan(&e.id, &e.title, &folderID, &opened); err != nil {
+			return nil, fmt.Errorf("failed to scan note title: %w", err)
+		}
+		if folderID != nil {
+			e.folderID = *folderID
+		}
+		if opened != nil {
+			e.opened = *opened
+		}
+		entries[e.id] = &e
+	}
+
+	return entries, rows.Err()
+}
+
+// QuickSwitch fuzzy-matches query against the titles of live notes, best
+// first, favouring recently opened notes. An empty query lists the most
+// recently opened notes.
+func (s *Service) QuickSwitch(query string, limit int) ([]*SwitcherResult, error) {
+	if limit <= 0 {
+		limit = defaultSwitcherLimit
+	}
+
+	entries, err := s.titles.snapshot(s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	empty := strings.TrimSpace(query) == ""
+
+	results := []*SwitcherResult{}
+	opened := make(map[string]time.Time)
+	for _, e := range entries {
+		if empty && e.opened.IsZero() {
+			continue
+		}
+
+		match, ok := fuzzy.Match(query, e.title)
+		if !ok {
+			continue
+		}
+
+		results = append(results, &SwitcherResult{
+			ID:       e.id,
+			Title:    e.title,
+			FolderID: e.folderID,
+			Score:    match.Score + recency(e.opened, now),
+			Ranges:   match.Ranges,
+		})
+		opened[e.id] = e.opened
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if empty {
+			return opened[a.ID].After(opened[b.ID])
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if len(a.Title) != len(b.Title) {
+			return len(a.Title) < len(b.Title)
+		}
+		return a.Title < b.Title
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// MarkOpened records that a note was opened, for the quick switcher's ranking
+func (s *Service) MarkOpened(id string) error {
+	now := time.Now()
+
+	result, err := s.db.Exec(`UPDATE notes SET opened_at = ? WHERE id = ? AND deleted_at IS NULL`, now, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark note opened: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("note not found: %s", id)
+	}
+
+	s.titles.touch(id, now)
+	return nil
+}
+
+// recency is the score bonus for a note opened at opened
+func recency(opened, now time.Time) int {
+	if opened.IsZero() {
+		return 0
+	}
+	age := now.Sub(opened)
+	if age < 0 {
+		age = 0
+	}
+	return int(recentBonus * math.Pow(0.5, float64(age)/float64(recentHalfLife)))
+}
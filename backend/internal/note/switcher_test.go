@@ -0,0 +1,104 @@
+package note
+
+import (
+	"testing"
+	"time"
+)
+
+func switcherTitles(t *testing.T, service *Service, query string) []string {
+	t.Helper()
+
+	results, err := service.QuickSwitch(query, 0)
+	if err != nil {
+		t.Fatalf("QuickSwitch(%q) failed: %v", query, err)
+	}
+	titles := []string{}
+	for _, r := range results {
+		titles = append(titles, r.Title)
+	}
+	return titles
+}
+
+func TestQuickSwitch(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	meeting, _ := service.CreateNote("Meeting notes", "", "")
+	service.CreateNote("Quick switcher design", "", "")
+	service.CreateNote("Shopping list", "", "")
+
+	if got := switcherTitles(t, service, "meetng"); len(got) != 1 || got[0] != "Meeting notes" {
+		t.Errorf("QuickSwitch(meetng) = %v, want [Meeting notes]", got)
+	}
+	if got := switcherTitles(t, service, "qsd"); len(got) != 1 || got[0] != "Quick switcher design" {
+		t.Errorf("QuickSwitch(qsd) = %v, want [Quick switcher design]", got)
+	}
+
+	results, _ := service.QuickSwitch("notes", 0)
+	if len(results) != 1 || len(results[0].Ranges) != 1 || results[0].Ranges[0].Start != 8 || results[0].Ranges[0].End != 13 {
+		t.Errorf("QuickSwitch(notes) ranges = %+v, want [8,13)", results)
+	}
+
+	// The index follows renames, deletes and restores
+	service.UpdateNote(meeting.ID, "Standup", "", false)
+	if got := switcherTitles(t, service, "meetng"); len(got) != 0 {
+		t.Errorf("QuickSwitch(meetng) after rename = %v, want none", got)
+	}
+	if got := switcherTitles(t, service, "standup"); len(got) != 1 {
+		t.Errorf("QuickSwitch(standup) after rename = %v, want 1 result", got)
+	}
+
+	service.DeleteNote(meeting.ID)
+	if got := switcherTitles(t, service, "standup"); len(got) != 0 {
+		t.Errorf("QuickSwitch(standup) after delete = %v, want none", got)
+	}
+	service.RestoreNote(meeting.ID)
+	if got := switcherTitles(t, service, "standup"); len(got) != 1 {
+		t.Errorf("QuickSwitch(standup) after restore = %v, want 1 result", got)
+	}
+}
+
+func TestQuickSwitchFavorsRecentlyOpened(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	service.CreateNote("Project plan", "", "")
+	old, _ := service.CreateNote("Project plans archive", "", "")
+
+	if got := switcherTitles(t, service, "project"); got[0] != "Project plan" {
+		t.Errorf("first result = %v, want the shorter title", got)
+	}
+
+	if err := service.MarkOpened(old.ID); err != nil {
+		t.Fatalf("MarkOpened() failed: %v", err)
+	}
+	if got := switcherTitles(t, service, "project"); got[0] != "Project plans archive" {
+		t.Errorf("first result after opening = %v, want the opened note", got)
+	}
+
+	// With no query only opened notes are listed, most recent first
+	if got := switcherTitles(t, service, ""); len(got) != 1 || got[0] != "Project plans archive" {
+		t.Errorf("QuickSwitch(\"\") = %v, want the opened note", got)
+	}
+
+	// The opened time survives a reload of the index
+	service.titles.invalidate()
+	if got := switcherTitles(t, service, "project"); got[0] != "Project plans archive" {
+		t.Errorf("first result after reload = %v, want the opened note", got)
+	}
+
+	if err := service.MarkOpened("missing"); err == nil {
+		t.Error("Expected error marking a missing note opened")
+	}
+}
+
+func TestRecency(t *testing.T) {
+	now := time.Now()
+	if got := recency(time.Time{}, now); got != 0 {
+		t.Errorf("recency(never) = %d, want 0", got)
+	}
+	if got := recency(now, now); got != int(recentBonus) {
+		t.Errorf("recency(now) = %d, want %d", got, int(recentBonus))
+	}
+	if got := recency(now.Add(-recentHalfLife), now); got != int(recentBonus/2) {
+		t.Errorf("recency(half life ago) = %d, want %d", got, int(recentBonus/2))
+	}
+}
@@ -0,0 +1,322 @@
+package note
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"fuknotion/backend/internal/database"
+)
+
+// TagCount is a tag with the number of live notes carrying it
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// normalizeTag trims a tag name and drops a leading '#'
+func normalizeTag(name string) (string, error) {
+	name = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(name), "#"))
+	if name == "" {
+		return "", fmt.Errorf("tag name is required")
+	}
+	if strings.ContainsAny(name, ",\n") {
+		return "", fmt.Errorf("tag name cannot contain commas or newlines: %q", name)
+	}
+	return name, nil
+}
+
+// normalizeTags normalizes a tag list, dropping invalid entries and
+// case-insensitive duplicates while keeping the original order
+func normalizeTags(tags []string) []string {
+	var out []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		name, err := normalizeTag(tag)
+		if err != nil || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		out = append(out, name)
+	}
+	return out
+}
+
+// AddTag tags a note, writing the tag to its frontmatter
+func (s *Service) AddTag(noteID, tag string) error {
+	name, err := normalizeTag(tag)
+	if err != nil {
+		return err
+	}
+	if name, err = s.canonicalTag(name); err != nil {
+		return err
+	}
+
+	note, err := s.GetNote(noteID)
+	if err != nil {
+		return err
+	}
+
+	return s.editTags(noteID, note.FilePath, func(tags []string) []string {
+		return append(tags, name)
+	})
+}
+
+// RemoveTag removes a tag from a note's frontmatter
+func (s *Service) RemoveTag(noteID, tag string) error {
+	name, err := normalizeTag(tag)
+	if err != nil {
+		return err
+	}
+
+	note, err := s.GetNote(noteID)
+	if err != nil {
+		return err
+	}
+
+	return s.editTags(noteID, note.FilePath, func(tags []string) []string {
+		return withoutTag(tags, name)
+	})
+}
+
+// ListTags lists every tag in use with the number of live notes carrying it
+func (s *Service) ListTags() ([]*TagCount, error) {
+	query := `
+		SELECT t.name, COUNT(n.id)
+		FROM tags t
+		JOIN note_tags nt ON nt.tag_id = t.id
+		JOIN notes n ON n.id = nt.note_id AND n.deleted_at IS NULL
+		GROUP BY t.id
+		ORDER BY t.name COLLATE NOCASE
+	`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []*TagCount{}
+	for rows.Next() {
+		var tag TagCount
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, &tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// RenameTag renames a tag on every note carrying it, trashed notes included,
+// and returns how many notes were rewritten. Renaming onto an existing tag
+// merges the two.
+func (s *Service) RenameTag(oldName, newName string) (int, error) {
+	from, err := normalizeTag(oldName)
+	if err != nil {
+		return 0, err
+	}
+	to, err := normalizeTag(newName)
+	if err != nil {
+		return 0, err
+	}
+
+	var fromID int64
+	err = s.db.QueryRow(`SELECT id FROM tags WHERE name = ?`, from).Scan(&fromID)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("tag not found: %s", from)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get tag: %w", err)
+	}
+
+	if strings.EqualFold(from, to) {
+		// Only the spelling changes, so the tag row can be renamed in place
+		if _, err := s.db.Exec(`UPDATE tags SET name = ? WHERE id = ?`, to, fromID); err != nil {
+			return 0, fmt.Errorf("failed to rename tag: %w", err)
+		}
+	} else if to, err = s.canonicalTag(to); err != nil {
+		return 0, err
+	}
+
+	rows, err := s.db.Query(
+		`SELECT n.id, n.file_path FROM note_tags nt JOIN notes n ON n.id = nt.note_id WHERE nt.tag_id = ?`,
+		fromID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list tagged notes: %w", err)
+	}
+	type tagged struct{ id, path string }
+	var notes []tagged
+	for rows.Next() {
+		var n tagged
+		if err := rows.Scan(&n.id, &n.path); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan tagged note: %w", err)
+		}
+		notes = append(notes, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to list tagged notes: %w", err)
+	}
+
+	for i, n := range notes {
+		err := s.editTags(n.id, n.path, func(tags []string) []string {
+			for j, tag := range tags {
+				if strings.EqualFold(strings.TrimPrefix(strings.TrimSpace(tag), "#"), from) {
+					tags[j] = to
+				}
+			}
+			return tags
+		})
+		if err != nil {
+			return i, fmt.Errorf("failed to retag note %s: %w", n.id, err)
+		}
+	}
+
+	return len(notes), nil
+}
+
+// MergeTags renames each source tag to target, returning how many notes were rewritten
+func (s *Service) MergeTags(sources []string, target string) (int, error) {
+	total := 0
+	for _, source := range sources {
+		if strings.EqualFold(strings.TrimSpace(source), strings.TrimSpace(target)) {
+			continue
+		}
+		n, err := s.RenameTag(source, target)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// editTags rewrites a note's frontmatter tags and reindexes them, bumping
+// the note's update time along with its file's
+func (s *Service) editTags(noteID, filePath string, edit func(tags []string) []string) error {
+	now := time.Now()
+	var tags []string
+	undo, err := s.rewriteFrontmatter(filePath, func(fm *Frontmatter) {
+		fm.Tags = normalizeTags(edit(fm.Tags))
+		fm.Modified = now
+		tags = fm.Tags
+	})
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Transaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`UPDATE notes SET updated_at = ? WHERE id = ?`, now, noteID); err != nil {
+			return fmt.Errorf("failed to update note: %w", err)
+		}
+		return s.indexTags(tx, noteID, tags)
+	})
+	if err != nil {
+		undo()
+		return err
+	}
+
+	return nil
+}
+
+// indexTags replaces a note's rows in note_tags and drops tags no note uses
+func (s *Service) indexTags(q database.Executor, noteID string, tags []string) error {
+	if _, err := q.Exec(`DELETE FROM note_tags WHERE note_id = ?`, noteID); err != nil {
+		return fmt.Errorf("failed to clear note tags: %w", err)
+	}
+
+	for _, tag := range tags {
+		if _, err := q.Exec(`INSERT INTO tags (name) VALUES (?) ON CONFLICT(name) DO NOTHING`, tag); err != nil {
+			return fmt.Errorf("failed to insert tag: %w", err)
+		}
+
+		query := `INSERT OR IGNORE INTO note_tags (note_id, tag_id) SELECT ?, id FROM tags WHERE name = ?`
+		if _, err := q.Exec(query, noteID, tag); err != nil {
+			return fmt.Errorf("failed to tag note: %w", err)
+		}
+	}
+
+	if _, err := q.Exec(`DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM note_tags)`); err != nil {
+		return fmt.Errorf("failed to remove unused tags: %w", err)
+	}
+
+	return nil
+}
+
+// canonicalTag returns the stored spelling of a tag, or name if it is new
+func (s *Service) canonicalTag(name string) (string, error) {
+	var stored string
+	err := s.db.QueryRow(`SELECT name FROM tags WHERE name = ?`, name).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return name, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get tag: %w", err)
+	}
+	return stored, nil
+}
+
+// noteTags returns a note's tags in name order
+func (s *Service) noteTags(noteID string) ([]string, error) {
+	query := `
+		SELECT t.name FROM note_tags nt JOIN tags t ON t.id = nt.tag_id
+		WHERE nt.note_id = ? ORDER BY t.name COLLATE NOCASE
+	`
+
+	rows, err := s.db.Query(query, noteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get note tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// tagsByNote returns the tags of every note keyed by note ID
+func (s *Service) tagsByNote() (map[string][]string, error) {
+	query := `
+		SELECT nt.note_id, t.name FROM note_tags nt JOIN tags t ON t.id = nt.tag_id
+		ORDER BY t.name COLLATE NOCASE
+	`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get note tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make(map[string][]string)
+	for rows.Next() {
+		var noteID, tag string
+		if err := rows.Scan(&noteID, &tag); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags[noteID] = append(tags[noteID], tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// withoutTag removes every case-insensitive match of name from tags
+func withoutTag(tags []string, name string) []string {
+	var out []string
+	for _, tag := range tags {
+		if !strings.EqualFold(strings.TrimPrefix(strings.TrimSpace(tag), "#"), name) {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
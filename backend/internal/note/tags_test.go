@@ -0,0 +1,188 @@
+package note
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fileTags reads the tags stored in a note file's frontmatter
+func fileTags(t *testing.T, tmpDir, filePath string) []string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, filePath))
+	if err != nil {
+		t.Fatalf("Failed to read note file: %v", err)
+	}
+	fm, _, err := ParseMarkdown(string(data))
+	if err != nil {
+		t.Fatalf("Failed to parse note file: %v", err)
+	}
+	return fm.Tags
+}
+
+func TestAddAndRemoveTag(t *testing.T) {
+	service, tmpDir := setupTestService(t)
+
+	note, _ := service.CreateNote("Tagged", "content", "")
+
+	if err := service.AddTag(note.ID, "#Work"); err != nil {
+		t.Fatalf("AddTag() failed: %v", err)
+	}
+	if err := service.AddTag(note.ID, "work"); err != nil {
+		t.Fatalf("AddTag() with a duplicate failed: %v", err)
+	}
+	if err := service.AddTag(note.ID, "  "); err == nil {
+		t.Error("Expected error adding an empty tag")
+	}
+
+	if tags := fileTags(t, tmpDir, note.FilePath); len(tags) != 1 || tags[0] != "Work" {
+		t.Errorf("frontmatter tags = %v, want [Work]", tags)
+	}
+
+	// Saving from the editor must keep the frontmatter tags
+	if err := service.UpdateNote(note.ID, "Tagged", "new content", false); err != nil {
+		t.Fatalf("UpdateNote() failed: %v", err)
+	}
+	got, _ := service.GetNote(note.ID)
+	if len(got.Tags) != 1 || got.Tags[0] != "Work" {
+		t.Errorf("Tags after update = %v, want [Work]", got.Tags)
+	}
+
+	if err := service.RemoveTag(note.ID, "WORK"); err != nil {
+		t.Fatalf("RemoveTag() failed: %v", err)
+	}
+	if tags := fileTags(t, tmpDir, note.FilePath); len(tags) != 0 {
+		t.Errorf("frontmatter tags after removal = %v, want none", tags)
+	}
+
+	tags, _ := service.ListTags()
+	if len(tags) != 0 {
+		t.Errorf("ListTags() = %v, want no tags", tags)
+	}
+}
+
+func TestListNotesByTag(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	a, _ := service.CreateNote("A", "", "")
+	b, _ := service.CreateNote("B", "", "")
+	service.CreateNote("C", "", "")
+	service.AddTag(a.ID, "go")
+	service.AddTag(b.ID, "go")
+	service.AddTag(b.ID, "db")
+
+	notes, err := service.ListNotes("go")
+	if err != nil {
+		t.Fatalf("ListNotes() failed: %v", err)
+	}
+	if len(notes) != 2 {
+		t.Errorf("ListNotes(go) returned %d notes, want 2", len(notes))
+	}
+
+	tags, err := service.ListTags()
+	if err != nil {
+		t.Fatalf("ListTags() failed: %v", err)
+	}
+	want := map[string]int{"db": 1, "go": 2}
+	if len(tags) != len(want) {
+		t.Fatalf("ListTags() = %v, want %v", tags, want)
+	}
+	for _, tag := range tags {
+		if want[tag.Name] != tag.Count {
+			t.Errorf("tag %s count = %d, want %d", tag.Name, tag.Count, want[tag.Name])
+		}
+	}
+}
+
+func TestRenameAndMergeTags(t *testing.T) {
+	service, tmpDir := setupTestService(t)
+
+	a, _ := service.CreateNote("A", "", "")
+	b, _ := service.CreateNote("B", "", "")
+	service.AddTag(a.ID, "golang")
+	service.AddTag(b.ID, "golang")
+	service.AddTag(b.ID, "go")
+	service.DeleteNote(a.ID)
+
+	renamed, err := service.RenameTag("golang", "go")
+	if err != nil {
+		t.Fatalf("RenameTag() failed: %v", err)
+	}
+	if renamed != 2 {
+		t.Errorf("RenameTag() = %d, want 2 including the trashed note", renamed)
+	}
+
+	if tags := fileTags(t, tmpDir, b.FilePath); len(tags) != 1 || tags[0] != "go" {
+		t.Errorf("merged frontmatter tags = %v, want [go]", tags)
+	}
+	if tags := fileTags(t, tmpDir, trashPath(a.ID)); len(tags) != 1 || tags[0] != "go" {
+		t.Errorf("trashed frontmatter tags = %v, want [go]", tags)
+	}
+
+	if _, err := service.RenameTag("golang", "go"); err == nil {
+		t.Error("Expected error renaming a tag that no longer exists")
+	}
+
+	// A case-only rename changes the stored spelling
+	if _, err := service.RenameTag("go", "Go"); err != nil {
+		t.Fatalf("RenameTag() case change failed: %v", err)
+	}
+	tags, _ := service.ListTags()
+	if len(tags) != 1 || tags[0].Name != "Go" {
+		t.Errorf("ListTags() = %v, want [Go]", tags)
+	}
+
+	service.AddTag(b.ID, "x")
+	service.AddTag(b.ID, "y")
+	if _, err := service.MergeTags([]string{"x", "y"}, "z"); err != nil {
+		t.Fatalf("MergeTags() failed: %v", err)
+	}
+	got, _ := service.GetNote(b.ID)
+	if len(got.Tags) != 2 || got.Tags[0] != "Go" || got.Tags[1] != "z" {
+		t.Errorf("Tags after merge = %v, want [Go z]", got.Tags)
+	}
+}
+
+func TestTagEditsKeepIndexFresh(t *testing.T) {
+	service, tmpDir := setupTestService(t)
+
+	note, _ := service.CreateNote("A", "", "")
+
+	backdate(t, service, tmpDir, note)
+	if err := service.AddTag(note.ID, "go"); err != nil {
+		t.Fatalf("AddTag() failed: %v", err)
+	}
+	checkFresh(t, service, "AddTag")
+
+	backdate(t, service, tmpDir, note)
+	if _, err := service.RenameTag("go", "golang"); err != nil {
+		t.Fatalf("RenameTag() failed: %v", err)
+	}
+	checkFresh(t, service, "RenameTag")
+
+	backdate(t, service, tmpDir, note)
+	if err := service.RemoveTag(note.ID, "golang"); err != nil {
+		t.Fatalf("RemoveTag() failed: %v", err)
+	}
+	checkFresh(t, service, "RemoveTag")
+}
+
+func TestReindexRestoresTags(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	note, _ := service.CreateNote("Tagged", "", "")
+	service.AddTag(note.ID, "keep")
+
+	if _, err := service.db.Exec("DELETE FROM notes"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Reindex(); err != nil {
+		t.Fatalf("Reindex() failed: %v", err)
+	}
+
+	notes, _ := service.ListNotes("keep")
+	if len(notes) != 1 || notes[0].ID != note.ID {
+		t.Errorf("ListNotes(keep) after reindex = %v, want the tagged note", notes)
+	}
+}
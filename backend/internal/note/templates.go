@@ -0,0 +1,390 @@
+package note
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"fuknotion/backend/internal/models"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// TemplateDir is the workspace directory holding note templates. It is
+// hidden, so reindex and the watcher do not take templates for notes.
+const TemplateDir = ".templates"
+
+// Prompt is a value a template asks for when a note is created from it,
+// used in the template as {{name}}
+type Prompt struct {
+	Name     string `yaml:"name" json:"name"`
+	Label    string `yaml:"label,omitempty" json:"label,omitempty"`
+	Default  string `yaml:"default,omitempty" json:"default,omitempty"`
+	Required bool   `yaml:"required,omitempty" json:"required,omitempty"`
+}
+
+// TemplateFrontmatter is the YAML frontmatter of a template file
+type TemplateFrontmatter struct {
+	Name        string    `yaml:"name"`
+	Description string    `yaml:"description,omitempty"`
+	Title       string    `yaml:"title,omitempty"`     // title of new notes; may use variables
+	FolderID    string    `yaml:"folder_id,omitempty"` // folder new notes go to by default
+	Tags        []string  `yaml:"tags,omitempty"`
+	Prompts     []Prompt  `yaml:"prompts,omitempty"`
+	Created     time.Time `yaml:"created"`
+	Modified    time.Time `yaml:"modified"`
+}
+
+// Template is a markdown skeleton for new notes. Its ID is its file name.
+type Template struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Title       string    `json:"title,omitempty"`
+	FolderID    string    `json:"folderId,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Prompts     []Prompt  `json:"prompts,omitempty"`
+	Content     string    `json:"content"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// TemplateContext holds what a template's variables are filled in from
+type TemplateContext struct {
+	UserName  string
+	UserEmail string
+	Values    map[string]string // answers to the template's prompts
+	Now       time.Time
+}
+
+var (
+	// templateVarPattern matches {{name}} and {{name:format}}
+	templateVarPattern = regexp.MustCompile(`\{\{\s*([\w.-]+)\s*(?::([^{}]*))?\}\}`)
+	promptNamePattern  = regexp.MustCompile(`^[\w-]+$`)
+)
+
+// builtinVars are the variable names prompts cannot take
+var builtinVars = map[string]bool{"title": true, "date": true, "time": true, "datetime": true, "weekday": true}
+
+// templatePath is where the template with id is kept
+func templatePath(id string) string {
+	return filepath.Join(TemplateDir, id+".md")
+}
+
+func validTemplateID(id string) error {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return fmt.Errorf("invalid template id: %q", id)
+	}
+	return nil
+}
+
+// ListTemplates lists the workspace's templates by name. Markdown files
+// dropped into the template directory by hand are listed too, named after
+// the file if they have no frontmatter.
+func (s *Service) ListTemplates() ([]*Template, error) {
+	templates := []*Template{}
+	if !s.fs.FileExists(TemplateDir) {
+		return templates, nil
+	}
+
+	files, err := s.fs.ListFiles(TemplateDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		if !strings.EqualFold(filepath.Ext(file), ".md") {
+			continue
+		}
+		id := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		if validTemplateID(id) != nil {
+			continue
+		}
+		// A template with broken frontmatter is left out rather than hiding the rest
+		t, err := s.GetTemplate(id)
+		if err != nil {
+			fmt.Printf("Skipping template %s: %v\n", id, err)
+			continue
+		}
+		templates = append(templates, t)
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return strings.ToLower(templates[i].Name) < strings.ToLower(templates[j].Name)
+	})
+	return templates, nil
+}
+
+// GetTemplate retrieves a template by ID
+func (s *Service) GetTemplate(id string) (*Template, error) {
+	if err := validTemplateID(id); err != nil {
+		return nil, err
+	}
+
+	data, err := s.fs.ReadFile(templatePath(id))
+	if err != nil {
+		return nil, fmt.Errorf("template not found: %s", id)
+	}
+
+	t, err := parseTemplate(id, data)
+	if err != nil {
+		return nil, err
+	}
+	if t.UpdatedAt.IsZero() {
+		if info, err := s.fs.Stat(templatePath(id)); err == nil {
+			t.UpdatedAt = info.ModTime()
+		}
+	}
+	return t, nil
+}
+
+// CreateTemplate saves a new template, ignoring any ID it carries
+func (s *Service) CreateTemplate(t *Template) (*Template, error) {
+	created := *t
+	created.ID = uuid.New().String()
+	created.CreatedAt = time.Now()
+	created.UpdatedAt = created.CreatedAt
+
+	if err := s.writeTemplate(&created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdateTemplate replaces an existing template
+func (s *Service) UpdateTemplate(t *Template) error {
+	existing, err := s.GetTemplate(t.ID)
+	if err != nil {
+		return err
+	}
+
+	updated := *t
+	updated.CreatedAt = existing.CreatedAt
+	updated.UpdatedAt = time.Now()
+	return s.writeTemplate(&updated)
+}
+
+// DeleteTemplate deletes a template. Notes created from it are unaffected.
+func (s *Service) DeleteTemplate(id string) error {
+	if _, err := s.GetTemplate(id); err != nil {
+		return err
+	}
+	return s.fs.DeleteFile(templatePath(id))
+}
+
+func (s *Service) writeTemplate(t *Template) error {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return fmt.Errorf("template name is required")
+	}
+	if err := s.checkFolder(t.FolderID); err != nil {
+		return err
+	}
+	t.Tags = normalizeTags(t.Tags)
+
+	seen := make(map[string]bool)
+	for _, p := range t.Prompts {
+		if !promptNamePattern.MatchString(p.Name) || builtinVars[p.Name] {
+			return fmt.Errorf("invalid prompt name: %q", p.Name)
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("duplicate prompt: %s", p.Name)
+		}
+		seen[p.Name] = true
+	}
+
+	data, err := serializeTemplate(t)
+	if err != nil {
+		return err
+	}
+	if err := s.fs.WriteFile(templatePath(t.ID), data); err != nil {
+		return fmt.Errorf("failed to write template: %w", err)
+	}
+	return nil
+}
+
+// CreateNoteFromTemplate creates a note from a template, filling in its
+// variables. An empty title uses the template's title, or failing that its
+// name; an empty folderID uses the template's folder.
+//
+// Variables are {{title}}, {{date}}, {{time}}, {{datetime}}, {{weekday}},
+// {{user.name}}, {{user.email}} and the template's prompts by name. {{date}}
+// and {{time}} take a format such as {{date:YYYY-MM-DD}}. Unknown variables
+// are left as they are.
+func (s *Service) CreateNoteFromTemplate(templateID, title, folderID string, ctx TemplateContext) (*models.Note, error) {
+	t, err := s.GetTemplate(templateID)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Now.IsZero() {
+		ctx.Now = time.Now()
+	}
+
+	vars := map[string]string{
+		"user.name":  ctx.UserName,
+		"user.email": ctx.UserEmail,
+	}
+	for _, p := range t.Prompts {
+		value, ok := ctx.Values[p.Name]
+		if !ok || value == "" {
+			value = p.Default
+		}
+		if value == "" && p.Required {
+			label := p.Label
+			if label == "" {
+				label = p.Name
+			}
+			return nil, fmt.Errorf("a value for %q is required", label)
+		}
+		vars[p.Name] = value
+	}
+
+	title = strings.TrimSpace(title)
+	if title == "" {
+		title = strings.TrimSpace(expandTemplate(t.Title, vars, ctx.Now))
+	}
+	if title == "" {
+		title = t.Name
+	}
+	vars["title"] = title
+
+	// The template's folder may have been deleted since; fall back to the top level
+	if folderID == "" && s.checkFolder(t.FolderID) == nil {
+		folderID = t.FolderID
+	}
+
+	return s.createNote(title, expandTemplate(t.Content, vars, ctx.Now), folderID, t.Tags)
+}
+
+// expandTemplate replaces the variables in text
+func expandTemplate(text string, vars map[string]string, now time.Time) string {
+	return templateVarPattern.ReplaceAllStringFunc(text, func(m string) string {
+		sub := templateVarPattern.FindStringSubmatch(m)
+		name, format := sub[1], strings.TrimSpace(sub[2])
+
+		switch name {
+		case "date":
+			if format == "" {
+				format = "YYYY-MM-DD"
+			}
+			return now.Format(goLayout(format))
+		case "time":
+			if format == "" {
+				format = "HH:mm"
+			}
+			return now.Format(goLayout(format))
+		case "datetime":
+			return now.Format("2006-01-02 15:04")
+		case "weekday":
+			return now.Weekday().String()
+		}
+
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return m
+	})
+}
+
+// momentTokens maps the date tokens common in note apps to Go layout
+// elements, longest first so YYYY is not read as two YYs
+var momentTokens = []struct{ token, layout string }{
+	{"YYYY", "2006"}, {"YY", "06"},
+	{"MMMM", "January"}, {"MMM", "Jan"}, {"MM", "01"}, {"M", "1"},
+	{"dddd", "Monday"}, {"ddd", "Mon"},
+	{"DD", "02"}, {"D", "2"},
+	{"HH", "15"}, {"hh", "03"}, {"h", "3"},
+	{"mm", "04"}, {"ss", "05"}, {"A", "PM"}, {"a", "pm"},
+}
+
+// goLayout converts a format such as "YYYY-MM-DD HH:mm" to a Go time layout.
+// Text in [brackets] is kept literally.
+func goLayout(format string) string {
+	var b strings.Builder
+	for i := 0; i < len(format); {
+		if format[i] == '[' {
+			if end := strings.IndexByte(format[i:], ']'); end > 0 {
+				b.WriteString(format[i+1 : i+end])
+				i += end + 1
+				continue
+			}
+		}
+
+		matched := false
+		for _, t := range momentTokens {
+			if strings.HasPrefix(format[i:], t.token) {
+				b.WriteString(t.layout)
+				i += len(t.token)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteByte(format[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+// parseTemplate reads a template file; one without frontmatter is all content
+func parseTemplate(id string, data []byte) (*Template, error) {
+	raw := strings.ReplaceAll(string(data), "\r\n", "\n")
+	t := &Template{ID: id, Name: id, Content: raw}
+
+	if !strings.HasPrefix(raw, "---\n") {
+		return t, nil
+	}
+	parts := strings.SplitN(raw[4:], "\n---\n", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid frontmatter in template %s", id)
+	}
+
+	var fm TemplateFrontmatter
+	if err := yaml.Unmarshal([]byte(parts[0]), &fm); err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", id, err)
+	}
+
+	if fm.Name != "" {
+		t.Name = fm.Name
+	}
+	t.Description = fm.Description
+	t.Title = fm.Title
+	t.FolderID = fm.FolderID
+	t.Tags = fm.Tags
+	t.Prompts = fm.Prompts
+	t.CreatedAt = fm.Created
+	t.UpdatedAt = fm.Modified
+	t.Content = strings.TrimPrefix(parts[1], "\n")
+	return t, nil
+}
+
+func serializeTemplate(t *Template) ([]byte, error) {
+	fm := TemplateFrontmatter{
+		Name:        t.Name,
+		Description: t.Description,
+		Title:       t.Title,
+		FolderID:    t.FolderID,
+		Tags:        t.Tags,
+		Prompts:     t.Prompts,
+		Created:     t.CreatedAt,
+		Modified:    t.UpdatedAt,
+	}
+
+	yamlData, err := yaml.Marshal(&fm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	buf.Write(yamlData)
+	buf.WriteString("---\n")
+	buf.WriteString(t.Content)
+	return buf.Bytes(), nil
+}
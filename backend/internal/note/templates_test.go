@@ -0,0 +1,152 @@
+package note
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTemplateCRUD(t *testing.T) {
+	service, tmpDir := setupTestService(t)
+
+	if templates, err := service.ListTemplates(); err != nil || len(templates) != 0 {
+		t.Fatalf("ListTemplates() = %v, %v, want none", templates, err)
+	}
+
+	created, err := service.CreateTemplate(&Template{
+		Name:    "Meeting",
+		Title:   "Meeting {{date}}",
+		Tags:    []string{"#meeting"},
+		Prompts: []Prompt{{Name: "topic", Required: true}},
+		Content: "# {{topic}}\n",
+	})
+	if err != nil {
+		t.Fatalf("CreateTemplate() failed: %v", err)
+	}
+
+	got, err := service.GetTemplate(created.ID)
+	if err != nil {
+		t.Fatalf("GetTemplate() failed: %v", err)
+	}
+	if got.Name != "Meeting" || got.Content != "# {{topic}}\n" || len(got.Prompts) != 1 || got.Tags[0] != "meeting" {
+		t.Errorf("GetTemplate() = %+v", got)
+	}
+
+	got.Name = "Standup"
+	if err := service.UpdateTemplate(got); err != nil {
+		t.Fatalf("UpdateTemplate() failed: %v", err)
+	}
+
+	// Hand-written templates without frontmatter are listed under their file name
+	os.WriteFile(filepath.Join(tmpDir, TemplateDir, "Daily.md"), []byte("Plain"), 0600)
+
+	templates, err := service.ListTemplates()
+	if err != nil {
+		t.Fatalf("ListTemplates() failed: %v", err)
+	}
+	if len(templates) != 2 || templates[0].Name != "Daily" || templates[1].Name != "Standup" {
+		t.Errorf("ListTemplates() = %+v", templates)
+	}
+
+	if err := service.DeleteTemplate(created.ID); err != nil {
+		t.Fatalf("DeleteTemplate() failed: %v", err)
+	}
+	if _, err := service.GetTemplate(created.ID); err == nil {
+		t.Error("GetTemplate() found a deleted template")
+	}
+
+	for _, bad := range []*Template{
+		{Name: ""},
+		{Name: "Bad prompt", Prompts: []Prompt{{Name: "date"}}},
+		{Name: "Dup prompt", Prompts: []Prompt{{Name: "a"}, {Name: "a"}}},
+		{Name: "Bad folder", FolderID: "missing"},
+	} {
+		if _, err := service.CreateTemplate(bad); err == nil {
+			t.Errorf("CreateTemplate(%q) succeeded", bad.Name)
+		}
+	}
+	if _, err := service.GetTemplate("../notes/x"); err == nil {
+		t.Error("GetTemplate() accepted a path")
+	}
+}
+
+func TestCreateNoteFromTemplate(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	tmpl, err := service.CreateTemplate(&Template{
+		Name:  "Meeting",
+		Title: "{{topic}} {{date:YYYY-MM-DD}}",
+		Tags:  []string{"meeting"},
+		Prompts: []Prompt{
+			{Name: "topic", Label: "Topic", Required: true},
+			{Name: "place", Default: "Room 1"},
+		},
+		Content: "# {{title}}\nBy {{user.name}} on {{weekday}} in {{place}}\n{{unknown}}",
+	})
+	if err != nil {
+		t.Fatalf("CreateTemplate() failed: %v", err)
+	}
+
+	ctx := TemplateContext{
+		UserName: "Ada",
+		Values:   map[string]string{"topic": "Budget"},
+		Now:      time.Date(2024, 3, 5, 9, 30, 0, 0, time.UTC),
+	}
+
+	created, err := service.CreateNoteFromTemplate(tmpl.ID, "", "", ctx)
+	if err != nil {
+		t.Fatalf("CreateNoteFromTemplate() failed: %v", err)
+	}
+	if created.Title != "Budget 2024-03-05" {
+		t.Errorf("Title = %q", created.Title)
+	}
+	want := "# Budget 2024-03-05\nBy Ada on Tuesday in Room 1\n{{unknown}}"
+	if created.Content != want {
+		t.Errorf("Content = %q, want %q", created.Content, want)
+	}
+
+	got, err := service.GetNote(created.ID)
+	if err != nil {
+		t.Fatalf("GetNote() failed: %v", err)
+	}
+	if len(got.Tags) != 1 || got.Tags[0] != "meeting" {
+		t.Errorf("Tags = %v, want [meeting]", got.Tags)
+	}
+
+	// An explicit title wins over the template's
+	named, err := service.CreateNoteFromTemplate(tmpl.ID, "Kickoff", "", ctx)
+	if err != nil {
+		t.Fatalf("CreateNoteFromTemplate() failed: %v", err)
+	}
+	if named.Title != "Kickoff" {
+		t.Errorf("Title = %q, want Kickoff", named.Title)
+	}
+
+	ctx.Values = nil
+	if _, err := service.CreateNoteFromTemplate(tmpl.ID, "", "", ctx); err == nil {
+		t.Error("CreateNoteFromTemplate() succeeded without a required value")
+	}
+}
+
+func TestGoLayout(t *testing.T) {
+	now := time.Date(2024, 3, 5, 14, 7, 9, 0, time.UTC)
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"YYYY-MM-DD", "2024-03-05"},
+		{"DD/MM/YY", "05/03/24"},
+		{"dddd, MMMM D", "Tuesday, March 5"},
+		{"HH:mm:ss", "14:07:09"},
+		{"h:mm A", "2:07 PM"},
+		{"[Week of] MMM D", "Week of Mar 5"},
+	}
+
+	for _, tt := range tests {
+		if got := now.Format(goLayout(tt.format)); got != tt.want {
+			t.Errorf("goLayout(%q) formats as %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
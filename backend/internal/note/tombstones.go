@@ -0,0 +1,48 @@
+package note
+
+import (
+	"fmt"
+	"time"
+
+	"fuknotion/backend/internal/database"
+)
+
+// Tombstone is a note deleted for good. Trashed notes need none: their rows
+// remain until purged.
+type Tombstone struct {
+	NoteID    string    `json:"noteId"`
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+// recordTombstone remembers that the note with id is about to be removed,
+// dated by when it was trashed if it was
+func recordTombstone(q database.Executor, id string) error {
+	query := `
+		INSERT INTO note_tombstones (note_id, deleted_at)
+		SELECT id, COALESCE(deleted_at, ?) FROM notes WHERE id = ?
+		ON CONFLICT(note_id) DO UPDATE SET deleted_at = excluded.deleted_at
+	`
+	if _, err := q.Exec(query, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to record deleted note: %w", err)
+	}
+	return nil
+}
+
+// ListTombstones lists the notes deleted for good
+func (s *Service) ListTombstones() ([]*Tombstone, error) {
+	rows, err := s.db.Query(`SELECT note_id, deleted_at FROM note_tombstones ORDER BY note_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deleted notes: %w", err)
+	}
+	defer rows.Close()
+
+	var tombstones []*Tombstone
+	for rows.Next() {
+		var t Tombstone
+		if err := rows.Scan(&t.NoteID, &t.DeletedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan deleted note: %w", err)
+		}
+		tombstones = append(tombstones, &t)
+	}
+	return tombstones, rows.Err()
+}
@@ -0,0 +1,59 @@
+package note
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTombstones(t *testing.T) {
+	service, tmpDir := setupTestService(t)
+
+	purged, _ := service.CreateNote("Purged", "", "")
+	removed, _ := service.CreateNote("Removed outside", "", "")
+	service.CreateNote("Kept", "", "")
+
+	service.DeleteNote(purged.ID)
+	trash, _ := service.ListTrash()
+	if err := service.DeleteNotePermanently(purged.ID); err != nil {
+		t.Fatalf("DeleteNotePermanently() failed: %v", err)
+	}
+
+	os.Remove(filepath.Join(tmpDir, removed.FilePath))
+	if _, err := service.SyncFile(removed.FilePath); err != nil {
+		t.Fatalf("SyncFile() failed: %v", err)
+	}
+
+	tombstones, err := service.ListTombstones()
+	if err != nil {
+		t.Fatalf("ListTombstones() failed: %v", err)
+	}
+	byID := make(map[string]*Tombstone)
+	for _, ts := range tombstones {
+		byID[ts.NoteID] = ts
+	}
+	if len(byID) != 1 || byID[purged.ID] == nil {
+		t.Fatalf("ListTombstones() = %+v, want the purged note", tombstones)
+	}
+	// A purged note counts as deleted from when it was trashed
+	if !byID[purged.ID].DeletedAt.Equal(*trash[0].DeletedAt) {
+		t.Errorf("DeletedAt = %v, want %v", byID[purged.ID].DeletedAt, *trash[0].DeletedAt)
+	}
+
+	// A note removed outside the app is only trashed
+	if trash, _ := service.ListTrash(); len(trash) != 1 || trash[0].ID != removed.ID {
+		t.Errorf("ListTrash() = %+v, want the note removed outside", trash)
+	}
+
+	// A note that comes back is no longer deleted
+	if _, err := service.ApplyFile(removed.FilePath, []byte(
+		"---\nid: "+removed.ID+"\ntitle: Removed outside\n---\nBack again")); err != nil {
+		t.Fatalf("ApplyFile() failed: %v", err)
+	}
+	if tombstones, _ := service.ListTombstones(); len(tombstones) != 1 || tombstones[0].NoteID != purged.ID {
+		t.Errorf("ListTombstones() = %+v after the note came back", tombstones)
+	}
+	if trash, _ := service.ListTrash(); len(trash) != 0 {
+		t.Errorf("ListTrash() = %+v after the note came back", trash)
+	}
+}
@@ -0,0 +1,313 @@
+package note
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fuknotion/backend/internal/models"
+)
+
+// TrashDir is the workspace directory holding the files of trashed notes.
+// It is hidden, so the watcher and reindex skip it as live content.
+const TrashDir = ".trash"
+
+// trashPath returns where a trashed note's file is kept
+func trashPath(id string) string {
+	return filepath.Join(TrashDir, id+".md")
+}
+
+// ListTrash lists trashed notes, most recently deleted first
+func (s *Service) ListTrash() ([]*models.Note, error) {
+	query := `
+		SELECT id, title, folder_id, file_path, is_favorite, created_at, updated_at, deleted_at
+		FROM notes WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC
+	`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trash: %w", err)
+	}
+	defer rows.Close()
+
+	var notes []*models.Note
+	for rows.Next() {
+		var note models.Note
+		var folderID *string
+		var deletedAt time.Time
+
+		err := rows.Scan(
+			&note.ID,
+			&note.Title,
+			&folderID,
+			&note.FilePath,
+			&note.IsFavorite,
+			&note.CreatedAt,
+			&note.UpdatedAt,
+			&deletedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trashed note: %w", err)
+		}
+
+		if folderID != nil {
+			note.FolderID = *folderID
+		}
+		note.DeletedAt = &deletedAt
+
+		notes = append(notes, &note)
+	}
+
+	return notes, rows.Err()
+}
+
+// RestoreNote moves a trashed note back to where it was deleted from. If its
+// folder no longer exists the note is restored to the workspace root.
+func (s *Service) RestoreNote(id string) (*models.Note, error) {
+	var filePath string
+	var trashedFrom, folderID *string
+
+	query := `SELECT file_path, trashed_from, folder_id FROM notes WHERE id = ? AND deleted_at IS NOT NULL`
+	err := s.db.QueryRow(query, id).Scan(&filePath, &trashedFrom, &folderID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("note not in trash: %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trashed note: %w", err)
+	}
+
+	// Something else may have taken the original path in the meantime
+	target := filepath.Join("notes", id+".md")
+	if trashedFrom != nil && *trashedFrom != "" && !s.fs.FileExists(*trashedFrom) {
+		target = *trashedFrom
+	}
+	if s.fs.FileExists(target) {
+		return nil, fmt.Errorf("cannot restore note: %s already exists", target)
+	}
+
+	fm, content, err := s.readNoteFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// The folder is cleared by the foreign key if it was deleted meanwhile
+	currentFolder := ""
+	if folderID != nil {
+		currentFolder = *folderID
+	}
+
+	// Notes trashed by older builds may still carry their pin. A rewritten
+	// file is newer than the row, so the row's update time follows it.
+	undoRewrite := func() {}
+	var rewritten *time.Time
+	if fm.FolderID != currentFolder || fm.Pinned != 0 {
+		now := time.Now()
+		rewritten = &now
+		fm.FolderID = currentFolder
+		fm.Pinned = 0
+		fm.Modified = now
+		markdown, err := SerializeNote(fm, content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize note: %w", err)
+		}
+		if undoRewrite, err = s.replaceNoteFile(filePath, []byte(markdown)); err != nil {
+			return nil, err
+		}
+	}
+
+	undoMove, err := s.moveNoteFile(filePath, target)
+	if err != nil {
+		undoRewrite()
+		return nil, fmt.Errorf("failed to restore note file: %w", err)
+	}
+
+	query = `
+		UPDATE notes SET deleted_at = NULL, trashed_from = NULL, file_path = ?, pinned = 0,
+			updated_at = COALESCE(?, updated_at)
+		WHERE id = ?
+	`
+	if _, err := s.db.Exec(query, target, rewritten, id); err != nil {
+		undoMove()
+		undoRewrite()
+		return nil, fmt.Errorf("failed to restore note: %w", err)
+	}
+	s.titles.invalidate()
+
+	return s.GetNote(id)
+}
+
+// DeleteNotePermanently removes a trashed note's file and row for good
+func (s *Service) DeleteNotePermanently(id string) error {
+	var filePath string
+	query := `SELECT file_path FROM notes WHERE id = ? AND deleted_at IS NOT NULL`
+	err := s.db.QueryRow(query, id).Scan(&filePath)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("note not in trash: %s", id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get trashed note: %w", err)
+	}
+
+	return s.purge(id, filePath)
+}
+
+// EmptyTrash permanently deletes every trashed note and returns how many were removed
+func (s *Service) EmptyTrash() (int, error) {
+	return s.purgeWhere(`deleted_at IS NOT NULL`)
+}
+
+// PurgeTrash permanently deletes notes that have been in the trash longer
+// than retention and returns how many were removed
+func (s *Service) PurgeTrash(retention time.Duration) (int, error) {
+	return s.purgeWhere(`deleted_at IS NOT NULL AND deleted_at < ?`, time.Now().Add(-retention))
+}
+
+// purgeWhere permanently deletes the trashed notes matching a condition
+func (s *Service) purgeWhere(condition string, args ...interface{}) (int, error) {
+	rows, err := s.db.Query(`SELECT id, file_path FROM notes WHERE `+condition, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list trashed notes: %w", err)
+	}
+
+	type trashed struct{ id, path string }
+	var notes []trashed
+	for rows.Next() {
+		var t trashed
+		if err := rows.Scan(&t.id, &t.path); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan trashed note: %w", err)
+		}
+		notes = append(notes, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to list trashed notes: %w", err)
+	}
+
+	for i, t := range notes {
+		if err := s.purge(t.id, t.path); err != nil {
+			return i, err
+		}
+	}
+
+	return len(notes), nil
+}
+
+// purge deletes a trashed note's row, then its file (if still present). The
+// row goes first: if removing the file then fails, the note is not lost, as
+// reindexTrash puts the orphaned file back in the trash.
+func (s *Service) purge(id, filePath string) error {
+	err := s.db.Transaction(func(tx *sql.Tx) error {
+		if err := recordTombstone(tx, id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM notes WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete note: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.fs.FileExists(filePath) {
+		if err := s.fs.DeleteFile(filePath); err != nil {
+			return fmt.Errorf("failed to delete note file: %w", err)
+		}
+	}
+	return nil
+}
+
+// reindexTrash reconciles trashed rows with the files in .trash/: files
+// without a row are indexed as trashed, rows without a file are dropped
+func (s *Service) reindexTrash(report *ReindexReport) error {
+	files, err := s.fs.WalkFiles(TrashDir)
+	if err != nil {
+		return err
+	}
+
+	onDisk := make(map[string]bool, len(files))
+	for _, path := range files {
+		if !strings.EqualFold(filepath.Ext(path), ".md") {
+			continue
+		}
+		onDisk[path] = true
+
+		fm, content, err := s.readNoteFile(path)
+		if err != nil {
+			report.Failed = append(report.Failed, ReindexFailure{Path: path, Error: err.Error()})
+			continue
+		}
+		if fm.ID == "" {
+			report.Failed = append(report.Failed, ReindexFailure{Path: path, Error: "frontmatter has no id"})
+			continue
+		}
+
+		var deletedAt *time.Time
+		err = s.db.QueryRow(`SELECT deleted_at FROM notes WHERE id = ?`, fm.ID).Scan(&deletedAt)
+		switch {
+		case err == sql.ErrNoRows:
+			// Lost row: index it as trashed, dated by the file's mtime
+			if _, err := s.ensureFolder(fm.FolderID); err != nil {
+				return err
+			}
+			trashedAt := time.Now()
+			if info, err := s.fs.Stat(path); err == nil {
+				trashedAt = info.ModTime()
+			}
+			err := s.db.Transaction(func(tx *sql.Tx) error {
+				if err := s.upsertIndex(tx, fm, content, path); err != nil {
+					return err
+				}
+				query := `UPDATE notes SET deleted_at = ?, trashed_from = ?, pinned = 0 WHERE id = ?`
+				if _, err := tx.Exec(query, trashedAt, filepath.Join("notes", fm.ID+".md"), fm.ID); err != nil {
+					return fmt.Errorf("failed to mark note as trashed: %w", err)
+				}
+				return nil
+			})
+			if err != nil {
+				report.Failed = append(report.Failed, ReindexFailure{Path: path, Error: err.Error()})
+				continue
+			}
+			report.Indexed++
+		case err != nil:
+			return fmt.Errorf("failed to look up trashed note: %w", err)
+		case deletedAt != nil:
+			if _, err := s.db.Exec(`UPDATE notes SET file_path = ? WHERE id = ?`, path, fm.ID); err != nil {
+				return fmt.Errorf("failed to update trashed note: %w", err)
+			}
+		}
+		// A live row with the same ID wins over a stale trash copy
+	}
+
+	rows, err := s.db.Query(`SELECT id, file_path FROM notes WHERE deleted_at IS NOT NULL`)
+	if err != nil {
+		return fmt.Errorf("failed to list trashed notes: %w", err)
+	}
+	var stale []string
+	for rows.Next() {
+		var id, path string
+		if err := rows.Scan(&id, &path); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan trashed note: %w", err)
+		}
+		if !onDisk[path] {
+			stale = append(stale, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range stale {
+		if err := recordTombstone(s.db, id); err != nil {
+			return err
+		}
+		if _, err := s.db.Exec(`DELETE FROM notes WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("failed to remove stale trashed note: %w", err)
+		}
+		report.Removed++
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package note
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeleteAndRestoreNote(t *testing.T) {
+	service, tmpDir := setupTestService(t)
+
+	note, err := service.CreateNote("Trash me", "searchable words", "")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	if err := service.DeleteNote(note.ID); err != nil {
+		t.Fatalf("DeleteNote() failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, trashPath(note.ID))); err != nil {
+		t.Errorf("trashed file not found in %s: %v", TrashDir, err)
+	}
+
+	notes, _ := service.ListNotes("")
+	if len(notes) != 0 {
+		t.Errorf("ListNotes() returned %d notes, want 0", len(notes))
+	}
+	results, _ := service.SearchNotes("searchable")
+	if len(results) != 0 {
+		t.Errorf("SearchNotes() returned %d trashed results, want 0", len(results))
+	}
+
+	trash, err := service.ListTrash()
+	if err != nil {
+		t.Fatalf("ListTrash() failed: %v", err)
+	}
+	if len(trash) != 1 || trash[0].ID != note.ID || trash[0].DeletedAt == nil {
+		t.Fatalf("ListTrash() = %v, want the deleted note", trash)
+	}
+
+	restored, err := service.RestoreNote(note.ID)
+	if err != nil {
+		t.Fatalf("RestoreNote() failed: %v", err)
+	}
+	if restored.FilePath != note.FilePath {
+		t.Errorf("FilePath = %v, want %v", restored.FilePath, note.FilePath)
+	}
+	if restored.Content != note.Content {
+		t.Errorf("Content = %v, want %v", restored.Content, note.Content)
+	}
+
+	results, _ = service.SearchNotes("searchable")
+	if len(results) != 1 {
+		t.Errorf("SearchNotes() after restore returned %d results, want 1", len(results))
+	}
+
+	if _, err := service.RestoreNote(note.ID); err == nil {
+		t.Error("Expected error restoring a note that is not in the trash")
+	}
+}
+
+func TestEmptyAndPurgeTrash(t *testing.T) {
+	service, tmpDir := setupTestService(t)
+
+	old, _ := service.CreateNote("Old", "content", "")
+	recent, _ := service.CreateNote("Recent", "content", "")
+	service.DeleteNote(old.ID)
+	service.DeleteNote(recent.ID)
+
+	// Backdate the first deletion beyond the retention window
+	if _, err := service.db.Exec(`UPDATE notes SET deleted_at = ? WHERE id = ?`,
+		time.Now().Add(-40*24*time.Hour), old.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := service.PurgeTrash(30 * 24 * time.Hour)
+	if err != nil {
+		t.Fatalf("PurgeTrash() failed: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("PurgeTrash() = %d, want 1", purged)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, trashPath(old.ID))); !os.IsNotExist(err) {
+		t.Error("purged note file still exists")
+	}
+
+	emptied, err := service.EmptyTrash()
+	if err != nil {
+		t.Fatalf("EmptyTrash() failed: %v", err)
+	}
+	if emptied != 1 {
+		t.Errorf("EmptyTrash() = %d, want 1", emptied)
+	}
+
+	trash, _ := service.ListTrash()
+	if len(trash) != 0 {
+		t.Errorf("ListTrash() returned %d notes after emptying, want 0", len(trash))
+	}
+}
+
+func TestReindexKeepsTrash(t *testing.T) {
+	service, _ := setupTestService(t)
+
+	note, _ := service.CreateNote("Trashed", "content", "")
+	service.DeleteNote(note.ID)
+
+	drift, err := service.NeedsReindex()
+	if err != nil {
+		t.Fatalf("NeedsReindex() failed: %v", err)
+	}
+	if drift {
+		t.Error("NeedsReindex() = true with a trashed note, want false")
+	}
+
+	// Lose the index entirely and rebuild it from disk
+	if _, err := service.db.Exec("DELETE FROM notes"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Reindex(); err != nil {
+		t.Fatalf("Reindex() failed: %v", err)
+	}
+
+	trash, _ := service.ListTrash()
+	if len(trash) != 1 || trash[0].ID != note.ID {
+		t.Errorf("ListTrash() after reindex = %v, want the trashed note", trash)
+	}
+
+	if _, err := service.RestoreNote(note.ID); err != nil {
+		t.Errorf("RestoreNote() after reindex failed: %v", err)
+	}
+}
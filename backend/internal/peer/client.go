@@ -0,0 +1,542 @@
+package peer
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	gosync "sync"
+	"time"
+
+	"fuknotion/backend/internal/attachment"
+	"fuknotion/backend/internal/note"
+)
+
+// Client pairs with devices and syncs with paired ones
+type Client struct {
+	identity *Identity
+	peers    *Store
+	now      func() time.Time
+}
+
+// NewClient creates a client that presents identity to the devices it talks to
+func NewClient(identity *Identity, peers *Store) *Client {
+	return &Client{identity: identity, peers: peers, now: time.Now}
+}
+
+// remote is a connection to one device
+type remote struct {
+	client    *http.Client
+	base      string
+	workspace string // the workspace we sync, sent with every request
+}
+
+// remoteError marks an error reaching the device. It ends the sync, where
+// any other error only skips a note.
+type remoteError struct{ err error }
+
+func (e *remoteError) Error() string { return e.err.Error() }
+func (e *remoteError) Unwrap() error { return e.err }
+
+// connect prepares requests to the device at address. With a fingerprint
+// the device must present that certificate; without one, as when pairing,
+// whatever it presents first is recorded in seen and must not change.
+func (c *Client) connect(address, fingerprint, workspace string) (*remote, *string) {
+	var mu gosync.Mutex
+	seen := new(string)
+	config := &tls.Config{
+		Certificates: []tls.Certificate{c.identity.certificate},
+		MinVersion:   tls.VersionTLS13,
+		// Devices sign their own certificates; the fingerprint check below
+		// stands in for the usual verification
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			fp, err := peerFingerprint(&state)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			want := fingerprint
+			if want == "" {
+				if *seen == "" {
+					*seen = fp
+				}
+				want = *seen
+			}
+			if fp != want {
+				return fmt.Errorf("device presented an unexpected certificate")
+			}
+			return nil
+		},
+	}
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: config, ForceAttemptHTTP2: true},
+		Timeout:   time.Minute,
+	}
+	return &remote{client: client, base: "https://" + address, workspace: workspace}, seen
+}
+
+// Pair pairs with the device at address, which is showing code. Both
+// devices remember each other's certificate from then on.
+func (c *Client) Pair(ctx context.Context, address, code string) (*Peer, error) {
+	rc, serverFP := c.connect(address, "", "")
+
+	exchange, err := newSPAKE(code, true)
+	if err != nil {
+		return nil, err
+	}
+	var start pairStart
+	err = rc.call(ctx, http.MethodPost, "/pair/start", pairStart{Name: c.identity.Name, Message: exchange.Message()}, &start)
+	if err != nil {
+		return nil, err
+	}
+
+	clientMAC, serverMAC, err := exchange.confirm(start.Message, c.identity.Fingerprint, *serverFP)
+	if err != nil {
+		return nil, err
+	}
+	var finish pairFinish
+	if err := rc.call(ctx, http.MethodPost, "/pair/finish", pairFinish{MAC: clientMAC}, &finish); err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(finish.MAC, serverMAC) {
+		return nil, fmt.Errorf("device could not prove it knows the pairing code")
+	}
+
+	p := &Peer{
+		ID:          deviceID(*serverFP),
+		Name:        start.Name,
+		Fingerprint: *serverFP,
+		Address:     address,
+		PairedAt:    c.now(),
+	}
+	if err := c.peers.Add(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// syncRun is the state of one sync with a device
+type syncRun struct {
+	ctx     context.Context
+	remote  *remote
+	replica *Replica
+	peerID  string
+	report  *Report
+	agreed  []Entry // notes both sides now hold alike
+	label   string  // for conflict copies
+}
+
+// Sync exchanges changes with a paired device at address. Each note is
+// compared as it is here, on the device and as of the last sync: a side that
+// did not change it takes the other side's version, and deleting a note
+// counts as changing it. Edits made to a note on both sides are merged; if
+// they clash, the newer version stays and the older one is saved as a
+// conflict copy.
+//
+// It returns what was done so far along with an error if the device could
+// not be reached part way through; the next sync carries on from there.
+func (c *Client) Sync(ctx context.Context, p *Peer, address string, replica *Replica) (*Report, error) {
+	rc, _ := c.connect(address, p.Fingerprint, replica.WorkspaceID())
+
+	var theirs Manifest
+	if err := rc.call(ctx, http.MethodGet, "/manifest", nil, &theirs); err != nil {
+		return nil, err
+	}
+	if err := replica.Link(p.ID, theirs.WorkspaceID); err != nil {
+		return nil, err
+	}
+	ours, err := replica.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	baseline, err := replica.Baseline(p.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &syncRun{
+		ctx:     ctx,
+		remote:  rc,
+		replica: replica,
+		peerID:  p.ID,
+		report:  &Report{},
+		label:   "conflict copy " + c.now().Format("2006-01-02 15:04"),
+	}
+	// Attachments go first so notes never arrive before what they embed
+	if err := s.attachments(ours.Attachments, theirs.Attachments); err != nil {
+		return s.report, err
+	}
+
+	local, remote := entries(ours.Notes), entries(theirs.Notes)
+	ids := make([]string, 0, len(local)+len(remote))
+	for id := range local {
+		ids = append(ids, id)
+	}
+	for id := range remote {
+		if local[id] == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var runErr error
+	for _, id := range ids {
+		base, known := baseline[id]
+		hash, err := s.note(id, local[id], remote[id], base, known)
+		if err != nil {
+			var re *remoteError
+			if errors.As(err, &re) {
+				runErr = err
+				break
+			}
+			s.report.Failed = append(s.report.Failed, Issue{ID: id, Error: err.Error()})
+			continue
+		}
+		if !known || base != hash {
+			s.agreed = append(s.agreed, Entry{ID: id, Hash: hash})
+		}
+	}
+
+	if err := replica.SetBaseline(p.ID, s.agreed); err != nil {
+		return s.report, err
+	}
+	if runErr != nil {
+		return s.report, runErr
+	}
+	if err := rc.call(ctx, http.MethodPost, "/state", s.agreed, nil); err != nil {
+		return s.report, err
+	}
+	if err := c.peers.SetAddress(p.ID, address); err != nil {
+		return s.report, err
+	}
+	if err := c.peers.MarkSynced(p.ID, c.now()); err != nil {
+		return s.report, err
+	}
+	return s.report, nil
+}
+
+// attachments sends the attachments only we have and fetches those only the
+// device has. Attachments are never deleted by a sync; garbage collection
+// on each side removes what no note uses.
+func (s *syncRun) attachments(ours, theirs []string) error {
+	have := make(map[string]bool, len(ours))
+	for _, hash := range ours {
+		have[hash] = true
+	}
+	for _, hash := range theirs {
+		if have[hash] {
+			delete(have, hash)
+			continue
+		}
+		name, data, err := s.remote.get(s.ctx, "/attachments/"+hash)
+		if err == nil {
+			err = s.replica.SaveAttachment(hash, name, data)
+		}
+		if err != nil {
+			var re *remoteError
+			if errors.As(err, &re) {
+				return err
+			}
+			s.report.Failed = append(s.report.Failed, Issue{ID: hash, Error: err.Error()})
+			continue
+		}
+		s.report.Received++
+	}
+
+	for _, hash := range ours {
+		if !have[hash] {
+			continue
+		}
+		name, data, err := s.replica.ReadAttachment(hash)
+		if err == nil {
+			err = s.remote.put(s.ctx, "/attachments/"+hash, name, data)
+		}
+		if err != nil {
+			var re *remoteError
+			if errors.As(err, &re) {
+				return err
+			}
+			s.report.Failed = append(s.report.Failed, Issue{ID: hash, Error: err.Error()})
+			continue
+		}
+		s.report.Sent++
+	}
+	return nil
+}
+
+// note brings one note in step on both sides and returns the hash both now
+// hold. l and r are the note here and on the device, nil if never seen; base
+// is its hash as of the last sync, if known.
+func (s *syncRun) note(id string, l, r *Entry, base string, known bool) (string, error) {
+	ours, theirs := l.hash(), r.hash()
+	switch {
+	case ours == theirs:
+		return ours, nil
+	case known && ours == base:
+		return s.take(id, theirs)
+	case known && theirs == base:
+		return s.give(id, ours)
+
+	// Changed on both sides, or never synced. A deletion loses only to a
+	// later edit.
+	case ours == "":
+		if l == nil || r.UpdatedAt.After(l.UpdatedAt) {
+			return s.take(id, theirs)
+		}
+		return s.give(id, ours)
+	case theirs == "":
+		if r == nil || l.UpdatedAt.After(r.UpdatedAt) {
+			return s.give(id, ours)
+		}
+		return s.take(id, theirs)
+	default:
+		return s.conflict(id, l, r)
+	}
+}
+
+// take applies the device's version of a note here
+func (s *syncRun) take(id, theirs string) (string, error) {
+	if theirs == "" {
+		change, err := s.replica.DeleteNote(id)
+		if err != nil {
+			return "", err
+		}
+		s.changed(change)
+		s.report.DeletedLocal++
+		return "", nil
+	}
+
+	_, data, err := s.remote.get(s.ctx, "/notes/"+url.PathEscape(id))
+	if err != nil {
+		return "", err
+	}
+	change, err := s.replica.ApplyNote(id, data)
+	if err != nil {
+		return "", err
+	}
+	s.changed(change)
+	s.report.Received++
+	return hashOf(data), nil
+}
+
+// give applies our version of a note on the device
+func (s *syncRun) give(id, ours string) (string, error) {
+	if ours == "" {
+		if err := s.remote.call(s.ctx, http.MethodDelete, "/notes/"+url.PathEscape(id), nil, nil); err != nil {
+			return "", err
+		}
+		s.report.DeletedRemote++
+		return "", nil
+	}
+	return s.send(id)
+}
+
+// send uploads a note that is not deleted here
+func (s *syncRun) send(id string) (string, error) {
+	data, err := s.replica.ReadNote(id)
+	if err != nil {
+		return "", err
+	}
+	if err := s.remote.put(s.ctx, "/notes/"+url.PathEscape(id), "", data); err != nil {
+		return "", err
+	}
+	s.report.Sent++
+	return hashOf(data), nil
+}
+
+// conflict settles a note edited on both sides. Edits that do not overlap
+// are merged from the version both held after the last sync. Where they
+// clash, the newer version wins on both sides and the older one becomes a
+// new note on both.
+func (s *syncRun) conflict(id string, l, r *Entry) (string, error) {
+	_, theirs, err := s.remote.get(s.ctx, "/notes/"+url.PathEscape(id))
+	if err != nil {
+		return "", err
+	}
+	ours, err := s.replica.ReadNote(id)
+	if err != nil {
+		return "", err
+	}
+	if bytes.Equal(ours, theirs) {
+		return hashOf(ours), nil
+	}
+	base, err := s.replica.Base(s.peerID, id)
+	if err != nil {
+		return "", err
+	}
+
+	// The newer version merges as ours, so it is the one kept if they clash
+	newer, older := ours, theirs
+	if r.UpdatedAt.After(l.UpdatedAt) {
+		newer, older = theirs, ours
+	}
+	result, err := s.replica.Reconcile(base, newer, older, s.label)
+	if err != nil {
+		return "", fmt.Errorf("failed to merge versions: %w", err)
+	}
+
+	if result.Copy != nil || len(result.Conflicts) > 0 {
+		conflict := Conflict{NoteID: id, Hunks: result.Conflicts}
+		if fm, _, err := note.ParseMarkdown(string(result.Data)); err == nil {
+			conflict.Title = fm.Title
+		}
+		if copied := result.Copy; copied != nil {
+			conflict.CopyID, conflict.Title = copied.ID, copied.Title
+			s.changed(&note.FileChange{NoteID: copied.ID, Title: copied.Title, Path: copied.FilePath, Kind: note.FileCreated})
+
+			hash, err := s.send(copied.ID)
+			if err != nil {
+				return "", err
+			}
+			s.agreed = append(s.agreed, Entry{ID: copied.ID, Hash: hash})
+		}
+		s.report.Conflicts = append(s.report.Conflicts, conflict)
+	}
+
+	if !bytes.Equal(result.Data, ours) {
+		change, err := s.replica.ApplyNote(id, result.Data)
+		if err != nil {
+			return "", err
+		}
+		s.changed(change)
+		s.report.Received++
+	}
+	if !bytes.Equal(result.Data, theirs) {
+		if err := s.remote.put(s.ctx, "/notes/"+url.PathEscape(id), "", result.Data); err != nil {
+			return "", err
+		}
+		s.report.Sent++
+	}
+	return hashOf(result.Data), nil
+}
+
+func (s *syncRun) changed(change *note.FileChange) {
+	if change != nil {
+		s.report.Changes = append(s.report.Changes, change)
+	}
+}
+
+func (e *Entry) hash() string {
+	if e == nil {
+		return ""
+	}
+	return e.Hash
+}
+
+func entries(list []Entry) map[string]*Entry {
+	m := make(map[string]*Entry, len(list))
+	for i := range list {
+		m[list[i].ID] = &list[i]
+	}
+	return m
+}
+
+// pairStart is the first pairing message each way
+type pairStart struct {
+	Name    string `json:"name"`
+	Message []byte `json:"message"`
+}
+
+// pairFinish proves to the other side that this side knows the code
+type pairFinish struct {
+	MAC []byte `json:"mac"`
+}
+
+// call sends in as JSON, if not nil, and decodes the response into out, if
+// not nil
+func (rc *remote) call(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	resp, err := rc.do(ctx, method, path, body, "application/json", "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return &remoteError{fmt.Errorf("failed to read response from device: %w", err)}
+	}
+	return nil
+}
+
+// get downloads a note or attachment, returning the name it was sent under
+func (rc *remote) get(ctx context.Context, path string) (string, []byte, error) {
+	resp, err := rc.do(ctx, http.MethodGet, path, nil, "", "")
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, attachment.MaxSize+1))
+	if err != nil {
+		return "", nil, &remoteError{fmt.Errorf("failed to download %s: %w", path, err)}
+	}
+	return resp.Header.Get(nameHeader), data, nil
+}
+
+// put uploads a note or attachment
+func (rc *remote) put(ctx context.Context, path, name string, data []byte) error {
+	resp, err := rc.do(ctx, http.MethodPut, path, bytes.NewReader(data), "application/octet-stream", name)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (rc *remote) do(ctx context.Context, method, path string, body io.Reader, contentType, name string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rc.base+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType != "" && body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if name != "" {
+		req.Header.Set(nameHeader, name)
+	}
+	if rc.workspace != "" {
+		req.Header.Set(workspaceHeader, rc.workspace)
+	}
+
+	resp, err := rc.client.Do(req)
+	if err != nil {
+		return nil, &remoteError{fmt.Errorf("failed to reach device: %w", err)}
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	switch resp.StatusCode {
+	case http.StatusForbidden:
+		if path == "/pair/start" || path == "/pair/finish" {
+			return nil, &remoteError{fmt.Errorf("pairing failed: %s", bytes.TrimSpace(msg))}
+		}
+		return nil, &remoteError{ErrNotPaired}
+	case http.StatusConflict:
+		return nil, &remoteError{ErrOtherWorkspace}
+	}
+	err = fmt.Errorf("device returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	if resp.StatusCode >= 500 {
+		return nil, &remoteError{err}
+	}
+	return nil, err
+}
@@ -0,0 +1,112 @@
+package peer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Identity is this device as peers know it: a self-signed certificate whose
+// fingerprint paired peers pin
+type Identity struct {
+	Name        string
+	Fingerprint string // hex SHA-256 of the certificate
+	certificate tls.Certificate
+}
+
+// NewIdentity creates a key and certificate for a device called name
+func NewIdentity(name string) (*Identity, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate device key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(20, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create device certificate: %w", err)
+	}
+
+	data, err := encodePEM(der, key)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIdentity(data)
+}
+
+// ParseIdentity loads an identity saved with PEM
+func ParseIdentity(data []byte) (*Identity, error) {
+	cert, err := tls.X509KeyPair(data, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load device identity: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to load device identity: %w", err)
+	}
+	cert.Leaf = leaf
+	return &Identity{Name: leaf.Subject.CommonName, Fingerprint: fingerprint(leaf.Raw), certificate: cert}, nil
+}
+
+// PEM encodes the identity, private key included, for safekeeping
+func (id *Identity) PEM() ([]byte, error) {
+	key, ok := id.certificate.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported device key")
+	}
+	return encodePEM(id.certificate.Certificate[0], key)
+}
+
+func encodePEM(certDER []byte, key *ecdsa.PrivateKey) ([]byte, error) {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode device key: %w", err)
+	}
+	return append(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})...,
+	), nil
+}
+
+// ID is the short form of the fingerprint peers are listed under
+func (id *Identity) ID() string {
+	return deviceID(id.Fingerprint)
+}
+
+func fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+func deviceID(fingerprint string) string {
+	return fingerprint[:16]
+}
+
+// peerFingerprint returns the fingerprint of the certificate the other end
+// of a connection presented
+func peerFingerprint(state *tls.ConnectionState) (string, error) {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return "", fmt.Errorf("peer presented no certificate")
+	}
+	return fingerprint(state.PeerCertificates[0].Raw), nil
+}
@@ -0,0 +1,263 @@
+package peer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// serviceName is the mDNS service devices advertise themselves under
+const serviceName = "_fuknotion._tcp.local."
+
+// mdnsTTL is how long, in seconds, answers may be cached
+const mdnsTTL = 120
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// Discovered is a device found on the local network
+type Discovered struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Address string `json:"address"` // host:port
+}
+
+// service is what a device advertises about itself
+type service struct {
+	ID   string
+	Name string
+	Port int
+}
+
+// Advertiser answers mDNS queries for devices so others can find this one
+type Advertiser struct {
+	conn    *net.UDPConn
+	service service
+	done    chan struct{}
+}
+
+// Advertise announces this device, listening for peers on port, until the
+// advertiser is closed
+func Advertise(identity *Identity, port int) (*Advertiser, error) {
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to join mDNS group: %w", err)
+	}
+	a := &Advertiser{
+		conn:    conn,
+		service: service{ID: identity.ID(), Name: identity.Name, Port: port},
+		done:    make(chan struct{}),
+	}
+	go a.serve()
+	return a, nil
+}
+
+// Close stops answering queries
+func (a *Advertiser) Close() error {
+	err := a.conn.Close()
+	<-a.done
+	return err
+}
+
+func (a *Advertiser) serve() {
+	defer close(a.done)
+
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := a.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		resp, err := answer(buf[:n], a.service, localIPs())
+		if err != nil || resp == nil {
+			continue
+		}
+		// Answer the asker directly; browsers query from their own port
+		if _, err := a.conn.WriteToUDP(resp, from); err != nil {
+			fmt.Printf("Failed to answer mDNS query: %v\n", err)
+		}
+	}
+}
+
+// Browse looks for devices on the local network for up to timeout
+func Browse(ctx context.Context, timeout time.Duration) ([]*Discovered, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mDNS socket: %w", err)
+	}
+	defer conn.Close()
+
+	query, err := browseQuery()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.WriteToUDP(query, mdnsGroup); err != nil {
+		return nil, fmt.Errorf("failed to send mDNS query: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	var found []*Discovered
+	seen := make(map[string]bool)
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			// The deadline passing ends the search
+			break
+		}
+		d := parseAnswer(buf[:n], from.IP)
+		if d != nil && !seen[d.ID] {
+			seen[d.ID] = true
+			found = append(found, d)
+		}
+	}
+	return found, ctx.Err()
+}
+
+// browseQuery asks for every device advertising the service
+func browseQuery() ([]byte, error) {
+	msg := dnsmessage.Message{
+		Questions: []dnsmessage.Question{{
+			Name:  dnsmessage.MustNewName(serviceName),
+			Type:  dnsmessage.TypePTR,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	data, err := msg.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build mDNS query: %w", err)
+	}
+	return data, nil
+}
+
+// answer returns the response to query describing svc, reachable at ips, or
+// nil if the query is not for the service
+func answer(query []byte, svc service, ips []net.IP) ([]byte, error) {
+	var q dnsmessage.Message
+	if err := q.Unpack(query); err != nil || q.Header.Response {
+		return nil, nil
+	}
+	var asked []dnsmessage.Question
+	for _, question := range q.Questions {
+		if (question.Type == dnsmessage.TypePTR || question.Type == dnsmessage.TypeALL) &&
+			strings.EqualFold(question.Name.String(), serviceName) {
+			asked = append(asked, question)
+		}
+	}
+	if len(asked) == 0 {
+		return nil, nil
+	}
+
+	service := dnsmessage.MustNewName(serviceName)
+	instance, err := dnsmessage.NewName(svc.ID + "." + serviceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build mDNS answer: %w", err)
+	}
+	host, err := dnsmessage.NewName(svc.ID + ".local.")
+	if err != nil {
+		return nil, fmt.Errorf("failed to build mDNS answer: %w", err)
+	}
+	header := func(name dnsmessage.Name) dnsmessage.ResourceHeader {
+		return dnsmessage.ResourceHeader{Name: name, Class: dnsmessage.ClassINET, TTL: mdnsTTL}
+	}
+
+	resp := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: q.Header.ID, Response: true, Authoritative: true},
+		Questions: asked,
+		Answers: []dnsmessage.Resource{
+			{Header: header(service), Body: &dnsmessage.PTRResource{PTR: instance}},
+		},
+		Additionals: []dnsmessage.Resource{
+			{Header: header(instance), Body: &dnsmessage.SRVResource{Target: host, Port: uint16(svc.Port)}},
+			{Header: header(instance), Body: &dnsmessage.TXTResource{TXT: []string{"id=" + svc.ID, "name=" + svc.Name}}},
+		},
+	}
+	for _, ip := range ips {
+		if v4 := ip.To4(); v4 != nil {
+			resp.Additionals = append(resp.Additionals, dnsmessage.Resource{
+				Header: header(host),
+				Body:   &dnsmessage.AResource{A: [4]byte(v4)},
+			})
+		}
+	}
+
+	data, err := resp.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build mDNS answer: %w", err)
+	}
+	return data, nil
+}
+
+// parseAnswer reads a device from a response to browseQuery sent from ip.
+// It returns nil if the response describes no device.
+func parseAnswer(data []byte, from net.IP) *Discovered {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(data); err != nil || !msg.Header.Response {
+		return nil
+	}
+
+	var instance string
+	for _, r := range msg.Answers {
+		if ptr, ok := r.Body.(*dnsmessage.PTRResource); ok && strings.EqualFold(r.Header.Name.String(), serviceName) {
+			instance = ptr.PTR.String()
+			break
+		}
+	}
+	if instance == "" {
+		return nil
+	}
+
+	d := &Discovered{}
+	var port uint16
+	for _, r := range append(msg.Answers, msg.Additionals...) {
+		if !strings.EqualFold(r.Header.Name.String(), instance) {
+			continue
+		}
+		switch body := r.Body.(type) {
+		case *dnsmessage.SRVResource:
+			port = body.Port
+		case *dnsmessage.TXTResource:
+			for _, txt := range body.TXT {
+				key, value, _ := strings.Cut(txt, "=")
+				switch key {
+				case "id":
+					d.ID = value
+				case "name":
+					d.Name = value
+				}
+			}
+		}
+	}
+	if d.ID == "" || port == 0 {
+		return nil
+	}
+	// The device answered from the address it is reachable at
+	d.Address = net.JoinHostPort(from.String(), strconv.Itoa(int(port)))
+	return d
+}
+
+// localIPs lists this machine's IPv4 addresses other than loopback
+func localIPs() []net.IP {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() && ipnet.IP.To4() != nil {
+			ips = append(ips, ipnet.IP)
+		}
+	}
+	return ips
+}
@@ -0,0 +1,33 @@
+package peer
+
+import (
+	"net"
+	"testing"
+)
+
+func TestMDNSAnswer(t *testing.T) {
+	query, err := browseQuery()
+	if err != nil {
+		t.Fatalf("browseQuery() failed: %v", err)
+	}
+
+	svc := service{ID: "0123456789abcdef", Name: "Ada's laptop", Port: DefaultPort}
+	resp, err := answer(query, svc, []net.IP{net.ParseIP("192.168.1.20")})
+	if err != nil || resp == nil {
+		t.Fatalf("answer() = %v, %v", resp, err)
+	}
+
+	got := parseAnswer(resp, net.ParseIP("192.168.1.20"))
+	want := Discovered{ID: svc.ID, Name: svc.Name, Address: "192.168.1.20:47231"}
+	if got == nil || *got != want {
+		t.Errorf("parseAnswer() = %+v, want %+v", got, want)
+	}
+
+	// Answers are not questions, and other services are not ours to answer
+	if resp, _ := answer(resp, svc, nil); resp != nil {
+		t.Error("answer() replied to a response")
+	}
+	if got := parseAnswer(query, net.ParseIP("192.168.1.20")); got != nil {
+		t.Errorf("parseAnswer(query) = %+v", got)
+	}
+}
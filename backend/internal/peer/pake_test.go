@@ -0,0 +1,155 @@
+package peer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// exchange runs both sides of an exchange and returns the MACs each computed
+func exchange(t *testing.T, clientCode, serverCode, clientSees, serverSees string) (client, server [2][]byte) {
+	t.Helper()
+
+	c, err := newSPAKE(clientCode, true)
+	if err != nil {
+		t.Fatalf("newSPAKE() failed: %v", err)
+	}
+	s, err := newSPAKE(serverCode, false)
+	if err != nil {
+		t.Fatalf("newSPAKE() failed: %v", err)
+	}
+
+	client[0], client[1], err = c.confirm(s.Message(), "client-fp", clientSees)
+	if err != nil {
+		t.Fatalf("confirm() failed: %v", err)
+	}
+	server[0], server[1], err = s.confirm(c.Message(), "client-fp", serverSees)
+	if err != nil {
+		t.Fatalf("confirm() failed: %v", err)
+	}
+	return client, server
+}
+
+func TestSPAKE(t *testing.T) {
+	client, server := exchange(t, "482913", "482913", "server-fp", "server-fp")
+	if !bytes.Equal(client[0], server[0]) || !bytes.Equal(client[1], server[1]) {
+		t.Error("same code and certificates give different MACs")
+	}
+	if bytes.Equal(client[0], client[1]) {
+		t.Error("client and server MACs are the same")
+	}
+
+	client, server = exchange(t, "482913", "482914", "server-fp", "server-fp")
+	if bytes.Equal(client[0], server[0]) {
+		t.Error("different codes give the same MAC")
+	}
+
+	// A device in the middle presents its own certificate to the client
+	client, server = exchange(t, "482913", "482913", "middle-fp", "server-fp")
+	if bytes.Equal(client[0], server[0]) {
+		t.Error("different certificates give the same MAC")
+	}
+}
+
+// Vectors computed independently from the construction in pake.go, with
+// x = 0x11.. and y = 0x22.. (32 bytes each) and the code 482913. Messages
+// are given as their SHA-256.
+const (
+	katClientMsg = "5d60cfa04a47ee7eb8c0465236cf1b43c81e118c91b3cb22b845fd6ca2a7a7d7"
+	katServerMsg = "07361608e8f7d40acd05fcad065f8d7871cecda88a0b9a3255689975d52723e2"
+	katClientMAC = "2bdaf90f96be46f603b3036f80b45bc3985817378f90a8eb82df739c94a66454"
+	katServerMAC = "5ec1ebeed8ed9ca811df265fa405150eb4099075923b552900fb89ed63b84e66"
+)
+
+func katSecrets() (x, y *big.Int) {
+	x = new(big.Int).SetBytes(bytes.Repeat([]byte{0x11}, 32))
+	y = new(big.Int).SetBytes(bytes.Repeat([]byte{0x22}, 32))
+	return x, y
+}
+
+func TestSPAKEKnownAnswers(t *testing.T) {
+	x, y := katSecrets()
+	c, s := spakeWith("482913", true, x), spakeWith("482913", false, y)
+
+	for name, got := range map[string][]byte{
+		katClientMsg: c.Message(),
+		katServerMsg: s.Message(),
+	} {
+		if sum := sha256.Sum256(got); hex.EncodeToString(sum[:]) != name {
+			t.Errorf("message hashes to %x, want %s", sum, name)
+		}
+	}
+
+	for _, side := range []*spake{c, s} {
+		other := s
+		if side == s {
+			other = c
+		}
+		clientMAC, serverMAC, err := side.confirm(other.Message(), "client-fp", "server-fp")
+		if err != nil {
+			t.Fatalf("confirm() failed: %v", err)
+		}
+		if got := hex.EncodeToString(clientMAC); got != katClientMAC {
+			t.Errorf("client MAC = %s, want %s", got, katClientMAC)
+		}
+		if got := hex.EncodeToString(serverMAC); got != katServerMAC {
+			t.Errorf("server MAC = %s, want %s", got, katServerMAC)
+		}
+	}
+}
+
+func TestSPAKESharedSecret(t *testing.T) {
+	x, y := katSecrets()
+	want := new(big.Int).Exp(pakeG, new(big.Int).Mul(x, y), pakeP)
+
+	c, s := spakeWith("482913", true, x), spakeWith("482913", false, y)
+	if got := c.shared(s.msg); got.Cmp(want) != 0 {
+		t.Error("client's shared secret is not g^(xy)")
+	}
+	if got := s.shared(c.msg); got.Cmp(want) != 0 {
+		t.Error("server's shared secret is not g^(xy)")
+	}
+
+	wrong := spakeWith("482914", false, y)
+	if got := c.shared(wrong.msg); got.Cmp(want) == 0 {
+		t.Error("shared secret does not depend on the code")
+	}
+}
+
+func TestSPAKERejectsInvalidMessages(t *testing.T) {
+	s, _ := newSPAKE("482913", false)
+	for name, msg := range map[string][]byte{
+		"short": {1, 2, 3},
+		"zero":  make([]byte, pakeSize),
+		"one":   append(make([]byte, pakeSize-1), 1),
+		"p-1":   pakeP.FillBytes(make([]byte, pakeSize)),
+	} {
+		if _, _, err := s.confirm(msg, "a", "b"); err == nil {
+			t.Errorf("confirm(%s) succeeded", name)
+		}
+	}
+}
+
+func TestPAKEGroup(t *testing.T) {
+	if !pakeP.ProbablyPrime(20) || !pakeQ.ProbablyPrime(20) {
+		t.Error("group prime is not a safe prime")
+	}
+	if len(pakeP.Bytes()) != pakeSize {
+		t.Errorf("prime is %d bytes, want %d", len(pakeP.Bytes()), pakeSize)
+	}
+
+	one := big.NewInt(1)
+	for name, v := range map[string]*big.Int{"g": pakeG, "M": pakeM, "N": pakeN} {
+		if v.Cmp(one) <= 0 || v.Cmp(pakeP) >= 0 {
+			t.Errorf("%s is out of range", name)
+		}
+		if new(big.Int).Exp(v, pakeQ, pakeP).Cmp(one) != 0 {
+			t.Errorf("%s is not in the subgroup of order q", name)
+		}
+	}
+	if pakeM.Cmp(pakeN) == 0 || pakeM.Cmp(pakeG) == 0 || pakeN.Cmp(pakeG) == 0 {
+		t.Error("g, M and N are not distinct")
+	}
+}
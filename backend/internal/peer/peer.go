@@ -0,0 +1,60 @@
+// Package peer syncs a workspace directly with another device on the local
+// network, with no cloud in between. Devices find each other over mDNS or by
+// address, pair once with a short code, and then talk HTTPS, each pinning
+// the certificate the other presented when paired.
+package peer
+
+import (
+	"errors"
+
+	"fuknotion/backend/internal/note"
+)
+
+// DefaultPort is where a device listens for its peers unless told otherwise
+const DefaultPort = 47231
+
+const (
+	// workspaceHeader carries the ID of the workspace a request syncs
+	workspaceHeader = "X-Fuknotion-Workspace"
+
+	// nameHeader carries the name of an attachment
+	nameHeader = "X-Fuknotion-Name"
+)
+
+var (
+	// ErrNotPaired is returned when a device presents a certificate no
+	// paired device has
+	ErrNotPaired = errors.New("device is not paired")
+
+	// ErrOtherWorkspace is returned when a device syncs a different
+	// workspace than the one it was first synced with
+	ErrOtherWorkspace = errors.New("device syncs a different workspace")
+)
+
+// Conflict is a note changed on both devices since they last synced in ways
+// that did not merge. Fields both changed keep the newer version's value; if
+// the content clashed, the newer version stays in place and the older one is
+// kept as a copy. Hunks compare the newer version (ours) with the older.
+type Conflict struct {
+	NoteID string               `json:"noteId"`
+	CopyID string               `json:"copyId,omitempty"` // the note holding the older version
+	Title  string               `json:"title"`
+	Hunks  []note.MergeConflict `json:"hunks,omitempty"`
+}
+
+// Issue is a note or attachment a sync could not exchange
+type Issue struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// Report summarizes a sync with a device
+type Report struct {
+	Sent          int                `json:"sent"`
+	Received      int                `json:"received"`
+	DeletedRemote int                `json:"deletedRemote"`
+	DeletedLocal  int                `json:"deletedLocal"`
+	Conflicts     []Conflict         `json:"conflicts"`
+	Changes       []*note.FileChange `json:"changes"` // notes the sync changed here
+	Failed        []Issue            `json:"failed"`
+}
@@ -0,0 +1,359 @@
+package peer
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"fuknotion/backend/internal/attachment"
+	"fuknotion/backend/internal/database"
+	"fuknotion/backend/internal/filesystem"
+	"fuknotion/backend/internal/models"
+	"fuknotion/backend/internal/note"
+)
+
+// device is one Fuknotion instance with a workspace open
+type device struct {
+	identity    *Identity
+	peers       *Store
+	notes       *note.Service
+	attachments *attachment.Store
+	replica     *Replica
+	client      *Client
+	server      *Server
+	address     string
+	changes     []*note.FileChange // applied by the server
+	dir         string             // of the open workspace
+}
+
+func newDevice(t *testing.T, name string) *device {
+	t.Helper()
+
+	identity, err := NewIdentity(name)
+	if err != nil {
+		t.Fatalf("NewIdentity() failed: %v", err)
+	}
+	userDB, err := database.InitUserDB(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to initialize user database: %v", err)
+	}
+	t.Cleanup(func() { userDB.Close() })
+
+	d := &device{identity: identity, peers: NewStore(userDB)}
+	d.open(t, name+" notes")
+
+	d.client = NewClient(identity, d.peers)
+	d.server = NewServer(identity, d.peers, func() (*Replica, func(), error) {
+		return d.replica, func() {}, nil
+	}, func(change *note.FileChange) {
+		d.changes = append(d.changes, change)
+	})
+	if err := d.server.Start("127.0.0.1:0"); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	t.Cleanup(func() { d.server.Close() })
+	d.address = "127.0.0.1:" + strconv.Itoa(d.server.Port())
+	return d
+}
+
+// open opens a new workspace on the device
+func (d *device) open(t *testing.T, name string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	d.dir = dir
+	db, err := database.InitWorkspaceDB(dir)
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	fs, err := filesystem.NewFileSystem(dir)
+	if err != nil {
+		t.Fatalf("Failed to initialize filesystem: %v", err)
+	}
+
+	d.notes = note.NewService(db, fs)
+	d.attachments = attachment.NewStore(db, fs)
+	ws := &models.Workspace{ID: name, Name: name}
+	d.replica = NewReplica(ws, db, fs, d.notes, d.attachments)
+}
+
+// pair pairs d with other, which shows the code
+func (d *device) pair(t *testing.T, other *device) *Peer {
+	t.Helper()
+
+	code, err := other.server.NewPairingCode()
+	if err != nil {
+		t.Fatalf("NewPairingCode() failed: %v", err)
+	}
+	p, err := d.client.Pair(context.Background(), other.address, code)
+	if err != nil {
+		t.Fatalf("Pair() failed: %v", err)
+	}
+	return p
+}
+
+func (d *device) sync(t *testing.T, p *Peer, other *device) *Report {
+	t.Helper()
+
+	report, err := d.client.Sync(context.Background(), p, other.address, d.replica)
+	if err != nil {
+		t.Fatalf("Sync() failed: %v", err)
+	}
+	if len(report.Failed) > 0 {
+		t.Fatalf("Sync() failed for %+v", report.Failed)
+	}
+	return report
+}
+
+func TestPairing(t *testing.T) {
+	laptop, desktop := newDevice(t, "Laptop"), newDevice(t, "Desktop")
+	ctx := context.Background()
+
+	if _, err := desktop.client.Pair(ctx, laptop.address, "123456"); err == nil {
+		t.Fatal("Pair() without a code shown succeeded")
+	}
+
+	code, _ := laptop.server.NewPairingCode()
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	if _, err := desktop.client.Pair(ctx, laptop.address, wrong); err == nil {
+		t.Fatal("Pair() with the wrong code succeeded")
+	}
+
+	p, err := desktop.client.Pair(ctx, laptop.address, code)
+	if err != nil {
+		t.Fatalf("Pair() failed: %v", err)
+	}
+	if p.Name != "Laptop" || p.Fingerprint != laptop.identity.Fingerprint || p.Address != laptop.address {
+		t.Errorf("desktop paired with %+v", p)
+	}
+	back, err := laptop.peers.ByFingerprint(desktop.identity.Fingerprint)
+	if err != nil || back == nil || back.Name != "Desktop" || back.ID != desktop.identity.ID() {
+		t.Errorf("laptop paired with %+v, %v", back, err)
+	}
+
+	// The code works once
+	other := newDevice(t, "Phone")
+	if _, err := other.client.Pair(ctx, laptop.address, code); err == nil {
+		t.Error("Pair() with a used code succeeded")
+	}
+}
+
+func TestPairingCodeAllowsFewGuesses(t *testing.T) {
+	laptop, desktop := newDevice(t, "Laptop"), newDevice(t, "Desktop")
+	ctx := context.Background()
+
+	code, _ := laptop.server.NewPairingCode()
+	for i := 0; i < maxPairingAttempts; i++ {
+		guess := "00000" + strconv.Itoa(i)
+		if guess == code {
+			guess = "99999" + strconv.Itoa(i)
+		}
+		desktop.client.Pair(ctx, laptop.address, guess)
+	}
+	if _, err := desktop.client.Pair(ctx, laptop.address, code); err == nil {
+		t.Error("Pair() succeeded after too many wrong guesses")
+	}
+}
+
+func TestSync(t *testing.T) {
+	laptop, desktop := newDevice(t, "Laptop"), newDevice(t, "Desktop")
+	p := desktop.pair(t, laptop)
+
+	image, _ := laptop.attachments.Save("chart.png", []byte("\x89PNG\r\n\x1a\n chart"))
+	plan, _ := laptop.notes.CreateNote("Plan", "![chart]("+image.URL+")", "")
+	ideas, _ := laptop.notes.CreateNote("Ideas", "Draft", "")
+	todo, _ := desktop.notes.CreateNote("Todo", "Milk", "")
+
+	report := desktop.sync(t, p, laptop)
+	if report.Received != 3 || report.Sent != 1 {
+		t.Errorf("first sync = %+v, want 2 notes and 1 attachment received, 1 note sent", report)
+	}
+	for _, n := range []*models.Note{plan, ideas} {
+		if got, err := desktop.notes.GetNote(n.ID); err != nil || got.Content != n.Content {
+			t.Errorf("desktop %s = %+v, %v", n.Title, got, err)
+		}
+	}
+	if _, err := desktop.attachments.Read(image.Hash); err != nil {
+		t.Errorf("attachment did not reach the desktop: %v", err)
+	}
+	if got, err := laptop.notes.GetNote(todo.ID); err != nil || got.Content != "Milk" {
+		t.Errorf("laptop Todo = %+v, %v", got, err)
+	}
+	if len(laptop.changes) != 1 || laptop.changes[0].NoteID != todo.ID {
+		t.Errorf("laptop changes = %+v, want Todo", laptop.changes)
+	}
+
+	// Nothing changed, nothing to do
+	if report := desktop.sync(t, p, laptop); report.Sent+report.Received+report.DeletedLocal+report.DeletedRemote != 0 {
+		t.Errorf("second sync = %+v, want nothing done", report)
+	}
+
+	// Edits and deletions on either side travel both ways
+	desktop.notes.UpdateNote(plan.ID, "Plan", "Revised", false)
+	laptop.notes.DeleteNote(ideas.ID)
+	laptop.notes.DeleteNote(todo.ID)
+	laptop.notes.DeleteNotePermanently(todo.ID)
+
+	report = desktop.sync(t, p, laptop)
+	if report.Sent != 1 || report.DeletedLocal != 2 {
+		t.Errorf("sync = %+v, want 1 sent, 2 deleted here", report)
+	}
+	if got, _ := laptop.notes.GetNote(plan.ID); got == nil || got.Content != "Revised" {
+		t.Errorf("laptop Plan = %+v, want the desktop edit", got)
+	}
+	trash, _ := desktop.notes.ListTrash()
+	if len(trash) != 2 {
+		t.Errorf("desktop trash = %+v, want Ideas and Todo", trash)
+	}
+
+	// The laptop syncing back finds nothing new
+	back, _ := laptop.peers.ByFingerprint(desktop.identity.Fingerprint)
+	if report := laptop.sync(t, back, desktop); report.Sent+report.Received+report.DeletedLocal+report.DeletedRemote != 0 {
+		t.Errorf("sync from the laptop = %+v, want nothing done", report)
+	}
+
+	// A note restored and edited on one side comes back on the other
+	restored, err := desktop.notes.RestoreNote(ideas.ID)
+	if err != nil {
+		t.Fatalf("RestoreNote() failed: %v", err)
+	}
+	desktop.notes.UpdateNote(restored.ID, "Ideas", "Kept after all", false)
+	desktop.sync(t, p, laptop)
+	if got, err := laptop.notes.GetNote(ideas.ID); err != nil || got.DeletedAt != nil || got.Content != "Kept after all" {
+		t.Errorf("laptop Ideas = %+v, %v; want it restored with the edit", got, err)
+	}
+}
+
+func TestSyncConflictKeepsBothVersions(t *testing.T) {
+	laptop, desktop := newDevice(t, "Laptop"), newDevice(t, "Desktop")
+	p := desktop.pair(t, laptop)
+
+	plan, _ := laptop.notes.CreateNote("Plan", "Original", "")
+	desktop.sync(t, p, laptop)
+
+	laptop.notes.UpdateNote(plan.ID, "Plan", "Laptop edit", false)
+	desktop.notes.UpdateNote(plan.ID, "Plan", "Desktop edit", false)
+
+	report := desktop.sync(t, p, laptop)
+	if len(report.Conflicts) != 1 {
+		t.Fatalf("Conflicts = %+v, want 1", report.Conflicts)
+	}
+	conflict := report.Conflicts[0]
+	if conflict.NoteID != plan.ID || !strings.HasPrefix(conflict.Title, "Plan (conflict copy ") {
+		t.Errorf("conflict = %+v", conflict)
+	}
+
+	// Both devices end up with the same two versions
+	var kept string
+	for _, d := range []*device{laptop, desktop} {
+		got, _ := d.notes.GetNote(plan.ID)
+		copied, _ := d.notes.GetNote(conflict.CopyID)
+		if got == nil || copied == nil {
+			t.Fatalf("note = %+v, copy = %+v", got, copied)
+		}
+		versions := got.Content + "|" + copied.Content
+		if versions != "Laptop edit|Desktop edit" && versions != "Desktop edit|Laptop edit" {
+			t.Errorf("versions = %q, want both edits", versions)
+		}
+		if kept != "" && got.Content != kept {
+			t.Errorf("devices kept %q and %q", kept, got.Content)
+		}
+		kept = got.Content
+	}
+}
+
+func TestSyncMergesEditsToDifferentParts(t *testing.T) {
+	laptop, desktop := newDevice(t, "Laptop"), newDevice(t, "Desktop")
+	p := desktop.pair(t, laptop)
+
+	plan, _ := laptop.notes.CreateNote("Plan", "One\n\nTwo\n\nThree", "")
+	desktop.sync(t, p, laptop)
+
+	laptop.notes.UpdateNote(plan.ID, "Plan", "One (laptop)\n\nTwo\n\nThree", false)
+	desktop.notes.UpdateNote(plan.ID, "Plan", "One\n\nTwo\n\nThree (desktop)", false)
+
+	report := desktop.sync(t, p, laptop)
+	if len(report.Conflicts) != 0 || report.Sent != 1 || report.Received != 1 {
+		t.Errorf("sync = %+v, want the merge sent and applied", report)
+	}
+	for _, d := range []*device{laptop, desktop} {
+		got, _ := d.notes.GetNote(plan.ID)
+		if got == nil || got.Content != "One (laptop)\n\nTwo\n\nThree (desktop)" {
+			t.Errorf("note = %+v, want both edits", got)
+		}
+	}
+
+	back, _ := laptop.peers.ByFingerprint(desktop.identity.Fingerprint)
+	if report := laptop.sync(t, back, desktop); report.Sent+report.Received != 0 {
+		t.Errorf("sync from the laptop = %+v, want nothing done", report)
+	}
+}
+
+func TestSyncRefusesUnpairedDevice(t *testing.T) {
+	laptop, stranger := newDevice(t, "Laptop"), newDevice(t, "Stranger")
+	laptop.notes.CreateNote("Secret", "Plans", "")
+
+	// The stranger knows the laptop's certificate but is not paired with it
+	p := &Peer{ID: laptop.identity.ID(), Fingerprint: laptop.identity.Fingerprint}
+	_, err := stranger.client.Sync(context.Background(), p, laptop.address, stranger.replica)
+	if !errors.Is(err, ErrNotPaired) {
+		t.Errorf("Sync() = %v, want ErrNotPaired", err)
+	}
+
+	// A device presenting another certificate is not the paired one
+	desktop := newDevice(t, "Desktop")
+	pd := desktop.pair(t, laptop)
+	pd.Fingerprint = stranger.identity.Fingerprint
+	if _, err := desktop.client.Sync(context.Background(), pd, laptop.address, desktop.replica); err == nil {
+		t.Error("Sync() with an unexpected certificate succeeded")
+	}
+}
+
+func TestSyncRejectsPathsOutsideNotes(t *testing.T) {
+	laptop, desktop := newDevice(t, "Laptop"), newDevice(t, "Desktop")
+	p := desktop.pair(t, laptop)
+	desktop.sync(t, p, laptop)
+	rc, _ := desktop.client.connect(laptop.address, p.Fingerprint, desktop.replica.WorkspaceID())
+
+	for _, id := range []string{"../.templates/evil", "../../evil", "..", ".trash", `..\evil`} {
+		data, _ := note.SerializeNote(&note.Frontmatter{ID: id, Title: "Evil"}, "pwned")
+		if err := rc.put(context.Background(), "/notes/"+url.PathEscape(id), "", []byte(data)); err == nil {
+			t.Errorf("PUT of note %q succeeded", id)
+		}
+		if _, err := laptop.replica.ApplyNote(id, []byte(data)); err == nil {
+			t.Errorf("ApplyNote(%q) succeeded", id)
+		}
+		if _, err := laptop.replica.ReadNote(id); err == nil {
+			t.Errorf("ReadNote(%q) succeeded", id)
+		}
+		if _, err := laptop.replica.DeleteNote(id); err == nil {
+			t.Errorf("DeleteNote(%q) succeeded", id)
+		}
+	}
+
+	hidden, _ := filepath.Glob(filepath.Join(laptop.dir, ".*", "evil.md"))
+	outside, _ := filepath.Glob(filepath.Join(filepath.Dir(laptop.dir), "*evil.md"))
+	if len(hidden)+len(outside) != 0 {
+		t.Errorf("files written outside notes/: %v %v", hidden, outside)
+	}
+}
+
+func TestSyncStaysWithLinkedWorkspace(t *testing.T) {
+	laptop, desktop := newDevice(t, "Laptop"), newDevice(t, "Desktop")
+	p := desktop.pair(t, laptop)
+	desktop.sync(t, p, laptop)
+
+	laptop.open(t, "Other notes")
+	_, err := desktop.client.Sync(context.Background(), p, laptop.address, desktop.replica)
+	if !errors.Is(err, ErrOtherWorkspace) {
+		t.Errorf("Sync() with another workspace open = %v, want ErrOtherWorkspace", err)
+	}
+}
@@ -0,0 +1,313 @@
+package peer
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fuknotion/backend/internal/attachment"
+	"fuknotion/backend/internal/database"
+	"fuknotion/backend/internal/filesystem"
+	"fuknotion/backend/internal/models"
+	"fuknotion/backend/internal/note"
+)
+
+// Entry is one note in a manifest
+type Entry struct {
+	ID        string    `json:"id"`
+	Hash      string    `json:"hash"`      // hex SHA-256 of the note file, or "" if deleted
+	UpdatedAt time.Time `json:"updatedAt"` // when last edited, or when deleted
+}
+
+// Manifest lists what a workspace holds, for a peer to compare with its own
+type Manifest struct {
+	WorkspaceID   string   `json:"workspaceId"`
+	WorkspaceName string   `json:"workspaceName"`
+	Notes         []Entry  `json:"notes"`
+	Attachments   []string `json:"attachments"` // hashes
+}
+
+// Replica is a workspace as peer sync sees it: notes by ID, attachments by
+// hash, and what each paired device held as of the last sync
+type Replica struct {
+	workspace   *models.Workspace
+	db          *database.Database
+	fs          *filesystem.FileSystem
+	notes       *note.Service
+	attachments *attachment.Store
+}
+
+// NewReplica creates a replica of an open workspace
+func NewReplica(ws *models.Workspace, db *database.Database, fs *filesystem.FileSystem, notes *note.Service, attachments *attachment.Store) *Replica {
+	return &Replica{workspace: ws, db: db, fs: fs, notes: notes, attachments: attachments}
+}
+
+// WorkspaceID returns the ID of the workspace
+func (r *Replica) WorkspaceID() string {
+	return r.workspace.ID
+}
+
+// Manifest lists the workspace's notes, trashed and deleted ones included,
+// and its attachments
+func (r *Replica) Manifest() (*Manifest, error) {
+	m := &Manifest{WorkspaceID: r.workspace.ID, WorkspaceName: r.workspace.Name}
+
+	rows, err := r.db.Query(`SELECT id, file_path, updated_at, deleted_at FROM notes`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notes: %w", err)
+	}
+	type row struct {
+		id, path  string
+		updatedAt time.Time
+		deletedAt sql.NullTime
+	}
+	var notes []row
+	for rows.Next() {
+		var n row
+		if err := rows.Scan(&n.id, &n.path, &n.updatedAt, &n.deletedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan note: %w", err)
+		}
+		notes = append(notes, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list notes: %w", err)
+	}
+
+	for _, n := range notes {
+		if n.deletedAt.Valid {
+			m.Notes = append(m.Notes, Entry{ID: n.id, UpdatedAt: n.deletedAt.Time})
+			continue
+		}
+		data, err := r.fs.ReadFile(n.path)
+		if err != nil {
+			// Gone from disk; the watcher will catch up with it
+			continue
+		}
+		// The frontmatter date travels with the note, where the row's may
+		// be when the file was written here
+		updated := n.updatedAt
+		if fm, _, err := note.ParseMarkdown(string(data)); err == nil && !fm.Modified.IsZero() {
+			updated = fm.Modified
+		}
+		m.Notes = append(m.Notes, Entry{ID: n.id, Hash: hashOf(data), UpdatedAt: updated})
+	}
+
+	tombstones, err := r.notes.ListTombstones()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tombstones {
+		m.Notes = append(m.Notes, Entry{ID: t.NoteID, UpdatedAt: t.DeletedAt})
+	}
+
+	if m.Attachments, err = r.attachments.Hashes(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ReadNote returns the file of a note that is not deleted
+func (r *Replica) ReadNote(id string) ([]byte, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	path, err := r.livePath(id)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, fmt.Errorf("note not found: %s", id)
+	}
+	return r.fs.ReadFile(path)
+}
+
+// ApplyNote writes a note file received from a peer, bringing the note back
+// from the trash if it was deleted here
+func (r *Replica) ApplyNote(id string, data []byte) (*note.FileChange, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	fm, _, err := note.ParseMarkdown(string(data))
+	if err != nil {
+		return nil, err
+	}
+	if fm.ID != id {
+		return nil, fmt.Errorf("note file does not belong to note %s", id)
+	}
+
+	path, err := r.livePath(id)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		var trashed bool
+		if err := r.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM notes WHERE id = ? AND deleted_at IS NOT NULL)`, id).Scan(&trashed); err != nil {
+			return nil, fmt.Errorf("failed to look up note: %w", err)
+		}
+		if trashed {
+			restored, err := r.notes.RestoreNote(id)
+			if err != nil {
+				return nil, err
+			}
+			path = restored.FilePath
+		} else {
+			path = filepath.Join("notes", id+".md")
+		}
+	}
+	return r.notes.ApplyFile(path, data)
+}
+
+// DeleteNote moves a note deleted on a peer to the trash. It returns nil if
+// the note is not here or already deleted.
+func (r *Replica) DeleteNote(id string) (*note.FileChange, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	path, err := r.livePath(id)
+	if err != nil || path == "" {
+		return nil, err
+	}
+	return r.notes.TrashFile(path)
+}
+
+// Reconcile merges two versions of a note changed on both sides from base,
+// the version both held after the last sync. What does not merge keeps ours,
+// and theirs is saved as a new note; see note.Service.Reconcile.
+func (r *Replica) Reconcile(base, ours, theirs []byte, label string) (*note.Reconciled, error) {
+	return r.notes.Reconcile(base, ours, theirs, label)
+}
+
+// ReadAttachment returns an attachment's name and contents
+func (r *Replica) ReadAttachment(hash string) (string, []byte, error) {
+	data, err := r.attachments.Read(hash)
+	if err != nil {
+		return "", nil, err
+	}
+	name := hash
+	if a, err := r.attachments.Get(hash); err == nil {
+		name = a.Name
+	}
+	return name, data, nil
+}
+
+// SaveAttachment stores an attachment received from a peer, which must
+// match its hash
+func (r *Replica) SaveAttachment(hash, name string, data []byte) error {
+	if hashOf(data) != hash {
+		return fmt.Errorf("attachment content does not match its hash")
+	}
+	_, err := r.attachments.Save(name, data)
+	return err
+}
+
+// Link checks that a paired device syncs this workspace with the workspace
+// workspaceID on its side. The first sync links the two.
+func (r *Replica) Link(peerID, workspaceID string) error {
+	var linked string
+	err := r.db.QueryRow(`SELECT workspace_id FROM peer_links WHERE peer_id = ?`, peerID).Scan(&linked)
+	if err == sql.ErrNoRows {
+		if _, err := r.db.Exec(`INSERT INTO peer_links (peer_id, workspace_id) VALUES (?, ?)`, peerID, workspaceID); err != nil {
+			return fmt.Errorf("failed to link workspaces: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up linked workspace: %w", err)
+	}
+	if linked != workspaceID {
+		return ErrOtherWorkspace
+	}
+	return nil
+}
+
+// Baseline returns each note's hash as of the last sync with a device
+func (r *Replica) Baseline(peerID string) (map[string]string, error) {
+	rows, err := r.db.Query(`SELECT note_id, hash FROM peer_notes WHERE peer_id = ?`, peerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sync state: %w", err)
+	}
+	defer rows.Close()
+
+	baseline := make(map[string]string)
+	for rows.Next() {
+		var id, hash string
+		if err := rows.Scan(&id, &hash); err != nil {
+			return nil, fmt.Errorf("failed to scan sync state: %w", err)
+		}
+		baseline[id] = hash
+	}
+	return baseline, rows.Err()
+}
+
+// Base returns a note as of the last sync with a device, or nil if that is
+// not known
+func (r *Replica) Base(peerID, noteID string) ([]byte, error) {
+	var base []byte
+	err := r.db.QueryRow(`SELECT base FROM peer_notes WHERE peer_id = ? AND note_id = ?`, peerID, noteID).Scan(&base)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to load sync state: %w", err)
+	}
+	return base, nil
+}
+
+// SetBaseline records the notes both sides agree on after a sync with a
+// device, keeping each note as it is here to merge later edits from
+func (r *Replica) SetBaseline(peerID string, entries []Entry) error {
+	bases := make([][]byte, len(entries))
+	for i, e := range entries {
+		if e.Hash == "" {
+			continue
+		}
+		// Edited since; with no base, a conflict keeps both versions
+		if data, err := r.ReadNote(e.ID); err == nil && hashOf(data) == e.Hash {
+			bases[i] = data
+		}
+	}
+
+	query := `
+		INSERT INTO peer_notes (peer_id, note_id, hash, base) VALUES (?, ?, ?, ?)
+		ON CONFLICT(peer_id, note_id) DO UPDATE SET hash = excluded.hash, base = excluded.base
+	`
+	return r.db.Transaction(func(tx *sql.Tx) error {
+		for i, e := range entries {
+			if _, err := tx.Exec(query, peerID, e.ID, e.Hash, bases[i]); err != nil {
+				return fmt.Errorf("failed to save sync state: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// livePath returns where a note that is not deleted is kept, or "" if there
+// is no such note
+func (r *Replica) livePath(id string) (string, error) {
+	var path string
+	err := r.db.QueryRow(`SELECT file_path FROM notes WHERE id = ? AND deleted_at IS NULL`, id).Scan(&path)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up note: %w", err)
+	}
+	return path, nil
+}
+
+// checkID rejects a note ID from a peer that, made into a file name, could
+// reach outside notes/ or into a hidden directory
+func checkID(id string) error {
+	if id == "" || strings.HasPrefix(id, ".") || strings.ContainsAny(id, "/\\:\x00") {
+		return fmt.Errorf("invalid note id: %q", id)
+	}
+	return nil
+}
+
+func hashOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,391 @@
+package peer
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	gosync "sync"
+	"time"
+
+	"fuknotion/backend/internal/attachment"
+	"fuknotion/backend/internal/note"
+)
+
+const (
+	// pairingTTL is how long a pairing code can be used
+	pairingTTL = 5 * time.Minute
+
+	// maxPairingAttempts is how many wrong guesses end a pairing code
+	maxPairingAttempts = 5
+
+	// maxNoteSize caps a note file received from a device
+	maxNoteSize = 16 << 20
+)
+
+// ReplicaFunc returns the workspace to sync with devices, along with a
+// function to call when done with it
+type ReplicaFunc func() (*Replica, func(), error)
+
+// Server lets paired devices sync with this one, and new devices pair with
+// it while a pairing code is shown
+type Server struct {
+	identity *Identity
+	peers    *Store
+	replica  ReplicaFunc
+	onChange func(*note.FileChange)
+	now      func() time.Time
+
+	mu       gosync.Mutex
+	pairing  *pairing
+	listener net.Listener
+	http     *http.Server
+}
+
+// pairing is a pairing code waiting to be used
+type pairing struct {
+	code     string
+	expires  time.Time
+	attempts int
+
+	// Set once a device starts an exchange with the code
+	clientFP   string
+	clientName string
+	clientMAC  []byte
+	serverMAC  []byte
+}
+
+// NewServer creates a server for paired devices. onChange, if not nil, is
+// called with each note a device changes here.
+func NewServer(identity *Identity, peers *Store, replica ReplicaFunc, onChange func(*note.FileChange)) *Server {
+	return &Server{identity: identity, peers: peers, replica: replica, onChange: onChange, now: time.Now}
+}
+
+// Start listens on addr, such as ":47231"
+func (s *Server) Start(addr string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener != nil {
+		return fmt.Errorf("server already started")
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for devices: %w", err)
+	}
+	s.listener = ln
+	s.http = &http.Server{
+		Handler: s.routes(),
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{s.identity.certificate},
+			MinVersion:   tls.VersionTLS13,
+			// Devices are told apart by certificate fingerprint, checked
+			// against the paired devices on every request
+			ClientAuth: tls.RequireAnyClientCert,
+		},
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func(srv *http.Server) {
+		if err := srv.ServeTLS(ln, "", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("Failed to serve devices: %v\n", err)
+		}
+	}(s.http)
+	return nil
+}
+
+// Port returns the port the server listens on, or 0 if it is not started
+func (s *Server) Port() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return 0
+	}
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Close stops the server
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.http == nil {
+		return nil
+	}
+	err := s.http.Close()
+	s.http, s.listener = nil, nil
+	return err
+}
+
+// NewPairingCode returns a six-digit code another device can pair with
+// once, within the next few minutes. It replaces any earlier code.
+func (s *Server) NewPairingCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate pairing code: %w", err)
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pairing = &pairing{code: code, expires: s.now().Add(pairingTTL)}
+	return code, nil
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /pair/start", s.handlePairStart)
+	mux.HandleFunc("POST /pair/finish", s.handlePairFinish)
+	mux.HandleFunc("GET /manifest", s.paired(s.handleManifest))
+	mux.HandleFunc("GET /notes/{id}", s.paired(s.handleGetNote))
+	mux.HandleFunc("PUT /notes/{id}", s.paired(s.handlePutNote))
+	mux.HandleFunc("DELETE /notes/{id}", s.paired(s.handleDeleteNote))
+	mux.HandleFunc("GET /attachments/{hash}", s.paired(s.handleGetAttachment))
+	mux.HandleFunc("PUT /attachments/{hash}", s.paired(s.handlePutAttachment))
+	mux.HandleFunc("POST /state", s.paired(s.handleState))
+	return mux
+}
+
+func (s *Server) handlePairStart(w http.ResponseWriter, r *http.Request) {
+	clientFP, err := peerFingerprint(r.TLS)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	var req pairStart
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		http.Error(w, "invalid pairing request", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.activePairing()
+	if p == nil {
+		http.Error(w, "no pairing code is active", http.StatusForbidden)
+		return
+	}
+	// Every exchange tests one guess at the code
+	p.attempts++
+	if p.attempts > maxPairingAttempts {
+		s.pairing = nil
+		http.Error(w, "too many attempts", http.StatusForbidden)
+		return
+	}
+
+	exchange, err := newSPAKE(p.code, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	clientMAC, serverMAC, err := exchange.confirm(req.Message, clientFP, s.identity.Fingerprint)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p.clientFP, p.clientName = clientFP, req.Name
+	p.clientMAC, p.serverMAC = clientMAC, serverMAC
+
+	writeJSON(w, pairStart{Name: s.identity.Name, Message: exchange.Message()})
+}
+
+func (s *Server) handlePairFinish(w http.ResponseWriter, r *http.Request) {
+	clientFP, err := peerFingerprint(r.TLS)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	var req pairFinish
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		http.Error(w, "invalid pairing request", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.activePairing()
+	if p == nil || p.clientFP == "" || p.clientFP != clientFP {
+		http.Error(w, "no pairing in progress", http.StatusForbidden)
+		return
+	}
+	if !hmac.Equal(req.MAC, p.clientMAC) {
+		p.clientFP, p.clientMAC, p.serverMAC = "", nil, nil
+		http.Error(w, "wrong pairing code", http.StatusForbidden)
+		return
+	}
+
+	name := p.clientName
+	if name == "" {
+		name = "Unnamed device"
+	}
+	err = s.peers.Add(&Peer{ID: deviceID(clientFP), Name: name, Fingerprint: clientFP, PairedAt: s.now()})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.pairing = nil
+
+	writeJSON(w, pairFinish{MAC: p.serverMAC})
+}
+
+// activePairing returns the pairing code in use, if it has not expired.
+// Callers hold s.mu.
+func (s *Server) activePairing() *pairing {
+	if s.pairing != nil && s.now().After(s.pairing.expires) {
+		s.pairing = nil
+	}
+	return s.pairing
+}
+
+// pairedHandler handles a request from a paired device syncing the open
+// workspace
+type pairedHandler func(w http.ResponseWriter, r *http.Request, p *Peer, replica *Replica)
+
+// paired lets through requests from paired devices only, and only for the
+// workspace each was first synced with
+func (s *Server) paired(h pairedHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fp, err := peerFingerprint(r.TLS)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		p, err := s.peers.ByFingerprint(fp)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if p == nil {
+			http.Error(w, ErrNotPaired.Error(), http.StatusForbidden)
+			return
+		}
+
+		replica, release, err := s.replica()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		defer release()
+
+		workspace := r.Header.Get(workspaceHeader)
+		if workspace == "" {
+			http.Error(w, "missing workspace", http.StatusBadRequest)
+			return
+		}
+		if err := replica.Link(p.ID, workspace); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrOtherWorkspace) {
+				status = http.StatusConflict
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		h(w, r, p, replica)
+	}
+}
+
+func (s *Server) handleManifest(w http.ResponseWriter, r *http.Request, p *Peer, replica *Replica) {
+	m, err := replica.Manifest()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, m)
+}
+
+func (s *Server) handleGetNote(w http.ResponseWriter, r *http.Request, p *Peer, replica *Replica) {
+	data, err := replica.ReadNote(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Write(data)
+}
+
+func (s *Server) handlePutNote(w http.ResponseWriter, r *http.Request, p *Peer, replica *Replica) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxNoteSize))
+	if err != nil {
+		http.Error(w, "note is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	change, err := replica.ApplyNote(r.PathValue("id"), data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.changed(change)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleDeleteNote(w http.ResponseWriter, r *http.Request, p *Peer, replica *Replica) {
+	change, err := replica.DeleteNote(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.changed(change)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleGetAttachment(w http.ResponseWriter, r *http.Request, p *Peer, replica *Replica) {
+	name, data, err := replica.ReadAttachment(r.PathValue("hash"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set(nameHeader, name)
+	w.Write(data)
+}
+
+func (s *Server) handlePutAttachment(w http.ResponseWriter, r *http.Request, p *Peer, replica *Replica) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, attachment.MaxSize))
+	if err != nil {
+		http.Error(w, "attachment is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := replica.SaveAttachment(r.PathValue("hash"), r.Header.Get(nameHeader), data); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleState records the notes a device found both sides agree on at the
+// end of a sync, so this side starts from the same baseline when it syncs
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request, p *Peer, replica *Replica) {
+	var agreed []Entry
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxNoteSize)).Decode(&agreed); err != nil {
+		http.Error(w, "invalid sync state", http.StatusBadRequest)
+		return
+	}
+	if err := replica.SetBaseline(p.ID, agreed); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.peers.MarkSynced(p.ID, s.now()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) changed(change *note.FileChange) {
+	if change != nil && s.onChange != nil {
+		s.onChange(change)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Printf("Failed to write response: %v\n", err)
+	}
+}
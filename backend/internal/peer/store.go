@@ -0,0 +1,129 @@
+package peer
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"fuknotion/backend/internal/database"
+)
+
+// Peer is a paired device
+type Peer struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Fingerprint string     `json:"fingerprint"`
+	Address     string     `json:"address,omitempty"` // host:port it was last reached at
+	PairedAt    time.Time  `json:"pairedAt"`
+	LastSyncAt  *time.Time `json:"lastSyncAt,omitempty"`
+}
+
+// Store keeps paired devices in user.db
+type Store struct {
+	db *database.Database
+}
+
+// NewStore creates a store of paired devices
+func NewStore(db *database.Database) *Store {
+	return &Store{db: db}
+}
+
+const peerColumns = `id, name, fingerprint, address, paired_at, last_sync_at`
+
+// Add records a paired device, replacing an earlier pairing with it
+func (s *Store) Add(p *Peer) error {
+	query := `
+		INSERT INTO peers (id, name, fingerprint, address, paired_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			fingerprint = excluded.fingerprint,
+			address = excluded.address,
+			paired_at = excluded.paired_at
+	`
+	if _, err := s.db.Exec(query, p.ID, p.Name, p.Fingerprint, p.Address, p.PairedAt); err != nil {
+		return fmt.Errorf("failed to save paired device: %w", err)
+	}
+	return nil
+}
+
+// Get returns a paired device by ID
+func (s *Store) Get(id string) (*Peer, error) {
+	p, err := scanPeer(s.db.QueryRow(`SELECT `+peerColumns+` FROM peers WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("device not paired: %s", id)
+	}
+	return p, err
+}
+
+// ByFingerprint returns the paired device with a certificate, or nil if
+// there is none
+func (s *Store) ByFingerprint(fingerprint string) (*Peer, error) {
+	p, err := scanPeer(s.db.QueryRow(`SELECT `+peerColumns+` FROM peers WHERE fingerprint = ?`, fingerprint))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return p, err
+}
+
+// List lists paired devices by name
+func (s *Store) List() ([]*Peer, error) {
+	rows, err := s.db.Query(`SELECT ` + peerColumns + ` FROM peers ORDER BY name COLLATE NOCASE`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list paired devices: %w", err)
+	}
+	defer rows.Close()
+
+	var peers []*Peer
+	for rows.Next() {
+		p, err := scanPeer(rows)
+		if err != nil {
+			return nil, err
+		}
+		peers = append(peers, p)
+	}
+	return peers, rows.Err()
+}
+
+// Remove forgets a paired device
+func (s *Store) Remove(id string) error {
+	if _, err := s.db.Exec(`DELETE FROM peers WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to remove paired device: %w", err)
+	}
+	return nil
+}
+
+// SetAddress records where a device was reached
+func (s *Store) SetAddress(id, address string) error {
+	if _, err := s.db.Exec(`UPDATE peers SET address = ? WHERE id = ?`, address, id); err != nil {
+		return fmt.Errorf("failed to save device address: %w", err)
+	}
+	return nil
+}
+
+// MarkSynced records a completed sync with a device
+func (s *Store) MarkSynced(id string, at time.Time) error {
+	if _, err := s.db.Exec(`UPDATE peers SET last_sync_at = ? WHERE id = ?`, at, id); err != nil {
+		return fmt.Errorf("failed to save sync time: %w", err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPeer(row rowScanner) (*Peer, error) {
+	var p Peer
+	var lastSync sql.NullTime
+	err := row.Scan(&p.ID, &p.Name, &p.Fingerprint, &p.Address, &p.PairedAt, &lastSync)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan paired device: %w", err)
+	}
+	if lastSync.Valid {
+		p.LastSyncAt = &lastSync.Time
+	}
+	return &p, nil
+}
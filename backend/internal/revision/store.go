@@ -0,0 +1,293 @@
+package revision
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"fuknotion/backend/internal/database"
+	"fuknotion/backend/internal/diff"
+)
+
+// Revision is a snapshot of a note's title and content
+type Revision struct {
+	ID        int64     `json:"id"`
+	NoteID    string    `json:"noteId"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Summary is a revision without its content, for listing
+type Summary struct {
+	ID        int64     `json:"id"`
+	NoteID    string    `json:"noteId"`
+	Title     string    `json:"title"`
+	Size      int       `json:"size"` // content length in bytes
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Diff is a line-level comparison of two revisions
+type Diff struct {
+	From         *Summary    `json:"from"`
+	To           *Summary    `json:"to"`
+	TitleChanged bool        `json:"titleChanged"`
+	Lines        []diff.Line `json:"lines"`
+	Added        int         `json:"added"`
+	Removed      int         `json:"removed"`
+}
+
+// Policy controls how saves are coalesced and how history is thinned
+type Policy struct {
+	// Saves within CoalesceWindow of the latest revision update it in place...
+	CoalesceWindow time.Duration
+	// ...unless that revision already spans MaxCoalesceSpan
+	MaxCoalesceSpan time.Duration
+	// Every revision newer than KeepAll is kept
+	KeepAll time.Duration
+	// Between KeepAll and KeepDaily only the newest revision per day is kept;
+	// anything older is dropped
+	KeepDaily time.Duration
+	// MaxPerNote caps the number of revisions per note (newest kept)
+	MaxPerNote int
+}
+
+// DefaultPolicy is used unless the store is given another one
+var DefaultPolicy = Policy{
+	CoalesceWindow:  5 * time.Minute,
+	MaxCoalesceSpan: 30 * time.Minute,
+	KeepAll:         24 * time.Hour,
+	KeepDaily:       90 * 24 * time.Hour,
+	MaxPerNote:      100,
+}
+
+// Store persists note revisions in workspace.db
+type Store struct {
+	db     *database.Database
+	policy Policy
+	now    func() time.Time
+}
+
+// NewStore creates a revision store using DefaultPolicy
+func NewStore(db *database.Database) *Store {
+	return &Store{db: db, policy: DefaultPolicy, now: time.Now}
+}
+
+// SetPolicy replaces the coalescing and pruning policy
+func (s *Store) SetPolicy(policy Policy) {
+	s.policy = policy
+}
+
+// Record snapshots a note after a save. With coalesce set, a save shortly
+// after the previous one updates that revision instead of adding a new one,
+// so autosave does not flood the history. Unchanged saves are ignored.
+// q is the store's database or the transaction writing the note.
+func (s *Store) Record(q database.Executor, noteID, title, content string, coalesce bool) error {
+	now := s.now()
+
+	latest, err := s.latest(q, noteID)
+	if err != nil {
+		return err
+	}
+
+	if latest != nil && latest.Title == title && latest.Content == content {
+		return nil
+	}
+
+	if coalesce && latest != nil &&
+		now.Sub(latest.UpdatedAt) <= s.policy.CoalesceWindow &&
+		now.Sub(latest.CreatedAt) <= s.policy.MaxCoalesceSpan {
+		query := `UPDATE note_revisions SET title = ?, content = ?, updated_at = ? WHERE id = ?`
+		if _, err := q.Exec(query, title, content, now, latest.ID); err != nil {
+			return fmt.Errorf("failed to update revision: %w", err)
+		}
+		return nil
+	}
+
+	query := `INSERT INTO note_revisions (note_id, title, content, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`
+	if _, err := q.Exec(query, noteID, title, content, now, now); err != nil {
+		return fmt.Errorf("failed to insert revision: %w", err)
+	}
+
+	return s.prune(q, noteID)
+}
+
+// List returns a note's revisions, newest first
+func (s *Store) List(noteID string) ([]*Summary, error) {
+	query := `
+		SELECT id, note_id, title, length(CAST(content AS BLOB)), created_at, updated_at
+		FROM note_revisions WHERE note_id = ? ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := s.db.Query(query, noteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revisions: %w", err)
+	}
+	defer rows.Close()
+
+	summaries := []*Summary{}
+	for rows.Next() {
+		var r Summary
+		if err := rows.Scan(&r.ID, &r.NoteID, &r.Title, &r.Size, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan revision: %w", err)
+		}
+		summaries = append(summaries, &r)
+	}
+
+	return summaries, rows.Err()
+}
+
+// Get retrieves a revision with its content
+func (s *Store) Get(id int64) (*Revision, error) {
+	query := `SELECT id, note_id, title, content, created_at, updated_at FROM note_revisions WHERE id = ?`
+
+	var r Revision
+	err := s.db.QueryRow(query, id).Scan(&r.ID, &r.NoteID, &r.Title, &r.Content, &r.CreatedAt, &r.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("revision not found: %d", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revision: %w", err)
+	}
+
+	return &r, nil
+}
+
+// Latest returns a note's newest revision, or nil if it has none
+func (s *Store) Latest(noteID string) (*Revision, error) {
+	return s.latest(s.db, noteID)
+}
+
+// Diff compares two revisions of the same note line by line
+func (s *Store) Diff(fromID, toID int64) (*Diff, error) {
+	from, err := s.Get(fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.Get(toID)
+	if err != nil {
+		return nil, err
+	}
+	if from.NoteID != to.NoteID {
+		return nil, fmt.Errorf("revisions %d and %d belong to different notes", fromID, toID)
+	}
+
+	lines := diff.Text(from.Content, to.Content)
+	added, removed := diff.Stats(lines)
+
+	return &Diff{
+		From:         from.summary(),
+		To:           to.summary(),
+		TitleChanged: from.Title != to.Title,
+		Lines:        lines,
+		Added:        added,
+		Removed:      removed,
+	}, nil
+}
+
+// Prune thins a note's history according to the policy. The newest revision
+// is always kept.
+func (s *Store) Prune(noteID string) error {
+	return s.prune(s.db, noteID)
+}
+
+func (s *Store) prune(q database.Executor, noteID string) error {
+	rows, err := q.Query(
+		`SELECT id, created_at FROM note_revisions WHERE note_id = ? ORDER BY created_at DESC, id DESC`,
+		noteID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to list revisions for pruning: %w", err)
+	}
+
+	type entry struct {
+		id      int64
+		created time.Time
+	}
+	var entries []entry
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.id, &e.created); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan revision: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list revisions for pruning: %w", err)
+	}
+
+	now := s.now()
+	keptDays := make(map[string]bool)
+	var drop []int64
+	kept := 0
+
+	for i, e := range entries {
+		age := now.Sub(e.created)
+		keep := true
+
+		switch {
+		case i == 0:
+			// newest revision is always kept
+		case s.policy.MaxPerNote > 0 && kept >= s.policy.MaxPerNote:
+			keep = false
+		case age <= s.policy.KeepAll:
+			// recent history is kept in full
+		case s.policy.KeepDaily > 0 && age > s.policy.KeepDaily:
+			keep = false
+		default:
+			day := e.created.Local().Format("2006-01-02")
+			if keptDays[day] {
+				keep = false
+			}
+			keptDays[day] = true
+		}
+
+		if keep {
+			kept++
+		} else {
+			drop = append(drop, e.id)
+		}
+	}
+
+	for _, id := range drop {
+		if _, err := q.Exec(`DELETE FROM note_revisions WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("failed to prune revision: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// latest returns a note's newest revision, or nil if it has none
+func (s *Store) latest(q database.Executor, noteID string) (*Revision, error) {
+	query := `
+		SELECT id, note_id, title, content, created_at, updated_at
+		FROM note_revisions WHERE note_id = ? ORDER BY created_at DESC, id DESC LIMIT 1
+	`
+
+	var r Revision
+	err := q.QueryRow(query, noteID).Scan(&r.ID, &r.NoteID, &r.Title, &r.Content, &r.CreatedAt, &r.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest revision: %w", err)
+	}
+
+	return &r, nil
+}
+
+func (r *Revision) summary() *Summary {
+	return &Summary{
+		ID:        r.ID,
+		NoteID:    r.NoteID,
+		Title:     r.Title,
+		Size:      len(r.Content),
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
+	}
+}
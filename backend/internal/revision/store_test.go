@@ -0,0 +1,146 @@
+package revision
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"fuknotion/backend/internal/database"
+)
+
+// setupTestStore returns a store over a fresh workspace database with one
+// note row and a controllable clock
+func setupTestStore(t *testing.T) (*Store, *time.Time) {
+	t.Helper()
+
+	db, err := database.InitWorkspaceDB(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	query := `INSERT INTO notes (id, title, file_path, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`
+	if _, err := db.Exec(query, "n1", "Note", "notes/n1.md", now, now); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(db)
+	store.now = func() time.Time { return now }
+	return store, &now
+}
+
+func TestRecordCoalescesAutosaves(t *testing.T) {
+	store, now := setupTestStore(t)
+
+	store.Record(store.db, "n1", "Note", "v1", false)
+
+	*now = now.Add(time.Minute)
+	store.Record(store.db, "n1", "Note", "v2", true)
+
+	// Identical saves are ignored
+	store.Record(store.db, "n1", "Note", "v2", true)
+
+	revisions, _ := store.List("n1")
+	if len(revisions) != 1 {
+		t.Fatalf("List() returned %d revisions, want 1 after coalescing", len(revisions))
+	}
+	rev, _ := store.Get(revisions[0].ID)
+	if rev.Content != "v2" {
+		t.Errorf("Content = %q, want coalesced save v2", rev.Content)
+	}
+
+	// A save after the window starts a new revision
+	*now = now.Add(DefaultPolicy.CoalesceWindow + time.Second)
+	store.Record(store.db, "n1", "Note", "v3", true)
+
+	// So does an explicit, non-coalesced save
+	*now = now.Add(time.Second)
+	store.Record(store.db, "n1", "Note", "v4", false)
+
+	revisions, _ = store.List("n1")
+	if len(revisions) != 3 {
+		t.Fatalf("List() returned %d revisions, want 3", len(revisions))
+	}
+	if revisions[0].Size != 2 {
+		t.Errorf("Size = %d, want 2", revisions[0].Size)
+	}
+}
+
+func TestRecordCoalesceSpan(t *testing.T) {
+	store, now := setupTestStore(t)
+
+	// Continuous editing keeps extending one revision until it spans the limit
+	for i := 0; i < 20; i++ {
+		store.Record(store.db, "n1", "Note", fmt.Sprintf("draft %d", i), true)
+		*now = now.Add(4 * time.Minute)
+	}
+
+	revisions, _ := store.List("n1")
+	if len(revisions) < 2 {
+		t.Errorf("List() returned %d revisions, want continuous editing split by MaxCoalesceSpan", len(revisions))
+	}
+}
+
+func TestDiff(t *testing.T) {
+	store, now := setupTestStore(t)
+
+	store.Record(store.db, "n1", "Note", "one\ntwo\nthree", false)
+	*now = now.Add(time.Hour)
+	store.Record(store.db, "n1", "Renamed", "one\n2\nthree\nfour", false)
+
+	revisions, _ := store.List("n1")
+	d, err := store.Diff(revisions[1].ID, revisions[0].ID)
+	if err != nil {
+		t.Fatalf("Diff() failed: %v", err)
+	}
+	if d.Added != 2 || d.Removed != 1 {
+		t.Errorf("Diff() = +%d -%d, want +2 -1", d.Added, d.Removed)
+	}
+	if !d.TitleChanged {
+		t.Error("TitleChanged = false, want true")
+	}
+
+	if _, err := store.Diff(revisions[1].ID, 9999); err == nil {
+		t.Error("Expected error diffing against a missing revision")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	store, now := setupTestStore(t)
+	start := *now
+
+	// Four saves a day, one day apart, for 120 days
+	for day := 0; day < 120; day++ {
+		for i := 0; i < 4; i++ {
+			*now = start.Add(time.Duration(day)*24*time.Hour + time.Duration(i)*time.Hour)
+			store.Record(store.db, "n1", "Note", fmt.Sprintf("day %d save %d", day, i), false)
+		}
+	}
+
+	revisions, _ := store.List("n1")
+
+	// The last day is kept in full, earlier days within 90 days once each
+	if len(revisions) > DefaultPolicy.MaxPerNote {
+		t.Fatalf("List() returned %d revisions, want at most %d", len(revisions), DefaultPolicy.MaxPerNote)
+	}
+	seen := make(map[string]int)
+	for _, r := range revisions {
+		age := now.Sub(r.CreatedAt)
+		if age > DefaultPolicy.KeepDaily {
+			t.Errorf("revision from %v kept past KeepDaily", r.CreatedAt)
+		}
+		if age > DefaultPolicy.KeepAll {
+			seen[r.CreatedAt.Format("2006-01-02")]++
+		}
+	}
+	for day, count := range seen {
+		if count > 1 {
+			t.Errorf("%d revisions kept for %s, want 1", count, day)
+		}
+	}
+
+	if !revisions[0].CreatedAt.Equal(*now) {
+		t.Errorf("newest revision = %v, want %v", revisions[0].CreatedAt, *now)
+	}
+}
@@ -0,0 +1,112 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// SyncBackend is a place a workspace's files are synced with. Paths are
+// slash-separated and relative to the workspace; IDs are whatever the
+// backend uses to address a file, often the path itself.
+type SyncBackend interface {
+	// List lists every file
+	List(ctx context.Context) ([]RemoteFile, error)
+	// Get reads a file's content
+	Get(ctx context.Context, id string) ([]byte, error)
+	// Put writes the file at path. prev is the version it replaces, or nil
+	// for a new file; backends able to refuse a write when the file changed
+	// since then return ErrChanged.
+	Put(ctx context.Context, path string, data []byte, prev *RemoteFile) (*RemoteFile, error)
+	// Delete deletes a file. Deleting a file that is already gone succeeds.
+	Delete(ctx context.Context, id string) error
+	// Cursor returns a token that changes whenever any file does, so a run
+	// can skip listing when nothing changed. Backends that cannot tell
+	// return "".
+	Cursor(ctx context.Context) (string, error)
+	// Location identifies where the files are kept, such as a URL. Sync
+	// state recorded against another location is discarded.
+	Location() string
+}
+
+// ErrChanged is returned by Put when the file changed since the version it
+// was meant to replace
+var ErrChanged = errors.New("file changed on the remote")
+
+// RemoteFile is a file as listed by a backend
+type RemoteFile struct {
+	ID       string
+	Path     string // slash-separated, relative to the workspace
+	Revision string // changes whenever the content does
+	Hash     string // hex SHA-256 of the content, if the backend knows it
+}
+
+// Kind names a sync backend
+type Kind string
+
+const (
+	KindDrive  Kind = "drive"
+	KindWebDAV Kind = "webdav"
+	KindS3     Kind = "s3"
+	KindFolder Kind = "folder"
+)
+
+// Settings choose and configure a workspace's sync backend. Passwords and
+// keys are not part of them; they are kept in the OS keychain.
+type Settings struct {
+	Backend Kind `json:"backend"` // empty means Google Drive
+
+	URL string `json:"url,omitempty"` // WebDAV collection to sync into
+
+	Endpoint  string `json:"endpoint,omitempty"` // S3 endpoint, e.g. https://s3.eu-west-1.amazonaws.com
+	Region    string `json:"region,omitempty"`
+	Bucket    string `json:"bucket,omitempty"`
+	Prefix    string `json:"prefix,omitempty"`    // key prefix the workspace is kept under
+	PathStyle bool   `json:"pathStyle,omitempty"` // bucket in the path rather than the host name, for MinIO and the like
+
+	Folder string `json:"folder,omitempty"` // local folder to mirror into, e.g. inside a Syncthing or Dropbox folder
+}
+
+// Validate checks the settings hold what their backend needs
+func (s *Settings) Validate() error {
+	switch s.Backend {
+	case "", KindDrive:
+		return nil
+	case KindWebDAV:
+		return checkURL(s.URL)
+	case KindS3:
+		if err := checkURL(s.Endpoint); err != nil {
+			return err
+		}
+		if s.Bucket == "" || s.Region == "" {
+			return fmt.Errorf("bucket and region are required")
+		}
+		return nil
+	case KindFolder:
+		if !filepath.IsAbs(s.Folder) {
+			return fmt.Errorf("folder must be an absolute path")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown sync backend: %q", s.Backend)
+}
+
+func checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("invalid URL: %q", raw)
+	}
+	return nil
+}
+
+// joinKey joins a prefix and a path with a single slash
+func joinKey(prefix, p string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return p
+	}
+	return prefix + "/" + p
+}
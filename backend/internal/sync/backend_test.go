@@ -0,0 +1,82 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// testBackend checks the behaviour every SyncBackend shares. b must start
+// out empty.
+func testBackend(t *testing.T, b SyncBackend) {
+	t.Helper()
+	ctx := context.Background()
+
+	files, err := b.List(ctx)
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("List() = %+v, want no files", files)
+	}
+
+	note, err := b.Put(ctx, "notes/a.md", []byte("first"), nil)
+	if err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+	if note.Path != "notes/a.md" || note.Revision == "" {
+		t.Errorf("Put() = %+v", note)
+	}
+	attachment, err := b.Put(ctx, "attachments/ab/abcd", []byte("binary"), nil)
+	if err != nil {
+		t.Fatalf("Put() of a nested file failed: %v", err)
+	}
+
+	updated, err := b.Put(ctx, "notes/a.md", []byte("second, longer"), note)
+	if err != nil {
+		t.Fatalf("Put() of a new version failed: %v", err)
+	}
+	if updated.ID != note.ID || updated.Revision == note.Revision {
+		t.Errorf("Put() = %+v after %+v, want the same file at a new revision", updated, note)
+	}
+
+	files, err = b.List(ctx)
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	byPath := make(map[string]RemoteFile)
+	for _, f := range files {
+		byPath[f.Path] = f
+	}
+	if len(files) != 2 || byPath["notes/a.md"].Revision != updated.Revision || byPath["attachments/ab/abcd"].ID != attachment.ID {
+		t.Errorf("List() = %+v", files)
+	}
+
+	data, err := b.Get(ctx, updated.ID)
+	if err != nil || string(data) != "second, longer" {
+		t.Errorf("Get() = %q, %v", data, err)
+	}
+
+	// Replacing a version that is no longer current either fails with
+	// ErrChanged or, for backends without conditional writes, succeeds
+	if _, err := b.Put(ctx, "notes/a.md", []byte("stale"), note); err != nil && !errors.Is(err, ErrChanged) {
+		t.Errorf("Put() over a stale version = %v, want ErrChanged or nothing", err)
+	}
+
+	if err := b.Delete(ctx, attachment.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if err := b.Delete(ctx, attachment.ID); err != nil {
+		t.Errorf("Delete() of a deleted file failed: %v", err)
+	}
+	if files, _ := b.List(ctx); len(files) != 1 {
+		t.Errorf("List() after Delete() = %+v, want 1 file", files)
+	}
+
+	if b.Location() == "" {
+		t.Error("Location() is empty")
+	}
+	if _, err := b.Cursor(ctx); err != nil {
+		t.Errorf("Cursor() failed: %v", err)
+	}
+}
@@ -0,0 +1,329 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// DriveRootFolder is the Drive folder holding one folder per synced workspace.
+// With the drive.file scope the app only sees files it created itself.
+const DriveRootFolder = "Fuknotion"
+
+const (
+	driveAPI         = "https://www.googleapis.com"
+	driveFolderType  = "application/vnd.google-apps.folder"
+	driveFileFields  = "id,name,version,appProperties"
+	driveListFields  = "nextPageToken,files(" + driveFileFields + ")"
+	driveMaxPageSize = "1000"
+)
+
+// Drive keeps a workspace's files in a Google Drive folder. Files are stored
+// flat in the folder; their workspace paths and hashes are kept in app
+// properties.
+type Drive struct {
+	client      *http.Client
+	baseURL     string
+	workspaceID string
+	folderID    string // looked up on first use
+}
+
+// NewDrive creates a Drive client for a workspace. client must add the OAuth
+// token to requests, e.g. one made by oauth2.NewClient.
+func NewDrive(client *http.Client, workspaceID string) *Drive {
+	return &Drive{client: client, baseURL: driveAPI, workspaceID: workspaceID}
+}
+
+// driveFile is a file resource in Drive API responses
+type driveFile struct {
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	Version       string            `json:"version"`
+	AppProperties map[string]string `json:"appProperties"`
+}
+
+// List lists the workspace's files
+func (d *Drive) List(ctx context.Context) ([]RemoteFile, error) {
+	folderID, err := d.folder(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := d.list(ctx, fmt.Sprintf("'%s' in parents and trashed = false", folderID))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []RemoteFile
+	for _, f := range files {
+		// Files without a path were not put there by us
+		if f.AppProperties["path"] == "" {
+			continue
+		}
+		result = append(result, RemoteFile{
+			ID:       f.ID,
+			Path:     f.AppProperties["path"],
+			Revision: f.Version,
+			Hash:     f.AppProperties["sha256"],
+		})
+	}
+	return result, nil
+}
+
+// Get reads a file's content
+func (d *Drive) Get(ctx context.Context, id string) ([]byte, error) {
+	resp, err := d.do(ctx, http.MethodGet, "/drive/v3/files/"+url.PathEscape(id)+"?alt=media", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	return data, nil
+}
+
+// Put creates the file at path, or replaces the content of prev. Drive has
+// no conditional writes, so it never returns ErrChanged.
+func (d *Drive) Put(ctx context.Context, filePath string, data []byte, prev *RemoteFile) (*RemoteFile, error) {
+	hash := hashOf(data)
+	meta := map[string]interface{}{
+		"name":          path.Base(filePath),
+		"appProperties": map[string]string{"path": filePath, "sha256": hash},
+	}
+
+	var method, endpoint string
+	if prev != nil {
+		method, endpoint = http.MethodPatch, "/upload/drive/v3/files/"+url.PathEscape(prev.ID)
+	} else {
+		folderID, err := d.folder(ctx)
+		if err != nil {
+			return nil, err
+		}
+		meta["parents"] = []string{folderID}
+		method, endpoint = http.MethodPost, "/upload/drive/v3/files"
+	}
+
+	body, contentType, err := multipartBody(meta, data)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.do(ctx, method, endpoint+"?uploadType=multipart&fields="+driveFileFields, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var f driveFile
+	if err := json.NewDecoder(resp.Body).Decode(&f); err != nil {
+		return nil, fmt.Errorf("failed to decode uploaded file: %w", err)
+	}
+	return &RemoteFile{ID: f.ID, Path: filePath, Revision: f.Version, Hash: hash}, nil
+}
+
+// Delete deletes a file. Deleting a file that is already gone succeeds.
+func (d *Drive) Delete(ctx context.Context, id string) error {
+	resp, err := d.do(ctx, http.MethodDelete, "/drive/v3/files/"+url.PathEscape(id), "", nil)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Cursor returns Drive's start page token for changes. It moves on with any
+// change in the user's Drive, not just ours, so it only ever errs towards
+// listing.
+func (d *Drive) Cursor(ctx context.Context) (string, error) {
+	resp, err := d.do(ctx, http.MethodGet, "/drive/v3/changes/startPageToken", "", nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		StartPageToken string `json:"startPageToken"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode change token: %w", err)
+	}
+	return token.StartPageToken, nil
+}
+
+// Location identifies the workspace's Drive folder
+func (d *Drive) Location() string {
+	return "drive:" + d.workspaceID
+}
+
+// folder returns the ID of the workspace's folder, creating it and the root
+// folder if needed
+func (d *Drive) folder(ctx context.Context) (string, error) {
+	if d.folderID != "" {
+		return d.folderID, nil
+	}
+
+	rootID, err := d.findOrCreateFolder(ctx, DriveRootFolder, "root")
+	if err != nil {
+		return "", err
+	}
+	if d.folderID, err = d.findOrCreateFolder(ctx, d.workspaceID, rootID); err != nil {
+		return "", err
+	}
+	return d.folderID, nil
+}
+
+func (d *Drive) findOrCreateFolder(ctx context.Context, name, parentID string) (string, error) {
+	q := fmt.Sprintf("name = '%s' and '%s' in parents and mimeType = '%s' and trashed = false",
+		escapeQuery(name), parentID, driveFolderType)
+	files, err := d.list(ctx, q)
+	if err != nil {
+		return "", err
+	}
+	if len(files) > 0 {
+		return files[0].ID, nil
+	}
+
+	meta, err := json.Marshal(map[string]interface{}{
+		"name":     name,
+		"mimeType": driveFolderType,
+		"parents":  []string{parentID},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode folder: %w", err)
+	}
+
+	resp, err := d.do(ctx, http.MethodPost, "/drive/v3/files?fields=id", "application/json", bytes.NewReader(meta))
+	if err != nil {
+		return "", fmt.Errorf("failed to create folder %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	var f driveFile
+	if err := json.NewDecoder(resp.Body).Decode(&f); err != nil {
+		return "", fmt.Errorf("failed to decode folder: %w", err)
+	}
+	return f.ID, nil
+}
+
+// list runs a files.list query, following pages
+func (d *Drive) list(ctx context.Context, q string) ([]driveFile, error) {
+	var files []driveFile
+	pageToken := ""
+	for {
+		params := url.Values{
+			"q":        {q},
+			"fields":   {driveListFields},
+			"pageSize": {driveMaxPageSize},
+		}
+		if pageToken != "" {
+			params.Set("pageToken", pageToken)
+		}
+
+		resp, err := d.do(ctx, http.MethodGet, "/drive/v3/files?"+params.Encode(), "", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			NextPageToken string      `json:"nextPageToken"`
+			Files         []driveFile `json:"files"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode file list: %w", err)
+		}
+
+		files = append(files, page.Files...)
+		if page.NextPageToken == "" {
+			return files, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// driveError is an error response from the Drive API
+type driveError struct {
+	Status  int
+	Message string
+}
+
+func (e *driveError) Error() string {
+	return fmt.Sprintf("drive returned %d: %s", e.Status, e.Message)
+}
+
+func isNotFound(err error) bool {
+	de, ok := err.(*driveError)
+	return ok && de.Status == http.StatusNotFound
+}
+
+// do sends a request to the Drive API and returns the response if it succeeded
+func (d *Drive) do(ctx context.Context, method, endpoint, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, d.baseURL+endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach Drive: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&e)
+		return nil, &driveError{Status: resp.StatusCode, Message: e.Error.Message}
+	}
+	return resp, nil
+}
+
+// multipartBody builds a multipart/related upload of metadata and content
+func multipartBody(meta map[string]interface{}, data []byte) (io.Reader, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	metaPart, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json; charset=UTF-8"}})
+	if err != nil {
+		return nil, "", err
+	}
+	if err := json.NewEncoder(metaPart).Encode(meta); err != nil {
+		return nil, "", fmt.Errorf("failed to encode file metadata: %w", err)
+	}
+
+	dataPart, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/octet-stream"}})
+	if err != nil {
+		return nil, "", err
+	}
+	dataPart.Write(data)
+
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return &buf, "multipart/related; boundary=" + w.Boundary(), nil
+}
+
+// escapeQuery escapes a value for a single-quoted Drive query string
+func escapeQuery(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+}
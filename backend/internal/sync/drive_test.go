@@ -0,0 +1,279 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	gosync "sync"
+	"testing"
+)
+
+// fakeDrive is an in-memory stand-in for the parts of the Drive API the
+// client uses
+type fakeDrive struct {
+	mu        gosync.Mutex
+	files     map[string]*fakeFile
+	nextID    int
+	changes   int  // bumped on every write, for the change token
+	down      bool // answer every request with 503, as if offline
+	failAfter int  // when > 0, go down after this many more uploads
+	server    *httptest.Server
+}
+
+type fakeFile struct {
+	name          string
+	mimeType      string
+	parents       []string
+	appProperties map[string]string
+	version       int64
+	data          []byte
+}
+
+var (
+	parentQuery = regexp.MustCompile(`'([^']+)' in parents`)
+	nameQuery   = regexp.MustCompile(`name = '([^']+)'`)
+)
+
+func newFakeDrive(t *testing.T) *fakeDrive {
+	t.Helper()
+
+	f := &fakeDrive{files: make(map[string]*fakeFile)}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// client returns a Drive client for a workspace talking to the fake
+func (f *fakeDrive) client(workspaceID string) *Drive {
+	d := NewDrive(f.server.Client(), workspaceID)
+	d.baseURL = f.server.URL
+	return d
+}
+
+func (f *fakeDrive) setDown(down bool) {
+	f.mu.Lock()
+	f.down = down
+	f.mu.Unlock()
+}
+
+func (f *fakeDrive) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.down {
+		http.Error(w, `{"error":{"message":"unavailable"}}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	id := ""
+	for _, prefix := range []string{"/drive/v3/files/", "/upload/drive/v3/files/"} {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			id = strings.TrimPrefix(r.URL.Path, prefix)
+		}
+	}
+	upload := strings.HasPrefix(r.URL.Path, "/upload/")
+
+	if r.Method != http.MethodGet {
+		f.changes++
+	}
+
+	switch {
+	case r.URL.Path == "/drive/v3/changes/startPageToken":
+		json.NewEncoder(w).Encode(map[string]string{"startPageToken": strconv.Itoa(f.changes)})
+	case r.Method == http.MethodGet && id == "":
+		f.list(w, r)
+	case r.Method == http.MethodGet:
+		file, ok := f.files[id]
+		if !ok {
+			http.Error(w, `{"error":{"message":"not found"}}`, http.StatusNotFound)
+			return
+		}
+		w.Write(file.data)
+	case r.Method == http.MethodPost && !upload:
+		var meta struct {
+			Name     string   `json:"name"`
+			MimeType string   `json:"mimeType"`
+			Parents  []string `json:"parents"`
+		}
+		json.NewDecoder(r.Body).Decode(&meta)
+		newID := f.create(&fakeFile{name: meta.Name, mimeType: meta.MimeType, parents: meta.Parents})
+		json.NewEncoder(w).Encode(map[string]string{"id": newID})
+	case upload && (r.Method == http.MethodPost || r.Method == http.MethodPatch):
+		f.upload(w, r, id)
+	case r.Method == http.MethodDelete:
+		if _, ok := f.files[id]; !ok {
+			http.Error(w, `{"error":{"message":"not found"}}`, http.StatusNotFound)
+			return
+		}
+		delete(f.files, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func (f *fakeDrive) create(file *fakeFile) string {
+	f.nextID++
+	id := fmt.Sprintf("file%d", f.nextID)
+	file.version = 1
+	f.files[id] = file
+	return id
+}
+
+func (f *fakeDrive) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	parent := parentQuery.FindStringSubmatch(q)
+	name := nameQuery.FindStringSubmatch(q)
+
+	files := []map[string]interface{}{}
+	for id, file := range f.files {
+		if parent == nil || len(file.parents) == 0 || file.parents[0] != parent[1] {
+			continue
+		}
+		if name != nil && file.name != name[1] {
+			continue
+		}
+		if strings.Contains(q, "mimeType") && file.mimeType != driveFolderType {
+			continue
+		}
+		files = append(files, map[string]interface{}{
+			"id":            id,
+			"name":          file.name,
+			"version":       strconv.FormatInt(file.version, 10),
+			"appProperties": file.appProperties,
+		})
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"files": files})
+}
+
+func (f *fakeDrive) upload(w http.ResponseWriter, r *http.Request, id string) {
+	if f.failAfter > 0 {
+		f.failAfter--
+		if f.failAfter == 0 {
+			f.down = true
+		}
+	}
+
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		http.Error(w, "bad content type", http.StatusBadRequest)
+		return
+	}
+	mr := multipart.NewReader(r.Body, params["boundary"])
+
+	var meta struct {
+		Name          string            `json:"name"`
+		Parents       []string          `json:"parents"`
+		AppProperties map[string]string `json:"appProperties"`
+	}
+	part, err := mr.NextPart()
+	if err != nil {
+		http.Error(w, "missing metadata", http.StatusBadRequest)
+		return
+	}
+	json.NewDecoder(part).Decode(&meta)
+	part, err = mr.NextPart()
+	if err != nil {
+		http.Error(w, "missing media", http.StatusBadRequest)
+		return
+	}
+	data, _ := io.ReadAll(part)
+
+	file, ok := f.files[id]
+	switch {
+	case id == "":
+		id = f.create(&fakeFile{name: meta.Name, parents: meta.Parents, appProperties: meta.AppProperties, data: data})
+		file = f.files[id]
+	case !ok:
+		http.Error(w, `{"error":{"message":"not found"}}`, http.StatusNotFound)
+		return
+	default:
+		file.data = data
+		file.appProperties = meta.AppProperties
+		file.version++
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":            id,
+		"name":          file.name,
+		"version":       strconv.FormatInt(file.version, 10),
+		"appProperties": file.appProperties,
+	})
+}
+
+func TestDriveFiles(t *testing.T) {
+	fake := newFakeDrive(t)
+	drive := fake.client("ws1")
+	ctx := context.Background()
+
+	files, err := drive.List(ctx)
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("List() = %v, want no files", files)
+	}
+
+	cursor, err := drive.Cursor(ctx)
+	if err != nil {
+		t.Fatalf("Cursor() failed: %v", err)
+	}
+
+	created, err := drive.Put(ctx, "notes/a.md", []byte("first"), nil)
+	if err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+	updated, err := drive.Put(ctx, "notes/a.md", []byte("second"), created)
+	if err != nil {
+		t.Fatalf("Put() of a new version failed: %v", err)
+	}
+	if updated.ID != created.ID || updated.Revision == created.Revision {
+		t.Errorf("Put() = %+v after %+v, want the same file at a new revision", updated, created)
+	}
+	if next, _ := drive.Cursor(ctx); next == cursor {
+		t.Error("Cursor() did not move after a write")
+	}
+
+	files, err = drive.List(ctx)
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Path != "notes/a.md" || files[0].Hash != hashOf([]byte("second")) || files[0].Revision != updated.Revision {
+		t.Errorf("List() = %+v", files)
+	}
+
+	data, err := drive.Get(ctx, created.ID)
+	if err != nil || string(data) != "second" {
+		t.Errorf("Get() = %q, %v", data, err)
+	}
+
+	if err := drive.Delete(ctx, created.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if err := drive.Delete(ctx, created.ID); err != nil {
+		t.Errorf("Delete() of a deleted file failed: %v", err)
+	}
+
+	// Another client for the workspace finds the same folder rather than
+	// making its own, while other workspaces stay apart
+	drive.Put(ctx, "notes/b.md", []byte("third"), nil)
+	if files, _ := fake.client("ws1").List(ctx); len(files) != 1 {
+		t.Errorf("second client listed %d files, want 1", len(files))
+	}
+	if files, _ := fake.client("ws2").List(ctx); len(files) != 0 {
+		t.Errorf("other workspace listed %d files, want 0", len(files))
+	}
+
+	fake.setDown(true)
+	if _, err := drive.List(ctx); err == nil {
+		t.Error("List() succeeded while Drive was down")
+	}
+}
@@ -0,0 +1,624 @@
+// Package sync keeps a workspace's note files and attachments in step with a
+// copy kept elsewhere: a Google Drive folder, a WebDAV server, an S3 bucket
+// or a local folder.
+package sync
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"fuknotion/backend/internal/attachment"
+	"fuknotion/backend/internal/database"
+	"fuknotion/backend/internal/filesystem"
+	"fuknotion/backend/internal/note"
+)
+
+// syncedDirs are the workspace directories kept in sync. Hidden directories
+// such as the trash and templates stay on this device.
+var syncedDirs = []string{"notes", attachment.Dir}
+
+var attachmentPathPattern = regexp.MustCompile(`^` + attachment.Dir + `/[0-9a-f]{2}/[0-9a-f]{64}$`)
+
+// Engine syncs a workspace with a SyncBackend. What each file looked like when
+// last synced is kept in the sync_files table, so a run compares three
+// versions of every file: local, remote and last synced. Changes made while
+// offline are picked up by the next run, and a run cut short resumes where it
+// stopped.
+type Engine struct {
+	mu          gosync.Mutex // one run at a time
+	db          *database.Database
+	fs          *filesystem.FileSystem
+	notes       *note.Service
+	attachments *attachment.Store
+	now         func() time.Time
+}
+
+// NewEngine creates a sync engine for a workspace
+func NewEngine(db *database.Database, fs *filesystem.FileSystem, notes *note.Service, attachments *attachment.Store) *Engine {
+	return &Engine{db: db, fs: fs, notes: notes, attachments: attachments, now: time.Now}
+}
+
+// Conflict is a note changed both here and on the remote since the last sync
+// in ways that did not merge. Fields both changed keep the local value; if
+// the content clashed, the local version stays in place and the remote one is
+// kept as a copy.
+type Conflict struct {
+	Path   string               `json:"path"`
+	NoteID string               `json:"noteId"`
+	CopyID string               `json:"copyId,omitempty"` // the note holding the remote version
+	Title  string               `json:"title"`
+	Hunks  []note.MergeConflict `json:"hunks,omitempty"`
+}
+
+// Issue is a file a run could not sync
+type Issue struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// Report summarizes a sync run
+type Report struct {
+	Uploaded      int                `json:"uploaded"`
+	Downloaded    int                `json:"downloaded"`
+	DeletedRemote int                `json:"deletedRemote"`
+	DeletedLocal  int                `json:"deletedLocal"`
+	Conflicts     []Conflict         `json:"conflicts"`
+	Changes       []*note.FileChange `json:"changes"` // notes the run changed here
+	Failed        []Issue            `json:"failed"`
+}
+
+// Status describes a workspace's sync state without contacting the remote
+type Status struct {
+	Pending  int        `json:"pending"` // local changes not yet synced
+	LastSync *time.Time `json:"lastSync,omitempty"`
+}
+
+// fileState is a file as it was when last synced
+type fileState struct {
+	RemoteID string
+	Revision string
+	Hash     string
+	Size     int64
+	ModTime  time.Time
+	Base     []byte // a note's content, to merge edits made since from
+}
+
+// localFile is a file as it is now in the workspace
+type localFile struct {
+	Hash    string
+	Size    int64
+	ModTime time.Time
+}
+
+// remoteError marks an error talking to the remote. It ends the run, since
+// the remote is likely unreachable, where a local error only skips a file.
+type remoteError struct{ err error }
+
+func (e *remoteError) Error() string { return e.err.Error() }
+func (e *remoteError) Unwrap() error { return e.err }
+
+// Run syncs the workspace with backend b. It returns what was done so far
+// along with an error if the backend could not be reached part way through;
+// the next run carries on from there.
+func (e *Engine) Run(ctx context.Context, b SyncBackend) (*Report, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.useLocation(b.Location()); err != nil {
+		return nil, err
+	}
+	state, err := e.loadState()
+	if err != nil {
+		return nil, err
+	}
+	local, err := e.scanLocal(state)
+	if err != nil {
+		return nil, err
+	}
+
+	// Taken before listing, so changes made while we run show up next time
+	cursor, err := b.Cursor(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach sync backend: %w", err)
+	}
+	var files []RemoteFile
+	if last, err := e.meta("cursor"); err != nil {
+		return nil, err
+	} else if cursor != "" && cursor == last {
+		// Nothing changed remotely since the last complete run, which left
+		// the state matching the remote
+		for p, s := range state {
+			files = append(files, RemoteFile{ID: s.RemoteID, Path: p, Revision: s.Revision, Hash: s.Hash})
+		}
+	} else if files, err = b.List(ctx); err != nil {
+		return nil, fmt.Errorf("failed to list remote files: %w", err)
+	}
+
+	remoteByPath := make(map[string]*RemoteFile)
+	for i := range files {
+		f := &files[i]
+		if !syncable(f.Path) {
+			continue
+		}
+		// Prefer the copy we synced before if a path turns up twice
+		if existing, ok := remoteByPath[f.Path]; ok && state[f.Path] != nil && existing.ID == state[f.Path].RemoteID {
+			continue
+		}
+		remoteByPath[f.Path] = f
+	}
+
+	paths := make(map[string]bool)
+	for p := range local {
+		paths[p] = true
+	}
+	for p := range remoteByPath {
+		paths[p] = true
+	}
+	for p := range state {
+		paths[p] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	// Attachments sort before notes, so notes arrive after the images they embed
+	sort.Strings(sorted)
+
+	report := &Report{Conflicts: []Conflict{}, Changes: []*note.FileChange{}, Failed: []Issue{}}
+	for _, p := range sorted {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		err := e.syncPath(ctx, b, p, local[p], remoteByPath[p], state[p], report)
+		var re *remoteError
+		if errors.As(err, &re) {
+			return report, err
+		}
+		if err != nil {
+			report.Failed = append(report.Failed, Issue{Path: p, Error: err.Error()})
+		}
+	}
+
+	// A file that failed may have changed remotely; list again next time
+	if len(report.Failed) > 0 {
+		cursor = ""
+	}
+	if err := e.setMeta("cursor", cursor); err != nil {
+		return report, err
+	}
+	if err := e.setMeta("last_sync", e.now().UTC().Format(time.RFC3339)); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// useLocation discards the sync state when the workspace has moved to a
+// different backend location. State from before locations were recorded is
+// kept.
+func (e *Engine) useLocation(location string) error {
+	last, err := e.meta("location")
+	if err != nil || last == location {
+		return err
+	}
+
+	if last != "" {
+		err := e.db.Transaction(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(`DELETE FROM sync_files`); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`DELETE FROM sync_meta WHERE key = 'cursor'`)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to reset sync state: %w", err)
+		}
+	}
+	return e.setMeta("location", location)
+}
+
+// Status reports how many local changes are waiting to be synced
+func (e *Engine) Status() (*Status, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	state, err := e.loadState()
+	if err != nil {
+		return nil, err
+	}
+	local, err := e.scanLocal(state)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{}
+	for p, l := range local {
+		if s := state[p]; s == nil || s.Hash != l.Hash {
+			status.Pending++
+		}
+	}
+	for p := range state {
+		if local[p] == nil {
+			status.Pending++
+		}
+	}
+
+	if value, err := e.meta("last_sync"); err != nil {
+		return nil, err
+	} else if t, err := time.Parse(time.RFC3339, value); err == nil {
+		status.LastSync = &t
+	}
+	return status, nil
+}
+
+// syncPath brings one file in step. l, r and s are the local, remote and
+// last synced versions; any of them may be nil.
+func (e *Engine) syncPath(ctx context.Context, b SyncBackend, p string, l *localFile, r *RemoteFile, s *fileState, report *Report) error {
+	if s == nil {
+		switch {
+		case l != nil && r == nil:
+			return e.upload(ctx, b, p, nil, report)
+		case l == nil && r != nil:
+			return e.download(ctx, b, p, r, report)
+		case l != nil && r != nil:
+			if r.Hash == l.Hash {
+				return e.saveState(p, r, l.Hash)
+			}
+			return e.resolveConflict(ctx, b, p, l, r, nil, report)
+		}
+		return nil
+	}
+
+	localChanged := l == nil || l.Hash != s.Hash
+	remoteChanged := r == nil || r.ID != s.RemoteID || r.Revision != s.Revision
+
+	switch {
+	case !localChanged && !remoteChanged:
+		return nil
+
+	case localChanged && !remoteChanged:
+		if l == nil {
+			if err := b.Delete(ctx, s.RemoteID); err != nil {
+				return &remoteError{fmt.Errorf("failed to delete %s: %w", p, err)}
+			}
+			report.DeletedRemote++
+			return e.deleteState(p)
+		}
+		return e.upload(ctx, b, p, r, report)
+
+	case !localChanged && remoteChanged:
+		if r == nil {
+			return e.deleteLocal(ctx, b, p, report)
+		}
+		return e.download(ctx, b, p, r, report)
+	}
+
+	// Changed on both sides. An edit wins over a deletion.
+	switch {
+	case l == nil && r == nil:
+		return e.deleteState(p)
+	case l == nil:
+		return e.download(ctx, b, p, r, report)
+	case r == nil:
+		return e.upload(ctx, b, p, nil, report)
+	case r.Hash == l.Hash:
+		return e.saveState(p, r, l.Hash)
+	}
+	return e.resolveConflict(ctx, b, p, l, r, s, report)
+}
+
+// upload sends the local file at p, replacing prev if set
+func (e *Engine) upload(ctx context.Context, b SyncBackend, p string, prev *RemoteFile, report *Report) error {
+	rel := filepath.FromSlash(p)
+
+	// Stat before reading: if the file changes in between, the next run sees
+	// a newer mtime and checks it again
+	info, err := e.fs.Stat(rel)
+	if err != nil {
+		return err
+	}
+	data, err := e.fs.ReadFile(rel)
+	if err != nil {
+		return err
+	}
+
+	f, err := b.Put(ctx, p, data, prev)
+	if errors.Is(err, ErrChanged) {
+		// Someone wrote it after we listed; the next run sorts it out
+		return err
+	}
+	if err != nil {
+		return &remoteError{fmt.Errorf("failed to upload %s: %w", p, err)}
+	}
+	report.Uploaded++
+	return e.saveLocalState(p, f, hashOf(data), baseOf(p, data), info.Size(), info.ModTime())
+}
+
+// download fetches the remote file r and applies it at p
+func (e *Engine) download(ctx context.Context, b SyncBackend, p string, r *RemoteFile, report *Report) error {
+	data, err := b.Get(ctx, r.ID)
+	if err != nil {
+		return &remoteError{fmt.Errorf("failed to download %s: %w", p, err)}
+	}
+	hash := hashOf(data)
+	if r.Hash != "" && r.Hash != hash {
+		return fmt.Errorf("downloaded content does not match its hash")
+	}
+
+	if isAttachment(p) {
+		if path.Base(p) != hash {
+			return fmt.Errorf("attachment content does not match its name")
+		}
+		if _, err := e.attachments.Save(path.Base(p), data); err != nil {
+			return err
+		}
+	} else {
+		change, err := e.notes.ApplyFile(filepath.FromSlash(p), data)
+		if err != nil {
+			return err
+		}
+		report.Changes = append(report.Changes, change)
+	}
+
+	report.Downloaded++
+	return e.saveState(p, r, hash)
+}
+
+// deleteLocal removes a file deleted on the remote. Notes go to the trash.
+// Attachments a note still embeds are kept and uploaded again.
+func (e *Engine) deleteLocal(ctx context.Context, b SyncBackend, p string, report *Report) error {
+	rel := filepath.FromSlash(p)
+
+	if isAttachment(p) {
+		removed, err := e.attachments.Remove(path.Base(p))
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return e.upload(ctx, b, p, nil, report)
+		}
+	} else {
+		change, err := e.notes.TrashFile(rel)
+		if err != nil {
+			return err
+		}
+		if change != nil {
+			report.Changes = append(report.Changes, change)
+		} else if e.fs.FileExists(rel) {
+			// Never indexed, e.g. a file that does not parse
+			if err := e.fs.DeleteFile(rel); err != nil {
+				return err
+			}
+		}
+	}
+
+	report.DeletedLocal++
+	return e.deleteState(p)
+}
+
+// resolveConflict handles a file whose local and remote versions both differ
+// from s, the last synced version, or that has none. A note's versions are
+// merged from s; what does not merge keeps the local version, and the remote
+// one becomes a conflict copy. The result is uploaded over the remote.
+func (e *Engine) resolveConflict(ctx context.Context, b SyncBackend, p string, l *localFile, r *RemoteFile, s *fileState, report *Report) error {
+	if isAttachment(p) {
+		// Same name means same content; a mismatch is a damaged copy
+		return e.upload(ctx, b, p, r, report)
+	}
+
+	data, err := b.Get(ctx, r.ID)
+	if err != nil {
+		return &remoteError{fmt.Errorf("failed to download %s: %w", p, err)}
+	}
+	if hashOf(data) == l.Hash {
+		return e.saveState(p, r, l.Hash)
+	}
+
+	rel := filepath.FromSlash(p)
+	ours, err := e.fs.ReadFile(rel)
+	if err != nil {
+		return err
+	}
+	var base []byte
+	if s != nil {
+		base = s.Base
+	}
+	label := "conflict copy " + e.now().Format("2006-01-02 15:04")
+	result, err := e.notes.Reconcile(base, ours, data, label)
+	if err != nil {
+		return fmt.Errorf("failed to merge remote version: %w", err)
+	}
+
+	if !bytes.Equal(result.Data, ours) {
+		change, err := e.notes.ApplyFile(rel, result.Data)
+		if err != nil {
+			return err
+		}
+		report.Changes = append(report.Changes, change)
+	}
+	if result.Copy != nil || len(result.Conflicts) > 0 {
+		conflict := Conflict{
+			Path:   p,
+			NoteID: strings.TrimSuffix(path.Base(p), path.Ext(p)),
+			Hunks:  result.Conflicts,
+		}
+		if fm, _, err := note.ParseMarkdown(string(result.Data)); err == nil {
+			conflict.Title = fm.Title
+		}
+		if copied := result.Copy; copied != nil {
+			conflict.CopyID, conflict.Title = copied.ID, copied.Title
+			report.Changes = append(report.Changes, &note.FileChange{
+				NoteID: copied.ID,
+				Title:  copied.Title,
+				Path:   copied.FilePath,
+				Kind:   note.FileCreated,
+			})
+		}
+		report.Conflicts = append(report.Conflicts, conflict)
+	}
+
+	return e.upload(ctx, b, p, r, report)
+}
+
+// scanLocal hashes the workspace's synced files. Files whose size and mtime
+// match the last sync reuse the hash recorded then.
+func (e *Engine) scanLocal(state map[string]*fileState) (map[string]*localFile, error) {
+	local := make(map[string]*localFile)
+	for _, dir := range syncedDirs {
+		files, err := e.fs.WalkFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rel := range files {
+			p := filepath.ToSlash(rel)
+			if !syncable(p) {
+				continue
+			}
+			info, err := e.fs.Stat(rel)
+			if err != nil {
+				continue
+			}
+
+			f := &localFile{Size: info.Size(), ModTime: info.ModTime()}
+			if s := state[p]; s != nil && s.Size == f.Size && s.ModTime.Equal(f.ModTime) {
+				f.Hash = s.Hash
+			} else {
+				data, err := e.fs.ReadFile(rel)
+				if err != nil {
+					continue
+				}
+				f.Hash = hashOf(data)
+			}
+			local[p] = f
+		}
+	}
+	return local, nil
+}
+
+func (e *Engine) loadState() (map[string]*fileState, error) {
+	rows, err := e.db.Query(`SELECT path, remote_id, revision, hash, size, mod_time, base FROM sync_files`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sync state: %w", err)
+	}
+	defer rows.Close()
+
+	state := make(map[string]*fileState)
+	for rows.Next() {
+		var p string
+		var s fileState
+		if err := rows.Scan(&p, &s.RemoteID, &s.Revision, &s.Hash, &s.Size, &s.ModTime, &s.Base); err != nil {
+			return nil, fmt.Errorf("failed to scan sync state: %w", err)
+		}
+		state[p] = &s
+	}
+	return state, rows.Err()
+}
+
+// saveState records p as synced at remote revision r, reading the local
+// file from disk
+func (e *Engine) saveState(p string, r *RemoteFile, hash string) error {
+	rel := filepath.FromSlash(p)
+	info, err := e.fs.Stat(rel)
+	if err != nil {
+		return err
+	}
+	var base []byte
+	if !isAttachment(p) {
+		data, err := e.fs.ReadFile(rel)
+		if err != nil {
+			return err
+		}
+		// Edited since; with no base, a conflict keeps both versions
+		if hashOf(data) == hash {
+			base = data
+		}
+	}
+	return e.saveLocalState(p, r, hash, base, info.Size(), info.ModTime())
+}
+
+func (e *Engine) saveLocalState(p string, r *RemoteFile, hash string, base []byte, size int64, modTime time.Time) error {
+	query := `
+		INSERT INTO sync_files (path, remote_id, revision, hash, size, mod_time, base, synced_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(path) DO UPDATE SET
+			remote_id = excluded.remote_id,
+			revision = excluded.revision,
+			hash = excluded.hash,
+			size = excluded.size,
+			mod_time = excluded.mod_time,
+			base = excluded.base,
+			synced_at = excluded.synced_at
+	`
+	if _, err := e.db.Exec(query, p, r.ID, r.Revision, hash, size, modTime, base, e.now()); err != nil {
+		return fmt.Errorf("failed to save sync state: %w", err)
+	}
+	return nil
+}
+
+func (e *Engine) deleteState(p string) error {
+	if _, err := e.db.Exec(`DELETE FROM sync_files WHERE path = ?`, p); err != nil {
+		return fmt.Errorf("failed to clear sync state: %w", err)
+	}
+	return nil
+}
+
+func (e *Engine) meta(key string) (string, error) {
+	var value string
+	err := e.db.QueryRow(`SELECT value FROM sync_meta WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read sync setting: %w", err)
+	}
+	return value, nil
+}
+
+func (e *Engine) setMeta(key, value string) error {
+	query := `INSERT INTO sync_meta (key, value) VALUES (?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value`
+	if _, err := e.db.Exec(query, key, value); err != nil {
+		return fmt.Errorf("failed to save sync setting: %w", err)
+	}
+	return nil
+}
+
+// syncable reports whether p, a slash-separated path, is a file we sync.
+// Paths from the remote are checked too, so it cannot write elsewhere.
+func syncable(p string) bool {
+	if isAttachment(p) {
+		return true
+	}
+	return strings.HasPrefix(p, "notes/") && strings.EqualFold(path.Ext(p), ".md") &&
+		path.Clean(p) == p && !strings.Contains(p, "/.")
+}
+
+// baseOf is what to keep of the file at p to merge later edits from: the
+// content of a note, nothing of an attachment
+func baseOf(p string, data []byte) []byte {
+	if isAttachment(p) {
+		return nil
+	}
+	return data
+}
+
+func isAttachment(p string) bool {
+	return attachmentPathPattern.MatchString(p)
+}
+
+func hashOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,292 @@
+package sync
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"fuknotion/backend/internal/attachment"
+	"fuknotion/backend/internal/database"
+	"fuknotion/backend/internal/filesystem"
+	"fuknotion/backend/internal/note"
+)
+
+// device is one copy of a workspace, as open on one machine
+type device struct {
+	notes       *note.Service
+	attachments *attachment.Store
+	engine      *Engine
+	backend     SyncBackend
+}
+
+func newDevice(t *testing.T, backend SyncBackend) *device {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+
+	db, err := database.InitWorkspaceDB(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	fs, err := filesystem.NewFileSystem(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize filesystem: %v", err)
+	}
+
+	notes := note.NewService(db, fs)
+	attachments := attachment.NewStore(db, fs)
+	return &device{
+		notes:       notes,
+		attachments: attachments,
+		engine:      NewEngine(db, fs, notes, attachments),
+		backend:     backend,
+	}
+}
+
+func (d *device) sync(t *testing.T) *Report {
+	t.Helper()
+
+	report, err := d.engine.Run(context.Background(), d.backend)
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	if len(report.Failed) > 0 {
+		t.Fatalf("Run() failed files: %+v", report.Failed)
+	}
+	return report
+}
+
+func TestSyncBetweenDevices(t *testing.T) {
+	fake := newFakeDrive(t)
+	laptop, desktop := newDevice(t, fake.client("shared")), newDevice(t, fake.client("shared"))
+
+	image, err := laptop.attachments.Save("chart.png", []byte("\x89PNG\r\n\x1a\n chart"))
+	if err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	created, err := laptop.notes.CreateNote("Plan", "See ![chart]("+image.URL+")", "")
+	if err != nil {
+		t.Fatalf("CreateNote() failed: %v", err)
+	}
+
+	if report := laptop.sync(t); report.Uploaded != 2 {
+		t.Errorf("first run uploaded %d files, want 2", report.Uploaded)
+	}
+	if report := laptop.sync(t); report.Uploaded != 0 || report.Downloaded != 0 {
+		t.Errorf("second run = %+v, want nothing to do", report)
+	}
+
+	report := desktop.sync(t)
+	if report.Downloaded != 2 || len(report.Changes) != 1 || report.Changes[0].NoteID != created.ID {
+		t.Errorf("desktop run = %+v, want the note and its attachment", report)
+	}
+	got, err := desktop.notes.GetNote(created.ID)
+	if err != nil {
+		t.Fatalf("GetNote() on desktop failed: %v", err)
+	}
+	if got.Title != "Plan" || got.Content != created.Content {
+		t.Errorf("desktop note = %q %q", got.Title, got.Content)
+	}
+	if listed, _ := desktop.attachments.ListForNote(created.ID); len(listed) != 1 {
+		t.Errorf("desktop note has %d attachments, want 1", len(listed))
+	}
+
+	// An edit travels back
+	if err := desktop.notes.UpdateNote(created.ID, "Plan", "Revised", false); err != nil {
+		t.Fatalf("UpdateNote() failed: %v", err)
+	}
+	if report := desktop.sync(t); report.Uploaded != 1 {
+		t.Errorf("desktop run uploaded %d files, want 1", report.Uploaded)
+	}
+	if report := laptop.sync(t); report.Downloaded != 1 {
+		t.Errorf("laptop run downloaded %d files, want 1", report.Downloaded)
+	}
+	if got, _ := laptop.notes.GetNote(created.ID); got == nil || got.Content != "Revised" {
+		t.Errorf("laptop note = %+v, want the desktop edit", got)
+	}
+
+	// A deletion sends the note to the other device's trash
+	if err := laptop.notes.DeleteNote(created.ID); err != nil {
+		t.Fatalf("DeleteNote() failed: %v", err)
+	}
+	if report := laptop.sync(t); report.DeletedRemote != 1 {
+		t.Errorf("laptop run deleted %d remote files, want 1", report.DeletedRemote)
+	}
+	if report := desktop.sync(t); report.DeletedLocal != 1 {
+		t.Errorf("desktop run deleted %d local files, want 1", report.DeletedLocal)
+	}
+	if trash, _ := desktop.notes.ListTrash(); len(trash) != 1 || trash[0].ID != created.ID {
+		t.Errorf("desktop trash = %+v, want the deleted note", trash)
+	}
+}
+
+func TestSyncConflictKeepsBothVersions(t *testing.T) {
+	fake := newFakeDrive(t)
+	laptop, desktop := newDevice(t, fake.client("shared")), newDevice(t, fake.client("shared"))
+
+	created, _ := laptop.notes.CreateNote("Plan", "Original", "")
+	laptop.sync(t)
+	desktop.sync(t)
+
+	laptop.notes.UpdateNote(created.ID, "Plan", "Laptop edit", false)
+	desktop.notes.UpdateNote(created.ID, "Plan", "Desktop edit", false)
+	laptop.sync(t)
+
+	report := desktop.sync(t)
+	if len(report.Conflicts) != 1 {
+		t.Fatalf("Conflicts = %+v, want 1", report.Conflicts)
+	}
+	conflict := report.Conflicts[0]
+	if conflict.NoteID != created.ID || !strings.HasPrefix(conflict.Title, "Plan (conflict copy ") {
+		t.Errorf("conflict = %+v", conflict)
+	}
+
+	if got, _ := desktop.notes.GetNote(created.ID); got == nil || got.Content != "Desktop edit" {
+		t.Errorf("local version = %+v, want the desktop edit kept", got)
+	}
+	if copied, _ := desktop.notes.GetNote(conflict.CopyID); copied == nil || copied.Content != "Laptop edit" {
+		t.Errorf("conflict copy = %+v, want the laptop edit", copied)
+	}
+
+	// The desktop version and the copy reach the laptop
+	desktop.sync(t)
+	laptop.sync(t)
+	if got, _ := laptop.notes.GetNote(created.ID); got == nil || got.Content != "Desktop edit" {
+		t.Errorf("laptop note = %+v, want the desktop edit", got)
+	}
+	if _, err := laptop.notes.GetNote(conflict.CopyID); err != nil {
+		t.Errorf("conflict copy did not reach the laptop: %v", err)
+	}
+}
+
+func TestSyncMergesEditsToDifferentParts(t *testing.T) {
+	fake := newFakeDrive(t)
+	laptop, desktop := newDevice(t, fake.client("shared")), newDevice(t, fake.client("shared"))
+
+	created, _ := laptop.notes.CreateNote("Plan", "One\n\nTwo\n\nThree", "")
+	laptop.sync(t)
+	desktop.sync(t)
+
+	laptop.notes.UpdateNote(created.ID, "Plan", "One (laptop)\n\nTwo\n\nThree", false)
+	desktop.notes.UpdateNote(created.ID, "Plan", "One\n\nTwo\n\nThree (desktop)", false)
+	laptop.sync(t)
+
+	report := desktop.sync(t)
+	if len(report.Conflicts) != 0 {
+		t.Errorf("Conflicts = %+v, want none", report.Conflicts)
+	}
+	desktop.sync(t)
+	laptop.sync(t)
+
+	for _, d := range []*device{laptop, desktop} {
+		got, _ := d.notes.GetNote(created.ID)
+		if got == nil || got.Content != "One (laptop)\n\nTwo\n\nThree (desktop)" {
+			t.Errorf("note = %+v, want both edits", got)
+		}
+		if notes, _ := d.notes.ListNotes(""); len(notes) != 1 {
+			t.Errorf("notes = %d, want no conflict copy", len(notes))
+		}
+	}
+}
+
+func TestSyncResumesAfterGoingOffline(t *testing.T) {
+	fake := newFakeDrive(t)
+	drive := fake.client("shared")
+	laptop := newDevice(t, drive)
+
+	laptop.notes.CreateNote("One", "1", "")
+	laptop.notes.CreateNote("Two", "2", "")
+
+	status, err := laptop.engine.Status()
+	if err != nil {
+		t.Fatalf("Status() failed: %v", err)
+	}
+	if status.Pending != 2 || status.LastSync != nil {
+		t.Errorf("Status() = %+v, want 2 pending and no sync yet", status)
+	}
+
+	// The connection drops after the first upload
+	drive.folder(context.Background())
+	fake.mu.Lock()
+	fake.failAfter = 1
+	fake.mu.Unlock()
+
+	report, err := laptop.engine.Run(context.Background(), drive)
+	if err == nil {
+		t.Fatal("Run() succeeded while offline")
+	}
+	if report == nil || report.Uploaded != 1 {
+		t.Errorf("interrupted run = %+v, want 1 upload", report)
+	}
+	if status, _ := laptop.engine.Status(); status.Pending != 1 {
+		t.Errorf("Pending = %d after the interrupted run, want 1", status.Pending)
+	}
+
+	fake.setDown(false)
+	if report := laptop.sync(t); report.Uploaded != 1 {
+		t.Errorf("resumed run uploaded %d files, want 1", report.Uploaded)
+	}
+	status, _ = laptop.engine.Status()
+	if status.Pending != 0 || status.LastSync == nil {
+		t.Errorf("Status() = %+v, want nothing pending", status)
+	}
+}
+
+func TestSyncThroughFolder(t *testing.T) {
+	shared := t.TempDir()
+	newFolder := func() *Folder {
+		folder, err := NewFolder(shared)
+		if err != nil {
+			t.Fatalf("NewFolder() failed: %v", err)
+		}
+		return folder
+	}
+	laptop, desktop := newDevice(t, newFolder()), newDevice(t, newFolder())
+
+	created, _ := laptop.notes.CreateNote("Plan", "Original", "")
+	laptop.sync(t)
+	if report := desktop.sync(t); report.Downloaded != 1 {
+		t.Errorf("desktop run downloaded %d files, want 1", report.Downloaded)
+	}
+
+	desktop.notes.UpdateNote(created.ID, "Plan", "Desktop edit", false)
+	desktop.sync(t)
+	laptop.sync(t)
+	if got, _ := laptop.notes.GetNote(created.ID); got == nil || got.Content != "Desktop edit" {
+		t.Errorf("laptop note = %+v, want the desktop edit", got)
+	}
+
+	// Edits on both sides keep both versions
+	laptop.notes.UpdateNote(created.ID, "Plan", "Laptop edit", false)
+	desktop.notes.UpdateNote(created.ID, "Plan", "Desktop again", false)
+	laptop.sync(t)
+	if report := desktop.sync(t); len(report.Conflicts) != 1 {
+		t.Errorf("Conflicts = %+v, want 1", report.Conflicts)
+	}
+}
+
+func TestSyncLocationChangeStartsOver(t *testing.T) {
+	fake := newFakeDrive(t)
+	laptop := newDevice(t, fake.client("shared"))
+
+	laptop.notes.CreateNote("Plan", "Original", "")
+	laptop.sync(t)
+
+	folder, err := NewFolder(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFolder() failed: %v", err)
+	}
+	laptop.backend = folder
+
+	// State recorded against Drive says nothing about the folder, so the note
+	// is uploaded again rather than deleted locally as gone from the remote
+	report := laptop.sync(t)
+	if report.Uploaded != 1 || report.DeletedLocal != 0 {
+		t.Errorf("run against the new location = %+v, want 1 upload", report)
+	}
+	if notes, _ := laptop.notes.ListNotes(""); len(notes) != 1 {
+		t.Errorf("ListNotes() = %d notes, want 1", len(notes))
+	}
+}
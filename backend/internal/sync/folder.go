@@ -0,0 +1,160 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Folder mirrors a workspace into a local folder, for setups where another
+// tool such as Syncthing or Dropbox carries the folder between machines
+type Folder struct {
+	root string
+}
+
+// NewFolder creates a backend mirroring into root, creating it if needed
+func NewFolder(root string) (*Folder, error) {
+	if !filepath.IsAbs(root) {
+		return nil, fmt.Errorf("sync folder must be an absolute path: %q", root)
+	}
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create sync folder: %w", err)
+	}
+	return &Folder{root: filepath.Clean(root)}, nil
+}
+
+// List lists the folder's files. Hidden files and directories, such as
+// Syncthing's .stfolder or partial downloads, are skipped.
+func (f *Folder) List(ctx context.Context) ([]RemoteFile, error) {
+	var files []RemoteFile
+	err := filepath.WalkDir(f.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == f.root {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(f.root, p)
+		if err != nil {
+			return err
+		}
+		id := filepath.ToSlash(rel)
+		files = append(files, RemoteFile{ID: id, Path: id, Revision: revisionOf(info)})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sync folder: %w", err)
+	}
+	return files, nil
+}
+
+// Get reads a file's content
+func (f *Folder) Get(ctx context.Context, id string) ([]byte, error) {
+	full, err := f.resolve(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(full)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", id, err)
+	}
+	return data, nil
+}
+
+// Put writes the file at p through a temporary file, so tools watching the
+// folder never pick up half a file
+func (f *Folder) Put(ctx context.Context, p string, data []byte, prev *RemoteFile) (*RemoteFile, error) {
+	full, err := f.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(full)
+	switch {
+	case err == nil && (prev == nil || revisionOf(info) != prev.Revision):
+		return nil, ErrChanged
+	case err != nil && !os.IsNotExist(err):
+		return nil, fmt.Errorf("failed to check %s: %w", p, err)
+	case err != nil && prev != nil:
+		return nil, ErrChanged
+	}
+
+	if err := os.MkdirAll(filepath.Dir(full), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(full), "."+filepath.Base(full)+".tmp*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", p, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to write %s: %w", p, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", p, err)
+	}
+	if err := os.Rename(tmp.Name(), full); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", p, err)
+	}
+
+	if info, err = os.Stat(full); err != nil {
+		return nil, err
+	}
+	return &RemoteFile{ID: p, Path: p, Revision: revisionOf(info), Hash: hashOf(data)}, nil
+}
+
+// Delete deletes a file
+func (f *Folder) Delete(ctx context.Context, id string) error {
+	full, err := f.resolve(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(full); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete %s: %w", id, err)
+	}
+	return nil
+}
+
+// Cursor returns "": directory times do not change when a file's content
+// does, so there is no cheap way to tell
+func (f *Folder) Cursor(ctx context.Context) (string, error) {
+	return "", nil
+}
+
+// Location identifies the folder
+func (f *Folder) Location() string {
+	return "file://" + filepath.ToSlash(f.root)
+}
+
+// resolve returns the full path of id, refusing paths outside the folder
+func (f *Folder) resolve(id string) (string, error) {
+	if id == "" || path.IsAbs(id) || path.Clean(id) != id || id == ".." || strings.HasPrefix(id, "../") {
+		return "", fmt.Errorf("invalid path: %q", id)
+	}
+	return filepath.Join(f.root, filepath.FromSlash(id)), nil
+}
+
+// revisionOf is a local file's revision: its mtime and size
+func revisionOf(info os.FileInfo) string {
+	return fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size())
+}
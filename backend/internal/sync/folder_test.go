@@ -0,0 +1,40 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFolder(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "Sync")
+	folder, err := NewFolder(root)
+	if err != nil {
+		t.Fatalf("NewFolder() failed: %v", err)
+	}
+	testBackend(t, folder)
+
+	ctx := context.Background()
+
+	// Hidden files, such as Syncthing's markers, are not listed
+	os.MkdirAll(filepath.Join(root, ".stfolder"), 0700)
+	os.WriteFile(filepath.Join(root, "notes", ".a.md.tmp"), []byte("partial"), 0600)
+	if files, _ := folder.List(ctx); len(files) != 1 {
+		t.Errorf("List() = %+v, want only the note", files)
+	}
+
+	// A file written by someone else since we listed it is not overwritten
+	files, _ := folder.List(ctx)
+	os.WriteFile(filepath.Join(root, "notes", "a.md"), []byte("edited in another app"), 0600)
+	if _, err := folder.Put(ctx, "notes/a.md", []byte("ours"), &files[0]); !errors.Is(err, ErrChanged) {
+		t.Errorf("Put() over a changed file = %v, want ErrChanged", err)
+	}
+
+	for _, bad := range []string{"../outside.md", "/etc/passwd", "notes/../../x"} {
+		if _, err := folder.Get(ctx, bad); err == nil {
+			t.Errorf("Get(%q) succeeded", bad)
+		}
+	}
+}
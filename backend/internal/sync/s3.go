@@ -0,0 +1,299 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config locates an S3-compatible bucket and holds the keys to sign in
+type S3Config struct {
+	Endpoint        string // e.g. https://s3.eu-west-1.amazonaws.com or http://localhost:9000
+	Region          string
+	Bucket          string
+	Prefix          string // key prefix the workspace is kept under
+	PathStyle       bool   // address the bucket in the path rather than the host name
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// S3 keeps a workspace's files as objects in an S3-compatible bucket, keyed
+// by their workspace paths under a prefix. Requests are signed with AWS
+// Signature Version 4.
+type S3 struct {
+	client *http.Client
+	config S3Config
+	now    func() time.Time
+}
+
+// NewS3 creates an S3 backend
+func NewS3(client *http.Client, config S3Config) (*S3, error) {
+	if err := checkURL(config.Endpoint); err != nil {
+		return nil, err
+	}
+	if config.Bucket == "" || config.Region == "" {
+		return nil, fmt.Errorf("bucket and region are required")
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+	config.Prefix = strings.Trim(config.Prefix, "/")
+	return &S3{client: client, config: config, now: time.Now}, nil
+}
+
+// List lists the objects under the prefix
+func (s *S3) List(ctx context.Context) ([]RemoteFile, error) {
+	prefix := joinKey(s.config.Prefix, "")
+	var files []RemoteFile
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Contents []struct {
+				Key  string `xml:"Key"`
+				ETag string `xml:"ETag"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode object list: %w", err)
+		}
+
+		for _, obj := range result.Contents {
+			p := strings.TrimPrefix(obj.Key, prefix)
+			if p == "" || strings.HasSuffix(p, "/") {
+				continue
+			}
+			files = append(files, RemoteFile{ID: obj.Key, Path: p, Revision: obj.ETag})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return files, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Get reads an object
+func (s *S3) Get(ctx context.Context, id string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, id, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", id, err)
+	}
+	return data, nil
+}
+
+// Put writes the object for p. Conditional writes are not supported widely
+// enough across S3-compatible stores to rely on, so it never returns
+// ErrChanged.
+func (s *S3) Put(ctx context.Context, p string, data []byte, prev *RemoteFile) (*RemoteFile, error) {
+	key := joinKey(s.config.Prefix, p)
+	hash := hashOf(data)
+
+	header := http.Header{
+		"Content-Type":      {"application/octet-stream"},
+		"X-Amz-Meta-Sha256": {hash},
+	}
+	resp, err := s.do(ctx, http.MethodPut, key, nil, header, data)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	return &RemoteFile{ID: key, Path: p, Revision: resp.Header.Get("ETag"), Hash: hash}, nil
+}
+
+// Delete deletes an object. S3 succeeds for missing objects too.
+func (s *S3) Delete(ctx context.Context, id string) error {
+	resp, err := s.do(ctx, http.MethodDelete, id, nil, nil, nil)
+	if isStatus(err, http.StatusNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Cursor returns "": S3 has no change token short of bucket notifications
+func (s *S3) Cursor(ctx context.Context) (string, error) {
+	return "", nil
+}
+
+// Location identifies the bucket and prefix
+func (s *S3) Location() string {
+	return fmt.Sprintf("s3:%s/%s/%s", s.config.Endpoint, s.config.Bucket, s.config.Prefix)
+}
+
+// objectURL returns the URL of key, or of the bucket when key is empty
+func (s *S3) objectURL(key string) (*url.URL, error) {
+	u, err := url.Parse(s.config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if s.config.PathStyle {
+		u.Path = "/" + s.config.Bucket + "/" + key
+	} else {
+		u.Host = s.config.Bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	// Send the path encoded exactly as it is signed
+	u.RawPath = uriEncode(u.Path, false)
+	return u, nil
+}
+
+// do sends a signed request and returns the response if it succeeded
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	s.sign(req, body)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach S3: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var e struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		xml.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&e)
+		return nil, &statusError{Status: resp.StatusCode, Body: strings.TrimSpace(e.Code + " " + e.Message)}
+	}
+	return resp, nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to req, signing
+// the host, content type, range and x-amz-* headers
+func (s *S3) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := hashOf(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		name := strings.ToLower(k)
+		if strings.HasPrefix(name, "x-amz-") || name == "content-type" || name == "range" {
+			headers[name] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hashOf([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretAccessKey), date)
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query sorted by key, as signing requires
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters, and "/"
+// unless encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
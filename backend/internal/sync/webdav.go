@@ -0,0 +1,295 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getetag/><d:getlastmodified/><d:getcontentlength/></d:prop></d:propfind>`
+
+// WebDAV keeps a workspace's files under a WebDAV collection, such as a
+// Nextcloud folder, laid out as they are in the workspace
+type WebDAV struct {
+	client   *http.Client
+	base     *url.URL // the collection, with a trailing slash
+	username string
+	password string
+}
+
+// NewWebDAV creates a backend syncing into the collection at rawURL, signing
+// in with basic auth when username is set
+func NewWebDAV(client *http.Client, rawURL, username, password string) (*WebDAV, error) {
+	if err := checkURL(rawURL); err != nil {
+		return nil, err
+	}
+	base, _ := url.Parse(rawURL)
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	base.RawPath = ""
+	return &WebDAV{client: client, base: base, username: username, password: password}, nil
+}
+
+// davResponse is one resource in a PROPFIND multistatus reply
+type davResponse struct {
+	Href     string `xml:"href"`
+	Propstat []struct {
+		Status string `xml:"status"`
+		Prop   struct {
+			ResourceType struct {
+				Collection *struct{} `xml:"collection"`
+			} `xml:"resourcetype"`
+			ETag          string `xml:"getetag"`
+			LastModified  string `xml:"getlastmodified"`
+			ContentLength string `xml:"getcontentlength"`
+		} `xml:"prop"`
+	} `xml:"propstat"`
+}
+
+// List lists the collection's files, one directory level per request since
+// servers often refuse infinite depth
+func (w *WebDAV) List(ctx context.Context) ([]RemoteFile, error) {
+	var files []RemoteFile
+	dirs := []string{""}
+	for len(dirs) > 0 {
+		dir := dirs[0]
+		dirs = dirs[1:]
+
+		responses, err := w.propfind(ctx, dir)
+		if err != nil {
+			// The collection itself may not exist yet
+			if dir == "" && isStatus(err, http.StatusNotFound) {
+				return nil, nil
+			}
+			return nil, err
+		}
+
+		for _, r := range responses {
+			p, ok := w.relative(r.Href)
+			if !ok || p == "" || strings.TrimSuffix(p, "/") == strings.TrimSuffix(dir, "/") {
+				continue
+			}
+			if strings.HasPrefix(path.Base(strings.TrimSuffix(p, "/")), ".") {
+				continue
+			}
+
+			for _, ps := range r.Propstat {
+				if !strings.Contains(ps.Status, " 200 ") {
+					continue
+				}
+				if ps.Prop.ResourceType.Collection != nil {
+					dirs = append(dirs, strings.TrimSuffix(p, "/")+"/")
+					break
+				}
+
+				revision := ps.Prop.ETag
+				if revision == "" {
+					revision = ps.Prop.LastModified + "-" + ps.Prop.ContentLength
+				}
+				files = append(files, RemoteFile{ID: p, Path: p, Revision: revision})
+				break
+			}
+		}
+	}
+	return files, nil
+}
+
+// Get reads a file's content
+func (w *WebDAV) Get(ctx context.Context, id string) ([]byte, error) {
+	resp, err := w.do(ctx, http.MethodGet, id, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", id, err)
+	}
+	return data, nil
+}
+
+// Put writes the file at p, creating collections as needed. Replacing prev
+// is conditional on its etag, so a concurrent write returns ErrChanged.
+func (w *WebDAV) Put(ctx context.Context, p string, data []byte, prev *RemoteFile) (*RemoteFile, error) {
+	header := http.Header{}
+	if prev != nil && isETag(prev.Revision) {
+		header.Set("If-Match", prev.Revision)
+	} else if prev == nil {
+		header.Set("If-None-Match", "*")
+	}
+
+	resp, err := w.do(ctx, http.MethodPut, p, header, data)
+	if isStatus(err, http.StatusConflict) || isStatus(err, http.StatusNotFound) {
+		// Missing parent collection
+		if err := w.mkcolAll(ctx, path.Dir(p)); err != nil {
+			return nil, err
+		}
+		resp, err = w.do(ctx, http.MethodPut, p, header, data)
+	}
+	if isStatus(err, http.StatusPreconditionFailed) {
+		return nil, ErrChanged
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	revision := resp.Header.Get("ETag")
+	if revision == "" || strings.HasPrefix(revision, "W/") {
+		// Not every server returns the new etag; ask for it
+		responses, err := w.propfind(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range responses {
+			for _, ps := range r.Propstat {
+				if ps.Prop.ETag != "" {
+					revision = ps.Prop.ETag
+				} else if revision == "" {
+					revision = ps.Prop.LastModified + "-" + ps.Prop.ContentLength
+				}
+			}
+		}
+	}
+	return &RemoteFile{ID: p, Path: p, Revision: revision, Hash: hashOf(data)}, nil
+}
+
+// Delete deletes a file
+func (w *WebDAV) Delete(ctx context.Context, id string) error {
+	resp, err := w.do(ctx, http.MethodDelete, id, nil, nil)
+	if isStatus(err, http.StatusNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Cursor returns "": collection etags only change with their children's on
+// some servers, so they cannot be trusted to cover the whole tree
+func (w *WebDAV) Cursor(ctx context.Context) (string, error) {
+	return "", nil
+}
+
+// Location identifies the collection
+func (w *WebDAV) Location() string {
+	return w.base.String()
+}
+
+// propfind lists a resource and, for a collection, its direct children
+func (w *WebDAV) propfind(ctx context.Context, p string) ([]davResponse, error) {
+	header := http.Header{"Depth": {"1"}, "Content-Type": {"application/xml; charset=utf-8"}}
+	resp, err := w.do(ctx, "PROPFIND", p, header, []byte(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var ms struct {
+		Responses []davResponse `xml:"response"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("failed to decode WebDAV listing: %w", err)
+	}
+	return ms.Responses, nil
+}
+
+// mkcolAll creates collection dir and any missing parents, up to and
+// including the synced collection itself
+func (w *WebDAV) mkcolAll(ctx context.Context, dir string) error {
+	collections := []string{""}
+	if dir != "." && dir != "" {
+		var prefix string
+		for _, part := range strings.Split(dir, "/") {
+			prefix += part + "/"
+			collections = append(collections, prefix)
+		}
+	}
+
+	for _, prefix := range collections {
+		resp, err := w.do(ctx, "MKCOL", prefix, nil, nil)
+		// 405 means it already exists
+		if isStatus(err, http.StatusMethodNotAllowed) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create collection %s: %w", prefix, err)
+		}
+		resp.Body.Close()
+	}
+	return nil
+}
+
+// relative turns an href from a listing into a path under the collection
+func (w *WebDAV) relative(href string) (string, bool) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	p, ok := strings.CutPrefix(u.Path, w.base.Path)
+	return p, ok
+}
+
+// statusError is an unexpected status from an HTTP backend
+type statusError struct {
+	Status int
+	Body   string
+}
+
+func (e *statusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("server returned %d", e.Status)
+	}
+	return fmt.Sprintf("server returned %d: %s", e.Status, e.Body)
+}
+
+func isStatus(err error, status int) bool {
+	se, ok := err.(*statusError)
+	return ok && se.Status == status
+}
+
+// do sends a request for the resource at p and returns the response if it
+// succeeded
+func (w *WebDAV) do(ctx context.Context, method, p string, header http.Header, body []byte) (*http.Response, error) {
+	u := *w.base
+	u.Path += p
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if w.username != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach WebDAV server: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, &statusError{Status: resp.StatusCode, Body: strings.TrimSpace(string(msg))}
+	}
+	return resp, nil
+}
+
+// isETag reports whether revision is a strong etag rather than a stand-in
+// made from the modification time
+func isETag(revision string) bool {
+	return strings.HasPrefix(revision, `"`)
+}
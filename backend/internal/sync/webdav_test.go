@@ -0,0 +1,78 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/webdav"
+)
+
+func newWebDAVServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	fs := webdav.NewMemFS()
+	dav := &webdav.Handler{Prefix: "/dav", FileSystem: fs, LockSystem: webdav.NewMemLS()}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "ada" || pass != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		// The handler ignores If-Match and If-None-Match, which servers such as
+		// Nextcloud and Apache honour on PUT
+		if r.Method == http.MethodPut {
+			etag := ""
+			if fi, err := fs.Stat(r.Context(), strings.TrimPrefix(r.URL.Path, "/dav")); err == nil {
+				etag = fmt.Sprintf(`"%x%x"`, fi.ModTime().UnixNano(), fi.Size())
+			}
+			match, noneMatch := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+			if (match != "" && match != etag) || (noneMatch == "*" && etag != "") {
+				http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+				return
+			}
+		}
+		dav.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWebDAV(t *testing.T) {
+	server := newWebDAVServer(t)
+
+	// The collection does not exist until the first file is written
+	dav, err := NewWebDAV(server.Client(), server.URL+"/dav/Notes Sync", "ada", "secret")
+	if err != nil {
+		t.Fatalf("NewWebDAV() failed: %v", err)
+	}
+	testBackend(t, dav)
+
+	ctx := context.Background()
+	files, _ := dav.List(ctx)
+	if len(files) != 1 {
+		t.Fatalf("List() = %+v, want 1 file", files)
+	}
+
+	// Another client writes the file; our stale version is refused
+	other, _ := NewWebDAV(server.Client(), server.URL+"/dav/Notes Sync/", "ada", "secret")
+	if _, err := other.Put(ctx, "notes/a.md", []byte("from elsewhere"), &files[0]); err != nil {
+		t.Fatalf("Put() from another client failed: %v", err)
+	}
+	if _, err := dav.Put(ctx, "notes/a.md", []byte("ours"), &files[0]); !errors.Is(err, ErrChanged) {
+		t.Errorf("Put() over a changed file = %v, want ErrChanged", err)
+	}
+	if other.Location() != dav.Location() {
+		t.Errorf("Location() = %q and %q for the same collection", other.Location(), dav.Location())
+	}
+
+	wrong, _ := NewWebDAV(server.Client(), server.URL+"/dav/Notes Sync", "ada", "wrong")
+	if _, err := wrong.List(ctx); err == nil {
+		t.Error("List() succeeded with a wrong password")
+	}
+}
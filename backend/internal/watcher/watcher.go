@@ -0,0 +1,242 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"fuknotion/backend/internal/filesystem"
+	"fuknotion/backend/internal/note"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultDebounce is how long a file must be quiet before it is re-parsed.
+// Editors often write a file in several steps (truncate, write, rename).
+const DefaultDebounce = 300 * time.Millisecond
+
+// Watcher picks up edits made to note files outside the app and applies
+// them to the index through note.Service
+type Watcher struct {
+	fs       *filesystem.FileSystem
+	notes    *note.Service
+	onChange func(*note.FileChange)
+	onError  func(path string, err error)
+	debounce time.Duration
+
+	fsw     *fsnotify.Watcher
+	mu      sync.Mutex
+	timers  map[string]*time.Timer
+	pending chan string
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// New creates a watcher for the workspace behind fs. onChange is called for
+// every change applied to the index; onError for files that failed to sync.
+// Either callback may be nil.
+func New(fs *filesystem.FileSystem, notes *note.Service, onChange func(*note.FileChange), onError func(string, error)) *Watcher {
+	return &Watcher{
+		fs:       fs,
+		notes:    notes,
+		onChange: onChange,
+		onError:  onError,
+		debounce: DefaultDebounce,
+		timers:   make(map[string]*time.Timer),
+		pending:  make(chan string, 64),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start begins watching the workspace directory tree
+func (w *Watcher) Start() error {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	w.fsw = fsw
+
+	if err := w.addTree(w.fs.BasePath()); err != nil {
+		fsw.Close()
+		return err
+	}
+
+	w.wg.Add(2)
+	go w.readEvents()
+	go w.processPending()
+
+	return nil
+}
+
+// Close stops the watcher and waits for in-flight syncs to finish
+func (w *Watcher) Close() error {
+	if w.fsw == nil {
+		return nil
+	}
+
+	close(w.done)
+	err := w.fsw.Close()
+
+	w.mu.Lock()
+	for path, timer := range w.timers {
+		timer.Stop()
+		delete(w.timers, path)
+	}
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	w.fsw = nil
+	return err
+}
+
+// addTree watches dir and every non-hidden directory below it
+func (w *Watcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != dir && isHidden(d.Name()) {
+			return filepath.SkipDir
+		}
+		if err := w.fsw.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// readEvents turns raw fsnotify events into debounced per-file syncs
+func (w *Watcher) readEvents() {
+	defer w.wg.Done()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			w.handleEvent(event)
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			w.reportError("", err)
+		}
+	}
+}
+
+func (w *Watcher) handleEvent(event fsnotify.Event) {
+	name := filepath.Base(event.Name)
+	if isHidden(name) {
+		return
+	}
+
+	// New directories need their own watch (fsnotify is not recursive)
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			if err := w.addTree(event.Name); err != nil {
+				w.reportError(event.Name, err)
+			}
+			// Files may be there already, e.g. when a folder is moved in
+			w.scheduleTree(event.Name)
+			return
+		}
+	}
+
+	if !strings.EqualFold(filepath.Ext(name), ".md") {
+		return
+	}
+
+	rel, err := filepath.Rel(w.fs.BasePath(), event.Name)
+	if err != nil {
+		return
+	}
+
+	w.schedule(rel)
+}
+
+// scheduleTree schedules every note file in dir and the non-hidden
+// directories below it
+func (w *Watcher) scheduleTree(dir string) {
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if isHidden(d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".md") {
+			return nil
+		}
+		if rel, err := filepath.Rel(w.fs.BasePath(), path); err == nil {
+			w.schedule(rel)
+		}
+		return nil
+	})
+}
+
+// schedule (re)starts the debounce timer for a file
+func (w *Watcher) schedule(rel string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if timer, ok := w.timers[rel]; ok {
+		timer.Reset(w.debounce)
+		return
+	}
+
+	w.timers[rel] = time.AfterFunc(w.debounce, func() {
+		w.mu.Lock()
+		delete(w.timers, rel)
+		w.mu.Unlock()
+
+		select {
+		case w.pending <- rel:
+		case <-w.done:
+		}
+	})
+}
+
+// processPending syncs files one at a time so index writes never race each other
+func (w *Watcher) processPending() {
+	defer w.wg.Done()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case rel := <-w.pending:
+			change, err := w.notes.SyncFile(rel)
+			if err != nil {
+				w.reportError(rel, err)
+				continue
+			}
+			if change != nil && w.onChange != nil {
+				w.onChange(change)
+			}
+		}
+	}
+}
+
+func (w *Watcher) reportError(path string, err error) {
+	if w.onError != nil {
+		w.onError(path, err)
+	}
+}
+
+// isHidden reports whether a file or directory should be ignored, such as
+// .git, .trash or an editor's temporary file
+func isHidden(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~")
+}
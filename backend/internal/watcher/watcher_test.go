@@ -0,0 +1,174 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fuknotion/backend/internal/database"
+	"fuknotion/backend/internal/filesystem"
+	"fuknotion/backend/internal/note"
+)
+
+func setupTestWatcher(t *testing.T) (*note.Service, string, chan *note.FileChange) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+
+	db, err := database.InitWorkspaceDB(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	fs, err := filesystem.NewFileSystem(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize filesystem: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, "notes"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	notes := note.NewService(db, fs)
+	changes := make(chan *note.FileChange, 10)
+
+	w := New(fs, notes, func(c *note.FileChange) { changes <- c }, func(path string, err error) {
+		t.Logf("sync error for %s: %v", path, err)
+	})
+	w.debounce = 50 * time.Millisecond
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	t.Cleanup(func() { w.Close() })
+
+	return notes, tmpDir, changes
+}
+
+func waitForChange(t *testing.T, changes chan *note.FileChange) *note.FileChange {
+	t.Helper()
+
+	select {
+	case change := <-changes:
+		return change
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for file change")
+		return nil
+	}
+}
+
+func TestWatcherAppliesExternalEdit(t *testing.T) {
+	notes, tmpDir, changes := setupTestWatcher(t)
+
+	n, err := notes.CreateNote("Original", "original body", "")
+	if err != nil {
+		t.Fatalf("CreateNote() failed: %v", err)
+	}
+
+	// Our own write must not be reported as an external change
+	select {
+	case change := <-changes:
+		t.Fatalf("unexpected change for own write: %+v", change)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	path := filepath.Join(tmpDir, n.FilePath)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := strings.Replace(string(data), "original body", "edited elsewhere", 1)
+	edited = strings.Replace(edited, "title: Original", "title: Edited", 1)
+	if err := os.WriteFile(path, []byte(edited), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	change := waitForChange(t, changes)
+	if change.NoteID != n.ID || change.Kind != note.FileModified {
+		t.Errorf("change = %+v, want modified %s", change, n.ID)
+	}
+
+	got, err := notes.GetNote(n.ID)
+	if err != nil {
+		t.Fatalf("GetNote() failed: %v", err)
+	}
+	if got.Title != "Edited" {
+		t.Errorf("Title = %v, want Edited", got.Title)
+	}
+
+	results, err := notes.SearchNotes("elsewhere")
+	if err != nil {
+		t.Fatalf("SearchNotes() failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("search found %d results, want 1", len(results))
+	}
+}
+
+func TestWatcherAppliesExternalDelete(t *testing.T) {
+	notes, tmpDir, changes := setupTestWatcher(t)
+
+	n, err := notes.CreateNote("Doomed", "body", "")
+	if err != nil {
+		t.Fatalf("CreateNote() failed: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(tmpDir, n.FilePath)); err != nil {
+		t.Fatal(err)
+	}
+
+	change := waitForChange(t, changes)
+	if change.NoteID != n.ID || change.Kind != note.FileDeleted {
+		t.Errorf("change = %+v, want deleted %s", change, n.ID)
+	}
+
+	if _, err := notes.GetNote(n.ID); err == nil {
+		t.Error("note still indexed after its file was deleted")
+	}
+}
+
+func TestWatcherKeepsHistoryOfMovedNote(t *testing.T) {
+	notes, tmpDir, changes := setupTestWatcher(t)
+
+	n, err := notes.CreateNote("Travels", "first draft", "")
+	if err != nil {
+		t.Fatalf("CreateNote() failed: %v", err)
+	}
+	if err := notes.UpdateNote(n.ID, "Travels", "second draft", false); err != nil {
+		t.Fatalf("UpdateNote() failed: %v", err)
+	}
+	before, _ := notes.Revisions().List(n.ID)
+
+	// Editors and synced folders see a move as a delete and a create
+	moved := filepath.Join("notes", "archive", "travels.md")
+	if err := os.MkdirAll(filepath.Join(tmpDir, "notes", "archive"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(tmpDir, n.FilePath), filepath.Join(tmpDir, moved)); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.After(3 * time.Second)
+	for {
+		if got, err := notes.GetNote(n.ID); err == nil && got.FilePath == moved {
+			break
+		}
+		select {
+		case <-changes:
+		case <-deadline:
+			t.Fatal("moved note was not picked up")
+		}
+	}
+
+	after, err := notes.Revisions().List(n.ID)
+	if err != nil || len(after) < len(before) || len(before) == 0 {
+		t.Errorf("revisions = %d, %v; want the %d from before the move", len(after), err, len(before))
+	}
+	if trash, _ := notes.ListTrash(); len(trash) != 0 {
+		t.Errorf("ListTrash() = %+v, want the moved note live", trash)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, ".trash", n.ID+".md")); !os.IsNotExist(err) {
+		t.Errorf("trashed copy left behind: %v", err)
+	}
+}
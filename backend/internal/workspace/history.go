@@ -0,0 +1,47 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+
+	"fuknotion/backend/internal/git"
+	"fuknotion/backend/internal/models"
+	"fuknotion/backend/internal/note"
+)
+
+// EnableHistory turns the active workspace's directory into a git
+// repository, or adopts the one already there, and starts committing changes
+// as they are saved. who authors the commits if git has no identity set.
+func (m *Manager) EnableHistory(ctx context.Context, who git.Identity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.active == nil {
+		return fmt.Errorf("no workspace is open")
+	}
+	if m.active.History != nil {
+		return nil
+	}
+
+	repo, err := git.Init(ctx, m.active.Workspace.Path, who)
+	if err != nil {
+		return fmt.Errorf("failed to set up git repository: %w", err)
+	}
+	history := newHistory(m.active.Workspace, repo, m.active.Notes)
+
+	// Start the history with the workspace as it is now
+	if _, err := history.Commit(ctx); err != nil {
+		history.Close()
+		return fmt.Errorf("failed to commit workspace: %w", err)
+	}
+	m.active.History = history
+	return nil
+}
+
+func newHistory(ws *models.Workspace, repo *git.Repo, notes *note.Service) *git.History {
+	history := git.NewHistory(repo, notes, func(err error) {
+		fmt.Printf("Failed to commit changes in workspace %s: %v\n", ws.ID, err)
+	})
+	history.Start()
+	return history
+}
@@ -0,0 +1,52 @@
+package workspace
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+
+	"fuknotion/backend/internal/git"
+)
+
+func TestEnableHistory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	manager, _ := setupTestManager(t)
+	ws, err := manager.Open("")
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+
+	session, release, _ := manager.Acquire()
+	session.Notes.CreateNote("Plan", "Draft", "")
+	release()
+
+	if err := manager.EnableHistory(context.Background(), git.Identity{Name: "Ada"}); err != nil {
+		t.Fatalf("EnableHistory() failed: %v", err)
+	}
+	if !git.IsRepo(ws.Path) {
+		t.Fatal("workspace is not a git repository")
+	}
+
+	session, release, _ = manager.Acquire()
+	commits, err := session.History.Repo().Log(context.Background(), "", 10)
+	release()
+	if err != nil || len(commits) != 1 {
+		t.Fatalf("Log() = %+v, %v; want the initial commit", commits, err)
+	}
+
+	// Reopening the workspace picks the history up again
+	if _, err := manager.SwitchWorkspace(ws.ID); err != nil {
+		t.Fatalf("SwitchWorkspace() failed: %v", err)
+	}
+	session, release, _ = manager.Acquire()
+	defer release()
+	if session.History == nil {
+		t.Error("History is nil after reopening the workspace")
+	}
+}
@@ -0,0 +1,491 @@
+package workspace
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"fuknotion/backend/internal/attachment"
+	"fuknotion/backend/internal/database"
+	"fuknotion/backend/internal/export"
+	"fuknotion/backend/internal/filesystem"
+	"fuknotion/backend/internal/folder"
+	"fuknotion/backend/internal/git"
+	"fuknotion/backend/internal/importer"
+	"fuknotion/backend/internal/models"
+	"fuknotion/backend/internal/note"
+	wssync "fuknotion/backend/internal/sync"
+	"fuknotion/backend/internal/watcher"
+
+	"github.com/google/uuid"
+)
+
+// DefaultID is the ID of the workspace created on first launch
+const DefaultID = "default"
+
+// Session holds the open database and services of the active workspace
+type Session struct {
+	Workspace   *models.Workspace
+	DB          *database.Database
+	FS          *filesystem.FileSystem
+	Notes       *note.Service
+	Folders     *folder.Service
+	Attachments *attachment.Store
+	Export      *export.Service
+	Importer    *importer.Service
+	Sync        *wssync.Engine
+	History     *git.History // nil unless the workspace is kept in git
+	Watcher     *watcher.Watcher
+
+	refs    int           // borrowers, guarded by Manager.mu
+	retired bool          // no longer active; closed once the last borrower is done
+	closed  chan struct{} // closed once the session is
+}
+
+// Close releases the workspace's resources
+func (s *Session) Close() error {
+	if s.Watcher != nil {
+		if err := s.Watcher.Close(); err != nil {
+			fmt.Printf("Failed to stop file watcher: %v\n", err)
+		}
+	}
+	if s.History != nil {
+		if err := s.History.Close(); err != nil {
+			fmt.Printf("Failed to commit workspace history: %v\n", err)
+		}
+	}
+	return s.DB.Close()
+}
+
+// Manager tracks workspaces in user.db and owns the active workspace session.
+// Callers use Acquire to borrow the active session. Switching does not wait
+// for borrowers, which may be in the middle of a slow sync: the session they
+// hold is closed once the last of them releases it.
+type Manager struct {
+	mu        sync.Mutex // guards active, draining and session refs; never held for long
+	switching sync.Mutex // one open, close or delete of a workspace at a time
+	basePath  string
+	userDB    *database.Database
+	active    *Session
+	draining  []*Session // replaced sessions still borrowed
+
+	onExternalChange func(*note.FileChange)
+	onExternalError  func(string, error)
+}
+
+// NewManager creates a workspace manager rooted at the app data directory
+func NewManager(basePath string, userDB *database.Database) *Manager {
+	return &Manager{basePath: basePath, userDB: userDB}
+}
+
+// OnExternalChange registers callbacks for note files changed outside the
+// app while a workspace is open. It must be called before Open.
+func (m *Manager) OnExternalChange(onChange func(*note.FileChange), onError func(path string, err error)) {
+	m.onExternalChange = onChange
+	m.onExternalError = onError
+}
+
+// Open opens the workspace with the given ID, falling back to the default
+// workspace (created if needed) when the ID is empty or unknown
+func (m *Manager) Open(id string) (*models.Workspace, error) {
+	if err := m.ensureDefault(); err != nil {
+		return nil, err
+	}
+
+	ws, err := m.GetWorkspace(id)
+	if err != nil {
+		if ws, err = m.GetWorkspace(DefaultID); err != nil {
+			return nil, err
+		}
+	}
+
+	return m.SwitchWorkspace(ws.ID)
+}
+
+// Acquire returns the active session and a release function that must be
+// called once the caller is done with it. The session stays open until then,
+// even if another workspace is opened meanwhile.
+func (m *Manager) Acquire() (*Session, func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.active == nil {
+		return nil, nil, fmt.Errorf("no workspace is open")
+	}
+	session := m.active
+	session.refs++
+
+	var once sync.Once
+	return session, func() { once.Do(func() { m.release(session) }) }, nil
+}
+
+// Active returns the active workspace, or nil if none is open
+func (m *Manager) Active() *models.Workspace {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.active == nil {
+		return nil
+	}
+	ws := *m.active.Workspace
+	return &ws
+}
+
+// CreateWorkspace creates a new, empty workspace under workspaces/{id}
+func (m *Manager) CreateWorkspace(name string) (*models.Workspace, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("workspace name is required")
+	}
+
+	return m.insertWorkspace(uuid.New().String(), name)
+}
+
+// GetWorkspace retrieves a workspace by ID
+func (m *Manager) GetWorkspace(id string) (*models.Workspace, error) {
+	query := `SELECT id, name, path, created_at, updated_at FROM workspaces WHERE id = ?`
+
+	var ws models.Workspace
+	err := m.userDB.QueryRow(query, id).Scan(&ws.ID, &ws.Name, &ws.Path, &ws.CreatedAt, &ws.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("workspace not found: %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workspace: %w", err)
+	}
+
+	return &ws, nil
+}
+
+// ListWorkspaces lists all workspaces ordered by name
+func (m *Manager) ListWorkspaces() ([]*models.Workspace, error) {
+	query := `SELECT id, name, path, created_at, updated_at FROM workspaces ORDER BY name COLLATE NOCASE`
+
+	rows, err := m.userDB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspaces: %w", err)
+	}
+	defer rows.Close()
+
+	var workspaces []*models.Workspace
+	for rows.Next() {
+		var ws models.Workspace
+		if err := rows.Scan(&ws.ID, &ws.Name, &ws.Path, &ws.CreatedAt, &ws.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan workspace: %w", err)
+		}
+		workspaces = append(workspaces, &ws)
+	}
+
+	return workspaces, rows.Err()
+}
+
+// RenameWorkspace changes a workspace's display name
+func (m *Manager) RenameWorkspace(id, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("workspace name is required")
+	}
+
+	now := time.Now()
+	result, err := m.userDB.Exec(`UPDATE workspaces SET name = ?, updated_at = ? WHERE id = ?`, name, now, id)
+	if err != nil {
+		return fmt.Errorf("failed to rename workspace: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("workspace not found: %s", id)
+	}
+
+	m.mu.Lock()
+	if m.active != nil && m.active.Workspace.ID == id {
+		m.active.Workspace.Name = name
+		m.active.Workspace.UpdatedAt = now
+	}
+	m.mu.Unlock()
+
+	return nil
+}
+
+// SwitchWorkspace opens the given workspace and makes it active. The new
+// workspace is opened before the old one is closed, so a failure leaves the
+// current workspace untouched.
+func (m *Manager) SwitchWorkspace(id string) (*models.Workspace, error) {
+	m.switching.Lock()
+	defer m.switching.Unlock()
+
+	ws, err := m.GetWorkspace(id)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if m.active != nil && m.active.Workspace.ID == id {
+		current := *m.active.Workspace
+		m.mu.Unlock()
+		return &current, nil
+	}
+	m.mu.Unlock()
+
+	// Opening can take a while; borrowers keep using the old session meanwhile
+	session, err := m.openSession(ws)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	old := m.active
+	m.active = session
+	closeNow := old != nil && m.retire(old)
+	m.mu.Unlock()
+
+	if closeNow {
+		m.closeSession(old)
+	}
+
+	result := *ws
+	return &result, nil
+}
+
+// DeleteWorkspace removes a workspace and its directory. The active workspace
+// cannot be deleted.
+func (m *Manager) DeleteWorkspace(id string) error {
+	ws, err := m.GetWorkspace(id)
+	if err != nil {
+		return err
+	}
+
+	// Held throughout, so the workspace cannot be opened while it is deleted
+	m.switching.Lock()
+	defer m.switching.Unlock()
+
+	m.mu.Lock()
+	isActive := m.active != nil && m.active.Workspace.ID == id
+	inUse := false
+	for _, s := range m.draining {
+		inUse = inUse || s.Workspace.ID == id
+	}
+	m.mu.Unlock()
+	if isActive {
+		return fmt.Errorf("cannot delete the active workspace")
+	}
+	if inUse {
+		return fmt.Errorf("workspace is still in use, try again shortly")
+	}
+
+	// Only remove directories we created ourselves
+	root := filepath.Join(m.basePath, "workspaces") + string(filepath.Separator)
+	if !strings.HasPrefix(filepath.Clean(ws.Path)+string(filepath.Separator), root) {
+		return fmt.Errorf("refusing to delete workspace outside %s", root)
+	}
+
+	if _, err := m.userDB.Exec(`DELETE FROM workspaces WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete workspace: %w", err)
+	}
+
+	if err := os.RemoveAll(ws.Path); err != nil {
+		return fmt.Errorf("failed to remove workspace directory: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the active workspace, waiting for its borrowers to release it
+func (m *Manager) Close() error {
+	m.switching.Lock()
+	defer m.switching.Unlock()
+
+	m.mu.Lock()
+	session := m.active
+	m.active = nil
+	closeNow := session != nil && m.retire(session)
+	m.mu.Unlock()
+
+	if session == nil {
+		return nil
+	}
+	if !closeNow {
+		<-session.closed
+		return nil
+	}
+	return m.closeSession(session)
+}
+
+// retire takes a session out of use. It reports whether nobody borrows it,
+// so the caller should close it now; otherwise the last release closes it.
+// Callers hold m.mu.
+func (m *Manager) retire(s *Session) bool {
+	s.retired = true
+	if s.refs > 0 {
+		m.draining = append(m.draining, s)
+		return false
+	}
+	return true
+}
+
+// release returns a borrowed session, closing it if it was retired and this
+// was the last borrower
+func (m *Manager) release(s *Session) {
+	m.mu.Lock()
+	s.refs--
+	last := s.retired && s.refs == 0
+	if last {
+		for i, d := range m.draining {
+			if d == s {
+				m.draining = append(m.draining[:i], m.draining[i+1:]...)
+				break
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	if last {
+		m.closeSession(s)
+	}
+}
+
+// closeSession closes a retired session nobody borrows any more
+func (m *Manager) closeSession(s *Session) error {
+	defer close(s.closed)
+
+	err := s.Close()
+	if err != nil {
+		fmt.Printf("Failed to close workspace %s: %v\n", s.Workspace.ID, err)
+	}
+	return err
+}
+
+// ensureDefault records the default workspace on first launch and moves notes
+// written by older builds (directly under the app data directory) into it.
+// The move comes first and is retried on every launch while those notes are
+// still there, so a failed move is not forgotten once the row exists.
+func (m *Manager) ensureDefault() error {
+	legacyNotes := filepath.Join(m.basePath, "notes")
+	if _, err := os.Stat(legacyNotes); err == nil {
+		defaultPath := filepath.Join(m.basePath, "workspaces", DefaultID)
+		workspaceNotes := filepath.Join(defaultPath, "notes")
+		if _, err := os.Stat(workspaceNotes); os.IsNotExist(err) {
+			if err := os.MkdirAll(defaultPath, 0700); err != nil {
+				return fmt.Errorf("failed to create workspace directory: %w", err)
+			}
+			if err := os.Rename(legacyNotes, workspaceNotes); err != nil {
+				return fmt.Errorf("failed to move legacy notes into default workspace: %w", err)
+			}
+		}
+	}
+
+	if _, err := m.GetWorkspace(DefaultID); err == nil {
+		return nil
+	}
+	_, err := m.insertWorkspace(DefaultID, "Personal")
+	return err
+}
+
+// insertWorkspace records a workspace and creates its directory
+func (m *Manager) insertWorkspace(id, name string) (*models.Workspace, error) {
+	now := time.Now()
+	ws := &models.Workspace{
+		ID:        id,
+		Name:      name,
+		Path:      filepath.Join(m.basePath, "workspaces", id),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := os.MkdirAll(ws.Path, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create workspace directory: %w", err)
+	}
+
+	query := `INSERT INTO workspaces (id, name, path, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`
+	if _, err := m.userDB.Exec(query, ws.ID, ws.Name, ws.Path, ws.CreatedAt, ws.UpdatedAt); err != nil {
+		return nil, fmt.Errorf("failed to insert workspace: %w", err)
+	}
+
+	return ws, nil
+}
+
+// openSession opens a workspace's database and wires up its services
+func (m *Manager) openSession(ws *models.Workspace) (*Session, error) {
+	fs, err := filesystem.NewFileSystem(ws.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := database.InitWorkspaceDB(ws.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	notes := note.NewService(db, fs)
+
+	// Markdown files are the source of truth; rebuild the index if it drifted
+	// (e.g. workspace.db was deleted or files were edited while we were closed)
+	if drift, err := notes.NeedsReindex(); err != nil {
+		fmt.Printf("Failed to check index for workspace %s: %v\n", ws.ID, err)
+	} else if drift {
+		report, err := notes.Reindex()
+		if err != nil {
+			fmt.Printf("Failed to reindex workspace %s: %v\n", ws.ID, err)
+		} else {
+			fmt.Printf("Reindexed workspace %s: %d indexed, %d trashed, %d removed, %d failed\n",
+				ws.ID, report.Indexed, report.Trashed, report.Removed, len(report.Failed))
+		}
+	}
+
+	// The search index can also be emptied by a migration or damaged on its own
+	if check, err := notes.CheckSearchIndex(); err != nil {
+		fmt.Printf("Failed to check search index for workspace %s: %v\n", ws.ID, err)
+	} else if !check.OK() {
+		report, err := notes.RebuildSearchIndex()
+		if err != nil {
+			fmt.Printf("Failed to rebuild search index for workspace %s: %v\n", ws.ID, err)
+		} else {
+			fmt.Printf("Rebuilt search index for workspace %s: %d indexed, %d failed\n",
+				ws.ID, report.Indexed, len(report.Failed))
+		}
+	}
+
+	// Drop attachments whose notes were edited or purged while we were closed
+	attachments := attachment.NewStore(db, fs)
+	if report, err := attachments.CollectGarbage(); err != nil {
+		fmt.Printf("Failed to collect attachments for workspace %s: %v\n", ws.ID, err)
+	} else if report.Removed > 0 {
+		fmt.Printf("Collected %d unused attachments in workspace %s, freeing %d bytes\n",
+			report.Removed, ws.ID, report.FreedBytes)
+	}
+
+	folders := folder.NewService(db, notes)
+	session := &Session{
+		Workspace:   ws,
+		DB:          db,
+		FS:          fs,
+		Notes:       notes,
+		Folders:     folders,
+		Attachments: attachments,
+		Export:      export.NewService(notes, folders),
+		Importer:    importer.NewService(notes, folders),
+		Sync:        wssync.NewEngine(db, fs, notes, attachments),
+		closed:      make(chan struct{}),
+	}
+
+	// Workspaces kept in git commit their changes as they go
+	if git.IsRepo(ws.Path) {
+		if repo, err := git.Open(ws.Path); err != nil {
+			fmt.Printf("Failed to open git repository of workspace %s: %v\n", ws.ID, err)
+		} else {
+			session.History = newHistory(ws, repo, notes)
+		}
+	}
+
+	// Pick up edits made in other editors; the app still works without it
+	w := watcher.New(fs, notes, m.onExternalChange, m.onExternalError)
+	if err := w.Start(); err != nil {
+		fmt.Printf("Failed to watch workspace %s: %v\n", ws.ID, err)
+	} else {
+		session.Watcher = w
+	}
+
+	return session, nil
+}
@@ -0,0 +1,235 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fuknotion/backend/internal/database"
+)
+
+func setupTestManager(t *testing.T) (*Manager, string) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+
+	userDB, err := database.InitUserDB(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to initialize user database: %v", err)
+	}
+
+	manager := NewManager(tmpDir, userDB)
+	t.Cleanup(func() {
+		manager.Close()
+		userDB.Close()
+	})
+
+	return manager, tmpDir
+}
+
+func TestOpenCreatesDefault(t *testing.T) {
+	manager, tmpDir := setupTestManager(t)
+
+	ws, err := manager.Open("")
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	if ws.ID != DefaultID {
+		t.Errorf("ID = %v, want %v", ws.ID, DefaultID)
+	}
+
+	dbPath := filepath.Join(tmpDir, "workspaces", DefaultID, "workspace.db")
+	if _, err := os.Stat(dbPath); err != nil {
+		t.Errorf("workspace.db not created: %v", err)
+	}
+
+	// Unknown IDs fall back to the default workspace
+	ws, err = manager.Open("missing")
+	if err != nil {
+		t.Fatalf("Open() with unknown ID failed: %v", err)
+	}
+	if ws.ID != DefaultID {
+		t.Errorf("ID = %v, want %v", ws.ID, DefaultID)
+	}
+}
+
+func TestOpenMovesLegacyNotes(t *testing.T) {
+	manager, tmpDir := setupTestManager(t)
+
+	legacy := filepath.Join(tmpDir, "notes", "old.md")
+	if err := os.MkdirAll(filepath.Dir(legacy), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(legacy, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := manager.Open(""); err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+
+	moved := filepath.Join(tmpDir, "workspaces", DefaultID, "notes", "old.md")
+	if _, err := os.Stat(moved); err != nil {
+		t.Errorf("legacy note not moved into default workspace: %v", err)
+	}
+}
+
+func TestOpenRetriesLegacyNotesMove(t *testing.T) {
+	manager, tmpDir := setupTestManager(t)
+
+	// An earlier launch recorded the default workspace but failed to move
+	if _, err := manager.insertWorkspace(DefaultID, "Personal"); err != nil {
+		t.Fatalf("insertWorkspace() failed: %v", err)
+	}
+	legacy := filepath.Join(tmpDir, "notes", "old.md")
+	if err := os.MkdirAll(filepath.Dir(legacy), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(legacy, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := manager.Open(""); err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+
+	moved := filepath.Join(tmpDir, "workspaces", DefaultID, "notes", "old.md")
+	if _, err := os.Stat(moved); err != nil {
+		t.Errorf("legacy note not moved into default workspace: %v", err)
+	}
+}
+
+func TestSwitchWorkspaceIsolatesNotes(t *testing.T) {
+	manager, _ := setupTestManager(t)
+
+	if _, err := manager.Open(""); err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+
+	session, release, err := manager.Acquire()
+	if err != nil {
+		t.Fatalf("Acquire() failed: %v", err)
+	}
+	if _, err := session.Notes.CreateNote("Default note", "content", ""); err != nil {
+		t.Fatalf("CreateNote() failed: %v", err)
+	}
+	release()
+
+	work, err := manager.CreateWorkspace("Work")
+	if err != nil {
+		t.Fatalf("CreateWorkspace() failed: %v", err)
+	}
+	if _, err := manager.SwitchWorkspace(work.ID); err != nil {
+		t.Fatalf("SwitchWorkspace() failed: %v", err)
+	}
+
+	session, release, err = manager.Acquire()
+	if err != nil {
+		t.Fatalf("Acquire() failed: %v", err)
+	}
+	notes, err := session.Notes.ListNotes("")
+	release()
+	if err != nil {
+		t.Fatalf("ListNotes() failed: %v", err)
+	}
+	if len(notes) != 0 {
+		t.Errorf("new workspace has %d notes, want 0", len(notes))
+	}
+
+	if active := manager.Active(); active == nil || active.ID != work.ID {
+		t.Errorf("Active() = %v, want %v", active, work.ID)
+	}
+}
+
+func TestRenameAndDeleteWorkspace(t *testing.T) {
+	manager, _ := setupTestManager(t)
+
+	if _, err := manager.Open(""); err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+
+	ws, err := manager.CreateWorkspace("Scratch")
+	if err != nil {
+		t.Fatalf("CreateWorkspace() failed: %v", err)
+	}
+
+	if err := manager.RenameWorkspace(ws.ID, "Sandbox"); err != nil {
+		t.Fatalf("RenameWorkspace() failed: %v", err)
+	}
+	got, _ := manager.GetWorkspace(ws.ID)
+	if got.Name != "Sandbox" {
+		t.Errorf("Name = %v, want Sandbox", got.Name)
+	}
+
+	if err := manager.DeleteWorkspace(DefaultID); err == nil {
+		t.Error("Expected error deleting active workspace, got nil")
+	}
+
+	if err := manager.DeleteWorkspace(ws.ID); err != nil {
+		t.Fatalf("DeleteWorkspace() failed: %v", err)
+	}
+	if _, err := os.Stat(ws.Path); !os.IsNotExist(err) {
+		t.Error("workspace directory still exists after deletion")
+	}
+
+	list, _ := manager.ListWorkspaces()
+	if len(list) != 1 {
+		t.Errorf("ListWorkspaces() returned %d workspaces, want 1", len(list))
+	}
+}
+
+func TestSwitchWorkspaceDoesNotWaitForBorrowers(t *testing.T) {
+	manager, _ := setupTestManager(t)
+
+	if _, err := manager.Open(""); err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	work, _ := manager.CreateWorkspace("Work")
+
+	// A borrower busy with a slow sync holds the old session
+	session, release, err := manager.Acquire()
+	if err != nil {
+		t.Fatalf("Acquire() failed: %v", err)
+	}
+
+	switched := make(chan error, 1)
+	go func() {
+		_, err := manager.SwitchWorkspace(work.ID)
+		switched <- err
+	}()
+	select {
+	case err := <-switched:
+		if err != nil {
+			t.Fatalf("SwitchWorkspace() failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SwitchWorkspace() waited for the borrowed session")
+	}
+
+	// Other callers get the new session meanwhile
+	current, releaseCurrent, err := manager.Acquire()
+	if err != nil || current.Workspace.ID != work.ID {
+		t.Fatalf("Acquire() = %v, %v; want the new workspace", current, err)
+	}
+	releaseCurrent()
+
+	// The old session still works, and cannot be deleted, until released
+	if _, err := session.Notes.CreateNote("Late", "still open", ""); err != nil {
+		t.Errorf("CreateNote() on the borrowed session failed: %v", err)
+	}
+	if err := manager.DeleteWorkspace(DefaultID); err == nil {
+		t.Error("DeleteWorkspace() of a borrowed workspace succeeded")
+	}
+
+	release()
+	release() // releasing twice is harmless
+	select {
+	case <-session.closed:
+	default:
+		t.Error("old session not closed after its last release")
+	}
+	if err := manager.DeleteWorkspace(DefaultID); err != nil {
+		t.Errorf("DeleteWorkspace() after release failed: %v", err)
+	}
+}
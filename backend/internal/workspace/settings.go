@@ -0,0 +1,53 @@
+package workspace
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	wssync "fuknotion/backend/internal/sync"
+)
+
+// SyncSettings returns where a workspace syncs to. A workspace that was never
+// configured gets zero settings, which mean Google Drive.
+func (m *Manager) SyncSettings(id string) (*wssync.Settings, error) {
+	var raw string
+	err := m.userDB.QueryRow(`SELECT sync_settings FROM workspaces WHERE id = ?`, id).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("workspace not found: %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sync settings: %w", err)
+	}
+
+	var settings wssync.Settings
+	if raw == "" {
+		return &settings, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &settings); err != nil {
+		return nil, fmt.Errorf("failed to decode sync settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// SetSyncSettings changes where a workspace syncs to
+func (m *Manager) SetSyncSettings(id string, settings *wssync.Settings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to encode sync settings: %w", err)
+	}
+
+	result, err := m.userDB.Exec(`UPDATE workspaces SET sync_settings = ?, updated_at = ? WHERE id = ?`,
+		string(raw), time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to save sync settings: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("workspace not found: %s", id)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package workspace
+
+import (
+	"testing"
+
+	wssync "fuknotion/backend/internal/sync"
+)
+
+func TestSyncSettings(t *testing.T) {
+	manager, _ := setupTestManager(t)
+
+	ws, err := manager.Open("")
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+
+	settings, err := manager.SyncSettings(ws.ID)
+	if err != nil {
+		t.Fatalf("SyncSettings() failed: %v", err)
+	}
+	if settings.Backend != "" {
+		t.Errorf("Backend = %q, want Drive by default", settings.Backend)
+	}
+
+	webdav := &wssync.Settings{Backend: wssync.KindWebDAV, URL: "https://cloud.example.com/remote.php/dav/files/ada/Notes"}
+	if err := manager.SetSyncSettings(ws.ID, webdav); err != nil {
+		t.Fatalf("SetSyncSettings() failed: %v", err)
+	}
+	if settings, _ = manager.SyncSettings(ws.ID); *settings != *webdav {
+		t.Errorf("SyncSettings() = %+v, want %+v", settings, webdav)
+	}
+
+	invalid := []*wssync.Settings{
+		{Backend: wssync.KindWebDAV, URL: "ftp://example.com"},
+		{Backend: wssync.KindS3, Endpoint: "https://s3.example.com"},
+		{Backend: wssync.KindFolder, Folder: "relative/path"},
+		{Backend: "dropbox"},
+	}
+	for _, s := range invalid {
+		if err := manager.SetSyncSettings(ws.ID, s); err == nil {
+			t.Errorf("SetSyncSettings(%+v) succeeded, want error", s)
+		}
+	}
+	if settings, _ = manager.SyncSettings(ws.ID); *settings != *webdav {
+		t.Errorf("SyncSettings() = %+v after invalid settings, want them unchanged", settings)
+	}
+
+	if err := manager.SetSyncSettings("missing", webdav); err == nil {
+		t.Error("SetSyncSettings() on an unknown workspace succeeded")
+	}
+}
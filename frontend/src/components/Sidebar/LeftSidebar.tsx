@@ -21,7 +21,7 @@ export function LeftSidebar() {
 
   const loadNotes = async () => {
     try {
-      const notes = await ListNotes();
+      const notes = await ListNotes('');
       setAllNotes(notes || []);
     } catch (error) {
       console.error('Failed to load notes:', error);
@@ -46,10 +46,11 @@ export async function getNote(id: string): Promise<Note> {
 export async function updateNote(
   id: string,
   title: string,
-  content: string
+  content: string,
+  rewriteLinks: boolean = false
 ): Promise<void> {
   // This will be replaced by: import { UpdateNote } from '../wailsjs/go/app/App'
-  console.log('updateNote called (mock):', { id, title, content: content.substring(0, 50) + '...' });
+  console.log('updateNote called (mock):', { id, title, content: content.substring(0, 50) + '...', rewriteLinks });
 }
 
 export async function deleteNote(id: string): Promise<void> {
@@ -57,8 +58,8 @@ export async function deleteNote(id: string): Promise<void> {
   console.log('deleteNote called (mock):', id);
 }
 
-export async function listNotes(): Promise<Note[]> {
+export async function listNotes(tag: string = ''): Promise<Note[]> {
   // This will be replaced by: import { ListNotes } from '../wailsjs/go/app/App'
-  console.log('listNotes called (mock)');
+  console.log('listNotes called (mock):', tag);
   return [];
 }
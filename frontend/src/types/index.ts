@@ -14,9 +14,13 @@ export interface Note {
   folderId?: string;
   filePath: string;
   isFavorite: boolean;
+  pinned?: number; // order among pinned notes from 1
+  position?: number; // manual order within the folder
+  tags?: string[];
   content: string;
   createdAt: string;
   updatedAt: string;
+  deletedAt?: string; // set while the note is in the trash
 }
 
 export type Theme = 'light' | 'dark' | 'system';
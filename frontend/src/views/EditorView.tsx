@@ -19,7 +19,7 @@ export function EditorView() {
       const fullContent = frontmatter ? addFrontmatter(frontmatter, content) : content;
 
       // Update note in backend
-      await updateNote(currentNote.id, currentNote.title, fullContent);
+      await updateNote(currentNote.id, currentNote.title, fullContent, false);
 
       // Update local state
       setNote({
@@ -38,8 +38,8 @@ export function EditorView() {
     if (!currentNote) return;
 
     try {
-      // Update note with new title
-      await updateNote(currentNote.id, newTitle, currentNote.content);
+      // Update note with new title, fixing [[links]] to the old one
+      await updateNote(currentNote.id, newTitle, currentNote.content, true);
 
       // Update local state
       setNote({
@@ -34,6 +34,8 @@ func main() {
 		Height: 800,
 		AssetServer: &assetserver.Options{
 			Assets: assets,
+			// Requests the embedded assets cannot answer, i.e. attachments
+			Handler: app.AttachmentHandler(myApp),
 		},
 		BackgroundColour: &options.RGBA{R: 255, G: 255, B: 255, A: 255},
 		OnStartup:        myApp.Startup,